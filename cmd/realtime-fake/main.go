@@ -0,0 +1,40 @@
+// realtime-fake 启动本地模拟的 GPT Realtime 上游，便于在没有 Azure 的环境下开发
+//
+// 用法：
+//
+//	go run ./cmd/realtime-fake -addr :8090 -scenario internal/realtimefake/testdata/greeting.yaml
+//
+// 然后将 AZURE_OPENAI_REALTIME_ENDPOINT 设置为 ws://localhost:8090
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"smart-glasses-backend/internal/realtimefake"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "listen address")
+	scenarioPath := flag.String("scenario", "", "scenario file (YAML or JSON); empty replies to response.create with a default text")
+	apiKey := flag.String("api-key", "", "require this api-key header when set")
+	flag.Parse()
+
+	var scenario *realtimefake.Scenario
+	if *scenarioPath != "" {
+		var err error
+		scenario, err = realtimefake.LoadScenario(*scenarioPath)
+		if err != nil {
+			log.Fatalf("Failed to load scenario: %v", err)
+		}
+		log.Printf("Loaded scenario %q with %d steps", scenario.Name, len(scenario.Steps))
+	}
+
+	server := realtimefake.NewServer(scenario)
+	server.APIKey = *apiKey
+
+	log.Printf("Fake realtime upstream listening on %s", *addr)
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+}
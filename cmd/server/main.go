@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/base64"
 	"log"
 	"smart-glasses-backend/internal/config"
 	"smart-glasses-backend/internal/handler"
@@ -9,6 +10,7 @@ import (
 	"smart-glasses-backend/internal/service"
 	"smart-glasses-backend/pkg/azure"
 	"smart-glasses-backend/pkg/database"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -37,6 +39,10 @@ func main() {
 	userRepo := repository.NewUserRepository(db)
 	translateRepo := repository.NewTranslateRepository(db)
 	tokenRepo := repository.NewTokenRepository(db)
+	feedbackRepo := repository.NewFeedbackRepository(db)
+	memoryRepo := repository.NewTranslationMemoryRepository(db)
+	realtimeRepo := repository.NewRealtimeRepository(db)
+	recordingRepo := repository.NewRecordingRepository(db)
 
 	// Initialize Azure OpenAI client
 	azureClient := azure.NewOpenAIClient(
@@ -49,8 +55,17 @@ func main() {
 	// Initialize services
 	authService := service.NewAuthService(userRepo, redisClient, cfg.JWT.SecretKey, cfg.JWT.AccessTokenExpiry, cfg.JWT.RefreshTokenExpiry)
 	userService := service.NewUserService(userRepo)
-	translateService := service.NewTranslateService(azureClient, translateRepo, tokenRepo)
+	languageRegistry := service.NewLanguageRegistry()
+	translateService := service.NewTranslateService(azureClient, translateRepo, tokenRepo, memoryRepo, languageRegistry)
+	feedbackService := service.NewFeedbackService(translateRepo, feedbackRepo, memoryRepo)
 	statisticsService := service.NewStatisticsService(translateRepo, tokenRepo)
+
+	// Normalize language values stored before the registry existed
+	go func() {
+		if err := translateService.NormalizeStoredLanguages(); err != nil {
+			log.Printf("Failed to normalize stored languages: %v", err)
+		}
+	}()
 	
 	// Initialize Realtime service with proper configuration
 	// Requirements: 2.1 - 集成 Realtime 相关服务
@@ -60,6 +75,26 @@ func main() {
 		cfg.Realtime.DeploymentName,
 		cfg.Realtime.APIVersion,
 	)
+	// Interpreter turns are recorded as translations
+	realtimeService.SetTranslateService(translateService)
+	realtimeService.SetRealtimeRepository(realtimeRepo)
+	realtimeService.SetToolRegistry(service.NewRealtimeToolRegistry(translateService))
+	realtimeService.SetTranscodeG711(cfg.Realtime.TranscodeG711)
+	realtimeService.SetQuota(service.RealtimeQuota{
+		DailyAudioSeconds:   cfg.Quota.RealtimeDailyAudioSeconds,
+		MonthlyAudioSeconds: cfg.Quota.RealtimeMonthlyAudioSeconds,
+		DailyTokens:         cfg.Quota.RealtimeDailyTokens,
+		MonthlyTokens:       cfg.Quota.RealtimeMonthlyTokens,
+		WarnRatio:           cfg.Quota.WarnRatio,
+	})
+
+	// Session recording is opt-in per user and disabled unless configured
+	recordingService := newRecordingService(cfg.Recording)
+	if recordingService != nil {
+		recordingService.SetRecordingRepository(recordingRepo)
+		realtimeService.SetRecordingService(recordingService)
+		recordingService.StartPurge()
+	}
 	
 	// Get security monitor from realtime service
 	// Requirements: 9.5, 10.1, 10.2, 10.4, 10.5 - 安全和监控功能
@@ -80,10 +115,13 @@ func main() {
 	userHandler := handler.NewUserHandler(userService)
 	translateHandler := handler.NewTranslateHandler(translateService)
 	statisticsHandler := handler.NewStatisticsHandler(statisticsService)
+	feedbackHandler := handler.NewFeedbackHandler(feedbackService)
+	languageHandler := handler.NewLanguageHandler(languageRegistry)
 	
 	// Initialize Realtime handler
 	// Requirements: 2.1 - 添加 /api/v1/realtime/chat WebSocket 路由
 	realtimeHandler := handler.NewRealtimeHandler(realtimeService)
+	recordingHandler := handler.NewRecordingHandler(recordingService)
 	
 	// Initialize monitoring handler
 	// Requirements: 9.5, 10.5 - 监控API端点
@@ -94,7 +132,7 @@ func main() {
 	performanceHandler := handler.NewPerformanceHandler(performanceMonitor)
 
 	// Setup router
-	router := setupRouter(authHandler, userHandler, translateHandler, statisticsHandler, realtimeHandler, monitoringHandler, performanceHandler, securityMonitor, cfg)
+	router := setupRouter(authHandler, userHandler, translateHandler, statisticsHandler, feedbackHandler, languageHandler, realtimeHandler, recordingHandler, monitoringHandler, performanceHandler, securityMonitor, cfg)
 
 	// Start server
 	addr := ":" + cfg.Server.Port
@@ -104,7 +142,59 @@ func main() {
 	}
 }
 
-func setupRouter(authHandler *handler.AuthHandler, userHandler *handler.UserHandler, translateHandler *handler.TranslateHandler, statisticsHandler *handler.StatisticsHandler, realtimeHandler *handler.RealtimeHandler, monitoringHandler *handler.MonitoringHandler, performanceHandler *handler.PerformanceHandler, securityMonitor *service.SecurityMonitor, cfg *config.Config) *gin.Engine {
+// newRecordingService 按配置创建录音存储和录音服务，未开启或配置无效时返回 nil，不录音
+func newRecordingService(cfg config.RecordingConfig) *service.RecordingService {
+	if !cfg.Enabled {
+		return nil
+	}
+	key, err := base64.StdEncoding.DecodeString(cfg.EncryptionKey)
+	if err != nil || len(key) != 32 {
+		log.Printf("Recording disabled: RECORDING_ENCRYPTION_KEY must be a base64 encoded 32-byte key")
+		return nil
+	}
+
+	var store service.RecordingStore
+	switch cfg.Storage {
+	case "s3":
+		store, err = service.NewS3RecordingStore(service.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+			Prefix:    cfg.S3Prefix,
+		})
+	case "", "local":
+		store, err = service.NewLocalRecordingStore(cfg.LocalDir)
+	default:
+		log.Printf("Recording disabled: unsupported storage %q", cfg.Storage)
+		return nil
+	}
+	if err != nil {
+		log.Printf("Recording disabled: %v", err)
+		return nil
+	}
+
+	format := cfg.Format
+	if format == service.RecordingFormatOpus && !service.OpusEncodingAvailable() {
+		log.Printf("Opus recording requires a build with -tags opus, falling back to WAV")
+		format = service.RecordingFormatWAV
+	}
+	recordingService, err := service.NewRecordingService(store, service.RecordingConfig{
+		Format:        format,
+		Retention:     time.Duration(cfg.RetentionDays) * 24 * time.Hour,
+		EncryptionKey: key,
+	})
+	if err != nil {
+		log.Printf("Recording disabled: %v", err)
+		return nil
+	}
+	log.Printf("Session recording enabled: storage=%s, format=%s, retention=%dd", cfg.Storage, format, cfg.RetentionDays)
+	return recordingService
+}
+
+func setupRouter(authHandler *handler.AuthHandler, userHandler *handler.UserHandler, translateHandler *handler.TranslateHandler, statisticsHandler *handler.StatisticsHandler, feedbackHandler *handler.FeedbackHandler, languageHandler *handler.LanguageHandler, realtimeHandler *handler.RealtimeHandler, recordingHandler *handler.RecordingHandler, monitoringHandler *handler.MonitoringHandler, performanceHandler *handler.PerformanceHandler, securityMonitor *service.SecurityMonitor, cfg *config.Config) *gin.Engine {
 	router := gin.Default()
 
 	// Middleware
@@ -145,6 +235,13 @@ func setupRouter(authHandler *handler.AuthHandler, userHandler *handler.UserHand
 			auth.POST("/refresh", authHandler.Refresh)
 		}
 
+		// Language routes (public)
+		languages := v1.Group("/languages")
+		{
+			languages.GET("", languageHandler.ListLanguages)
+			languages.GET("/validate", languageHandler.ValidateLanguage)
+		}
+
 		// Protected routes
 		protected := v1.Group("")
 		protected.Use(middleware.AuthMiddleware(cfg.JWT.SecretKey))
@@ -159,7 +256,23 @@ func setupRouter(authHandler *handler.AuthHandler, userHandler *handler.UserHand
 			translate := protected.Group("/translate")
 			{
 				translate.POST("/text", translateHandler.TranslateText)
+				translate.POST("/stream", translateHandler.TranslateStreamSSE)
 				translate.GET("/history", translateHandler.GetHistory)
+				translate.GET("/history/export", translateHandler.ExportHistory)
+				translate.POST("/history/:id/feedback", feedbackHandler.SubmitFeedback)
+			}
+
+			// Realtime conversation history
+			realtime := protected.Group("/realtime")
+			{
+				realtime.GET("/sessions", realtimeHandler.ListSessions)
+				realtime.GET("/usage", realtimeHandler.GetUsage)
+				realtime.GET("/sessions/:id", realtimeHandler.GetSession)
+				realtime.GET("/recording/consent", recordingHandler.GetConsent)
+				realtime.PUT("/recording/consent", recordingHandler.GrantConsent)
+				realtime.DELETE("/recording/consent", recordingHandler.RevokeConsent)
+				realtime.GET("/recordings", recordingHandler.ListRecordings)
+				realtime.GET("/recordings/:sessionId/:file", recordingHandler.DownloadRecording)
 			}
 
 			// Statistics routes
@@ -168,6 +281,14 @@ func setupRouter(authHandler *handler.AuthHandler, userHandler *handler.UserHand
 				statistics.GET("", statisticsHandler.GetStatistics)
 			}
 
+			// Admin routes
+			admin := protected.Group("/admin")
+			admin.Use(middleware.RequireAdmin(cfg.Admin.Emails))
+			{
+				admin.GET("/feedback/stats", feedbackHandler.GetFeedbackStats)
+				admin.GET("/recordings/:userId/:sessionId/:file", recordingHandler.AdminDownloadRecording)
+			}
+
 			// Monitoring routes
 			// Requirements: 9.5, 10.4, 10.5 - 监控API端点
 			monitoring := protected.Group("/monitoring")
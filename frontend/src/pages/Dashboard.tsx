@@ -97,7 +97,8 @@ export default function Dashboard() {
   function getLanguageName(code: string): string {
     const langMap: Record<string, string> = {
       'en': '英语',
-      'zh': '中文',
+      'zh-Hans': '简体中文',
+      'zh-Hant': '繁体中文',
       'ja': '日语',
       'ko': '韩语',
       'fr': '法语',
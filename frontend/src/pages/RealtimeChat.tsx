@@ -1,8 +1,8 @@
 import { useState, useEffect, useRef, useCallback } from 'react'
 import { useAuth } from '../contexts/AuthContext'
-import { Mic, MicOff, Wifi, WifiOff, AlertCircle, Volume2, Activity } from 'lucide-react'
+import { Mic, MicOff, Wifi, WifiOff, AlertCircle, Volume2, Activity, Send } from 'lucide-react'
 import { RealtimeMessage, WebSocketMessage, AudioConfig } from '../types'
-import { errorHandler, ErrorType, handleAudioPlayback, cleanupAudioResources } from '../utils/errorHandler'
+import { errorHandler, ErrorType, handleAudioPlayback, stopAudioPlayback, cleanupAudioResources } from '../utils/errorHandler'
 
 const AUDIO_CONFIG: AudioConfig = {
   sampleRate: 24000,  // GPT Realtime API要求24kHz
@@ -26,6 +26,9 @@ export default function RealtimeChat() {
   const [connectionQuality, setConnectionQuality] = useState<'good' | 'fair' | 'poor'>('good')
   const [isAudioPlaying, setIsAudioPlaying] = useState(false)
   const [reconnectAttempts, setReconnectAttempts] = useState(0)
+  const [textInput, setTextInput] = useState('')
+  const [textOnlyReply, setTextOnlyReply] = useState(false)
+  const [upstreamReconnecting, setUpstreamReconnecting] = useState(false)
 
   // Refs for WebSocket and audio components
   const websocketRef = useRef<WebSocket | null>(null)
@@ -182,11 +185,40 @@ export default function RealtimeChat() {
       case 'response_complete':
         setIsProcessing(false)
         break
+
+      case 'response_interrupted':
+        // 用户打断了助手，立即停止播放
+        stopAudioPlayback()
+        setIsAudioPlaying(false)
+        setIsProcessing(false)
+        break
         
       case 'session_configured':
         console.log('Session configured successfully')
         break
-        
+
+      case 'reconnecting':
+        // 服务端与 GPT 的连接中断，正在重连；本地连接保持不变
+        setUpstreamReconnecting(true)
+        setIsProcessing(false)
+        stopAudioPlayback()
+        setIsAudioPlaying(false)
+        break
+
+      case 'reconnected':
+        setUpstreamReconnecting(false)
+        setError('')
+        break
+
+      case 'quota_warning':
+        setError(data.message || '实时对话用量即将达到上限')
+        break
+
+      case 'quota_exceeded':
+        // 服务端随后会关闭连接
+        setError(data.message || '实时对话用量已达上限')
+        break
+
       case 'connection_quality':
         if (data.data?.quality) {
           setConnectionQuality(data.data.quality)
@@ -194,6 +226,7 @@ export default function RealtimeChat() {
         break
         
       case 'error':
+        setUpstreamReconnecting(false)
         const errorMessage = data.error ? 
           (typeof data.error === 'string' ? data.error : JSON.stringify(data.error)) : 
           '未知错误'
@@ -716,6 +749,25 @@ export default function RealtimeChat() {
     }, 100)
   }, [])
 
+  // 文字输入，适用于不方便说话的场合；可选择本轮只要文字回复
+  const sendTextMessage = useCallback(() => {
+    const text = textInput.trim()
+    if (!text || !websocketRef.current || websocketRef.current.readyState !== WebSocket.OPEN) return
+
+    // 助手仍在播放时服务端会打断当前回复
+    stopAudioPlayback()
+    setIsAudioPlaying(false)
+
+    websocketRef.current.send(JSON.stringify({
+      type: 'text_input',
+      text,
+      modalities: textOnlyReply ? ['text'] : ['audio']
+    }))
+    addMessage('user', text)
+    setTextInput('')
+    setIsProcessing(true)
+  }, [textInput, textOnlyReply, addMessage])
+
   const getConnectionQualityIcon = (quality: string) => {
     switch (quality) {
       case 'good': return <Wifi className="w-4 h-4" />
@@ -817,6 +869,14 @@ export default function RealtimeChat() {
             </div>
           )}
           
+          {/* Upstream Reconnection Status */}
+          {upstreamReconnecting && (
+            <div className="flex items-center gap-2 px-3 py-1 rounded-full text-sm font-medium bg-yellow-100 text-yellow-800">
+              <Activity className="w-4 h-4 animate-pulse" />
+              服务重连中
+            </div>
+          )}
+
           {/* Reconnection Status */}
           {reconnectAttempts > 0 && (
             <div className="flex items-center gap-2 px-3 py-1 rounded-full text-sm font-medium bg-yellow-100 text-yellow-800">
@@ -935,6 +995,40 @@ export default function RealtimeChat() {
           )}
         </div>
 
+        {/* Text Input */}
+        <form
+          onSubmit={(e) => {
+            e.preventDefault()
+            sendTextMessage()
+          }}
+          className="flex items-center gap-2"
+        >
+          <input
+            type="text"
+            value={textInput}
+            onChange={(e) => setTextInput(e.target.value)}
+            disabled={!isConnected}
+            maxLength={4096}
+            placeholder="不方便说话时，可以在这里输入文字"
+            className="flex-1 px-4 py-2 border border-gray-300 rounded-full text-sm focus:outline-none focus:ring-2 focus:ring-blue-500 disabled:bg-gray-100"
+          />
+          <label className="flex items-center gap-1 text-xs text-gray-600 whitespace-nowrap">
+            <input
+              type="checkbox"
+              checked={textOnlyReply}
+              onChange={(e) => setTextOnlyReply(e.target.checked)}
+            />
+            仅文字回复
+          </label>
+          <button
+            type="submit"
+            disabled={!isConnected || !textInput.trim()}
+            className="p-2 rounded-full bg-blue-500 text-white hover:bg-blue-600 disabled:bg-gray-400 disabled:cursor-not-allowed"
+          >
+            <Send className="w-4 h-4" />
+          </button>
+        </form>
+
         {/* Enhanced Status Info */}
         <div className="flex justify-center min-h-[50px] items-center">
           {isProcessing && (
@@ -5,7 +5,8 @@ import type { TranslateResponse } from '../types'
 
 const languages = [
   { code: 'en', name: '英语' },
-  { code: 'zh', name: '中文' },
+  { code: 'zh-Hans', name: '简体中文' },
+  { code: 'zh-Hant', name: '繁体中文' },
   { code: 'ja', name: '日语' },
   { code: 'ko', name: '韩语' },
   { code: 'fr', name: '法语' },
@@ -17,7 +18,7 @@ const languages = [
 export default function Translation() {
   const [sourceText, setSourceText] = useState('')
   const [sourceLanguage, setSourceLanguage] = useState('en')
-  const [targetLanguage, setTargetLanguage] = useState('zh')
+  const [targetLanguage, setTargetLanguage] = useState('zh-Hans')
   const [result, setResult] = useState<TranslateResponse | null>(null)
   const [loading, setLoading] = useState(false)
   const [error, setError] = useState('')
@@ -62,6 +62,7 @@ export interface TokenUsage {
   date: string
   input_tokens: number
   output_tokens: number
+  estimated_tokens: number
 }
 
 export interface StatisticsResponse {
@@ -6,6 +6,8 @@ let globalAudioContext: AudioContext | null = null
 // 音频播放队列管理
 let audioPlaybackQueue: Promise<void> = Promise.resolve()
 let currentAudioSource: AudioBufferSourceNode | null = null
+// 每次打断播放时递增，排队中属于旧批次的音频不再播放
+let playbackGeneration = 0
 
 export interface ErrorInfo {
   type: ErrorType
@@ -266,6 +268,19 @@ export class FrontendErrorHandler {
 // Export singleton instance
 export const errorHandler = FrontendErrorHandler.getInstance()
 
+// 立即停止当前播放并丢弃排队中的音频（用户打断助手时调用）
+export const stopAudioPlayback = (): void => {
+  playbackGeneration++
+  if (currentAudioSource) {
+    try {
+      currentAudioSource.stop()
+    } catch (e) {
+      console.warn('Error stopping audio source:', e)
+    }
+    currentAudioSource = null
+  }
+}
+
 // Utility functions for common error scenarios
 export const handleMicrophonePermission = async (): Promise<MediaStream> => {
   try {
@@ -291,8 +306,12 @@ export const handleMicrophonePermission = async (): Promise<MediaStream> => {
 }
 
 export const handleAudioPlayback = async (audioData: string): Promise<void> => {
+  const generation = playbackGeneration
   // 将音频播放加入队列，避免重叠播放
   audioPlaybackQueue = audioPlaybackQueue.then(async () => {
+    if (generation !== playbackGeneration) {
+      return
+    }
     try {
       // 停止当前播放的音频（如果有）
       if (currentAudioSource) {
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -14,6 +16,9 @@ type Config struct {
 	JWT      JWTConfig      `yaml:"jwt"`
 	Azure    AzureConfig    `yaml:"azure"`
 	Realtime RealtimeConfig `yaml:"realtime"`
+	Admin    AdminConfig    `yaml:"admin"`
+	Quota    QuotaConfig    `yaml:"quota"`
+	Recording RecordingConfig `yaml:"recording"`
 }
 
 type ServerConfig struct {
@@ -45,6 +50,38 @@ type RealtimeConfig struct {
 	APIKey         string `yaml:"realtime_api_key"`
 	DeploymentName string `yaml:"realtime_deployment_name"`
 	APIVersion     string `yaml:"realtime_api_version"`
+	TranscodeG711  bool   `yaml:"realtime_transcode_g711"` // 上游不支持 G.711 时在服务端转换为 pcm16
+}
+
+// AdminConfig 管理员配置，列表中的邮箱可以访问管理接口
+type AdminConfig struct {
+	Emails []string `yaml:"emails"`
+}
+
+// QuotaConfig 每个用户的实时对话配额，0 表示不限制
+type QuotaConfig struct {
+	RealtimeDailyAudioSeconds   float64 `yaml:"realtime_daily_audio_seconds"`
+	RealtimeMonthlyAudioSeconds float64 `yaml:"realtime_monthly_audio_seconds"`
+	RealtimeDailyTokens         int     `yaml:"realtime_daily_tokens"`
+	RealtimeMonthlyTokens       int     `yaml:"realtime_monthly_tokens"`
+	WarnRatio                   float64 `yaml:"warn_ratio"` // 用量达到配额的该比例时提醒客户端
+}
+
+// RecordingConfig 会话录音配置，只录制用户同意录音的会话
+type RecordingConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	Storage       string `yaml:"storage"`        // local 或 s3
+	LocalDir      string `yaml:"local_dir"`
+	Format        string `yaml:"format"`         // wav 或 opus，opus 需要 -tags opus 构建
+	RetentionDays int    `yaml:"retention_days"` // 到期自动删除
+	EncryptionKey string `yaml:"encryption_key"` // base64 编码的 32 字节 AES-256 密钥
+	S3Endpoint    string `yaml:"s3_endpoint"`
+	S3Region      string `yaml:"s3_region"`
+	S3Bucket      string `yaml:"s3_bucket"`
+	S3AccessKey   string `yaml:"s3_access_key"`
+	S3SecretKey   string `yaml:"s3_secret_key"`
+	S3PathStyle   bool   `yaml:"s3_path_style"` // MinIO 等自建服务使用路径形式的地址
+	S3Prefix      string `yaml:"s3_prefix"`     // 与其他数据共用桶时录音所在的目录
 }
 
 func Load() (*Config, error) {
@@ -77,6 +114,32 @@ func Load() (*Config, error) {
 			APIKey:         getEnv("AZURE_OPENAI_REALTIME_API_KEY", ""),
 			DeploymentName: getEnv("AZURE_OPENAI_REALTIME_DEPLOYMENT_NAME", "gpt-4o-realtime-preview"),
 			APIVersion:     getEnv("AZURE_OPENAI_REALTIME_API_VERSION", "2024-10-01-preview"),
+			TranscodeG711:  parseBool(getEnv("REALTIME_TRANSCODE_G711", "false")),
+		},
+		Admin: AdminConfig{
+			Emails: splitList(getEnv("ADMIN_EMAILS", "")),
+		},
+		Quota: QuotaConfig{
+			RealtimeDailyAudioSeconds:   parseFloat(getEnv("REALTIME_DAILY_AUDIO_SECONDS", "0")),
+			RealtimeMonthlyAudioSeconds: parseFloat(getEnv("REALTIME_MONTHLY_AUDIO_SECONDS", "0")),
+			RealtimeDailyTokens:         parseInt(getEnv("REALTIME_DAILY_TOKENS", "0")),
+			RealtimeMonthlyTokens:       parseInt(getEnv("REALTIME_MONTHLY_TOKENS", "0")),
+			WarnRatio:                   parseFloat(getEnv("REALTIME_QUOTA_WARN_RATIO", "0.8")),
+		},
+		Recording: RecordingConfig{
+			Enabled:       parseBool(getEnv("RECORDING_ENABLED", "false")),
+			Storage:       getEnv("RECORDING_STORAGE", "local"),
+			LocalDir:      getEnv("RECORDING_DIR", "data/recordings"),
+			Format:        getEnv("RECORDING_FORMAT", "wav"),
+			RetentionDays: parseInt(getEnv("RECORDING_RETENTION_DAYS", "30")),
+			EncryptionKey: getEnv("RECORDING_ENCRYPTION_KEY", ""),
+			S3Endpoint:    getEnv("RECORDING_S3_ENDPOINT", ""),
+			S3Region:      getEnv("RECORDING_S3_REGION", "us-east-1"),
+			S3Bucket:      getEnv("RECORDING_S3_BUCKET", ""),
+			S3AccessKey:   getEnv("RECORDING_S3_ACCESS_KEY", ""),
+			S3SecretKey:   getEnv("RECORDING_S3_SECRET_KEY", ""),
+			S3PathStyle:   parseBool(getEnv("RECORDING_S3_PATH_STYLE", "false")),
+			S3Prefix:      getEnv("RECORDING_S3_PREFIX", ""),
 		},
 	}
 
@@ -102,6 +165,41 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// splitList 解析逗号分隔的列表，忽略空项
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseInt 解析非负整数，格式错误时视为 0（不限制）
+func parseInt(s string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// parseFloat 解析非负小数，格式错误时视为 0
+func parseFloat(s string) float64 {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || f < 0 {
+		return 0
+	}
+	return f
+}
+
+// parseBool 解析布尔值，格式错误时视为 false
+func parseBool(s string) bool {
+	b, err := strconv.ParseBool(strings.TrimSpace(s))
+	return err == nil && b
+}
+
 func parseDuration(s string) time.Duration {
 	d, err := time.ParseDuration(s)
 	if err != nil {
@@ -153,5 +251,65 @@ func overrideWithEnv(cfg *Config) {
 	if realtimeVersion := os.Getenv("AZURE_OPENAI_REALTIME_API_VERSION"); realtimeVersion != "" {
 		cfg.Realtime.APIVersion = realtimeVersion
 	}
+	if v := os.Getenv("REALTIME_TRANSCODE_G711"); v != "" {
+		cfg.Realtime.TranscodeG711 = parseBool(v)
+	}
+	if adminEmails := os.Getenv("ADMIN_EMAILS"); adminEmails != "" {
+		cfg.Admin.Emails = splitList(adminEmails)
+	}
+	if v := os.Getenv("REALTIME_DAILY_AUDIO_SECONDS"); v != "" {
+		cfg.Quota.RealtimeDailyAudioSeconds = parseFloat(v)
+	}
+	if v := os.Getenv("REALTIME_MONTHLY_AUDIO_SECONDS"); v != "" {
+		cfg.Quota.RealtimeMonthlyAudioSeconds = parseFloat(v)
+	}
+	if v := os.Getenv("REALTIME_DAILY_TOKENS"); v != "" {
+		cfg.Quota.RealtimeDailyTokens = parseInt(v)
+	}
+	if v := os.Getenv("REALTIME_MONTHLY_TOKENS"); v != "" {
+		cfg.Quota.RealtimeMonthlyTokens = parseInt(v)
+	}
+	if v := os.Getenv("REALTIME_QUOTA_WARN_RATIO"); v != "" {
+		cfg.Quota.WarnRatio = parseFloat(v)
+	}
+	if v := os.Getenv("RECORDING_ENABLED"); v != "" {
+		cfg.Recording.Enabled = parseBool(v)
+	}
+	if v := os.Getenv("RECORDING_STORAGE"); v != "" {
+		cfg.Recording.Storage = v
+	}
+	if v := os.Getenv("RECORDING_DIR"); v != "" {
+		cfg.Recording.LocalDir = v
+	}
+	if v := os.Getenv("RECORDING_FORMAT"); v != "" {
+		cfg.Recording.Format = v
+	}
+	if v := os.Getenv("RECORDING_RETENTION_DAYS"); v != "" {
+		cfg.Recording.RetentionDays = parseInt(v)
+	}
+	if v := os.Getenv("RECORDING_ENCRYPTION_KEY"); v != "" {
+		cfg.Recording.EncryptionKey = v
+	}
+	if v := os.Getenv("RECORDING_S3_ENDPOINT"); v != "" {
+		cfg.Recording.S3Endpoint = v
+	}
+	if v := os.Getenv("RECORDING_S3_REGION"); v != "" {
+		cfg.Recording.S3Region = v
+	}
+	if v := os.Getenv("RECORDING_S3_BUCKET"); v != "" {
+		cfg.Recording.S3Bucket = v
+	}
+	if v := os.Getenv("RECORDING_S3_ACCESS_KEY"); v != "" {
+		cfg.Recording.S3AccessKey = v
+	}
+	if v := os.Getenv("RECORDING_S3_SECRET_KEY"); v != "" {
+		cfg.Recording.S3SecretKey = v
+	}
+	if v := os.Getenv("RECORDING_S3_PATH_STYLE"); v != "" {
+		cfg.Recording.S3PathStyle = parseBool(v)
+	}
+	if v := os.Getenv("RECORDING_S3_PREFIX"); v != "" {
+		cfg.Recording.S3Prefix = v
+	}
 }
 
@@ -29,12 +29,14 @@ func TestRealtimeConfigLoading(t *testing.T) {
 				"AZURE_OPENAI_REALTIME_API_KEY":         "test-key-123",
 				"AZURE_OPENAI_REALTIME_DEPLOYMENT_NAME": "custom-deployment",
 				"AZURE_OPENAI_REALTIME_API_VERSION":     "2024-11-01-preview",
+				"REALTIME_TRANSCODE_G711":               "true",
 			},
 			expected: RealtimeConfig{
 				Endpoint:       "https://test.openai.azure.com/",
 				APIKey:         "test-key-123",
 				DeploymentName: "custom-deployment",
 				APIVersion:     "2024-11-01-preview",
+				TranscodeG711:  true,
 			},
 		},
 	}
@@ -46,6 +48,7 @@ func TestRealtimeConfigLoading(t *testing.T) {
 			os.Unsetenv("AZURE_OPENAI_REALTIME_API_KEY")
 			os.Unsetenv("AZURE_OPENAI_REALTIME_DEPLOYMENT_NAME")
 			os.Unsetenv("AZURE_OPENAI_REALTIME_API_VERSION")
+			os.Unsetenv("REALTIME_TRANSCODE_G711")
 
 			// Set test env vars
 			for key, value := range tc.envVars {
@@ -71,6 +74,9 @@ func TestRealtimeConfigLoading(t *testing.T) {
 			if cfg.Realtime.APIVersion != tc.expected.APIVersion {
 				t.Errorf("Expected APIVersion %s, got %s", tc.expected.APIVersion, cfg.Realtime.APIVersion)
 			}
+			if cfg.Realtime.TranscodeG711 != tc.expected.TranscodeG711 {
+				t.Errorf("Expected TranscodeG711 %v, got %v", tc.expected.TranscodeG711, cfg.Realtime.TranscodeG711)
+			}
 
 			// Clean up
 			for key := range tc.envVars {
@@ -119,4 +125,79 @@ func TestBackwardCompatibility(t *testing.T) {
 	os.Unsetenv("AZURE_OPENAI_API_VERSION")
 	os.Unsetenv("AZURE_OPENAI_REALTIME_ENDPOINT")
 	os.Unsetenv("AZURE_OPENAI_REALTIME_API_KEY")
-}
\ No newline at end of file
+}
+
+func TestAdminEmailsLoading(t *testing.T) {
+	os.Setenv("ADMIN_EMAILS", " admin@example.com, ,ops@example.com ")
+	defer os.Unsetenv("ADMIN_EMAILS")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	expected := []string{"admin@example.com", "ops@example.com"}
+	if len(cfg.Admin.Emails) != len(expected) {
+		t.Fatalf("Expected admin emails %v, got %v", expected, cfg.Admin.Emails)
+	}
+	for i, email := range expected {
+		if cfg.Admin.Emails[i] != email {
+			t.Errorf("Expected admin email %s at index %d, got %s", email, i, cfg.Admin.Emails[i])
+		}
+	}
+}
+
+func TestQuotaConfigLoading(t *testing.T) {
+	os.Setenv("REALTIME_DAILY_AUDIO_SECONDS", "1800")
+	os.Setenv("REALTIME_MONTHLY_TOKENS", "500000")
+	os.Setenv("REALTIME_DAILY_TOKENS", "invalid")
+	defer os.Unsetenv("REALTIME_DAILY_AUDIO_SECONDS")
+	defer os.Unsetenv("REALTIME_MONTHLY_TOKENS")
+	defer os.Unsetenv("REALTIME_DAILY_TOKENS")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if cfg.Quota.RealtimeDailyAudioSeconds != 1800 {
+		t.Errorf("Expected daily audio seconds 1800, got %v", cfg.Quota.RealtimeDailyAudioSeconds)
+	}
+	if cfg.Quota.RealtimeMonthlyTokens != 500000 {
+		t.Errorf("Expected monthly tokens 500000, got %d", cfg.Quota.RealtimeMonthlyTokens)
+	}
+	if cfg.Quota.RealtimeDailyTokens != 0 {
+		t.Errorf("Expected invalid daily tokens to mean unlimited, got %d", cfg.Quota.RealtimeDailyTokens)
+	}
+	if cfg.Quota.WarnRatio != 0.8 {
+		t.Errorf("Expected default warn ratio 0.8, got %v", cfg.Quota.WarnRatio)
+	}
+}
+
+func TestRecordingConfigLoading(t *testing.T) {
+	os.Setenv("RECORDING_ENABLED", "true")
+	os.Setenv("RECORDING_STORAGE", "s3")
+	os.Setenv("RECORDING_RETENTION_DAYS", "7")
+	os.Setenv("RECORDING_S3_PATH_STYLE", "true")
+	os.Setenv("RECORDING_S3_PREFIX", "recordings/")
+	defer os.Unsetenv("RECORDING_ENABLED")
+	defer os.Unsetenv("RECORDING_STORAGE")
+	defer os.Unsetenv("RECORDING_RETENTION_DAYS")
+	defer os.Unsetenv("RECORDING_S3_PATH_STYLE")
+	defer os.Unsetenv("RECORDING_S3_PREFIX")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if !cfg.Recording.Enabled || cfg.Recording.Storage != "s3" || !cfg.Recording.S3PathStyle || cfg.Recording.S3Prefix != "recordings/" {
+		t.Errorf("Expected recording to S3 with path-style addressing under recordings/, got %+v", cfg.Recording)
+	}
+	if cfg.Recording.RetentionDays != 7 {
+		t.Errorf("Expected retention 7 days, got %d", cfg.Recording.RetentionDays)
+	}
+	if cfg.Recording.Format != "wav" || cfg.Recording.S3Region != "us-east-1" {
+		t.Errorf("Expected default format wav and region us-east-1, got %s / %s", cfg.Recording.Format, cfg.Recording.S3Region)
+	}
+}
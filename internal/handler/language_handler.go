@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"net/http"
+	"smart-glasses-backend/internal/model"
+	"smart-glasses-backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LanguageHandler struct {
+	languages *service.LanguageRegistry
+}
+
+func NewLanguageHandler(languages *service.LanguageRegistry) *LanguageHandler {
+	return &LanguageHandler{languages: languages}
+}
+
+// ListLanguages 返回支持的语言，locale 指定显示名称的语言，provider 按服务能力过滤
+func (h *LanguageHandler) ListLanguages(c *gin.Context) {
+	locale := c.DefaultQuery("locale", service.LocaleEnglish)
+	languages := h.languages.List(locale, c.Query("provider"))
+
+	c.JSON(http.StatusOK, gin.H{"data": languages})
+}
+
+// ValidateLanguage 校验语言代码或名称并返回规范代码
+func (h *LanguageHandler) ValidateLanguage(c *gin.Context) {
+	input := c.Query("code")
+	if input == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	resp := &model.LanguageValidationResponse{Input: input}
+	lang, err := h.languages.Lookup(input, c.DefaultQuery("locale", service.LocaleEnglish))
+	if err != nil {
+		resp.Error = err.Error()
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	resp.Valid = true
+	resp.Code = lang.Code
+	resp.Language = lang
+	c.JSON(http.StatusOK, resp)
+}
@@ -185,14 +185,20 @@ func (h *MonitoringHandler) GetPrivacyStatus(c *gin.Context) {
 	}
 
 	privacyEnabled := h.securityMonitor.EnsureAudioDataPrivacy()
+	data := gin.H{
+		"privacy_mode_enabled": privacyEnabled,
+		"audio_data_protection": "enabled",
+		"data_retention_policy": "no_audio_storage",
+	}
+	// 开启录音后只保存用户同意录制的会话，加密保存并到期删除
+	if retention := h.securityMonitor.RecordingRetention(); retention > 0 {
+		data["data_retention_policy"] = "consented_recordings"
+		data["recording_retention_days"] = int(retention / (24 * time.Hour))
+	}
 	
 	c.JSON(http.StatusOK, gin.H{
 		"status": "success",
-		"data": gin.H{
-			"privacy_mode_enabled": privacyEnabled,
-			"audio_data_protection": "enabled",
-			"data_retention_policy": "no_audio_storage",
-		},
+		"data": data,
 		"user_id": userID,
 	})
 }
@@ -2,12 +2,18 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"smart-glasses-backend/internal/repository"
 	"smart-glasses-backend/internal/service"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
@@ -17,9 +23,15 @@ type RealtimeHandler struct {
 }
 
 type ClientMessage struct {
-	Type      string `json:"type"`
-	Audio     string `json:"audio,omitempty"`
-	SessionID string `json:"session_id,omitempty"`
+	Type       string                                `json:"type"`
+	Audio      string                                `json:"audio,omitempty"`
+	SessionID  string                                `json:"session_id,omitempty"`
+	Config     *service.RealtimeSessionConfigRequest `json:"config,omitempty"`
+	AudioEndMs *int                                  `json:"audio_end_ms,omitempty"` // interrupt 时客户端已播放的语音时长
+	Text       string                                `json:"text,omitempty"`         // text_input 的文字内容
+	Modalities []string                              `json:"modalities,omitempty"`   // 本轮响应的输出方式，如 ["text"] 或 ["audio"]
+	Seq        *uint32                               `json:"seq,omitempty"`          // audio_data 的包序号，提供时经抖动缓冲重排
+	CaptureTs  int64                                 `json:"capture_ts,omitempty"`   // audio_data 的采集时间（Unix 毫秒），用于抖动统计
 }
 
 type ServerMessage struct {
@@ -38,28 +50,74 @@ func NewRealtimeHandler(realtimeService *service.RealtimeService) *RealtimeHandl
 			},
 			ReadBufferSize:  4096,
 			WriteBufferSize: 4096,
+			// 客户端请求该子协议时音频改用二进制帧，控制消息仍为 JSON
+			Subprotocols: []string{service.AudioFrameSubprotocol},
 		},
 	}
 }
 
+// ValidateConnection 检查连接请求已通过认证
+func (h *RealtimeHandler) ValidateConnection(c *gin.Context) error {
+	userID, exists := c.Get("user_id")
+	if !exists || fmt.Sprint(userID) == "" {
+		return errors.New("user not authenticated")
+	}
+	return nil
+}
+
 // HandleRealtimeConnection 处理实时WebSocket连接，集成GPT Realtime API
+// 每个连接分配一个会话 ID，会话记录、监控接口和性能指标都以它为键
 func (h *RealtimeHandler) HandleRealtimeConnection(c *gin.Context) {
 	// 获取用户信息
-	userID, exists := c.Get("user_id")
-	if !exists {
-		log.Printf("User ID not found in context")
+	if err := h.ValidateConnection(c); err != nil {
+		log.Printf("Realtime connection rejected: %v", err)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
 		return
 	}
-
+	userID, _ := c.Get("user_id")
 	userEmail, _ := c.Get("user_email")
+
+	userUUID, err := uuid.Parse(fmt.Sprint(userID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
 	
 	log.Printf("Realtime connection request from user: %s (%s)", userID, userEmail)
 
+	// 配额已用完时不再建立连接
+	if usage, quotaErr := h.realtimeService.CheckRealtimeQuota(userUUID); quotaErr != nil {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": "quota exceeded",
+			"user_message": "实时对话用量已达上限",
+			"usage": usage,
+		})
+		return
+	}
+
+	// 升级前登记会话，超过并发上限时直接拒绝
+	emailStr, _ := userEmail.(string)
+	sessionID, err := h.realtimeService.StartMonitoredSession(userUUID, emailStr, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		if errors.Is(err, service.ErrMaxSessionsReached) {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error": "too many sessions",
+				"user_message": "当前连接数已满，请稍后重试",
+			})
+			return
+		}
+		log.Printf("Failed to start session for user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start session"})
+		return
+	}
+	endReason := "completed"
+	defer func() { h.realtimeService.EndMonitoredSession(sessionID, endReason) }()
+
 	// WebSocket升级
 	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade failed: %v", err)
+		endReason = "error"
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "websocket upgrade failed",
 			"user_message": "WebSocket连接升级失败",
@@ -68,18 +126,27 @@ func (h *RealtimeHandler) HandleRealtimeConnection(c *gin.Context) {
 	}
 	defer conn.Close()
 
-	log.Printf("WebSocket connection established successfully for user: %s", userID)
+	log.Printf("WebSocket connection established successfully for user: %s (session %s)", userID, sessionID)
+
+	binaryAudio := conn.Subprotocol() == service.AudioFrameSubprotocol
+	audioFraming := "json"
+	if binaryAudio {
+		audioFraming = "binary"
+	}
 
 	// 发送连接成功消息
 	err = conn.WriteJSON(map[string]interface{}{
 		"type": "connection_established",
 		"user_id": userID,
+		"session_id": sessionID,
+		"audio_framing": audioFraming,
 		"status": "connected",
 		"timestamp": time.Now().Unix(),
 		"message": "WebSocket连接成功建立",
 	})
 	if err != nil {
 		log.Printf("Failed to send connection message: %v", err)
+		endReason = "error"
 		return
 	}
 
@@ -98,17 +165,23 @@ func (h *RealtimeHandler) HandleRealtimeConnection(c *gin.Context) {
 		})
 		
 		// 使用模拟模式处理消息
-		h.handleMockMode(conn, userID)
+		h.handleMockMode(conn, userID, sessionID)
 		return
 	}
-	defer gptConn.Close()
 
 	log.Printf("Connected to GPT Realtime API for user: %s", userID)
 
-	// 配置GPT会话
-	err = h.realtimeService.ConfigureSession(gptConn)
+	// 配置GPT会话，客户端发送 configure_session 前使用默认配置
+	// 上游断开后会重连并替换连接，由 EndSession 关闭当前的上游连接
+	session := h.realtimeService.NewSession(sessionID, userUUID, conn, gptConn, nil)
+	defer h.realtimeService.EndSession(session)
+	if binaryAudio {
+		session.EnableBinaryAudio()
+	}
+	err = h.realtimeService.ConfigureSession(gptConn, session.Config())
 	if err != nil {
 		log.Printf("Failed to configure GPT session: %v", err)
+		endReason = "error"
 		conn.WriteJSON(map[string]interface{}{
 			"type": "error",
 			"error": "session_config_failed",
@@ -119,36 +192,65 @@ func (h *RealtimeHandler) HandleRealtimeConnection(c *gin.Context) {
 	}
 
 	// 启动GPT响应处理协程
-	go h.realtimeService.HandleRealtimeResponse(gptConn, conn)
+	go h.realtimeService.HandleRealtimeResponse(session)
 
 	// 客户端消息处理循环
-	h.handleGPTMode(conn, gptConn, userID)
+	h.handleGPTMode(conn, userID, session)
 }
 
 // handleMockMode 处理模拟模式（当GPT API不可用时）
-func (h *RealtimeHandler) handleMockMode(conn *websocket.Conn, userID interface{}) {
+func (h *RealtimeHandler) handleMockMode(conn *websocket.Conn, userID interface{}, sessionID uuid.UUID) {
+	sessionConfig := service.DefaultRealtimeSessionConfig()
+
 	for {
-		var msg map[string]interface{}
-		err := conn.ReadJSON(&msg)
+		messageType, data, err := conn.ReadMessage()
 		if err != nil {
 			log.Printf("WebSocket read error: %v", err)
 			break
 		}
-		
-		msgType, ok := msg["type"].(string)
-		if !ok {
+		if messageType == websocket.BinaryMessage {
+			// 测试模式没有上游，二进制音频帧直接丢弃
+			h.realtimeService.RecordClientMessage(sessionID, "audio_frame", len(data))
 			continue
 		}
+
+		var msg ClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			log.Printf("Invalid message from %s: %s", userID, data)
+			continue
+		}
+		h.realtimeService.RecordClientMessage(sessionID, msg.Type, len(data))
 		
-		switch msgType {
+		switch msg.Type {
 		case "configure_session":
+			// 测试模式同样校验配置，客户端可以据此验证参数
+			cfg, err := h.realtimeService.ResolveSessionConfig(sessionConfig, msg.Config)
+			if err != nil {
+				h.writeSessionConfigError(conn, err)
+				continue
+			}
+			sessionConfig = cfg
 			conn.WriteJSON(map[string]interface{}{
 				"type": "session_configured",
 				"status": "success",
+				"config": sessionConfig,
 				"timestamp": time.Now().Unix(),
 				"message": "会话配置成功（测试模式）",
 			})
 			
+		case "text_input":
+			conn.WriteJSON(map[string]interface{}{
+				"type": "text_response",
+				"text": "我收到了您的文字消息。当前处于测试模式，GPT Realtime API暂时不可用。",
+				"timestamp": time.Now().Unix(),
+			})
+
+			conn.WriteJSON(map[string]interface{}{
+				"type": "response_complete",
+				"timestamp": time.Now().Unix(),
+				"message": "文字处理完成（测试模式）",
+			})
+
 		case "commit_audio":
 			// 模拟GPT回复
 			conn.WriteJSON(map[string]interface{}{
@@ -167,38 +269,82 @@ func (h *RealtimeHandler) handleMockMode(conn *websocket.Conn, userID interface{
 }
 
 // handleGPTMode 处理真正的GPT模式
-func (h *RealtimeHandler) handleGPTMode(conn *websocket.Conn, gptConn *websocket.Conn, userID interface{}) {
+func (h *RealtimeHandler) handleGPTMode(conn *websocket.Conn, userID interface{}, session *service.RealtimeSession) {
 	for {
-		var msg map[string]interface{}
-		err := conn.ReadJSON(&msg)
+		messageType, data, err := conn.ReadMessage()
 		if err != nil {
 			log.Printf("WebSocket read error: %v", err)
 			break
 		}
-		
-		msgType, ok := msg["type"].(string)
-		if !ok {
-			log.Printf("Invalid message type from %s: %v", userID, msg)
+		if messageType == websocket.BinaryMessage {
+			h.realtimeService.RecordClientMessage(session.ID, "audio_frame", len(data))
+			if err := h.handleAudioFrame(conn, session, data); err != nil {
+				log.Printf("WebSocket write error: %v", err)
+				break
+			}
 			continue
 		}
+
+		var msg ClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil || msg.Type == "" {
+			log.Printf("Invalid message from %s: %s", userID, data)
+			continue
+		}
+		msgType := msg.Type
+		h.realtimeService.RecordClientMessage(session.ID, msgType, len(data))
 		
 		log.Printf("Received message type '%s' from %s", msgType, userID)
 		
 		// 根据消息类型处理
 		switch msgType {
 		case "configure_session":
-			// 会话配置确认
+			// 校验客户端配置并更新GPT会话，返回实际生效的配置
+			cfg, cfgErr := h.realtimeService.ResolveSessionConfig(session.Config(), msg.Config)
+			if cfgErr != nil {
+				log.Printf("Rejected session config from %s: %v", userID, cfgErr)
+				err = h.writeSessionConfigError(conn, cfgErr)
+				break
+			}
+			// 录音需要服务端开启且用户已同意，隐私模式下未经同意不能录音
+			if cfgErr = h.realtimeService.CheckRecording(session, cfg); cfgErr != nil {
+				log.Printf("Rejected recording for %s: %v", userID, cfgErr)
+				err = h.writeClient(conn, map[string]interface{}{
+					"type": "error",
+					"error": "recording_not_allowed",
+					"message": "未同意录音或服务端未开启录音",
+					"details": cfgErr.Error(),
+					"timestamp": time.Now().Unix(),
+				})
+				break
+			}
+			if cfgErr = h.realtimeService.ConfigureSession(session.Upstream(), cfg); cfgErr != nil {
+				log.Printf("Failed to update GPT session: %v", cfgErr)
+				err = h.writeClient(conn, map[string]interface{}{
+					"type": "error",
+					"error": "session_config_failed",
+					"message": "GPT会话配置失败",
+					"timestamp": time.Now().Unix(),
+				})
+				break
+			}
+			h.realtimeService.UpdateSessionConfig(session, cfg)
+
 			response := map[string]interface{}{
 				"type": "session_configured",
 				"status": "success",
+				"config": cfg,
 				"timestamp": time.Now().Unix(),
 				"message": "会话配置成功",
 			}
-			err = conn.WriteJSON(response)
+			err = h.writeClient(conn, response)
 			
 		case "audio_data":
+			// 上游重连期间丢弃音频，客户端已收到 reconnecting 通知
+			if session.Reconnecting() {
+				continue
+			}
 			// 处理音频数据 - 发送到GPT API
-			if audioData, ok := msg["audio"].(string); ok {
+			if audioData := msg.Audio; audioData != "" {
 				log.Printf("Received audio_data message from user: %s, base64 length: %d", userID, len(audioData))
 				
 				// 验证和解码音频数据
@@ -210,11 +356,19 @@ func (h *RealtimeHandler) handleGPTMode(conn *websocket.Conn, gptConn *websocket
 				
 				log.Printf("Decoded audio data size: %d bytes", len(decodedAudio))
 				
-				// 发送音频数据到GPT API
-				err = h.realtimeService.SendAudioData(gptConn, decodedAudio)
+				// 发送音频数据到GPT API，带序号的包先按序号重排
+				if msg.Seq != nil {
+					err = h.realtimeService.SendSessionAudioPacket(session, service.AudioPacket{
+						Sequence:         *msg.Seq,
+						CaptureTimestamp: msg.CaptureTs,
+						Payload:          decodedAudio,
+					})
+				} else {
+					err = h.realtimeService.SendSessionAudio(session, decodedAudio)
+				}
 				if err != nil {
 					log.Printf("Failed to send audio to GPT API: %v", err)
-					conn.WriteJSON(map[string]interface{}{
+					h.writeClient(conn, map[string]interface{}{
 						"type": "error",
 						"error": "audio_send_failed",
 						"message": "音频发送失败",
@@ -230,15 +384,67 @@ func (h *RealtimeHandler) handleGPTMode(conn *websocket.Conn, gptConn *websocket
 		case "commit_audio":
 			// 提交音频缓冲区到GPT API
 			log.Printf("Committing audio buffer for user: %s", userID)
-			err = h.realtimeService.CommitAudioBuffer(gptConn)
+			if flushErr := h.realtimeService.FlushSessionAudio(session); flushErr != nil {
+				log.Printf("Failed to flush buffered audio: %v", flushErr)
+			}
+			err = h.realtimeService.CommitAudioBuffer(session.Upstream())
 			if err != nil {
 				log.Printf("Failed to commit audio buffer: %v", err)
-				conn.WriteJSON(map[string]interface{}{
+				h.writeClient(conn, map[string]interface{}{
 					"type": "error",
 					"error": "audio_commit_failed",
 					"message": "音频提交失败",
 					"timestamp": time.Now().Unix(),
 				})
+				break
+			}
+
+			// 服务器 VAD 开启时上游会自动回复；关闭时由客户端提交触发，并可指定本轮输出方式
+			if session.Config().VAD.Enabled {
+				break
+			}
+			modalities, modErr := h.realtimeService.ResolveResponseModalities(session, msg.Modalities)
+			if modErr != nil {
+				err = h.writeSessionConfigError(conn, modErr)
+				break
+			}
+			if respErr := h.realtimeService.RequestResponse(session, modalities); respErr != nil {
+				log.Printf("Failed to request response for user %s: %v", userID, respErr)
+			}
+
+		case "text_input":
+			// 文字输入，适用于不方便说话的场合
+			textErr := h.realtimeService.SendTextInput(session, msg.Text, msg.Modalities)
+			switch {
+			case textErr == nil:
+			case errors.Is(textErr, service.ErrInvalidTextInput):
+				err = h.writeClient(conn, map[string]interface{}{
+					"type": "error",
+					"error": "invalid_text_input",
+					"message": "文字内容为空或过长",
+					"details": textErr.Error(),
+					"timestamp": time.Now().Unix(),
+				})
+			case errors.Is(textErr, service.ErrInvalidSessionConfig):
+				err = h.writeSessionConfigError(conn, textErr)
+			default:
+				log.Printf("Failed to send text input for user %s: %v", userID, textErr)
+				err = h.writeClient(conn, map[string]interface{}{
+					"type": "error",
+					"error": "text_send_failed",
+					"message": "文字发送失败",
+					"timestamp": time.Now().Unix(),
+				})
+			}
+			
+		case "interrupt":
+			// 客户端打断助手，audio_end_ms 为客户端实际播放到的位置
+			playedMs := -1
+			if msg.AudioEndMs != nil && *msg.AudioEndMs >= 0 {
+				playedMs = *msg.AudioEndMs
+			}
+			if interruptErr := h.realtimeService.InterruptResponse(session, service.InterruptReasonClient, playedMs); interruptErr != nil {
+				log.Printf("Failed to interrupt response for user %s: %v", userID, interruptErr)
 			}
 			
 		case "stop_listening":
@@ -250,7 +456,7 @@ func (h *RealtimeHandler) handleGPTMode(conn *websocket.Conn, gptConn *websocket
 				"type": "input_audio_buffer.clear",
 			}
 			
-			if err := gptConn.WriteJSON(stopMessage); err != nil {
+			if err := h.realtimeService.WriteJSON(session.Upstream(), stopMessage, 5*time.Second); err != nil {
 				log.Printf("Failed to send clear buffer signal to GPT API: %v", err)
 			} else {
 				log.Printf("Sent clear buffer signal to GPT API for user: %s", userID)
@@ -262,17 +468,17 @@ func (h *RealtimeHandler) handleGPTMode(conn *websocket.Conn, gptConn *websocket
 				"timestamp": time.Now().Unix(),
 				"message": "停止监听信号已处理",
 			}
-			err = conn.WriteJSON(response)
+			err = h.writeClient(conn, response)
 			
 		case "test":
 			// 测试消息 - 回显
 			response := map[string]interface{}{
 				"type": "echo",
-				"original": msg,
+				"original": json.RawMessage(data),
 				"timestamp": time.Now().Unix(),
 				"message": "消息已收到并回显",
 			}
-			err = conn.WriteJSON(response)
+			err = h.writeClient(conn, response)
 			
 		default:
 			// 未知消息类型
@@ -283,7 +489,7 @@ func (h *RealtimeHandler) handleGPTMode(conn *websocket.Conn, gptConn *websocket
 				"timestamp": time.Now().Unix(),
 				"message": "未知的消息类型",
 			}
-			err = conn.WriteJSON(response)
+			err = h.writeClient(conn, response)
 		}
 		
 		if err != nil {
@@ -295,6 +501,149 @@ func (h *RealtimeHandler) handleGPTMode(conn *websocket.Conn, gptConn *websocket
 	log.Printf("WebSocket connection closed for user: %s", userID)
 }
 
+// handleAudioFrame 处理二进制音频帧，未协商二进制帧或帧格式错误时回复错误
+func (h *RealtimeHandler) handleAudioFrame(conn *websocket.Conn, session *service.RealtimeSession, data []byte) error {
+	if !session.BinaryAudio() {
+		return h.writeClient(conn, map[string]interface{}{
+			"type": "error",
+			"error": "binary_not_negotiated",
+			"message": "未协商二进制音频帧",
+			"details": fmt.Sprintf("request the %s subprotocol when connecting", service.AudioFrameSubprotocol),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	frame, err := service.ParseAudioFrame(data)
+	if err == nil && frame.Type != service.AudioFrameTypeInput {
+		err = fmt.Errorf("%w: clients may only send input frames", service.ErrInvalidAudioFrame)
+	}
+	if err != nil {
+		return h.writeClient(conn, map[string]interface{}{
+			"type": "error",
+			"error": "invalid_audio_frame",
+			"message": "音频帧格式错误",
+			"details": err.Error(),
+			"timestamp": time.Now().Unix(),
+		})
+	}
+
+	// 上游重连期间丢弃音频，客户端已收到 reconnecting 通知
+	if session.Reconnecting() {
+		return nil
+	}
+	if err := h.realtimeService.SendSessionAudioFrame(session, frame); err != nil {
+		log.Printf("Failed to send audio frame to GPT API: %v", err)
+		return h.writeClient(conn, map[string]interface{}{
+			"type": "error",
+			"error": "audio_send_failed",
+			"message": "音频发送失败",
+			"timestamp": time.Now().Unix(),
+		})
+	}
+	return nil
+}
+
+// clientWriteTimeout 写客户端消息的超时
+const clientWriteTimeout = 2 * time.Second
+
+// writeClient 经由服务的写锁发送，避免与 GPT 响应转发协程并发写同一连接
+func (h *RealtimeHandler) writeClient(conn *websocket.Conn, v interface{}) error {
+	return h.realtimeService.WriteJSON(conn, v, clientWriteTimeout)
+}
+
+// writeSessionConfigError 通知客户端会话配置被拒绝，原配置保持不变
+func (h *RealtimeHandler) writeSessionConfigError(conn *websocket.Conn, err error) error {
+	return h.writeClient(conn, map[string]interface{}{
+		"type": "error",
+		"error": "invalid_session_config",
+		"message": "会话配置无效",
+		"details": err.Error(),
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// ListSessions 列出当前用户的实时会话
+func (h *RealtimeHandler) ListSessions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	sessions, err := h.realtimeService.ListSessions(userUUID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": sessions})
+}
+
+// GetUsage 返回当前用户当日和当月的实时对话用量及配额
+func (h *RealtimeHandler) GetUsage(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": h.realtimeService.GetRealtimeUsage(userUUID)})
+}
+
+// GetSession 返回一次实时会话的完整转写
+func (h *RealtimeHandler) GetSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid session ID"})
+		return
+	}
+
+	session, err := h.realtimeService.GetSessionTranscript(userUUID, sessionID)
+	if errors.Is(err, repository.ErrRealtimeSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
 // HandleRealtimeConnectionSimple 别名，保持兼容性
 func (h *RealtimeHandler) HandleRealtimeConnectionSimple(c *gin.Context) {
 	h.HandleRealtimeConnection(c)
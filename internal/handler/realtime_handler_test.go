@@ -3,14 +3,18 @@ package handler
 import (
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"smart-glasses-backend/internal/realtimefake"
 	"smart-glasses-backend/internal/service"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRealtimeHandler_ValidateConnection(t *testing.T) {
@@ -150,4 +154,124 @@ func TestRealtimeHandler_BufferConfiguration(t *testing.T) {
 		assert.Equal(t, 4096, handler.upgrader.ReadBufferSize, "Read buffer should be 4KB for audio optimization")
 		assert.Equal(t, 4096, handler.upgrader.WriteBufferSize, "Write buffer should be 4KB for audio optimization")
 	})
-}
\ No newline at end of file
+}
+
+// newFakeRealtimeServer 启动连接模拟上游的实时聊天服务，返回服务和 WebSocket 地址
+func newFakeRealtimeServer(t *testing.T) (*service.RealtimeService, string) {
+	t.Helper()
+
+	scenario, err := realtimefake.LoadScenario(filepath.Join("..", "realtimefake", "testdata", "greeting.yaml"))
+	require.NoError(t, err)
+	upstream := httptest.NewServer(realtimefake.NewServer(scenario))
+	t.Cleanup(upstream.Close)
+
+	realtimeService := service.NewRealtimeService("test-key", upstream.URL, "test-deployment", "2024-10-01-preview")
+	handler := NewRealtimeHandler(realtimeService)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "0b8f5c1e-8d3a-4f7b-9a61-2c4e5d6f7a8b")
+		c.Set("user_email", "test@example.com")
+		c.Next()
+	})
+	router.GET("/realtime/chat", handler.HandleRealtimeConnection)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return realtimeService, "ws" + strings.TrimPrefix(server.URL, "http") + "/realtime/chat"
+}
+
+func TestRealtimeHandler_SessionLifecycle(t *testing.T) {
+	realtimeService, wsURL := newFakeRealtimeServer(t)
+	monitor := realtimeService.GetSecurityMonitor()
+
+	header := http.Header{}
+	header.Set("User-Agent", "SmartGlasses/1.0")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var established map[string]interface{}
+	require.NoError(t, conn.ReadJSON(&established))
+	assert.Equal(t, "connection_established", established["type"])
+	sessionID, _ := established["session_id"].(string)
+	_, err = uuid.Parse(sessionID)
+	require.NoError(t, err, "session_id should be a UUID")
+
+	// 会话已登记到安全监控，连接和性能指标以同一 ID 为键
+	active := monitor.GetActiveSessions()
+	require.Len(t, active, 1)
+	assert.Equal(t, sessionID, active[0].ID)
+	assert.Equal(t, "SmartGlasses/1.0", active[0].UserAgent)
+	assert.NotNil(t, monitor.GetConnectionQuality(sessionID))
+	assert.NotNil(t, realtimeService.GetPerformanceMonitor().GetWebSocketMetrics(sessionID))
+
+	require.NoError(t, conn.WriteJSON(map[string]string{"type": "test"}))
+	for {
+		var msg map[string]interface{}
+		require.NoError(t, conn.ReadJSON(&msg))
+		if msg["type"] == "echo" {
+			break
+		}
+	}
+	conn.Close()
+
+	// 断开后会话结束
+	assert.Eventually(t, func() bool {
+		return len(monitor.GetActiveSessions()) == 0
+	}, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, 1, monitor.GetSessionStats()["completed_sessions"])
+}
+
+func TestRealtimeHandler_MaxSessions(t *testing.T) {
+	realtimeService, wsURL := newFakeRealtimeServer(t)
+	realtimeService.GetSecurityMonitor().SetMaxSessions(0)
+
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+}
+
+func TestRealtimeHandler_BinaryAudioFrames(t *testing.T) {
+	_, wsURL := newFakeRealtimeServer(t)
+
+	readError := func(conn *websocket.Conn) map[string]interface{} {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for {
+			var msg map[string]interface{}
+			require.NoError(t, conn.ReadJSON(&msg))
+			if msg["type"] == "error" {
+				return msg
+			}
+		}
+	}
+
+	// 未协商子协议时拒绝二进制帧
+	plain, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer plain.Close()
+	var established map[string]interface{}
+	require.NoError(t, plain.ReadJSON(&established))
+	assert.Equal(t, "json", established["audio_framing"])
+	frame := service.EncodeAudioFrame(&service.AudioFrame{Type: service.AudioFrameTypeInput, Payload: make([]byte, 480)})
+	require.NoError(t, plain.WriteMessage(websocket.BinaryMessage, frame))
+	assert.Equal(t, "binary_not_negotiated", readError(plain)["error"])
+
+	dialer := websocket.Dialer{Subprotocols: []string{service.AudioFrameSubprotocol}}
+	conn, _, err := dialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, service.AudioFrameSubprotocol, conn.Subprotocol())
+	require.NoError(t, conn.ReadJSON(&established))
+	assert.Equal(t, "binary", established["audio_framing"])
+
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, []byte{1, 2, 3}))
+	assert.Equal(t, "invalid_audio_frame", readError(conn)["error"])
+
+	// 合法帧不产生错误：随后的未知消息是第一个错误
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, frame))
+	require.NoError(t, conn.WriteJSON(map[string]string{"type": "bogus"}))
+	assert.Equal(t, "unknown_message_type", readError(conn)["error"])
+}
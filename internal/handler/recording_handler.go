@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"smart-glasses-backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RecordingHandler 管理用户的录音同意，列出和下载会话录音
+type RecordingHandler struct {
+	recordingService *service.RecordingService // 服务端未开启录音时为 nil
+}
+
+func NewRecordingHandler(recordingService *service.RecordingService) *RecordingHandler {
+	return &RecordingHandler{recordingService: recordingService}
+}
+
+// currentUser 解析当前用户，服务端未开启录音或用户无效时写出错误响应
+func (h *RecordingHandler) currentUser(c *gin.Context) (uuid.UUID, bool) {
+	if h.recordingService == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "recording is not enabled"})
+		return uuid.Nil, false
+	}
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return uuid.Nil, false
+	}
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return uuid.Nil, false
+	}
+	return userUUID, true
+}
+
+// GetConsent 返回当前用户是否同意录音
+func (h *RecordingHandler) GetConsent(c *gin.Context) {
+	userUUID, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	consent, err := h.recordingService.GetConsent(userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	data := gin.H{
+		"consented":      consent != nil,
+		"retention_days": int(h.recordingService.Retention().Hours() / 24),
+	}
+	if consent != nil {
+		data["consented_at"] = consent.ConsentedAt
+	}
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// GrantConsent 同意录制之后开启了 recording 的实时会话
+func (h *RecordingHandler) GrantConsent(c *gin.Context) {
+	userUUID, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	consent, err := h.recordingService.GrantConsent(userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"consented":      true,
+		"consented_at":   consent.ConsentedAt,
+		"retention_days": int(h.recordingService.Retention().Hours() / 24),
+	}})
+}
+
+// RevokeConsent 撤回同意并删除已保存的录音
+func (h *RecordingHandler) RevokeConsent(c *gin.Context) {
+	userUUID, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.recordingService.RevokeConsent(userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"consented":          false,
+		"deleted_recordings": deleted,
+	}})
+}
+
+// ListRecordings 列出当前用户保存的会话录音
+func (h *RecordingHandler) ListRecordings(c *gin.Context) {
+	userUUID, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	recordings, err := h.recordingService.ListRecordings(userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": recordings})
+}
+
+// DownloadRecording 下载当前用户的一个录音文件（解密后）
+func (h *RecordingHandler) DownloadRecording(c *gin.Context) {
+	userUUID, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	h.download(c, userUUID)
+}
+
+// AdminDownloadRecording 管理员下载指定用户的录音文件，用于处理用户的问题反馈
+func (h *RecordingHandler) AdminDownloadRecording(c *gin.Context) {
+	if _, ok := h.currentUser(c); !ok {
+		return
+	}
+	userUUID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+	adminID, _ := c.Get("user_id")
+	log.Printf("Admin %v downloading recording %s/%s of user %s", adminID, c.Param("sessionId"), c.Param("file"), userUUID)
+	h.download(c, userUUID)
+}
+
+func (h *RecordingHandler) download(c *gin.Context, userUUID uuid.UUID) {
+	sessionID, err := uuid.Parse(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid session ID"})
+		return
+	}
+	name := c.Param("file")
+
+	body, err := h.recordingService.OpenRecording(userUUID, sessionID, name)
+	if errors.Is(err, service.ErrRecordingNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer body.Close()
+
+	// 先解密第一段，密钥不匹配或文件损坏时还能返回错误
+	reader := bufio.NewReaderSize(body, 64*1024)
+	if _, err := reader.Peek(1); err != nil && err != io.EOF {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	contentType := "application/octet-stream"
+	switch path.Ext(name) {
+	case ".wav":
+		contentType = "audio/wav"
+	case ".opus":
+		contentType = "audio/ogg"
+	case ".json":
+		contentType = "application/json"
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	c.Header("Content-Type", contentType)
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, reader); err != nil {
+		log.Printf("Failed to send recording %s/%s: %v", sessionID, name, err)
+	}
+}
@@ -1,10 +1,17 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"smart-glasses-backend/internal/model"
 	"smart-glasses-backend/internal/service"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -44,7 +51,11 @@ func (h *TranslateHandler) TranslateText(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.translateService.TranslateText(userUUID, &req)
+	resp, err := h.translateService.TranslateText(c.Request.Context(), userUUID, &req)
+	if errors.Is(err, service.ErrUnsupportedLanguage) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -60,12 +71,27 @@ func (h *TranslateHandler) GetHistory(c *gin.Context) {
 		return
 	}
 
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 
+	// 非法或非正数的 limit 回退到默认值，避免不带 LIMIT 返回全部历史
+	if limit < 1 {
+		limit = 20
+	}
 	if limit > 100 {
 		limit = 100
 	}
+	if offset < 0 {
+		offset = 0
+	}
+	filter.Limit = limit
+	filter.Offset = offset
 
 	userUUID, err := uuid.Parse(userID.(string))
 	if err != nil {
@@ -73,7 +99,11 @@ func (h *TranslateHandler) GetHistory(c *gin.Context) {
 		return
 	}
 
-	history, err := h.translateService.GetHistory(userUUID, limit, offset)
+	history, err := h.translateService.GetHistory(userUUID, filter)
+	if errors.Is(err, service.ErrUnsupportedLanguage) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -82,6 +112,102 @@ func (h *TranslateHandler) GetHistory(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": history})
 }
 
+// ExportHistory 以 CSV、JSON Lines 或 Anki 卡片格式流式导出翻译历史
+func (h *TranslateHandler) ExportHistory(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	format, err := service.ParseExportFormat(c.DefaultQuery("format", "csv"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 响应头发送前校验语言，之后就无法再返回错误状态码
+	if err := h.translateService.NormalizeHistoryFilter(filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 导出默认不限制条数，显式传入 limit 时才截断
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "0"))
+	filter.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	filename := fmt.Sprintf("translations-%s.%s", time.Now().Format("20060102"), format.FileExtension())
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	// 响应头已发送，导出中途出错只能记录日志并中断连接
+	if err := h.translateService.ExportHistory(c.Request.Context(), userUUID, filter, format, &flushWriter{w: c.Writer}); err != nil {
+		log.Printf("Failed to export translation history for user %s: %v", userUUID, err)
+		c.Abort()
+	}
+}
+
+// parseHistoryFilter 解析历史列表和导出共用的过滤参数
+func parseHistoryFilter(c *gin.Context) (*model.HistoryFilter, error) {
+	filter := &model.HistoryFilter{
+		SourceLanguage: c.Query("source_language"),
+		TargetLanguage: c.Query("target_language"),
+		Query:          c.Query("q"),
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, err := parseHistoryTime(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from: %v", err)
+		}
+		filter.From = &t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseHistoryTime(to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to: %v", err)
+		}
+		// 只给日期时包含当天
+		if len(to) == len("2006-01-02") {
+			t = t.AddDate(0, 0, 1)
+		}
+		filter.To = &t
+	}
+
+	return filter, nil
+}
+
+// parseHistoryTime 支持 RFC3339 时间和 YYYY-MM-DD 日期
+func parseHistoryTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// flushWriter 每次写入后立即刷新到客户端，用于流式响应
+type flushWriter struct {
+	w gin.ResponseWriter
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	fw.w.Flush()
+	return n, err
+}
+
 func (h *TranslateHandler) TranslateStream(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -127,13 +253,20 @@ func (h *TranslateHandler) TranslateStream(c *gin.Context) {
 				continue
 			}
 
+			sourceLanguage, targetLanguage, err = h.translateService.NormalizeLanguagePair(msg.SourceLanguage, msg.TargetLanguage)
+			if err != nil {
+				conn.WriteJSON(model.WebSocketMessage{
+					Type:  "error",
+					Error: err.Error(),
+				})
+				continue
+			}
+
 			currentText = msg.Text
-			sourceLanguage = msg.SourceLanguage
-			targetLanguage = msg.TargetLanguage
 			fullTranslatedText = ""
 
 			// Stream translation
-			err := h.translateService.TranslateStream(userUUID, msg.Text, msg.SourceLanguage, msg.TargetLanguage, func(chunk string) error {
+			err = h.translateService.TranslateStream(c.Request.Context(), userUUID, msg.Text, sourceLanguage, targetLanguage, func(chunk string) error {
 				fullTranslatedText += chunk
 				return conn.WriteJSON(model.WebSocketMessage{
 					Type:           "translation_chunk",
@@ -157,17 +290,7 @@ func (h *TranslateHandler) TranslateStream(c *gin.Context) {
 				IsComplete:     true,
 			})
 
-			// Save to history (async, don't block)
-			go func() {
-				translation := &model.Translation{
-					UserID:         userUUID,
-					SourceText:     currentText,
-					TranslatedText: fullTranslatedText,
-					SourceLanguage: sourceLanguage,
-					TargetLanguage: targetLanguage,
-				}
-				_ = h.translateService.SaveTranslation(translation)
-			}()
+			h.saveStreamedTranslation(userUUID, currentText, sourceLanguage, targetLanguage, fullTranslatedText)
 
 		default:
 			conn.WriteJSON(model.WebSocketMessage{
@@ -178,3 +301,130 @@ func (h *TranslateHandler) TranslateStream(c *gin.Context) {
 	}
 }
 
+// TranslateStreamSSE 通过 Server-Sent Events 流式翻译，供无法使用 WebSocket 的网络环境使用
+// 事件语义与 WebSocket 的 translation_chunk / translation_complete 消息一致
+func (h *TranslateHandler) TranslateStreamSSE(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req model.TranslateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.SourceLanguage, req.TargetLanguage, err = h.translateService.NormalizeLanguagePair(req.SourceLanguage, req.TargetLanguage)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 禁止 nginx 缓冲事件流
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	// 客户端断开时取消上游翻译请求
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	chunks := make(chan string, 16)
+	var streamErr error
+	go func() {
+		defer close(chunks)
+		streamErr = h.translateService.TranslateStream(ctx, userUUID, req.Text, req.SourceLanguage, req.TargetLanguage, func(chunk string) error {
+			select {
+			case chunks <- chunk:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	var fullTranslatedText strings.Builder
+	for streaming := true; streaming; {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				streaming = false
+				break
+			}
+			fullTranslatedText.WriteString(chunk)
+			writeSSEEvent(c.Writer, model.WebSocketMessage{
+				Type:           "translation_chunk",
+				TranslatedText: chunk,
+				IsComplete:     false,
+			})
+
+		case <-heartbeat.C:
+			// 注释行保持连接，防止代理因空闲断开
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+			c.Writer.Flush()
+
+		case <-ctx.Done():
+			log.Printf("SSE translation stream cancelled by client for user %s", userUUID)
+			return
+		}
+	}
+
+	if streamErr != nil {
+		writeSSEEvent(c.Writer, model.WebSocketMessage{
+			Type:  "error",
+			Error: streamErr.Error(),
+		})
+		return
+	}
+
+	writeSSEEvent(c.Writer, model.WebSocketMessage{
+		Type:           "translation_complete",
+		TranslatedText: fullTranslatedText.String(),
+		IsComplete:     true,
+	})
+
+	h.saveStreamedTranslation(userUUID, req.Text, req.SourceLanguage, req.TargetLanguage, fullTranslatedText.String())
+}
+
+// sseHeartbeatInterval SSE 心跳间隔，测试中缩短
+var sseHeartbeatInterval = 15 * time.Second
+
+// writeSSEEvent 以消息类型作为事件名写出一条 SSE 事件
+func writeSSEEvent(w gin.ResponseWriter, msg model.WebSocketMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Failed to marshal SSE event: %v", err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Type, data)
+	w.Flush()
+}
+
+// saveStreamedTranslation 保存流式翻译结果（异步，不阻塞响应），WebSocket 与 SSE 共用
+func (h *TranslateHandler) saveStreamedTranslation(userID uuid.UUID, sourceText, sourceLanguage, targetLanguage, translatedText string) {
+	go func() {
+		translation := &model.Translation{
+			UserID:         userID,
+			SourceText:     sourceText,
+			TranslatedText: translatedText,
+			SourceLanguage: sourceLanguage,
+			TargetLanguage: targetLanguage,
+		}
+		if err := h.translateService.SaveTranslation(translation); err != nil {
+			log.Printf("Failed to save streamed translation for user %s: %v", userID, err)
+		}
+	}()
+}
@@ -0,0 +1,266 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"smart-glasses-backend/internal/model"
+	"smart-glasses-backend/internal/repository"
+	"smart-glasses-backend/internal/service"
+	"smart-glasses-backend/pkg/azure"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHistoryDB 记录写入 translation_history 的行和历史查询的参数，token 用量直接忽略
+type fakeHistoryDB struct {
+	saved   chan []driver.Value
+	queries chan []driver.Value
+}
+
+func (f *fakeHistoryDB) Connect(context.Context) (driver.Conn, error) { return f, nil }
+func (f *fakeHistoryDB) Driver() driver.Driver                        { return nil }
+func (f *fakeHistoryDB) Prepare(query string) (driver.Stmt, error) {
+	return &fakeHistoryStmt{db: f, query: query}, nil
+}
+
+func (f *fakeHistoryDB) Close() error { return nil }
+func (f *fakeHistoryDB) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions not supported")
+}
+
+type fakeHistoryStmt struct {
+	db    *fakeHistoryDB
+	query string
+}
+
+func (s *fakeHistoryStmt) Close() error  { return nil }
+func (s *fakeHistoryStmt) NumInput() int { return -1 }
+func (s *fakeHistoryStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if strings.HasPrefix(s.query, "INSERT INTO translation_history") {
+		s.db.saved <- args
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeHistoryStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if !strings.Contains(s.query, "FROM translation_history") {
+		return nil, errors.New("query not supported: " + s.query)
+	}
+	s.db.queries <- append([]driver.Value{s.query}, args...)
+	return emptyRows{}, nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string {
+	return []string{"id", "user_id", "source_text", "translated_text", "source_language", "target_language", "created_at"}
+}
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+// streamUpstream 模拟 Azure OpenAI 流式接口，依次发送 chunks，每块之间等待 gap
+func streamUpstream(chunks []string, gap time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", chunk)
+			w.(http.Flusher).Flush()
+			time.Sleep(gap)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}
+}
+
+// newTranslateTestServer 启动翻译接口，上游由 upstream 模拟，返回服务地址和记录历史写入的数据库
+func newTranslateTestServer(t *testing.T, upstream http.Handler) (*httptest.Server, *fakeHistoryDB) {
+	t.Helper()
+	azureServer := httptest.NewServer(upstream)
+	t.Cleanup(azureServer.Close)
+
+	fake := &fakeHistoryDB{saved: make(chan []driver.Value, 4), queries: make(chan []driver.Value, 4)}
+	db := sql.OpenDB(fake)
+	t.Cleanup(func() { db.Close() })
+	translateService := service.NewTranslateService(
+		azure.NewOpenAIClient(azureServer.URL, "key", "gpt-4", "2024-10-21"),
+		repository.NewTranslateRepository(db),
+		repository.NewTokenRepository(db),
+		nil,
+		service.NewLanguageRegistry(),
+	)
+	handler := NewTranslateHandler(translateService)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", uuid.New().String())
+		c.Next()
+	})
+	router.POST("/translate/stream", handler.TranslateStreamSSE)
+	router.GET("/translate/stream", handler.TranslateStream)
+	router.GET("/translate/history", handler.GetHistory)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, fake
+}
+
+func postSSE(ctx context.Context, t *testing.T, server *httptest.Server) *http.Response {
+	t.Helper()
+	body := `{"text":"Hello, world","source_language":"en","target_language":"zh-CN"}`
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/translate/stream", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func waitSaved(t *testing.T, fake *fakeHistoryDB) []driver.Value {
+	t.Helper()
+	select {
+	case row := <-fake.saved:
+		return row
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the translation to be saved to history")
+		return nil
+	}
+}
+
+func TestTranslateStreamSSE_Events(t *testing.T) {
+	server, fake := newTranslateTestServer(t, streamUpstream([]string{"你好", "，", "世界"}, 0))
+
+	resp := postSSE(context.Background(), t, server)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	expected := `event: translation_chunk
+data: {"type":"translation_chunk","translated_text":"你好"}
+
+event: translation_chunk
+data: {"type":"translation_chunk","translated_text":"，"}
+
+event: translation_chunk
+data: {"type":"translation_chunk","translated_text":"世界"}
+
+event: translation_complete
+data: {"type":"translation_complete","translated_text":"你好，世界","is_complete":true}
+
+`
+	assert.Equal(t, expected, string(body))
+
+	// 与 WebSocket 接口保存相同的历史记录：id, user_id, source_text, translated_text, source_language, target_language
+	sseRow := waitSaved(t, fake)
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/translate/stream"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.WriteJSON(model.WebSocketMessage{Type: "translate", Text: "Hello, world", SourceLanguage: "en", TargetLanguage: "zh-CN"}))
+	for {
+		var msg model.WebSocketMessage
+		require.NoError(t, conn.ReadJSON(&msg))
+		if msg.Type == "translation_complete" {
+			break
+		}
+	}
+	wsRow := waitSaved(t, fake)
+
+	assert.Equal(t, wsRow[2:6], sseRow[2:6])
+	assert.Equal(t, []driver.Value{"Hello, world", "你好，世界", "en", "zh-Hans"}, sseRow[2:6])
+}
+
+func TestTranslateStreamSSE_Heartbeat(t *testing.T) {
+	interval := sseHeartbeatInterval
+	sseHeartbeatInterval = 20 * time.Millisecond
+	t.Cleanup(func() { sseHeartbeatInterval = interval })
+
+	// 上游两块之间的空闲期间发送注释行心跳
+	server, _ := newTranslateTestServer(t, streamUpstream([]string{"你好", "世界"}, 100*time.Millisecond))
+	resp := postSSE(context.Background(), t, server)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(body), "\n\n: heartbeat\n\n")
+	assert.True(t, strings.HasSuffix(string(body), "event: translation_complete\ndata: {\"type\":\"translation_complete\",\"translated_text\":\"你好世界\",\"is_complete\":true}\n\n"),
+		"unexpected body %q", body)
+}
+
+func TestTranslateStreamSSE_ClientCancel(t *testing.T) {
+	upstreamStopped := make(chan struct{})
+	server, fake := newTranslateTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"你好\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(upstreamStopped)
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resp := postSSE(ctx, t, server)
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "event: translation_chunk\n", line)
+	cancel()
+
+	// 客户端断开后上游请求随之取消
+	select {
+	case <-upstreamStopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the upstream stream to be cancelled")
+	}
+
+	// 与 WebSocket 接口一致，未完成的翻译不写入历史
+	select {
+	case row := <-fake.saved:
+		t.Errorf("Expected no history for a cancelled stream, got %v", row)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestGetHistory_LimitIsAlwaysApplied(t *testing.T) {
+	server, fake := newTranslateTestServer(t, streamUpstream(nil, 0))
+
+	tests := []struct {
+		query string
+		limit int64
+	}{
+		{"", 20},
+		{"?limit=0", 20},
+		{"?limit=-5", 20},
+		{"?limit=abc", 20},
+		{"?limit=50", 50},
+		{"?limit=1000", 100},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(server.URL + "/translate/history" + tt.query)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, tt.query)
+
+		query := <-fake.queries
+		assert.Contains(t, query[0], "LIMIT", tt.query)
+		assert.Equal(t, tt.limit, query[len(query)-1], tt.query)
+	}
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 译文修正的处理状态
+const (
+	CorrectionStatusAccepted = "accepted"
+	CorrectionStatusRejected = "rejected"
+)
+
+type TranslationFeedback struct {
+	ID               uuid.UUID `json:"id" db:"id"`
+	TranslationID    uuid.UUID `json:"translation_id" db:"translation_id"`
+	UserID           uuid.UUID `json:"user_id" db:"user_id"`
+	Rating           int       `json:"rating" db:"rating"` // 1 为好评，-1 为差评
+	Reason           string    `json:"reason,omitempty" db:"reason"`
+	CorrectedText    string    `json:"corrected_text,omitempty" db:"corrected_text"`
+	CorrectionStatus string    `json:"correction_status,omitempty" db:"correction_status"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type FeedbackRequest struct {
+	Rating        string `json:"rating" binding:"required,oneof=up down"`
+	Reason        string `json:"reason" binding:"omitempty,oneof=inaccurate unnatural wrong_language incomplete offensive other"`
+	CorrectedText string `json:"corrected_text" binding:"max=5000"`
+}
+
+// TranslationMemoryEntry 用户翻译记忆条目，命中时直接返回而不调用模型
+type TranslationMemoryEntry struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	UserID         uuid.UUID  `json:"user_id" db:"user_id"`
+	SourceLanguage string     `json:"source_language" db:"source_language"`
+	TargetLanguage string     `json:"target_language" db:"target_language"`
+	SourceText     string     `json:"source_text" db:"source_text"`
+	TranslatedText string     `json:"translated_text" db:"translated_text"`
+	Origin         string     `json:"origin" db:"origin"`
+	TranslationID  *uuid.UUID `json:"translation_id,omitempty" db:"translation_id"` // 修正来源的翻译记录
+	HitCount       int        `json:"hit_count" db:"hit_count"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// FeedbackStat 按语言对聚合的反馈统计
+type FeedbackStat struct {
+	SourceLanguage      string  `json:"source_language"`
+	TargetLanguage      string  `json:"target_language"`
+	Total               int     `json:"total"`
+	ThumbsUp            int     `json:"thumbs_up"`
+	ThumbsDown          int     `json:"thumbs_down"`
+	Corrections         int     `json:"corrections"`
+	AcceptedCorrections int     `json:"accepted_corrections"`
+	ApprovalRate        float64 `json:"approval_rate"`
+	TopReason           string  `json:"top_reason,omitempty"`
+}
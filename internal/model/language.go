@@ -0,0 +1,23 @@
+package model
+
+// Language 语言注册表条目
+type Language struct {
+	Code       string            `json:"code"`        // BCP-47 规范代码
+	Name       string            `json:"name"`        // 按请求的界面语言显示的名称
+	NativeName string            `json:"native_name"` // 该语言自身的名称
+	Names      map[string]string `json:"names"`       // 各界面语言下的显示名称
+	Script     string            `json:"script"`      // ISO 15924 文字代码
+	RTL        bool              `json:"rtl"`         // 是否从右到左书写
+	Providers  []string          `json:"providers"`   // 支持该语言的服务能力
+	// DirectionDetection 双向传译判断说话语言的方式：words 可按用词与同一书写系统的其他语言区分，
+	// script 只能与书写系统不同的语言组成双向传译
+	DirectionDetection string `json:"direction_detection"`
+}
+
+type LanguageValidationResponse struct {
+	Input    string    `json:"input"`
+	Valid    bool      `json:"valid"`
+	Code     string    `json:"code,omitempty"`
+	Language *Language `json:"language,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 实时对话转写的说话方
+const (
+	RealtimeRoleUser      = "user"
+	RealtimeRoleAssistant = "assistant"
+)
+
+// RealtimeConversation 一次实时语音会话（realtime_sessions 表）
+type RealtimeConversation struct {
+	ID             uuid.UUID       `json:"id" db:"id"`
+	UserID         uuid.UUID       `json:"user_id" db:"user_id"`
+	Mode           string          `json:"mode" db:"mode"`
+	SourceLanguage string          `json:"source_language,omitempty" db:"source_language"`
+	TargetLanguage string          `json:"target_language,omitempty" db:"target_language"`
+	TurnCount      int             `json:"turn_count" db:"turn_count"`
+	StartedAt      time.Time       `json:"started_at" db:"started_at"`
+	EndedAt        *time.Time      `json:"ended_at,omitempty" db:"ended_at"`
+	Turns          []*RealtimeTurn `json:"turns,omitempty"`
+	Usage          *RealtimeUsage  `json:"usage,omitempty"`
+}
+
+// RealtimeTurn 会话中的一条转写
+type RealtimeTurn struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	SessionID uuid.UUID `json:"session_id" db:"session_id"`
+	Seq       int       `json:"seq" db:"seq"`
+	Role      string    `json:"role" db:"role"`
+	ItemID    string    `json:"item_id,omitempty" db:"item_id"`
+	Text      string    `json:"text" db:"text"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// RealtimeUsage 实时对话用量，语音按秒计，token 按文本和语音分开计
+type RealtimeUsage struct {
+	AudioInSeconds    float64 `json:"audio_in_seconds" db:"audio_in_seconds"`
+	AudioOutSeconds   float64 `json:"audio_out_seconds" db:"audio_out_seconds"`
+	InputTextTokens   int     `json:"input_text_tokens" db:"input_text_tokens"`
+	InputAudioTokens  int     `json:"input_audio_tokens" db:"input_audio_tokens"`
+	OutputTextTokens  int     `json:"output_text_tokens" db:"output_text_tokens"`
+	OutputAudioTokens int     `json:"output_audio_tokens" db:"output_audio_tokens"`
+}
+
+// Add 累加另一段用量
+func (u *RealtimeUsage) Add(other RealtimeUsage) {
+	u.AudioInSeconds += other.AudioInSeconds
+	u.AudioOutSeconds += other.AudioOutSeconds
+	u.InputTextTokens += other.InputTextTokens
+	u.InputAudioTokens += other.InputAudioTokens
+	u.OutputTextTokens += other.OutputTextTokens
+	u.OutputAudioTokens += other.OutputAudioTokens
+}
+
+// AudioSeconds 上行和下行语音的总时长
+func (u RealtimeUsage) AudioSeconds() float64 {
+	return u.AudioInSeconds + u.AudioOutSeconds
+}
+
+// TotalTokens 输入和输出 token 总数
+func (u RealtimeUsage) TotalTokens() int {
+	return u.InputTextTokens + u.InputAudioTokens + u.OutputTextTokens + u.OutputAudioTokens
+}
+
+// RealtimeUsageRecord 一次响应的用量（realtime_usage 表）
+type RealtimeUsageRecord struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	SessionID  uuid.UUID `json:"session_id" db:"session_id"`
+	UserID     uuid.UUID `json:"user_id" db:"user_id"`
+	ResponseID string    `json:"response_id,omitempty" db:"response_id"`
+	RealtimeUsage
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,13 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RecordingConsent 用户同意录制实时会话（recording_consents 表），撤回时删除
+type RecordingConsent struct {
+	UserID      uuid.UUID `json:"user_id" db:"user_id"`
+	ConsentedAt time.Time `json:"consented_at" db:"consented_at"`
+}
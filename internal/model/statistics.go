@@ -12,9 +12,10 @@ type UserStat struct {
 }
 
 type TokenUsage struct {
-	Date         string `json:"date"`
-	InputTokens  int    `json:"input_tokens"`
-	OutputTokens int    `json:"output_tokens"`
+	Date            string `json:"date"`
+	InputTokens     int    `json:"input_tokens"`
+	OutputTokens    int    `json:"output_tokens"`
+	EstimatedTokens int    `json:"estimated_tokens"` // 其中本地估算（非上游实测）的 token 数
 }
 
 type StatisticsResponse struct {
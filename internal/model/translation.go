@@ -37,6 +37,17 @@ type TranslationHistoryResponse struct {
 	CreatedAt       time.Time `json:"created_at"`
 }
 
+// HistoryFilter 翻译历史查询条件，历史列表和导出共用
+type HistoryFilter struct {
+	SourceLanguage string
+	TargetLanguage string
+	Query          string     // 原文或译文包含的关键字
+	From           *time.Time // 起始时间（含）
+	To             *time.Time // 截止时间（不含）
+	Limit          int        // 0 表示不限制条数
+	Offset         int
+}
+
 type WebSocketMessage struct {
 	Type           string `json:"type"`
 	Text           string `json:"text,omitempty"`
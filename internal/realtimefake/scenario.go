@@ -0,0 +1,111 @@
+// Package realtimefake 模拟 GPT Realtime API 上游，按脚本回放事件，用于本地开发和测试
+package realtimefake
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario 一段模拟会话的脚本
+// 步骤按顺序执行，断线重连后从断开处继续，因此可以脚本化重连场景
+type Scenario struct {
+	Name string `yaml:"name"`
+	// DefaultReply 脚本中没有匹配步骤时对 response.create 的回复，为空时使用内置文本
+	DefaultReply string `yaml:"default_reply"`
+	Steps        []Step `yaml:"steps"`
+}
+
+// Step 一个脚本步骤，On 为空的步骤紧接上一步（或连接建立时）执行
+// 动作按 DelayMs、Transcript、Events、Response、Error、Disconnect 的顺序执行
+type Step struct {
+	On         string                   `yaml:"on"`         // 等待的客户端事件类型
+	DelayMs    int                      `yaml:"delay_ms"`   // 执行前等待
+	Transcript string                   `yaml:"transcript"` // 最近提交的语音的用户转写
+	Events     []map[string]interface{} `yaml:"events"`     // 原样发送的事件
+	Response   *ResponseScript          `yaml:"response"`   // 一次完整的助手响应
+	Error      *ErrorScript             `yaml:"error"`
+	Disconnect bool                     `yaml:"disconnect"` // 直接断开连接，模拟上游掉线
+}
+
+// ResponseScript 展开为 response.created 到 response.done 的完整事件序列
+type ResponseScript struct {
+	Text         string              `yaml:"text"`
+	Audio        bool                `yaml:"audio"`          // 同时输出语音，Text 作为语音转写
+	AudioMs      int                 `yaml:"audio_ms"`       // 语音时长，默认按文本长度估算
+	Chunks       int                 `yaml:"chunks"`         // 拆分成的增量数，默认 1
+	ChunkDelayMs int                 `yaml:"chunk_delay_ms"` // 增量之间的间隔，期间可被 response.cancel 打断
+	FunctionCall *FunctionCallScript `yaml:"function_call"`
+}
+
+// FunctionCallScript 模型发起的一次工具调用
+type FunctionCallScript struct {
+	Name      string `yaml:"name"`
+	Arguments string `yaml:"arguments"`
+}
+
+// ErrorScript 上游 error 事件
+type ErrorScript struct {
+	Type    string `yaml:"type"`
+	Code    string `yaml:"code"`
+	Message string `yaml:"message"`
+}
+
+// LoadScenario 从 YAML（或 JSON）文件加载脚本
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	scenario, err := ParseScenario(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return scenario, nil
+}
+
+// ParseScenario 解析脚本，未知字段视为错误以便发现拼写问题
+func ParseScenario(data []byte) (*Scenario, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	scenario := &Scenario{}
+	if err := decoder.Decode(scenario); err != nil {
+		return nil, fmt.Errorf("invalid scenario: %w", err)
+	}
+
+	for i, step := range scenario.Steps {
+		if err := step.validate(); err != nil {
+			return nil, fmt.Errorf("invalid scenario: step %d: %w", i+1, err)
+		}
+	}
+	return scenario, nil
+}
+
+func (s *Step) validate() error {
+	if s.DelayMs < 0 {
+		return fmt.Errorf("delay_ms must not be negative")
+	}
+	if s.Transcript == "" && len(s.Events) == 0 && s.Response == nil && s.Error == nil && !s.Disconnect && s.DelayMs == 0 {
+		return fmt.Errorf("step has no action")
+	}
+	for _, event := range s.Events {
+		if _, ok := event["type"].(string); !ok {
+			return fmt.Errorf("event without type")
+		}
+	}
+	if r := s.Response; r != nil {
+		if r.Text == "" && r.FunctionCall == nil {
+			return fmt.Errorf("response needs text or function_call")
+		}
+		if r.FunctionCall != nil && r.FunctionCall.Name == "" {
+			return fmt.Errorf("function_call needs a name")
+		}
+		if r.Chunks < 0 || r.ChunkDelayMs < 0 || r.AudioMs < 0 {
+			return fmt.Errorf("response chunks, chunk_delay_ms and audio_ms must not be negative")
+		}
+	}
+	return nil
+}
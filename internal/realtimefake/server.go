@@ -0,0 +1,562 @@
+package realtimefake
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+)
+
+// 上行和下行语音均为 24kHz 单声道 PCM16
+const outputBytesPerMs = 24000 * 2 / 1000
+
+const defaultReply = "这是模拟上游的回复。"
+
+// Server 模拟的 Realtime 上游，实现 http.Handler，可直接用于 httptest.NewServer
+// 除脚本事件外，会话、音频缓冲和会话项相关的确认事件按真实协议自动回复
+type Server struct {
+	// APIKey 非空时校验 api-key 请求头
+	APIKey string
+
+	scenario *Scenario
+	upgrader websocket.Upgrader
+
+	mu          sync.Mutex
+	cursor      int // 下一个待执行的步骤，跨连接共享
+	received    []map[string]interface{}
+	changed     chan struct{}
+	connections int
+	seq         int
+}
+
+// NewServer 创建模拟上游，scenario 为 nil 时只有自动回复和默认回答
+func NewServer(scenario *Scenario) *Server {
+	if scenario == nil {
+		scenario = &Scenario{}
+	}
+	return &Server{
+		scenario: scenario,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		changed: make(chan struct{}),
+	}
+}
+
+// ServeHTTP 接受任意路径上的 WebSocket 连接
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.APIKey != "" && r.Header.Get("api-key") != s.APIKey {
+		http.Error(w, "invalid api-key", http.StatusUnauthorized)
+		return
+	}
+
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.connections++
+	s.mu.Unlock()
+
+	c := &connection{
+		server:    s,
+		ws:        ws,
+		queue:     make(chan []Step, 16),
+		done:      make(chan struct{}),
+		cancelled: make(map[string]bool),
+	}
+	c.serve()
+}
+
+// Connections 已建立的连接数，包括已断开的
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connections
+}
+
+// Received 返回收到的指定类型的客户端事件，eventType 为空时返回全部
+func (s *Server) Received(eventType string) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []map[string]interface{}
+	for _, event := range s.received {
+		if eventType == "" || event["type"] == eventType {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// WaitFor 等待收到至少 count 个指定类型的客户端事件
+func (s *Server) WaitFor(eventType string, count int, timeout time.Duration) ([]map[string]interface{}, error) {
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		changed := s.changed
+		s.mu.Unlock()
+
+		if events := s.Received(eventType); len(events) >= count {
+			return events, nil
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return nil, fmt.Errorf("received %d %s events, want %d", len(s.Received(eventType)), eventType, count)
+		}
+	}
+}
+
+func (s *Server) record(event map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received = append(s.received, event)
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// nextSteps 取出由该事件触发的步骤及其后紧接的步骤；eventType 为空表示连接建立
+func (s *Server) nextSteps(eventType string) []Step {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	steps := s.scenario.Steps
+	if s.cursor >= len(steps) || steps[s.cursor].On != eventType {
+		return nil
+	}
+
+	start := s.cursor
+	s.cursor++
+	for s.cursor < len(steps) && steps[s.cursor].On == "" {
+		s.cursor++
+	}
+	return steps[start:s.cursor]
+}
+
+func (s *Server) nextID(prefix string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	return fmt.Sprintf("%s_%d", prefix, s.seq)
+}
+
+// connection 一个上游连接；读协程处理自动回复，脚本步骤在执行协程中按顺序执行
+type connection struct {
+	server *Server
+	ws     *websocket.Conn
+	queue  chan []Step
+	done   chan struct{}
+
+	writeMu sync.Mutex
+
+	mu             sync.Mutex
+	lastAudioItem  string
+	activeResponse string
+	cancelled      map[string]bool
+	inputAudio     int // 上次响应以来追加的语音字节数
+	inputText      int // 上次响应以来新增的文字输入字符数
+}
+
+func (c *connection) serve() {
+	defer c.ws.Close()
+	defer close(c.done)
+
+	go c.execute()
+
+	c.send(map[string]interface{}{
+		"type":    "session.created",
+		"session": map[string]interface{}{"id": c.server.nextID("sess")},
+	})
+	c.enqueue(c.server.nextSteps(""))
+
+	for {
+		var event map[string]interface{}
+		if err := c.ws.ReadJSON(&event); err != nil {
+			return
+		}
+		eventType, _ := event["type"].(string)
+		c.server.record(event)
+		c.handle(eventType, event)
+
+		if steps := c.server.nextSteps(eventType); steps != nil {
+			c.enqueue(steps)
+		} else if eventType == "response.create" {
+			c.enqueue([]Step{{Response: &ResponseScript{Text: c.server.defaultReply()}}})
+		}
+	}
+}
+
+func (s *Server) defaultReply() string {
+	if s.scenario.DefaultReply != "" {
+		return s.scenario.DefaultReply
+	}
+	return defaultReply
+}
+
+func (c *connection) enqueue(steps []Step) {
+	if len(steps) == 0 {
+		return
+	}
+	select {
+	case c.queue <- steps:
+	case <-c.done:
+	}
+}
+
+// handle 按协议自动回复确认事件
+func (c *connection) handle(eventType string, event map[string]interface{}) {
+	switch eventType {
+	case "session.update":
+		session, _ := event["session"].(map[string]interface{})
+		c.send(map[string]interface{}{"type": "session.updated", "session": session})
+
+	case "input_audio_buffer.commit":
+		itemID := c.server.nextID("item")
+		c.mu.Lock()
+		c.lastAudioItem = itemID
+		c.mu.Unlock()
+		c.send(map[string]interface{}{"type": "input_audio_buffer.committed", "item_id": itemID})
+		c.send(map[string]interface{}{
+			"type": "conversation.item.created",
+			"item": map[string]interface{}{
+				"id":      itemID,
+				"type":    "message",
+				"role":    "user",
+				"content": []map[string]interface{}{{"type": "input_audio"}},
+			},
+		})
+
+	case "input_audio_buffer.append":
+		audio, _ := event["audio"].(string)
+		c.mu.Lock()
+		c.inputAudio += base64.StdEncoding.DecodedLen(len(audio))
+		c.mu.Unlock()
+
+	case "input_audio_buffer.clear":
+		c.send(map[string]interface{}{"type": "input_audio_buffer.cleared"})
+
+	case "conversation.item.create":
+		item, _ := event["item"].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+		}
+		if _, ok := item["id"].(string); !ok {
+			item["id"] = c.server.nextID("item")
+		}
+		c.mu.Lock()
+		c.inputText += inputTextRunes(item)
+		c.mu.Unlock()
+		c.send(map[string]interface{}{"type": "conversation.item.created", "item": item})
+
+	case "conversation.item.truncate":
+		c.send(map[string]interface{}{
+			"type":          "conversation.item.truncated",
+			"item_id":       event["item_id"],
+			"content_index": event["content_index"],
+			"audio_end_ms":  event["audio_end_ms"],
+		})
+
+	case "response.cancel":
+		c.mu.Lock()
+		if c.activeResponse != "" {
+			c.cancelled[c.activeResponse] = true
+		}
+		c.mu.Unlock()
+	}
+}
+
+// execute 依次执行脚本步骤
+func (c *connection) execute() {
+	for {
+		select {
+		case steps := <-c.queue:
+			for _, step := range steps {
+				if !c.run(step) {
+					return
+				}
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// run 执行一个步骤，连接已断开时返回 false
+func (c *connection) run(step Step) bool {
+	if !c.sleep(step.DelayMs) {
+		return false
+	}
+
+	if step.Transcript != "" {
+		c.mu.Lock()
+		itemID := c.lastAudioItem
+		c.mu.Unlock()
+		c.send(map[string]interface{}{
+			"type":          "conversation.item.input_audio_transcription.completed",
+			"item_id":       itemID,
+			"content_index": 0,
+			"transcript":    step.Transcript,
+		})
+	}
+
+	for _, event := range step.Events {
+		c.send(event)
+	}
+
+	if step.Response != nil && !c.respond(step.Response) {
+		return false
+	}
+
+	if step.Error != nil {
+		errType := step.Error.Type
+		if errType == "" {
+			errType = "server_error"
+		}
+		c.send(map[string]interface{}{
+			"type": "error",
+			"error": map[string]interface{}{
+				"type":    errType,
+				"code":    step.Error.Code,
+				"message": step.Error.Message,
+			},
+		})
+	}
+
+	if step.Disconnect {
+		log.Printf("realtimefake: disconnecting as scripted")
+		c.ws.Close()
+		return false
+	}
+	return true
+}
+
+// respond 发送一次完整响应；被 response.cancel 打断时以 cancelled 状态结束
+func (c *connection) respond(script *ResponseScript) bool {
+	responseID := c.server.nextID("resp")
+	itemID := c.server.nextID("item")
+
+	c.mu.Lock()
+	c.activeResponse = responseID
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.activeResponse = ""
+		delete(c.cancelled, responseID)
+		c.mu.Unlock()
+	}()
+
+	c.send(map[string]interface{}{
+		"type":     "response.created",
+		"response": map[string]interface{}{"id": responseID, "status": "in_progress"},
+	})
+
+	if call := script.FunctionCall; call != nil {
+		arguments := call.Arguments
+		if arguments == "" {
+			arguments = "{}"
+		}
+		callID := c.server.nextID("call")
+		c.send(map[string]interface{}{
+			"type":        "response.function_call_arguments.done",
+			"response_id": responseID,
+			"item_id":     itemID,
+			"call_id":     callID,
+			"name":        call.Name,
+			"arguments":   arguments,
+		})
+		c.send(responseDone(responseID, "completed", []map[string]interface{}{{
+			"id":        itemID,
+			"type":      "function_call",
+			"call_id":   callID,
+			"name":      call.Name,
+			"arguments": arguments,
+		}}, c.usage(utf8.RuneCountInString(arguments), 0)))
+		return true
+	}
+
+	chunks := splitText(script.Text, script.Chunks)
+	var audio []string
+	if script.Audio {
+		audioMs := script.AudioMs
+		if audioMs == 0 {
+			audioMs = utf8.RuneCountInString(script.Text) * 120
+		}
+		audio = splitAudio(make([]byte, audioMs*outputBytesPerMs), len(chunks))
+	}
+
+	deltaType, doneType, doneField := "response.text.delta", "response.text.done", "text"
+	if script.Audio {
+		deltaType, doneType, doneField = "response.audio_transcript.delta", "response.audio_transcript.done", "transcript"
+	}
+
+	for i, chunk := range chunks {
+		if i > 0 && !c.sleep(script.ChunkDelayMs) {
+			return false
+		}
+		if c.isCancelled(responseID) {
+			c.send(responseDone(responseID, "cancelled", nil, c.usage(0, 0)))
+			return true
+		}
+		if script.Audio {
+			c.send(map[string]interface{}{
+				"type":        "response.audio.delta",
+				"response_id": responseID,
+				"item_id":     itemID,
+				"delta":       audio[i],
+			})
+		}
+		c.send(map[string]interface{}{
+			"type":        deltaType,
+			"response_id": responseID,
+			"item_id":     itemID,
+			"delta":       chunk,
+		})
+	}
+
+	if script.Audio {
+		c.send(map[string]interface{}{"type": "response.audio.done", "response_id": responseID, "item_id": itemID})
+	}
+	c.send(map[string]interface{}{
+		"type":        doneType,
+		"response_id": responseID,
+		"item_id":     itemID,
+		doneField:     script.Text,
+	})
+	outputText, outputAudio := utf8.RuneCountInString(script.Text), 0
+	if script.Audio {
+		outputText, outputAudio = 0, audioTokens(audio)
+	}
+	c.send(responseDone(responseID, "completed", []map[string]interface{}{{
+		"id":   itemID,
+		"type": "message",
+		"role": "assistant",
+	}}, c.usage(outputText, outputAudio)))
+	return true
+}
+
+func (c *connection) isCancelled(responseID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cancelled[responseID]
+}
+
+// sleep 等待指定毫秒，连接断开时返回 false
+func (c *connection) sleep(ms int) bool {
+	if ms <= 0 {
+		return true
+	}
+	select {
+	case <-time.After(time.Duration(ms) * time.Millisecond):
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+func (c *connection) send(event map[string]interface{}) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	c.ws.WriteJSON(event)
+}
+
+// responseDone 构造 response.done
+func responseDone(responseID, status string, output []map[string]interface{}, usage map[string]interface{}) map[string]interface{} {
+	if output == nil {
+		output = []map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"type": "response.done",
+		"response": map[string]interface{}{
+			"id":     responseID,
+			"status": status,
+			"output": output,
+			"usage":  usage,
+		},
+	}
+}
+
+// usage 构造 response.done 的用量并清零输入计数
+// token 数粗略估算：文本每字符一个，语音每 100ms 一个
+func (c *connection) usage(outputText, outputAudio int) map[string]interface{} {
+	c.mu.Lock()
+	inputText, inputAudio := c.inputText, c.inputAudio/outputBytesPerMs/100
+	c.inputText, c.inputAudio = 0, 0
+	c.mu.Unlock()
+
+	return map[string]interface{}{
+		"total_tokens":  inputText + inputAudio + outputText + outputAudio,
+		"input_tokens":  inputText + inputAudio,
+		"output_tokens": outputText + outputAudio,
+		"input_token_details": map[string]interface{}{
+			"text_tokens":   inputText,
+			"audio_tokens":  inputAudio,
+			"cached_tokens": 0,
+		},
+		"output_token_details": map[string]interface{}{
+			"text_tokens":  outputText,
+			"audio_tokens": outputAudio,
+		},
+	}
+}
+
+// audioTokens 估算 base64 语音分片的 token 数
+func audioTokens(chunks []string) int {
+	bytes := 0
+	for _, chunk := range chunks {
+		bytes += base64.StdEncoding.DecodedLen(len(chunk))
+	}
+	return bytes / outputBytesPerMs / 100
+}
+
+// inputTextRunes 统计会话项中文字输入的字符数
+func inputTextRunes(item map[string]interface{}) int {
+	if item["role"] != "user" {
+		return 0
+	}
+	content, _ := item["content"].([]interface{})
+	n := 0
+	for _, part := range content {
+		if p, ok := part.(map[string]interface{}); ok && p["type"] == "input_text" {
+			text, _ := p["text"].(string)
+			n += utf8.RuneCountInString(text)
+		}
+	}
+	return n
+}
+
+// splitText 按字符把文本均分为 n 段
+func splitText(text string, n int) []string {
+	runes := []rune(text)
+	if n <= 1 || len(runes) <= 1 {
+		return []string{text}
+	}
+	if n > len(runes) {
+		n = len(runes)
+	}
+
+	chunks := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		chunks = append(chunks, string(runes[i*len(runes)/n:(i+1)*len(runes)/n]))
+	}
+	return chunks
+}
+
+// splitAudio 把 PCM16 数据按采样对齐均分为 n 段并编码为 base64
+func splitAudio(pcm []byte, n int) []string {
+	samples := len(pcm) / 2
+	chunks := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		start, end := i*samples/n*2, (i+1)*samples/n*2
+		chunks = append(chunks, base64.StdEncoding.EncodeToString(pcm[start:end]))
+	}
+	return chunks
+}
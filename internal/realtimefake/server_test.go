@@ -0,0 +1,206 @@
+package realtimefake
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func startServer(t *testing.T, scenarioFile string) (*Server, *websocket.Conn) {
+	t.Helper()
+
+	var scenario *Scenario
+	if scenarioFile != "" {
+		var err error
+		if scenario, err = LoadScenario(filepath.Join("testdata", scenarioFile)); err != nil {
+			t.Fatalf("LoadScenario failed: %v", err)
+		}
+	}
+
+	fake := NewServer(scenario)
+	httpServer := httptest.NewServer(fake)
+	t.Cleanup(httpServer.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return fake, conn
+}
+
+// readUntil 读取上游事件直到出现指定类型，返回期间收到的全部事件
+func readUntil(t *testing.T, conn *websocket.Conn, eventType string) []map[string]interface{} {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var events []map[string]interface{}
+	for {
+		var event map[string]interface{}
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("Did not receive %s: %v", eventType, err)
+		}
+		events = append(events, event)
+		if event["type"] == eventType {
+			return events
+		}
+	}
+}
+
+func countType(events []map[string]interface{}, eventType string) int {
+	n := 0
+	for _, event := range events {
+		if event["type"] == eventType {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLoadScenario_Testdata(t *testing.T) {
+	files, _ := filepath.Glob(filepath.Join("testdata", "*.yaml"))
+	if len(files) == 0 {
+		t.Fatal("no scenario files found")
+	}
+	for _, file := range files {
+		if _, err := LoadScenario(file); err != nil {
+			t.Errorf("%v", err)
+		}
+	}
+}
+
+func TestParseScenario_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"unknown field":    "steps:\n  - on: response.create\n    respons:\n      text: hi\n",
+		"no action":        "steps:\n  - on: response.create\n",
+		"empty response":   "steps:\n  - response:\n      audio: true\n",
+		"event no type":    "steps:\n  - events:\n      - foo: bar\n",
+		"unnamed function": "steps:\n  - response:\n      function_call:\n        arguments: '{}'\n",
+	}
+	for name, data := range cases {
+		if _, err := ParseScenario([]byte(data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestServer_ScriptedTurn(t *testing.T) {
+	_, conn := startServer(t, "greeting.yaml")
+
+	readUntil(t, conn, "session.created")
+	conn.WriteJSON(map[string]interface{}{"type": "session.update", "session": map[string]interface{}{"voice": "alloy"}})
+	readUntil(t, conn, "session.updated")
+
+	conn.WriteJSON(map[string]interface{}{"type": "input_audio_buffer.commit"})
+	events := readUntil(t, conn, "response.done")
+
+	var transcript, itemID string
+	for _, event := range events {
+		switch event["type"] {
+		case "input_audio_buffer.committed":
+			itemID, _ = event["item_id"].(string)
+		case "conversation.item.input_audio_transcription.completed":
+			transcript, _ = event["transcript"].(string)
+			if event["item_id"] != itemID {
+				t.Errorf("Transcript item %v does not match committed item %s", event["item_id"], itemID)
+			}
+		case "response.audio_transcript.done":
+			if event["transcript"] != "你好！有什么可以帮你？" {
+				t.Errorf("Unexpected assistant transcript: %v", event["transcript"])
+			}
+		}
+	}
+	if transcript != "你好" {
+		t.Errorf("Expected user transcript, got %q", transcript)
+	}
+	if n := countType(events, "response.audio.delta"); n != 3 {
+		t.Errorf("Expected 3 audio deltas, got %d", n)
+	}
+	if n := countType(events, "response.audio_transcript.delta"); n != 3 {
+		t.Errorf("Expected 3 transcript deltas, got %d", n)
+	}
+}
+
+func TestServer_Cancel(t *testing.T) {
+	_, conn := startServer(t, "barge_in.yaml")
+
+	conn.WriteJSON(map[string]interface{}{"type": "response.create"})
+	readUntil(t, conn, "response.audio.delta")
+	conn.WriteJSON(map[string]interface{}{"type": "response.cancel"})
+
+	events := readUntil(t, conn, "response.done")
+	done := events[len(events)-1]["response"].(map[string]interface{})
+	if done["status"] != "cancelled" {
+		t.Errorf("Expected cancelled response, got %v", done["status"])
+	}
+	if n := countType(events, "response.audio.delta"); n >= 9 {
+		t.Errorf("Expected the response to stop early, got %d more audio deltas", n)
+	}
+}
+
+func TestServer_DefaultReplyAndWaitFor(t *testing.T) {
+	fake, conn := startServer(t, "")
+
+	conn.WriteJSON(map[string]interface{}{
+		"type": "conversation.item.create",
+		"item": map[string]interface{}{"type": "message", "role": "user"},
+	})
+	created := readUntil(t, conn, "conversation.item.created")
+	if item := created[len(created)-1]["item"].(map[string]interface{}); item["id"] == nil {
+		t.Error("Expected created item to be assigned an id")
+	}
+
+	conn.WriteJSON(map[string]interface{}{"type": "response.create"})
+	events := readUntil(t, conn, "response.text.done")
+	if text := events[len(events)-1]["text"]; text != defaultReply {
+		t.Errorf("Expected default reply, got %v", text)
+	}
+
+	if _, err := fake.WaitFor("response.create", 1, time.Second); err != nil {
+		t.Error(err)
+	}
+	if _, err := fake.WaitFor("response.create", 2, 50*time.Millisecond); err == nil {
+		t.Error("Expected WaitFor to time out")
+	}
+}
+
+func TestServer_Disconnect(t *testing.T) {
+	fake, conn := startServer(t, "reconnect.yaml")
+
+	conn.WriteJSON(map[string]interface{}{"type": "input_audio_buffer.commit"})
+	readUntil(t, conn, "response.done")
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var event map[string]interface{}
+		if err := conn.ReadJSON(&event); err != nil {
+			break
+		}
+	}
+	if fake.Connections() != 1 {
+		t.Errorf("Expected 1 connection, got %d", fake.Connections())
+	}
+}
+
+func TestServer_APIKey(t *testing.T) {
+	fake := NewServer(nil)
+	fake.APIKey = "secret"
+	httpServer := httptest.NewServer(fake)
+	defer httpServer.Close()
+
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http")
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without api-key, got %v", err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"api-key": []string{"secret"}})
+	if err != nil {
+		t.Fatalf("Expected connection with api-key: %v", err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"database/sql"
+	"smart-glasses-backend/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type FeedbackRepository struct {
+	db *sql.DB
+}
+
+func NewFeedbackRepository(db *sql.DB) *FeedbackRepository {
+	return &FeedbackRepository{db: db}
+}
+
+// Upsert 保存反馈，同一用户对同一条翻译重复提交时覆盖之前的反馈
+func (r *FeedbackRepository) Upsert(feedback *model.TranslationFeedback) error {
+	query := `INSERT INTO translation_feedback (id, translation_id, user_id, rating, reason, corrected_text, correction_status, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), $8, $8)
+			  ON CONFLICT (translation_id, user_id) DO UPDATE SET
+				rating = EXCLUDED.rating,
+				reason = EXCLUDED.reason,
+				corrected_text = EXCLUDED.corrected_text,
+				correction_status = EXCLUDED.correction_status,
+				updated_at = EXCLUDED.updated_at
+			  RETURNING id, created_at, updated_at`
+
+	now := time.Now()
+	return r.db.QueryRow(query,
+		uuid.New(),
+		feedback.TranslationID,
+		feedback.UserID,
+		feedback.Rating,
+		feedback.Reason,
+		feedback.CorrectedText,
+		feedback.CorrectionStatus,
+		now,
+	).Scan(&feedback.ID, &feedback.CreatedAt, &feedback.UpdatedAt)
+}
+
+// GetStatsByLanguagePair 按语言对汇总最近 days 天的反馈
+func (r *FeedbackRepository) GetStatsByLanguagePair(days int) ([]*model.FeedbackStat, error) {
+	query := `SELECT
+				th.source_language,
+				th.target_language,
+				COUNT(*) AS total,
+				COUNT(*) FILTER (WHERE f.rating = 1) AS thumbs_up,
+				COUNT(*) FILTER (WHERE f.rating = -1) AS thumbs_down,
+				COUNT(f.corrected_text) AS corrections,
+				COUNT(*) FILTER (WHERE f.correction_status = 'accepted') AS accepted_corrections,
+				COALESCE(mode() WITHIN GROUP (ORDER BY f.reason), '') AS top_reason
+			  FROM translation_feedback f
+			  JOIN translation_history th ON th.id = f.translation_id
+			  WHERE f.updated_at >= NOW() - INTERVAL '1 day' * $1
+			  GROUP BY th.source_language, th.target_language
+			  ORDER BY total DESC`
+
+	rows, err := r.db.Query(query, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []*model.FeedbackStat
+	for rows.Next() {
+		s := &model.FeedbackStat{}
+		err := rows.Scan(
+			&s.SourceLanguage,
+			&s.TargetLanguage,
+			&s.Total,
+			&s.ThumbsUp,
+			&s.ThumbsDown,
+			&s.Corrections,
+			&s.AcceptedCorrections,
+			&s.TopReason,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if s.Total > 0 {
+			s.ApprovalRate = float64(s.ThumbsUp) / float64(s.Total)
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, rows.Err()
+}
@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"smart-glasses-backend/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrRealtimeSessionNotFound 实时会话不存在或不属于当前用户
+var ErrRealtimeSessionNotFound = errors.New("realtime session not found")
+
+type RealtimeRepository struct {
+	db *sql.DB
+}
+
+func NewRealtimeRepository(db *sql.DB) *RealtimeRepository {
+	return &RealtimeRepository{db: db}
+}
+
+// CreateSession 记录会话开始
+func (r *RealtimeRepository) CreateSession(session *model.RealtimeConversation) error {
+	query := `INSERT INTO realtime_sessions (id, user_id, mode, source_language, target_language, started_at)
+			  VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6)`
+
+	if session.StartedAt.IsZero() {
+		session.StartedAt = time.Now()
+	}
+
+	_, err := r.db.Exec(query,
+		session.ID,
+		session.UserID,
+		session.Mode,
+		session.SourceLanguage,
+		session.TargetLanguage,
+		session.StartedAt,
+	)
+	return err
+}
+
+// UpdateSessionMode 会话中途切换模式或语言时更新
+func (r *RealtimeRepository) UpdateSessionMode(id uuid.UUID, mode, sourceLanguage, targetLanguage string) error {
+	query := `UPDATE realtime_sessions SET mode = $2, source_language = NULLIF($3, ''), target_language = NULLIF($4, '')
+			  WHERE id = $1`
+	_, err := r.db.Exec(query, id, mode, sourceLanguage, targetLanguage)
+	return err
+}
+
+// EndSession 记录会话结束时间
+func (r *RealtimeRepository) EndSession(id uuid.UUID, endedAt time.Time) error {
+	_, err := r.db.Exec(`UPDATE realtime_sessions SET ended_at = $2 WHERE id = $1`, id, endedAt)
+	return err
+}
+
+// AddTurn 保存一条转写并累加会话轮数
+func (r *RealtimeRepository) AddTurn(turn *model.RealtimeTurn) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	turn.ID = uuid.New()
+	if turn.CreatedAt.IsZero() {
+		turn.CreatedAt = time.Now()
+	}
+
+	_, err = tx.Exec(`INSERT INTO realtime_turns (id, session_id, seq, role, item_id, text, created_at)
+			  VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)`,
+		turn.ID,
+		turn.SessionID,
+		turn.Seq,
+		turn.Role,
+		turn.ItemID,
+		turn.Text,
+		turn.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE realtime_sessions SET turn_count = turn_count + 1 WHERE id = $1`, turn.SessionID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListSessions 按开始时间倒序列出用户的会话，不含转写内容
+func (r *RealtimeRepository) ListSessions(userID uuid.UUID, limit, offset int) ([]*model.RealtimeConversation, error) {
+	query := `SELECT id, user_id, mode, COALESCE(source_language, ''), COALESCE(target_language, ''), turn_count, started_at, ended_at
+			  FROM realtime_sessions
+			  WHERE user_id = $1
+			  ORDER BY started_at DESC
+			  LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.Query(query, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*model.RealtimeConversation
+	for rows.Next() {
+		s, err := scanRealtimeSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
+// GetSession 获取用户的一次会话及其全部转写
+func (r *RealtimeRepository) GetSession(userID, id uuid.UUID) (*model.RealtimeConversation, error) {
+	query := `SELECT id, user_id, mode, COALESCE(source_language, ''), COALESCE(target_language, ''), turn_count, started_at, ended_at
+			  FROM realtime_sessions
+			  WHERE id = $1 AND user_id = $2`
+
+	session, err := scanRealtimeSession(r.db.QueryRow(query, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrRealtimeSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`SELECT id, session_id, seq, role, COALESCE(item_id, ''), text, created_at
+			  FROM realtime_turns
+			  WHERE session_id = $1
+			  ORDER BY seq`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	session.Turns = []*model.RealtimeTurn{}
+	for rows.Next() {
+		t := &model.RealtimeTurn{}
+		if err := rows.Scan(&t.ID, &t.SessionID, &t.Seq, &t.Role, &t.ItemID, &t.Text, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		session.Turns = append(session.Turns, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	session.Usage, err = r.sumUsage(`session_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// AddUsage 保存一次响应的用量
+func (r *RealtimeRepository) AddUsage(record *model.RealtimeUsageRecord) error {
+	record.ID = uuid.New()
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+
+	_, err := r.db.Exec(`INSERT INTO realtime_usage (id, session_id, user_id, response_id, audio_in_seconds, audio_out_seconds,
+			  input_text_tokens, input_audio_tokens, output_text_tokens, output_audio_tokens, created_at)
+			  VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $10, $11)`,
+		record.ID,
+		record.SessionID,
+		record.UserID,
+		record.ResponseID,
+		record.AudioInSeconds,
+		record.AudioOutSeconds,
+		record.InputTextTokens,
+		record.InputAudioTokens,
+		record.OutputTextTokens,
+		record.OutputAudioTokens,
+		record.CreatedAt,
+	)
+	return err
+}
+
+// GetUserUsageSince 汇总用户自某一时刻起的用量，用于配额检查
+func (r *RealtimeRepository) GetUserUsageSince(userID uuid.UUID, since time.Time) (*model.RealtimeUsage, error) {
+	return r.sumUsage(`user_id = $1 AND created_at >= $2`, userID, since)
+}
+
+func (r *RealtimeRepository) sumUsage(where string, args ...interface{}) (*model.RealtimeUsage, error) {
+	u := &model.RealtimeUsage{}
+	err := r.db.QueryRow(`SELECT COALESCE(SUM(audio_in_seconds), 0), COALESCE(SUM(audio_out_seconds), 0),
+			  COALESCE(SUM(input_text_tokens), 0), COALESCE(SUM(input_audio_tokens), 0),
+			  COALESCE(SUM(output_text_tokens), 0), COALESCE(SUM(output_audio_tokens), 0)
+			  FROM realtime_usage
+			  WHERE `+where, args...).Scan(
+		&u.AudioInSeconds,
+		&u.AudioOutSeconds,
+		&u.InputTextTokens,
+		&u.InputAudioTokens,
+		&u.OutputTextTokens,
+		&u.OutputAudioTokens,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// rowScanner 兼容 *sql.Row 和 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRealtimeSession(row rowScanner) (*model.RealtimeConversation, error) {
+	s := &model.RealtimeConversation{}
+	err := row.Scan(
+		&s.ID,
+		&s.UserID,
+		&s.Mode,
+		&s.SourceLanguage,
+		&s.TargetLanguage,
+		&s.TurnCount,
+		&s.StartedAt,
+		&s.EndedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"database/sql"
+	"smart-glasses-backend/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RecordingRepository struct {
+	db *sql.DB
+}
+
+func NewRecordingRepository(db *sql.DB) *RecordingRepository {
+	return &RecordingRepository{db: db}
+}
+
+// GetConsent 获取用户的录音同意，未同意时返回 nil
+func (r *RecordingRepository) GetConsent(userID uuid.UUID) (*model.RecordingConsent, error) {
+	consent := &model.RecordingConsent{}
+	err := r.db.QueryRow(`SELECT user_id, consented_at FROM recording_consents WHERE user_id = $1`, userID).
+		Scan(&consent.UserID, &consent.ConsentedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return consent, nil
+}
+
+// SetConsent 记录用户同意录音，重复同意时更新同意时间
+func (r *RecordingRepository) SetConsent(consent *model.RecordingConsent) error {
+	if consent.ConsentedAt.IsZero() {
+		consent.ConsentedAt = time.Now()
+	}
+	_, err := r.db.Exec(`INSERT INTO recording_consents (user_id, consented_at) VALUES ($1, $2)
+			  ON CONFLICT (user_id) DO UPDATE SET consented_at = EXCLUDED.consented_at`,
+		consent.UserID,
+		consent.ConsentedAt,
+	)
+	return err
+}
+
+// DeleteConsent 撤回用户的录音同意
+func (r *RecordingRepository) DeleteConsent(userID uuid.UUID) error {
+	_, err := r.db.Exec(`DELETE FROM recording_consents WHERE user_id = $1`, userID)
+	return err
+}
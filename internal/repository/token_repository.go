@@ -16,11 +16,12 @@ func NewTokenRepository(db *sql.DB) *TokenRepository {
 	return &TokenRepository{db: db}
 }
 
-func (r *TokenRepository) Create(userID uuid.UUID, inputTokens, outputTokens int) error {
-	query := `INSERT INTO token_usage (id, user_id, input_tokens, output_tokens, created_at)
-			  VALUES ($1, $2, $3, $4, $5)`
+// Create 记录一次 token 用量，estimated 表示用量为本地估算，source 为产生用量的翻译路径
+func (r *TokenRepository) Create(userID uuid.UUID, inputTokens, outputTokens int, estimated bool, source string) error {
+	query := `INSERT INTO token_usage (id, user_id, input_tokens, output_tokens, estimated, source, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
 	
-	_, err := r.db.Exec(query, uuid.New(), userID, inputTokens, outputTokens, time.Now())
+	_, err := r.db.Exec(query, uuid.New(), userID, inputTokens, outputTokens, estimated, source, time.Now())
 	return err
 }
 
@@ -28,7 +29,8 @@ func (r *TokenRepository) GetTokenUsage(userID uuid.UUID, days int) ([]*model.To
 	query := `SELECT 
 				DATE(created_at) as date,
 				SUM(input_tokens) as input_tokens,
-				SUM(output_tokens) as output_tokens
+				SUM(output_tokens) as output_tokens,
+				COALESCE(SUM(input_tokens + output_tokens) FILTER (WHERE estimated), 0) as estimated_tokens
 			  FROM token_usage
 			  WHERE user_id = $1 AND created_at >= NOW() - INTERVAL '1 day' * $2
 			  GROUP BY DATE(created_at)
@@ -44,7 +46,7 @@ func (r *TokenRepository) GetTokenUsage(userID uuid.UUID, days int) ([]*model.To
 	for rows.Next() {
 		u := &model.TokenUsage{}
 		var date time.Time
-		err := rows.Scan(&date, &u.InputTokens, &u.OutputTokens)
+		err := rows.Scan(&date, &u.InputTokens, &u.OutputTokens, &u.EstimatedTokens)
 		if err != nil {
 			return nil, err
 		}
@@ -59,7 +61,8 @@ func (r *TokenRepository) GetAllTokenUsage(days int) ([]*model.TokenUsage, error
 	query := `SELECT 
 				DATE(created_at) as date,
 				SUM(input_tokens) as input_tokens,
-				SUM(output_tokens) as output_tokens
+				SUM(output_tokens) as output_tokens,
+				COALESCE(SUM(input_tokens + output_tokens) FILTER (WHERE estimated), 0) as estimated_tokens
 			  FROM token_usage
 			  WHERE created_at >= NOW() - INTERVAL '1 day' * $1
 			  GROUP BY DATE(created_at)
@@ -75,7 +78,7 @@ func (r *TokenRepository) GetAllTokenUsage(days int) ([]*model.TokenUsage, error
 	for rows.Next() {
 		u := &model.TokenUsage{}
 		var date time.Time
-		err := rows.Scan(&date, &u.InputTokens, &u.OutputTokens)
+		err := rows.Scan(&date, &u.InputTokens, &u.OutputTokens, &u.EstimatedTokens)
 		if err != nil {
 			return nil, err
 		}
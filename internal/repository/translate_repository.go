@@ -1,13 +1,20 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"smart-glasses-backend/internal/model"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// ErrTranslationNotFound 翻译记录不存在或不属于当前用户
+var ErrTranslationNotFound = errors.New("translation not found")
+
 type TranslateRepository struct {
 	db *sql.DB
 }
@@ -38,14 +45,10 @@ func (r *TranslateRepository) Create(translation *model.Translation) error {
 	return err
 }
 
-func (r *TranslateRepository) GetHistory(userID uuid.UUID, limit, offset int) ([]*model.Translation, error) {
-	query := `SELECT id, user_id, source_text, translated_text, source_language, target_language, created_at
-			  FROM translation_history
-			  WHERE user_id = $1
-			  ORDER BY created_at DESC
-			  LIMIT $2 OFFSET $3`
-	
-	rows, err := r.db.Query(query, userID, limit, offset)
+func (r *TranslateRepository) GetHistory(userID uuid.UUID, filter *model.HistoryFilter) ([]*model.Translation, error) {
+	query, args := buildHistoryQuery(userID, filter)
+
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -53,16 +56,7 @@ func (r *TranslateRepository) GetHistory(userID uuid.UUID, limit, offset int) ([
 	
 	var translations []*model.Translation
 	for rows.Next() {
-		t := &model.Translation{}
-		err := rows.Scan(
-			&t.ID,
-			&t.UserID,
-			&t.SourceText,
-			&t.TranslatedText,
-			&t.SourceLanguage,
-			&t.TargetLanguage,
-			&t.CreatedAt,
-		)
+		t, err := scanTranslation(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -72,6 +66,194 @@ func (r *TranslateRepository) GetHistory(userID uuid.UUID, limit, offset int) ([
 	return translations, rows.Err()
 }
 
+// GetByID 获取当前用户的一条翻译记录
+func (r *TranslateRepository) GetByID(userID, id uuid.UUID) (*model.Translation, error) {
+	t := &model.Translation{}
+	query := `SELECT id, user_id, source_text, translated_text, source_language, target_language, created_at
+			  FROM translation_history WHERE id = $1 AND user_id = $2`
+
+	err := r.db.QueryRow(query, id, userID).Scan(
+		&t.ID,
+		&t.UserID,
+		&t.SourceText,
+		&t.TranslatedText,
+		&t.SourceLanguage,
+		&t.TargetLanguage,
+		&t.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrTranslationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// StreamHistory 逐行读取翻译历史并回调，不在内存中缓存整个结果集
+func (r *TranslateRepository) StreamHistory(ctx context.Context, userID uuid.UUID, filter *model.HistoryFilter, fn func(*model.Translation) error) error {
+	query, args := buildHistoryQuery(userID, filter)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t, err := scanTranslation(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// likeEscaper 转义 LIKE 模式中的通配符，关键词按字面匹配
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// buildHistoryQuery 根据过滤条件构建历史查询语句和参数
+func buildHistoryQuery(userID uuid.UUID, filter *model.HistoryFilter) (string, []interface{}) {
+	if filter == nil {
+		filter = &model.HistoryFilter{}
+	}
+
+	conditions := []string{"user_id = $1"}
+	args := []interface{}{userID}
+	addCondition := func(expr string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(expr, len(args)))
+	}
+
+	if filter.SourceLanguage != "" {
+		addCondition("source_language = $%d", filter.SourceLanguage)
+	}
+	if filter.TargetLanguage != "" {
+		addCondition("target_language = $%d", filter.TargetLanguage)
+	}
+	if filter.Query != "" {
+		args = append(args, "%"+likeEscaper.Replace(filter.Query)+"%")
+		n := len(args)
+		conditions = append(conditions, fmt.Sprintf(`(source_text ILIKE $%d ESCAPE '\' OR translated_text ILIKE $%d ESCAPE '\')`, n, n))
+	}
+	if filter.From != nil {
+		addCondition("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at < $%d", *filter.To)
+	}
+
+	query := `SELECT id, user_id, source_text, translated_text, source_language, target_language, created_at
+			  FROM translation_history
+			  WHERE ` + strings.Join(conditions, " AND ") + `
+			  ORDER BY created_at DESC`
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if filter.Offset > 0 {
+		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	return query, args
+}
+
+func scanTranslation(rows *sql.Rows) (*model.Translation, error) {
+	t := &model.Translation{}
+	err := rows.Scan(
+		&t.ID,
+		&t.UserID,
+		&t.SourceText,
+		&t.TranslatedText,
+		&t.SourceLanguage,
+		&t.TargetLanguage,
+		&t.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// DistinctLanguages 返回历史记录和翻译记忆中出现过的全部语言值
+func (r *TranslateRepository) DistinctLanguages() ([]string, error) {
+	query := `SELECT source_language FROM translation_history
+			  UNION SELECT target_language FROM translation_history
+			  UNION SELECT source_language FROM translation_memory
+			  UNION SELECT target_language FROM translation_memory`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var languages []string
+	for rows.Next() {
+		var language string
+		if err := rows.Scan(&language); err != nil {
+			return nil, err
+		}
+		languages = append(languages, language)
+	}
+
+	return languages, rows.Err()
+}
+
+// RenameLanguage 将历史记录和翻译记忆中的语言值统一改写，返回改写的历史记录行数
+// 翻译记忆在改写后会与已有条目冲突时保留已有条目，删除旧值条目
+func (r *TranslateRepository) RenameLanguage(from, to string) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE translation_history SET
+				source_language = CASE WHEN source_language = $1 THEN $2 ELSE source_language END,
+				target_language = CASE WHEN target_language = $1 THEN $2 ELSE target_language END
+			  WHERE source_language = $1 OR target_language = $1`, from, to)
+	if err != nil {
+		return 0, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM translation_memory m
+			  WHERE (m.source_language = $1 OR m.target_language = $1)
+				AND EXISTS (
+					SELECT 1 FROM translation_memory o
+					WHERE o.id <> m.id
+					  AND o.user_id = m.user_id
+					  AND o.source_language = CASE WHEN m.source_language = $1 THEN $2 ELSE m.source_language END
+					  AND o.target_language = CASE WHEN m.target_language = $1 THEN $2 ELSE m.target_language END
+					  AND md5(o.source_text) = md5(m.source_text)
+				)`, from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`UPDATE translation_memory SET
+				source_language = CASE WHEN source_language = $1 THEN $2 ELSE source_language END,
+				target_language = CASE WHEN target_language = $1 THEN $2 ELSE target_language END
+			  WHERE source_language = $1 OR target_language = $1`, from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	return updated, tx.Commit()
+}
+
 func (r *TranslateRepository) GetLanguageStats(userID uuid.UUID) ([]*model.LanguageStat, error) {
 	query := `SELECT target_language, COUNT(*) as count
 			  FROM translation_history
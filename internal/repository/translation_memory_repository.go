@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"database/sql"
+	"smart-glasses-backend/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TranslationMemoryRepository struct {
+	db *sql.DB
+}
+
+func NewTranslationMemoryRepository(db *sql.DB) *TranslationMemoryRepository {
+	return &TranslationMemoryRepository{db: db}
+}
+
+// Upsert 写入翻译记忆，相同原文已存在时更新译文和来源
+func (r *TranslationMemoryRepository) Upsert(entry *model.TranslationMemoryEntry) error {
+	query := `INSERT INTO translation_memory (id, user_id, source_language, target_language, source_text, translated_text, origin, translation_id, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+			  ON CONFLICT (user_id, source_language, target_language, md5(source_text)) DO UPDATE SET
+				translated_text = EXCLUDED.translated_text,
+				origin = EXCLUDED.origin,
+				translation_id = EXCLUDED.translation_id,
+				updated_at = EXCLUDED.updated_at
+			  RETURNING id`
+
+	return r.db.QueryRow(query,
+		uuid.New(),
+		entry.UserID,
+		entry.SourceLanguage,
+		entry.TargetLanguage,
+		entry.SourceText,
+		entry.TranslatedText,
+		entry.Origin,
+		entry.TranslationID,
+		time.Now(),
+	).Scan(&entry.ID)
+}
+
+// DeleteCorrection 删除由该翻译记录的修正写入的翻译记忆，修正被撤回时调用
+// 其他记录对同一原文的修正和其他来源的记忆保留
+func (r *TranslationMemoryRepository) DeleteCorrection(userID, translationID uuid.UUID) error {
+	query := `DELETE FROM translation_memory
+			  WHERE user_id = $1 AND translation_id = $2 AND origin = 'correction'`
+
+	_, err := r.db.Exec(query, userID, translationID)
+	return err
+}
+
+// Lookup 精确匹配原文查找翻译记忆，未命中时返回 nil
+func (r *TranslationMemoryRepository) Lookup(userID uuid.UUID, sourceLanguage, targetLanguage, sourceText string) (*model.TranslationMemoryEntry, error) {
+	entry := &model.TranslationMemoryEntry{}
+	query := `UPDATE translation_memory SET hit_count = hit_count + 1
+			  WHERE user_id = $1 AND source_language = $2 AND target_language = $3
+				AND md5(source_text) = md5($4) AND source_text = $4
+			  RETURNING id, user_id, source_language, target_language, source_text, translated_text, origin, hit_count, updated_at`
+
+	err := r.db.QueryRow(query, userID, sourceLanguage, targetLanguage, sourceText).Scan(
+		&entry.ID,
+		&entry.UserID,
+		&entry.SourceLanguage,
+		&entry.TargetLanguage,
+		&entry.SourceText,
+		&entry.TranslatedText,
+		&entry.Origin,
+		&entry.HitCount,
+		&entry.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// AudioFrameSubprotocol 客户端在 WebSocket 握手时请求该子协议即启用二进制音频帧
+// 控制消息仍为 JSON 文本帧，未协商的客户端继续使用 base64 的 audio_data/audio_response
+const AudioFrameSubprotocol = "audio-frames.v1"
+
+// 二进制音频帧头：版本(1) 类型(1) 标志(2) 序号(4) 时间戳毫秒(8)，大端序，之后为原始音频
+const (
+	AudioFrameHeaderSize = 16
+	AudioFrameVersion    = 1
+)
+
+// 音频帧类型
+const (
+	AudioFrameTypeInput  byte = 0x01 // 客户端上行音频，对应 audio_data
+	AudioFrameTypeOutput byte = 0x02 // 助手语音，对应 audio_response
+)
+
+// ErrInvalidAudioFrame 二进制帧格式不正确
+var ErrInvalidAudioFrame = errors.New("invalid audio frame")
+
+// AudioFrame 二进制音频帧，Payload 为会话协商格式的原始音频
+type AudioFrame struct {
+	Type      byte
+	Flags     uint16
+	Sequence  uint32
+	Timestamp int64 // Unix 毫秒
+	Payload   []byte
+}
+
+// EncodeAudioFrame 编码为二进制帧
+func EncodeAudioFrame(frame *AudioFrame) []byte {
+	out := make([]byte, AudioFrameHeaderSize+len(frame.Payload))
+	out[0] = AudioFrameVersion
+	out[1] = frame.Type
+	binary.BigEndian.PutUint16(out[2:4], frame.Flags)
+	binary.BigEndian.PutUint32(out[4:8], frame.Sequence)
+	binary.BigEndian.PutUint64(out[8:16], uint64(frame.Timestamp))
+	copy(out[AudioFrameHeaderSize:], frame.Payload)
+	return out
+}
+
+// ParseAudioFrame 解析二进制帧，Payload 引用 data 的内存
+func ParseAudioFrame(data []byte) (*AudioFrame, error) {
+	if len(data) < AudioFrameHeaderSize {
+		return nil, fmt.Errorf("%w: %d bytes is shorter than the %d-byte header", ErrInvalidAudioFrame, len(data), AudioFrameHeaderSize)
+	}
+	if data[0] != AudioFrameVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidAudioFrame, data[0])
+	}
+	frame := &AudioFrame{
+		Type:      data[1],
+		Flags:     binary.BigEndian.Uint16(data[2:4]),
+		Sequence:  binary.BigEndian.Uint32(data[4:8]),
+		Timestamp: int64(binary.BigEndian.Uint64(data[8:16])),
+		Payload:   data[AudioFrameHeaderSize:],
+	}
+	if frame.Type != AudioFrameTypeInput && frame.Type != AudioFrameTypeOutput {
+		return nil, fmt.Errorf("%w: unknown frame type 0x%02x", ErrInvalidAudioFrame, frame.Type)
+	}
+	return frame, nil
+}
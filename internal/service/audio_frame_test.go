@@ -0,0 +1,61 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestAudioFrame_RoundTrip(t *testing.T) {
+	frame := &AudioFrame{
+		Type:      AudioFrameTypeInput,
+		Flags:     0x0102,
+		Sequence:  0xfffffffe,
+		Timestamp: 1700000000123,
+		Payload:   []byte{1, 2, 3, 4},
+	}
+	data := EncodeAudioFrame(frame)
+	if len(data) != AudioFrameHeaderSize+4 {
+		t.Fatalf("Expected %d bytes, got %d", AudioFrameHeaderSize+4, len(data))
+	}
+	want := []byte{1, 0x01, 0x01, 0x02, 0xff, 0xff, 0xff, 0xfe}
+	if !bytes.Equal(data[:8], want) {
+		t.Errorf("Unexpected header prefix % x", data[:8])
+	}
+
+	parsed, err := ParseAudioFrame(data)
+	if err != nil {
+		t.Fatalf("ParseAudioFrame failed: %v", err)
+	}
+	if parsed.Type != frame.Type || parsed.Flags != frame.Flags || parsed.Sequence != frame.Sequence ||
+		parsed.Timestamp != frame.Timestamp || !bytes.Equal(parsed.Payload, frame.Payload) {
+		t.Errorf("Round trip mismatch: %+v", parsed)
+	}
+}
+
+func TestParseAudioFrame_Invalid(t *testing.T) {
+	valid := EncodeAudioFrame(&AudioFrame{Type: AudioFrameTypeOutput})
+
+	badVersion := append([]byte(nil), valid...)
+	badVersion[0] = 2
+	badType := append([]byte(nil), valid...)
+	badType[1] = 0x7f
+
+	cases := map[string][]byte{
+		"empty":       nil,
+		"short":       valid[:AudioFrameHeaderSize-1],
+		"bad version": badVersion,
+		"bad type":    badType,
+	}
+	for name, data := range cases {
+		if _, err := ParseAudioFrame(data); !errors.Is(err, ErrInvalidAudioFrame) {
+			t.Errorf("%s: expected ErrInvalidAudioFrame, got %v", name, err)
+		}
+	}
+
+	// 只有头部的帧合法，负载为空
+	frame, err := ParseAudioFrame(valid)
+	if err != nil || len(frame.Payload) != 0 {
+		t.Errorf("Expected empty payload frame to parse, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package service
+
+import "math"
+
+// 电平统计参数
+const (
+	minDBFS                     = -96.0 // 16 位 PCM 的最小可表示电平，全零样本按此值报告
+	clipSampleLevel             = 32767 // 绝对值达到满幅的样本视为削波
+	defaultAudioLevelIntervalMs = 200
+	minAudioLevelIntervalMs     = 50
+	maxAudioLevelIntervalMs     = 5000
+)
+
+// AudioLevel 一段上行音频的电平和质量统计
+type AudioLevel struct {
+	RMSDBFS      float64 `json:"rms_dbfs"`
+	PeakDBFS     float64 `json:"peak_dbfs"`
+	ClippingRate float64 `json:"clipping_rate"` // 达到满幅的样本比例
+	DCOffset     float64 `json:"dc_offset"`     // 样本均值相对满幅的比例，麦克风偏置异常时明显偏离 0
+	ZeroRunMs    float64 `json:"zero_run_ms"`   // 最长连续零样本时长，数字静音或丢包补零
+	DurationMs   float64 `json:"duration_ms"`
+}
+
+// levelMeter 累积多个音频块的电平统计，take 时输出并清零
+type levelMeter struct {
+	samples    int
+	sum        float64
+	sumSquares float64
+	peak       int
+	clipped    int
+	zeroRun    int // 当前连续零样本数，跨块和跨统计窗口延续
+	maxZeroRun int
+}
+
+// add 累积交错的多声道样本
+func (m *levelMeter) add(samples []int16) {
+	for _, s := range samples {
+		v := int(s)
+		m.sum += float64(v)
+		m.sumSquares += float64(v) * float64(v)
+		if v < 0 {
+			v = -v
+		}
+		if v > m.peak {
+			m.peak = v
+		}
+		if v >= clipSampleLevel {
+			m.clipped++
+		}
+		if v == 0 {
+			m.zeroRun++
+			if m.zeroRun > m.maxZeroRun {
+				m.maxZeroRun = m.zeroRun
+			}
+		} else {
+			m.zeroRun = 0
+		}
+	}
+	m.samples += len(samples)
+}
+
+// take 输出统计并开始新窗口，samplesPerMs 为每毫秒的样本数（含所有声道）
+func (m *levelMeter) take(samplesPerMs float64) AudioLevel {
+	level := AudioLevel{RMSDBFS: minDBFS, PeakDBFS: minDBFS}
+	if m.samples > 0 {
+		n := float64(m.samples)
+		level.RMSDBFS = toDBFS(math.Sqrt(m.sumSquares / n))
+		level.PeakDBFS = toDBFS(float64(m.peak))
+		level.ClippingRate = float64(m.clipped) / n
+		level.DCOffset = m.sum / n / 32768
+		level.ZeroRunMs = float64(m.maxZeroRun) / samplesPerMs
+		level.DurationMs = n / samplesPerMs
+	}
+	*m = levelMeter{zeroRun: m.zeroRun, maxZeroRun: m.zeroRun}
+	return level
+}
+
+// toDBFS 把样本幅度换算为相对满幅的分贝
+func toDBFS(amplitude float64) float64 {
+	if amplitude <= 0 {
+		return minDBFS
+	}
+	return math.Max(20*math.Log10(amplitude/32768), minDBFS)
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// constantPCM 生成 n 个相同值的 PCM16 样本
+func constantPCM(value int16, n int) []byte {
+	out := make([]byte, 0, n*2)
+	for i := 0; i < n; i++ {
+		out = binary.LittleEndian.AppendUint16(out, uint16(value))
+	}
+	return out
+}
+
+func TestLevelMeter(t *testing.T) {
+	var m levelMeter
+	m.add(pcm16Samples(generateTone(16000, 1000, 16384, 100, 1)))
+	level := m.take(16)
+	if math.Abs(level.RMSDBFS-(-9.03)) > 0.1 || math.Abs(level.PeakDBFS-(-6.02)) > 0.1 {
+		t.Errorf("Expected -9.0 dBFS RMS and -6.0 dBFS peak, got %.2f/%.2f", level.RMSDBFS, level.PeakDBFS)
+	}
+	if level.ClippingRate != 0 || math.Abs(level.DCOffset) > 0.001 || level.DurationMs != 100 {
+		t.Errorf("Unexpected level for clean tone %+v", level)
+	}
+
+	// 满幅方波全部削波
+	square := append(constantPCM(32767, 80), constantPCM(-32768, 80)...)
+	m.add(pcm16Samples(square))
+	if level := m.take(16); level.ClippingRate != 1 || level.PeakDBFS < -0.01 {
+		t.Errorf("Expected full clipping, got %+v", level)
+	}
+
+	// 直流偏置
+	m.add(pcm16Samples(constantPCM(3277, 160)))
+	if level := m.take(16); math.Abs(level.DCOffset-0.1) > 0.001 {
+		t.Errorf("Expected DC offset 0.1, got %.4f", level.DCOffset)
+	}
+
+	// 连续零样本跨块和跨窗口累计
+	m.add(pcm16Samples(constantPCM(0, 800)))
+	m.add(pcm16Samples(constantPCM(0, 800)))
+	if level := m.take(16); level.ZeroRunMs != 100 || level.RMSDBFS != minDBFS {
+		t.Errorf("Expected 100ms zero run at %.0f dBFS, got %+v", minDBFS, level)
+	}
+	m.add(pcm16Samples(append(constantPCM(0, 800), constantPCM(100, 16)...)))
+	if level := m.take(16); level.ZeroRunMs != 150 {
+		t.Errorf("Expected zero run to continue across windows, got %.0fms", level.ZeroRunMs)
+	}
+
+	if level := m.take(16); level.DurationMs != 0 || level.RMSDBFS != minDBFS {
+		t.Errorf("Expected empty window, got %+v", level)
+	}
+}
+
+func TestAudioProcessor_TakeAudioLevel(t *testing.T) {
+	ap := NewAudioProcessor()
+	if _, err := ap.ProcessRealtimeAudioChunk(generateTone(16000, 1000, 8000, 100, 1)); err != nil {
+		t.Fatalf("ProcessRealtimeAudioChunk failed: %v", err)
+	}
+	if _, ok := ap.TakeAudioLevel(200); ok {
+		t.Error("Expected no level before the window is full")
+	}
+	ap.ProcessRealtimeAudioChunk(generateTone(16000, 1000, 8000, 100, 1))
+	level, ok := ap.TakeAudioLevel(200)
+	if !ok || level.DurationMs != 200 {
+		t.Fatalf("Expected a 200ms level window, got %+v (%v)", level, ok)
+	}
+
+	// G.711 按解码后的样本统计
+	ap.SetAudioConfig(AudioConfig{SampleRate: 8000, ChannelCount: 1, BitDepth: 16, Format: FormatG711ULaw})
+	ap.ProcessRealtimeAudioChunk(encodeG711(generateTone(8000, 1000, 8000, 100, 1), FormatG711ULaw))
+	level, ok = ap.TakeAudioLevel(100)
+	if !ok || math.Abs(level.PeakDBFS-toDBFS(8000)) > 0.5 {
+		t.Errorf("Unexpected G.711 level %+v (%v)", level, ok)
+	}
+}
+
+func TestPerformanceMonitor_InputLevelAlerts(t *testing.T) {
+	pm := NewPerformanceMonitor()
+	defer pm.Shutdown()
+	pm.StartAudioLatencyMonitoring("session", "user")
+
+	alerts := make(chan AlertEvent, 10)
+	pm.RegisterAlertCallback("audio_input_clipping", func(e AlertEvent) { alerts <- e })
+	pm.RegisterAlertCallback("audio_input_silent", func(e AlertEvent) { alerts <- e })
+
+	clipped := AudioLevel{RMSDBFS: -3, PeakDBFS: 0, ClippingRate: 0.2, DurationMs: 1000}
+	for i := 0; i < 4; i++ {
+		pm.RecordInputLevel("session", clipped)
+	}
+	// 中途恢复后重新计时
+	pm.RecordInputLevel("session", AudioLevel{RMSDBFS: -30, PeakDBFS: -20, DurationMs: 1000})
+	for i := 0; i < 8; i++ {
+		pm.RecordInputLevel("session", clipped)
+	}
+
+	silent := AudioLevel{RMSDBFS: minDBFS, PeakDBFS: minDBFS, ZeroRunMs: 1000, DurationMs: 1000}
+	for i := 0; i < 10; i++ {
+		pm.RecordInputLevel("session", silent)
+	}
+
+	var got []AlertEvent
+	for len(got) < 2 {
+		select {
+		case e := <-alerts:
+			got = append(got, e)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Expected 2 alerts, got %v", got)
+		}
+	}
+	select {
+	case e := <-alerts:
+		t.Errorf("Expected each problem to alert once, got extra %v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+	types := map[string]bool{got[0].Type: true, got[1].Type: true}
+	if !types["audio_input_clipping"] || !types["audio_input_silent"] {
+		t.Errorf("Unexpected alerts %v", got)
+	}
+
+	metric := pm.GetAudioLatencyMetrics("session")
+	if metric.SilentDuration != 10*time.Second || metric.ClippedDuration != 0 || metric.InputLevel.RMSDBFS != minDBFS {
+		t.Errorf("Unexpected input level metrics %+v", metric)
+	}
+}
+
+func TestPerformanceMonitor_LatencyAlertDoesNotDeadlock(t *testing.T) {
+	pm := NewPerformanceMonitor()
+	defer pm.Shutdown()
+	pm.StartAudioLatencyMonitoring("session", "user")
+
+	done := make(chan struct{})
+	go func() {
+		pm.MeasureAudioLatency("session", time.Now().Add(-time.Second), time.Now())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("MeasureAudioLatency blocked while raising a latency alert")
+	}
+}
+
+func TestSendSessionAudio_ReportsAudioLevel(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+
+	clientConn, client := wsPair(t)
+	gptConn, _ := wsPair(t)
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, DefaultRealtimeSessionConfig())
+	t.Cleanup(func() { service.EndSession(session) })
+
+	chunk := generateTone(RealtimeSampleRate, 1000, 16384, 100, 1)
+	for i := 0; i < 2; i++ {
+		if err := service.SendSessionAudio(session, chunk); err != nil {
+			t.Fatalf("SendSessionAudio failed: %v", err)
+		}
+	}
+	msg := readClientMessages(t, client, "audio_level")["audio_level"]
+	if msg["rms_dbfs"] != -9.0 || msg["duration_ms"] != float64(defaultAudioLevelIntervalMs) {
+		t.Errorf("Unexpected audio_level message %v", msg)
+	}
+}
@@ -7,13 +7,36 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"sync"
 )
 
 // AudioProcessor 音频处理组件
+// 实时会话各自持有一个实例，上行音频按客户端声明的格式转换为 24kHz 单声道，下行语音转换为客户端播放采样率
 type AudioProcessor struct {
+	mu           sync.Mutex
 	sampleRate   int // 采样率，默认16kHz
 	channelCount int // 声道数，默认单声道
 	bitDepth     int // 位深度，默认16位
+	playbackRate int // 客户端播放采样率
+
+	format         AudioFormat // 客户端收发的音频格式
+	upstreamFormat AudioFormat // 与 Realtime API 之间的音频格式，pcm16 或与客户端相同的 G.711
+
+	input  *Resampler // 客户端采样率 -> 24kHz，流式保留块间状态
+	output *Resampler // 24kHz -> 客户端播放采样率
+
+	// 本地 VAD 与静音抑制，vad 为 nil 时不启用
+	suppression    SilenceSuppressionConfig
+	vad            *VoiceActivityDetector
+	preRoll        [][]byte // 最近丢弃的静音块
+	preRollMs      float64
+	compressCredit float64       // compress 模式下可发送的静音时长
+	activity       VoiceActivity // 自上次 TakeVoiceActivity 以来的统计
+
+	level levelMeter // 自上次 TakeAudioLevel 以来的电平统计
+
+	preprocessing AudioPreprocessingConfig
+	dsp           *preprocessor // 按客户端采样率创建，采样率变化后重建
 }
 
 // AudioFormat 音频格式枚举
@@ -23,6 +46,11 @@ const (
 	FormatPCM16 AudioFormat = "pcm16"
 	FormatWebM  AudioFormat = "webm"
 	FormatWAV   AudioFormat = "wav"
+	FormatOgg   AudioFormat = "ogg"
+
+	// G.711 为 8kHz 单声道，Realtime API 可以直接收发
+	FormatG711ULaw AudioFormat = "g711_ulaw"
+	FormatG711ALaw AudioFormat = "g711_alaw"
 )
 
 // AudioConfig 音频配置
@@ -51,6 +79,9 @@ func NewAudioProcessor() *AudioProcessor {
 		sampleRate:   16000, // 16kHz采样率
 		channelCount: 1,     // 单声道
 		bitDepth:     16,    // 16位深度
+		playbackRate: RealtimeSampleRate,
+		format:         FormatPCM16,
+		upstreamFormat: FormatPCM16,
 	}
 }
 
@@ -159,9 +190,24 @@ func (ap *AudioProcessor) ConvertAudioFormat(input []byte, fromFormat, toFormat
 		return input, nil
 
 	case fromFormat == string(FormatWebM) && toFormat == string(FormatPCM16):
-		// WebM到PCM16的转换（简化实现，实际需要使用专门的音频库）
+		// 浏览器 MediaRecorder 录制的 WebM（Opus 或 PCM）
 		return ap.convertWebMToPCM16(input)
 
+	case fromFormat == string(FormatOgg) && toFormat == string(FormatPCM16):
+		return ap.convertOggToPCM16(input)
+
+	case isG711(AudioFormat(fromFormat)) && toFormat == string(FormatPCM16):
+		// G.711 解码后从 8kHz 转换为处理器采样率
+		return ap.resampleToTarget(decodeG711(input, AudioFormat(fromFormat)), g711SampleRate)
+
+	case fromFormat == string(FormatPCM16) && isG711(AudioFormat(toFormat)):
+		// 处理器采样率的 PCM16 转换为 8kHz 后编码
+		pcm, err := ap.resampleFromSource(input, g711SampleRate)
+		if err != nil {
+			return nil, err
+		}
+		return encodeG711(pcm, AudioFormat(toFormat)), nil
+
 	case fromFormat == string(FormatWAV) && toFormat == string(FormatPCM16):
 		// WAV到PCM16的转换
 		return ap.convertWAVToPCM16(input)
@@ -175,87 +221,166 @@ func (ap *AudioProcessor) ConvertAudioFormat(input []byte, fromFormat, toFormat
 	}
 }
 
-// convertWebMToPCM16 将WebM格式转换为PCM16（简化实现）
+// convertWebMToPCM16 将WebM格式转换为处理器采样率的PCM16单声道
+// 支持 A_OPUS（需要 -tags opus 构建）以及 Chrome codecs=pcm 录制的 A_PCM/INT/LIT、A_PCM/FLOAT/IEEE
 func (ap *AudioProcessor) convertWebMToPCM16(webmData []byte) ([]byte, error) {
-	// 注意：这是一个简化的实现示例
-	// 实际的WebM解码需要使用专门的音频库如FFmpeg或libwebm
-	
-	// 对于演示目的，我们假设输入已经是PCM数据
-	// 在实际实现中，这里需要调用WebM解码器
-	
-	log.Printf("Warning: WebM to PCM16 conversion is simplified - actual implementation requires audio decoding library")
-	
-	// 验证输入数据
-	if len(webmData) < 100 {
-		return nil, &AudioProcessingError{
-			Type:    "format_error",
-			Message: "Invalid WebM data",
-			Details: "WebM data too short to be valid",
-		}
+	audio, err := demuxWebM(webmData)
+	if err != nil {
+		return nil, err
+	}
+	if len(audio.frames) == 0 {
+		return nil, webmFormatError("Audio track %d has no frames", audio.track.number)
 	}
 
-	// 简化处理：假设数据已经是PCM格式，只需要验证和调整
-	return ap.ensurePCM16Format(webmData)
-}
+	track := audio.track
+	switch track.codecID {
+	case "A_OPUS":
+		head, err := parseOpusHead(track.codecPrivate)
+		if err != nil {
+			return nil, err
+		}
+		pcm, err := decodeOpusPackets(head, audio.frames, -1)
+		if err != nil {
+			return nil, err
+		}
+		return ap.resampleToTarget(pcm, opusSampleRate)
+
+	case "A_PCM/INT/LIT", "A_PCM/FLOAT/IEEE":
+		// SamplingFrequency 是浮点数，超出范围或非有限值时转换为 int 的结果未定义
+		if math.IsNaN(track.sampleRate) || track.sampleRate < minInputSampleRate || track.sampleRate > maxInputSampleRate {
+			return nil, &AudioProcessingError{
+				Type:    "unsupported_format",
+				Message: "Unsupported sample rate",
+				Details: fmt.Sprintf("WebM sampling frequency %g Hz is outside the supported range %d-%d Hz", track.sampleRate, minInputSampleRate, maxInputSampleRate),
+			}
+		}
+		format := &wavFormat{
+			tag:           wavFormatPCM,
+			channels:      track.channels,
+			sampleRate:    int(track.sampleRate),
+			bitsPerSample: track.bitDepth,
+			blockAlign:    track.channels * track.bitDepth / 8,
+		}
+		if track.codecID == "A_PCM/FLOAT/IEEE" {
+			format.tag = wavFormatIEEEFloat
+		}
+		if err := format.validate(); err != nil {
+			return nil, err
+		}
+		pcm := decodeWAVData(format, bytes.Join(audio.frames, nil))
+		return ap.resampleToTarget(pcm, format.sampleRate)
 
-// convertWAVToPCM16 将WAV格式转换为PCM16
-func (ap *AudioProcessor) convertWAVToPCM16(wavData []byte) ([]byte, error) {
-	if len(wavData) < 44 {
+	default:
 		return nil, &AudioProcessingError{
-			Type:    "format_error",
-			Message: "Invalid WAV file",
-			Details: "WAV file too short to contain valid header",
+			Type:    "unsupported_format",
+			Message: "Unsupported WebM codec",
+			Details: fmt.Sprintf("WebM codec %q is not supported", track.codecID),
 		}
 	}
+}
 
-	// 验证WAV文件头
-	if !bytes.Equal(wavData[0:4], []byte("RIFF")) || !bytes.Equal(wavData[8:12], []byte("WAVE")) {
+// convertOggToPCM16 将Ogg Opus转换为处理器采样率的PCM16单声道
+func (ap *AudioProcessor) convertOggToPCM16(oggData []byte) ([]byte, error) {
+	stream, err := demuxOgg(oggData)
+	if err != nil {
+		return nil, err
+	}
+	if len(stream.packets) == 0 {
+		return nil, oggFormatError("Stream %d has no packets", stream.serial)
+	}
+	if bytes.HasPrefix(stream.packets[0], []byte("\x01vorbis")) {
 		return nil, &AudioProcessingError{
-			Type:    "format_error",
-			Message: "Invalid WAV file format",
-			Details: "Missing RIFF/WAVE header",
+			Type:    "unsupported_format",
+			Message: "Unsupported Ogg codec",
+			Details: "Ogg Vorbis is not supported, only Ogg Opus",
 		}
 	}
 
-	// 跳过WAV头部，提取PCM数据（简化实现）
-	// 实际实现需要解析完整的WAV头部信息
-	dataStart := 44
-	if len(wavData) <= dataStart {
-		return nil, &AudioProcessingError{
-			Type:    "format_error",
-			Message: "WAV file has no audio data",
-			Details: "WAV file contains only header without audio data",
-		}
+	head, err := parseOpusHead(stream.packets[0])
+	if err != nil {
+		return nil, err
+	}
+	packets := stream.packets[1:]
+	if len(packets) > 0 && bytes.HasPrefix(packets[0], []byte("OpusTags")) {
+		packets = packets[1:]
 	}
 
-	pcmData := wavData[dataStart:]
-	return ap.ensurePCM16Format(pcmData)
+	// 最后一页的 granule position 含 pre-skip，用于去掉末尾的编码填充
+	total := int64(-1)
+	if stream.granule >= 0 {
+		total = stream.granule - int64(head.preSkip)
+	}
+	pcm, err := decodeOpusPackets(head, packets, total)
+	if err != nil {
+		return nil, err
+	}
+	return ap.resampleToTarget(pcm, opusSampleRate)
 }
 
-// ensurePCM16Format 确保音频数据符合PCM16格式要求
-func (ap *AudioProcessor) ensurePCM16Format(data []byte) ([]byte, error) {
-	// 验证数据长度
-	if len(data)%2 != 0 {
-		// 如果长度不是偶数，截断最后一个字节
-		data = data[:len(data)-1]
-		log.Printf("Warning: Truncated audio data to ensure even length")
+// convertWAVToPCM16 将WAV格式转换为处理器采样率的PCM16单声道
+// 支持 8/16/24/32 位整数、32/64 位浮点以及 WAVE_FORMAT_EXTENSIBLE 封装，多声道平均为单声道
+func (ap *AudioProcessor) convertWAVToPCM16(wavData []byte) ([]byte, error) {
+	format, data, err := parseWAV(wavData)
+	if err != nil {
+		return nil, err
 	}
+	return ap.resampleToTarget(decodeWAVData(format, data), format.sampleRate)
+}
 
-	// 验证采样值范围（PCM16: -32768 to 32767）
-	samples := len(data) / 2
-	for i := 0; i < samples; i++ {
-		sample := int16(binary.LittleEndian.Uint16(data[i*2 : i*2+2]))
-		// PCM16样本值检查（可选的质量验证）
-		if sample == 0 && i > 0 && i < samples-1 {
-			// 检测连续的零值（可能表示静音或数据问题）
-			continue
-		}
+// resampleToTarget 把单声道 PCM16 从 rate 转换为处理器采样率
+func (ap *AudioProcessor) resampleToTarget(pcm []byte, rate int) ([]byte, error) {
+	ap.mu.Lock()
+	targetRate := ap.sampleRate
+	ap.mu.Unlock()
+	if rate == targetRate {
+		return pcm, nil
 	}
 
-	return data, nil
+	resampler, err := NewResampler(rate, targetRate)
+	if err != nil {
+		return nil, &AudioProcessingError{Type: "config_error", Message: "Invalid sample rate", Details: err.Error()}
+	}
+	return resampler.Process(pcm), nil
+}
+
+// resampleFromSource 把处理器采样率的单声道 PCM16 转换为 rate
+func (ap *AudioProcessor) resampleFromSource(pcm []byte, rate int) ([]byte, error) {
+	ap.mu.Lock()
+	sourceRate := ap.sampleRate
+	ap.mu.Unlock()
+	if sourceRate == rate {
+		return pcm, nil
+	}
+
+	resampler, err := NewResampler(sourceRate, rate)
+	if err != nil {
+		return nil, &AudioProcessingError{Type: "config_error", Message: "Invalid sample rate", Details: err.Error()}
+	}
+	return resampler.Process(pcm), nil
+}
+
+// meterLevel 把客户端音频计入电平统计，立体声按交错样本统计，调用方持有锁
+func (ap *AudioProcessor) meterLevel(data []byte) {
+	if isG711(ap.format) {
+		ap.level.add(pcm16Samples(decodeG711(data, ap.format)))
+		return
+	}
+	ap.level.add(pcm16Samples(data))
+}
+
+// TakeAudioLevel 累积的上行音频达到 minDurationMs 时返回电平统计并开始新窗口
+func (ap *AudioProcessor) TakeAudioLevel(minDurationMs float64) (AudioLevel, bool) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	samplesPerMs := float64(ap.sampleRate*ap.channelCount) / 1000
+	if ap.level.samples == 0 || float64(ap.level.samples)/samplesPerMs < minDurationMs {
+		return AudioLevel{}, false
+	}
+	return ap.level.take(samplesPerMs), true
 }
 
 // ProcessRealtimeAudioChunk 处理实时音频块
+// 启用静音抑制时按本地 VAD 的结果丢弃或压缩静音，被抑制的块返回空数据
 // Requirements: 3.5 - 支持实时音频流处理（100ms块）
 func (ap *AudioProcessor) ProcessRealtimeAudioChunk(audioChunk []byte) ([]byte, error) {
 	if len(audioChunk) == 0 {
@@ -268,39 +393,208 @@ func (ap *AudioProcessor) ProcessRealtimeAudioChunk(audioChunk []byte) ([]byte,
 		}
 	}
 
-	// 验证音频块格式
-	if err := ap.ValidateAudioFormat(audioChunk); err != nil {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	// 验证音频块格式，G.711 每字节一个样本，PCM16 需要按声道帧对齐
+	if frameSize := ap.channelCount * 2; !isG711(ap.format) && len(audioChunk)%frameSize != 0 {
+		err := &AudioProcessingError{
+			Type:    "format_error",
+			Message: "Invalid PCM16 frame alignment",
+			Details: fmt.Sprintf("Audio data length %d is not a multiple of %d-channel frames", len(audioChunk), ap.channelCount),
+		}
 		// Requirements: 3.4 - 记录错误并继续处理
 		log.Printf("Audio chunk validation failed: %v", err)
 		return nil, err
 	}
 
-	// 验证音频块大小（100ms的PCM16数据约为3200字节，16kHz单声道）
-	expectedChunkSize := ap.sampleRate / 10 * 2 // 100ms * sampleRate * 2bytes/sample
-	actualSize := len(audioChunk)
+	// 验证音频块大小（100ms的PCM16数据约为3200字节，16kHz单声道）；低延迟客户端的小块是正常的，只提示过大的块
+	if chunkMs := ap.clientAudioMs(len(audioChunk)); chunkMs > 200 {
+		log.Printf("Warning: Audio chunk of %.0fms exceeds expected size for 100ms", chunkMs)
+	}
+
+	// 预处理在电平统计和本地 VAD 之前，二者看到的与发往上游的是同一段音频
+	var processedChunk []byte
+	if ap.preprocessing.enabled() {
+		processedChunk = ap.preprocessChunk(audioChunk)
+		if len(processedChunk) == 0 {
+			return nil, nil // 降噪器凑满一帧之前没有输出
+		}
+	} else {
+		processedChunk = append([]byte(nil), audioChunk...)
+	}
+
+	ap.meterLevel(processedChunk)
+	if ap.vad == nil {
+		return processedChunk, nil
+	}
+	return ap.suppressSilence(processedChunk), nil
+}
+
+// suppressSilence 用本地 VAD 检测音频块，返回需要发送给上游的音频
+// 开口时把之前丢弃的最近一段静音一起发送，compress 模式下静音按比例保留
+func (ap *AudioProcessor) suppressSilence(chunk []byte) []byte {
+	var mono []byte
+	if isG711(ap.format) {
+		mono = decodeG711(chunk, ap.format)
+	} else {
+		mono = DownmixToMono(chunk, ap.channelCount)
+	}
+	res := ap.vad.Process(pcm16Samples(mono), ap.sampleRate)
+	chunkMs := ap.clientAudioMs(len(chunk))
+
+	send := res.Active
+	if !send && ap.suppression.Mode == SilenceModeCompress {
+		ap.compressCredit += chunkMs / silenceCompressRate
+		if ap.compressCredit >= chunkMs {
+			ap.compressCredit -= chunkMs
+			send = true
+			ap.preRoll, ap.preRollMs = ap.preRoll[:0], 0 // 已发送的静音之前的块不再补发，保持顺序
+		}
+	}
+
+	if !send {
+		res.SuppressedMs = chunkMs
+		ap.preRoll = append(ap.preRoll, chunk)
+		ap.preRollMs += chunkMs
+		for len(ap.preRoll) > 1 && ap.preRollMs-ap.clientAudioMs(len(ap.preRoll[0])) >= silencePreRollMs {
+			ap.preRollMs -= ap.clientAudioMs(len(ap.preRoll[0]))
+			ap.preRoll = ap.preRoll[1:]
+		}
+		ap.activity.add(res.VoiceActivity)
+		return nil
+	}
+
+	if res.Active && len(ap.preRoll) > 0 {
+		ap.preRoll = append(ap.preRoll, chunk)
+		chunk = bytes.Join(ap.preRoll, nil)
+		// 补发的静音已计入被抑制时长，从中扣除
+		res.SuppressedMs -= ap.preRollMs
+		ap.preRoll, ap.preRollMs = nil, 0
+	}
+	if res.Active {
+		ap.compressCredit = 0
+	}
+	ap.activity.add(res.VoiceActivity)
+	return chunk
+}
+
+// SetSilenceSuppression 设置本地 VAD 与静音抑制，关闭时清除检测状态
+func (ap *AudioProcessor) SetSilenceSuppression(cfg SilenceSuppressionConfig) error {
+	if cfg.Enabled {
+		if err := cfg.validate(); err != nil {
+			return &AudioProcessingError{Type: "config_error", Message: "Invalid silence suppression config", Details: err.Error()}
+		}
+	}
+
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	if cfg == ap.suppression && (ap.vad != nil) == cfg.Enabled {
+		return nil // 配置未变，保留噪声基底等状态
+	}
+	ap.suppression = cfg
+	ap.vad = nil
+	ap.preRoll, ap.preRollMs, ap.compressCredit = nil, 0, 0
+	if cfg.Enabled {
+		ap.vad = NewVoiceActivityDetector(cfg.ThresholdDB, cfg.HangoverMs)
+	}
+	return nil
+}
+
+// SetPreprocessing 设置上行音频预处理链，配置未变时保留滤波器和增益状态
+func (ap *AudioProcessor) SetPreprocessing(cfg AudioPreprocessingConfig) error {
+	if cfg.enabled() {
+		if err := cfg.validate(); err != nil {
+			return &AudioProcessingError{Type: "config_error", Message: "Invalid preprocessing config", Details: err.Error()}
+		}
+	}
+
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	if cfg != ap.preprocessing {
+		ap.preprocessing = cfg
+		ap.dsp = nil
+	}
+	return nil
+}
+
+// preprocessChunk 对客户端格式的音频块执行预处理链，输出仍为客户端格式，调用方持有锁
+// 多声道按下混后的单声道处理再复制到各声道；开启降噪时输出比输入滞后，长度不同
+func (ap *AudioProcessor) preprocessChunk(chunk []byte) []byte {
+	if ap.dsp == nil {
+		ap.dsp = newPreprocessor(ap.preprocessing, ap.sampleRate)
+	}
+	var mono []byte
+	if isG711(ap.format) {
+		mono = decodeG711(chunk, ap.format)
+	} else {
+		mono = DownmixToMono(chunk, ap.channelCount)
+	}
+	return ap.fromMono(ap.dsp.Process(mono))
+}
 
-	if actualSize > expectedChunkSize*2 {
-		log.Printf("Warning: Audio chunk size %d exceeds expected size %d for 100ms", actualSize, expectedChunkSize)
+// fromMono 把单声道 PCM16 转换回客户端格式，调用方持有锁
+func (ap *AudioProcessor) fromMono(mono []byte) []byte {
+	if isG711(ap.format) {
+		return encodeG711(mono, ap.format)
 	}
+	return upmixFromMono(mono, ap.channelCount)
+}
 
-	if actualSize < expectedChunkSize/2 {
-		log.Printf("Warning: Audio chunk size %d is smaller than expected minimum %d", actualSize, expectedChunkSize/2)
+// FlushPreprocessing 取出降噪器中尚未输出的尾部音频（客户端格式），提交音频前调用，最后一帧语音不会留在降噪器中
+// 尾部不足一帧，计入电平统计但不经本地 VAD
+func (ap *AudioProcessor) FlushPreprocessing() []byte {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	if ap.dsp == nil {
+		return nil
 	}
+	mono := ap.dsp.Flush()
+	if len(mono) == 0 {
+		return nil
+	}
+	tail := ap.fromMono(mono)
+	ap.meterLevel(tail)
+	return tail
+}
 
-	// 处理音频数据（可以在这里添加音频增强、降噪等处理）
-	processedChunk := make([]byte, len(audioChunk))
-	copy(processedChunk, audioChunk)
+// TakeVoiceActivity 返回并清零自上次调用以来的本地 VAD 统计
+func (ap *AudioProcessor) TakeVoiceActivity() VoiceActivity {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	activity := ap.activity
+	ap.activity = VoiceActivity{}
+	return activity
+}
 
-	return processedChunk, nil
+// clientAudioMs 客户端格式下 n 字节音频的时长（毫秒），调用方持有锁
+func (ap *AudioProcessor) clientAudioMs(n int) float64 {
+	if isG711(ap.format) {
+		return float64(n) / g711BytesPerMs
+	}
+	return float64(n) * 1000 / float64(ap.sampleRate*ap.channelCount*2)
+}
+
+// silence 生成 n 字节客户端格式的静音，用于补齐丢失的音频包
+func (ap *AudioProcessor) silence(n int) []byte {
+	ap.mu.Lock()
+	format := ap.format
+	ap.mu.Unlock()
+	if isG711(format) {
+		return encodeG711(make([]byte, n*2), format)
+	}
+	return make([]byte, n)
 }
 
 // GetAudioConfig 获取音频配置
 func (ap *AudioProcessor) GetAudioConfig() AudioConfig {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
 	return AudioConfig{
 		SampleRate:   ap.sampleRate,
 		ChannelCount: ap.channelCount,
 		BitDepth:     ap.bitDepth,
-		Format:       FormatPCM16,
+		Format:       ap.format,
 	}
 }
 
@@ -315,6 +609,10 @@ func (ap *AudioProcessor) SetAudioConfig(config AudioConfig) error {
 		}
 	}
 
+	if _, _, err := resampleRatio(config.SampleRate, RealtimeSampleRate); err != nil {
+		return &AudioProcessingError{Type: "config_error", Message: "Invalid sample rate", Details: err.Error()}
+	}
+
 	if config.ChannelCount <= 0 || config.ChannelCount > 2 {
 		return &AudioProcessingError{
 			Type:    "config_error",
@@ -331,13 +629,167 @@ func (ap *AudioProcessor) SetAudioConfig(config AudioConfig) error {
 		}
 	}
 
+	format := config.Format
+	if format == "" {
+		format = FormatPCM16
+	}
+	if isG711(format) && (config.SampleRate != g711SampleRate || config.ChannelCount != 1) {
+		return &AudioProcessingError{
+			Type:    "config_error",
+			Message: "Invalid G.711 config",
+			Details: fmt.Sprintf("G.711 requires 8000 Hz mono, got %d Hz with %d channels", config.SampleRate, config.ChannelCount),
+		}
+	}
+
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	if ap.sampleRate != config.SampleRate {
+		// 采样率变化后重建重采样器和预处理链
+		ap.input = nil
+		ap.dsp = nil
+	}
 	ap.sampleRate = config.SampleRate
 	ap.channelCount = config.ChannelCount
 	ap.bitDepth = config.BitDepth
+	ap.format = format
 
 	return nil
 }
 
+// SetPlaybackSampleRate 设置客户端播放助手语音的采样率
+func (ap *AudioProcessor) SetPlaybackSampleRate(rate int) error {
+	if rate <= 0 || rate > 48000 {
+		return &AudioProcessingError{
+			Type:    "config_error",
+			Message: "Invalid playback sample rate",
+			Details: fmt.Sprintf("Sample rate %d is not supported", rate),
+		}
+	}
+	if _, _, err := resampleRatio(RealtimeSampleRate, rate); err != nil {
+		return &AudioProcessingError{Type: "config_error", Message: "Invalid playback sample rate", Details: err.Error()}
+	}
+
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	if ap.playbackRate != rate {
+		ap.output = nil
+	}
+	ap.playbackRate = rate
+	return nil
+}
+
+// PlaybackSampleRate 客户端播放采样率
+func (ap *AudioProcessor) PlaybackSampleRate() int {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	return ap.playbackRate
+}
+
+// ConvertInputAudio 把客户端上行音频转换为上游格式
+// 上游与客户端同为 G.711 时原样转发；否则解码或下混为单声道并重采样为 Realtime API 要求的 24kHz PCM16
+// Requirements: 7.2, 7.3
+func (ap *AudioProcessor) ConvertInputAudio(data []byte) ([]byte, error) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	if ap.upstreamFormat == ap.format && isG711(ap.format) {
+		return append([]byte(nil), data...), nil
+	}
+
+	var mono []byte
+	if isG711(ap.format) {
+		mono = decodeG711(data, ap.format)
+	} else {
+		frameSize := ap.channelCount * 2
+		if len(data)%frameSize != 0 {
+			return nil, &AudioProcessingError{
+				Type:    "format_error",
+				Message: "Invalid PCM16 frame alignment",
+				Details: fmt.Sprintf("Audio data length %d is not a multiple of %d-channel frames", len(data), ap.channelCount),
+			}
+		}
+		mono = DownmixToMono(data, ap.channelCount)
+	}
+	if ap.input == nil {
+		resampler, err := NewResampler(ap.sampleRate, RealtimeSampleRate)
+		if err != nil {
+			return nil, &AudioProcessingError{Type: "config_error", Message: "Invalid sample rate", Details: err.Error()}
+		}
+		ap.input = resampler
+	}
+	return ap.input.Process(mono), nil
+}
+
+// ConvertOutputAudio 把上游的助手语音转换为客户端格式
+// 上游为 24kHz PCM16 时重采样到客户端播放采样率，客户端使用 G.711 时再编码
+func (ap *AudioProcessor) ConvertOutputAudio(data []byte) []byte {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	if isG711(ap.upstreamFormat) {
+		return data
+	}
+	if ap.output == nil {
+		resampler, err := NewResampler(RealtimeSampleRate, ap.playbackRate)
+		if err != nil {
+			return data
+		}
+		ap.output = resampler
+	}
+	pcm := ap.output.Process(data)
+	if isG711(ap.format) {
+		return encodeG711(pcm, ap.format)
+	}
+	return pcm
+}
+
+// setUpstreamFormat 设置与 Realtime API 之间的音频格式
+func (ap *AudioProcessor) setUpstreamFormat(format AudioFormat) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	ap.upstreamFormat = format
+}
+
+// passthroughOutput 上游语音是否可以不经转换直接发给客户端
+func (ap *AudioProcessor) passthroughOutput() bool {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	if isG711(ap.upstreamFormat) {
+		return true
+	}
+	return !isG711(ap.format) && ap.playbackRate == RealtimeSampleRate
+}
+
+// upstreamAudioMs 上游格式下 n 字节语音的时长（毫秒）
+func (ap *AudioProcessor) upstreamAudioMs(n int) float64 {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	if isG711(ap.upstreamFormat) {
+		return float64(n) / g711BytesPerMs
+	}
+	return float64(n) / realtimeAudioBytesPerMs
+}
+
+// upstreamPCM 把上游格式的音频解码为 PCM16，返回音频及其采样率
+func (ap *AudioProcessor) upstreamPCM(data []byte) ([]byte, int) {
+	ap.mu.Lock()
+	format := ap.upstreamFormat
+	ap.mu.Unlock()
+	if isG711(format) {
+		return decodeG711(data, format), g711SampleRate
+	}
+	return data, RealtimeSampleRate
+}
+
+// ResetOutput 新一轮响应开始时丢弃上一轮残留的滤波器状态
+func (ap *AudioProcessor) ResetOutput() {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	if ap.output != nil {
+		ap.output.Reset()
+	}
+}
+
 // GenerateTestAudioData 生成测试音频数据（用于测试）
 func (ap *AudioProcessor) GenerateTestAudioData(durationMs int, frequency float64) []byte {
 	samples := ap.sampleRate * durationMs / 1000
@@ -228,6 +228,17 @@ func TestSetAudioConfig(t *testing.T) {
 		t.Error("Expected error for invalid sample rate")
 	}
 	
+	// Test sample rate coprime with 24kHz
+	invalidConfig = validConfig
+	invalidConfig.SampleRate = 47999
+	err = ap.SetAudioConfig(invalidConfig)
+	if err == nil {
+		t.Error("Expected error for sample rate that cannot be resampled")
+	}
+	if err := ap.SetPlaybackSampleRate(47999); err == nil {
+		t.Error("Expected error for playback rate that cannot be resampled")
+	}
+	
 	// Test invalid channel count
 	invalidConfig = validConfig
 	invalidConfig.ChannelCount = 0
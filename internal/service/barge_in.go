@@ -0,0 +1,157 @@
+package service
+
+import (
+	"log"
+	"time"
+)
+
+// 打断原因
+const (
+	InterruptReasonSpeech    = "speech_started" // 服务器 VAD 检测到用户开口
+	InterruptReasonClient    = "client"         // 客户端主动打断
+	InterruptReasonTextInput = "text_input"     // 用户输入了新的文字消息
+)
+
+// responsePlayback 当前助手响应的语音输出进度
+type responsePlayback struct {
+	responseID   string
+	inProgress   bool      // 上游仍在生成该响应
+	itemID       string    // 正在播放的语音所属会话项
+	audioMs      float64   // 已发给客户端的语音时长
+	firstAudioAt time.Time // 第一段语音发给客户端的时间，近似为客户端开始播放的时间
+	cancelled    map[string]bool
+}
+
+// startResponse 新响应开始，之前的播放进度作废
+func (rs *RealtimeSession) startResponse(responseID string) {
+	rs.playbackMu.Lock()
+	defer rs.playbackMu.Unlock()
+
+	cancelled := rs.playback.cancelled
+	rs.playback = responsePlayback{
+		responseID: responseID,
+		inProgress: true,
+		cancelled:  cancelled,
+	}
+}
+
+// finishResponse 上游完成响应；客户端可能仍在播放已收到的语音，播放进度保留
+func (rs *RealtimeSession) finishResponse(responseID string) {
+	rs.playbackMu.Lock()
+	defer rs.playbackMu.Unlock()
+
+	if rs.playback.responseID == responseID {
+		rs.playback.inProgress = false
+	}
+	delete(rs.playback.cancelled, responseID)
+}
+
+// isCancelled 已取消响应的后续增量不再转发给客户端
+func (rs *RealtimeSession) isCancelled(responseID string) bool {
+	rs.playbackMu.Lock()
+	defer rs.playbackMu.Unlock()
+	return responseID != "" && rs.playback.cancelled[responseID]
+}
+
+// recordAudioSent 记录转发给客户端的语音时长
+func (rs *RealtimeSession) recordAudioSent(itemID string, audioMs float64) {
+	rs.playbackMu.Lock()
+	defer rs.playbackMu.Unlock()
+
+	if rs.playback.itemID != itemID {
+		rs.playback.itemID = itemID
+		rs.playback.audioMs = 0
+		rs.playback.firstAudioAt = time.Now()
+	}
+	rs.playback.audioMs += audioMs
+}
+
+// interruption 打断时需要对上游执行的操作
+type interruption struct {
+	responseID string // 需要取消的响应，为空表示响应已生成完毕
+	itemID     string // 需要截断的会话项，为空表示没有正在播放的语音
+	audioEndMs int
+}
+
+// takeInterruption 计算打断操作并清除播放进度，同一段语音只会被截断一次
+// playedMs 为客户端报告的已播放时长，小于 0 时按发送时间估算
+func (rs *RealtimeSession) takeInterruption(playedMs int) (interruption, bool) {
+	rs.playbackMu.Lock()
+	defer rs.playbackMu.Unlock()
+
+	p := &rs.playback
+	var result interruption
+
+	if p.inProgress && p.responseID != "" {
+		result.responseID = p.responseID
+		if p.cancelled == nil {
+			p.cancelled = make(map[string]bool)
+		}
+		p.cancelled[p.responseID] = true
+		p.inProgress = false
+	}
+
+	if p.itemID != "" && p.audioMs > 0 {
+		elapsedMs := float64(time.Since(p.firstAudioAt).Milliseconds())
+		if playedMs < 0 {
+			// 客户端未报告播放位置且语音已播完时无需截断
+			if elapsedMs < p.audioMs {
+				result.itemID = p.itemID
+				result.audioEndMs = int(elapsedMs)
+			}
+		} else {
+			result.itemID = p.itemID
+			result.audioEndMs = playedMs
+			if float64(playedMs) > p.audioMs {
+				result.audioEndMs = int(p.audioMs)
+			}
+		}
+		p.itemID = ""
+		p.audioMs = 0
+	}
+
+	return result, result.responseID != "" || result.itemID != ""
+}
+
+// InterruptResponse 打断助手：取消生成中的响应，按已播放位置截断会话项，并通知客户端停止播放
+// Requirements: 5.5 - 保持低延迟，用户开口后立即停止助手语音
+func (s *RealtimeService) InterruptResponse(session *RealtimeSession, reason string, playedMs int) error {
+	action, ok := session.takeInterruption(playedMs)
+	if !ok {
+		return nil
+	}
+
+	if action.responseID != "" {
+		if err := s.WriteJSON(session.Upstream(), map[string]interface{}{
+			"type": "response.cancel",
+		}, 5*time.Second); err != nil {
+			return err
+		}
+	}
+
+	if action.itemID != "" {
+		// 截断后上游只保留用户实际听到的部分，后续对话不会引用未播放的内容
+		if err := s.WriteJSON(session.Upstream(), map[string]interface{}{
+			"type":          "conversation.item.truncate",
+			"item_id":       action.itemID,
+			"content_index": 0,
+			"audio_end_ms":  action.audioEndMs,
+		}, 5*time.Second); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Interrupted response %q for session %s (%s), truncated item %q at %dms",
+		action.responseID, session.ID, reason, action.itemID, action.audioEndMs)
+
+	s.sendToClientWithTimeout(session.ClientConn, map[string]interface{}{
+		"type":         "response_interrupted",
+		"session_id":   session.ID,
+		"reason":       reason,
+		"response_id":  action.responseID,
+		"item_id":      action.itemID,
+		"audio_end_ms": action.audioEndMs,
+		"timestamp":    time.Now().UnixMilli(),
+	})
+	return nil
+}
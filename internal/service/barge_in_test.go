@@ -0,0 +1,176 @@
+package service
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// readUpstream 读取发往上游的消息，直到出现指定类型
+func readUpstream(t *testing.T, upstream *websocket.Conn, until string) map[string]interface{} {
+	t.Helper()
+
+	upstream.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var msg map[string]interface{}
+		if err := upstream.ReadJSON(&msg); err != nil {
+			t.Fatalf("Did not receive %s upstream: %v", until, err)
+		}
+		if msg["type"] == until {
+			return msg
+		}
+	}
+}
+
+func TestBargeIn_SpeechStartedCancelsAndTruncates(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+
+	clientConn, client := wsPair(t)
+	gptConn, upstream := wsPair(t)
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, nil)
+	t.Cleanup(func() { service.EndSession(session) })
+	go service.HandleRealtimeResponse(session)
+
+	// 10 秒语音，确保打断时客户端仍在播放
+	audio := base64.StdEncoding.EncodeToString(make([]byte, realtimeAudioBytesPerMs*10000))
+	for _, event := range []map[string]interface{}{
+		{"type": "response.created", "response": map[string]interface{}{"id": "resp_1"}},
+		{"type": "response.audio.delta", "response_id": "resp_1", "item_id": "item_1", "delta": audio},
+		{"type": "input_audio_buffer.speech_started"},
+		{"type": "response.audio.delta", "response_id": "resp_1", "item_id": "item_1", "delta": audio},
+		{"type": "response.done", "response": map[string]interface{}{"id": "resp_1", "status": "cancelled"}},
+	} {
+		if err := upstream.WriteJSON(event); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+
+	readUpstream(t, upstream, "response.cancel")
+	truncate := readUpstream(t, upstream, "conversation.item.truncate")
+	if truncate["item_id"] != "item_1" {
+		t.Errorf("Unexpected truncate item: %v", truncate)
+	}
+	if endMs := truncate["audio_end_ms"].(float64); endMs < 0 || endMs >= 10000 {
+		t.Errorf("Expected audio_end_ms within the sent audio, got %v", endMs)
+	}
+
+	// 打断后的语音增量被丢弃：客户端只收到一段语音
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	audioCount := 0
+	interrupted := false
+	for {
+		var msg map[string]interface{}
+		if err := client.ReadJSON(&msg); err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		switch msg["type"] {
+		case "audio_response":
+			audioCount++
+		case "response_interrupted":
+			interrupted = true
+			if msg["reason"] != InterruptReasonSpeech || msg["response_id"] != "resp_1" {
+				t.Errorf("Unexpected interruption: %v", msg)
+			}
+		}
+		if msg["type"] == "response_complete" {
+			break
+		}
+	}
+	if !interrupted {
+		t.Error("Expected client to be notified of the interruption")
+	}
+	if audioCount != 1 {
+		t.Errorf("Expected audio after the interruption to be dropped, got %d audio messages", audioCount)
+	}
+}
+
+func TestBargeIn_ClientReportedOffset(t *testing.T) {
+	session := &RealtimeSession{}
+	session.startResponse("resp_1")
+	session.recordAudioSent("item_1", 2000)
+	session.finishResponse("resp_1")
+
+	action, ok := session.takeInterruption(5000)
+	if !ok {
+		t.Fatal("Expected an interruption while audio is still playing")
+	}
+	if action.responseID != "" {
+		t.Errorf("Completed response must not be cancelled, got %q", action.responseID)
+	}
+	if action.itemID != "item_1" || action.audioEndMs != 2000 {
+		t.Errorf("Expected truncation clamped to the sent audio, got %+v", action)
+	}
+
+	if _, ok := session.takeInterruption(100); ok {
+		t.Error("Expected a second interruption to be a no-op")
+	}
+}
+
+func TestBargeIn_InterpreterIgnoresSpeechStarted(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+	cfg, err := service.ResolveSessionConfig(nil, &RealtimeSessionConfigRequest{
+		Mode:           RealtimeModeInterpreter,
+		SourceLanguage: "en",
+		TargetLanguage: "zh-Hans",
+	})
+	if err != nil {
+		t.Fatalf("ResolveSessionConfig failed: %v", err)
+	}
+
+	clientConn, client := wsPair(t)
+	gptConn, upstream := wsPair(t)
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, cfg)
+	t.Cleanup(func() { service.EndSession(session) })
+	go service.HandleRealtimeResponse(session)
+
+	// 说话人开口不打断上一句译文
+	audio := base64.StdEncoding.EncodeToString(make([]byte, realtimeAudioBytesPerMs*10000))
+	for _, event := range []map[string]interface{}{
+		{"type": "response.created", "response": map[string]interface{}{"id": "resp_1"}},
+		{"type": "response.audio.delta", "response_id": "resp_1", "item_id": "item_1", "delta": audio},
+		{"type": "input_audio_buffer.speech_started"},
+		{"type": "response.audio.delta", "response_id": "resp_1", "item_id": "item_1", "delta": audio},
+		{"type": "response.done", "response": map[string]interface{}{"id": "resp_1", "status": "completed"}},
+	} {
+		if err := upstream.WriteJSON(event); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	audioCount := 0
+	for {
+		var msg map[string]interface{}
+		if err := client.ReadJSON(&msg); err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		if msg["type"] == "response_interrupted" {
+			t.Fatalf("Unexpected interruption in interpreter mode: %v", msg)
+		}
+		if msg["type"] == "audio_response" {
+			audioCount++
+		}
+		if msg["type"] == "response_complete" {
+			break
+		}
+	}
+	if audioCount != 2 {
+		t.Errorf("Expected both audio deltas to be forwarded, got %d", audioCount)
+	}
+
+	// 客户端显式打断仍然生效，且这是发往上游的第一条消息
+	if err := service.InterruptResponse(session, InterruptReasonClient, -1); err != nil {
+		t.Fatalf("InterruptResponse failed: %v", err)
+	}
+	upstream.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg map[string]interface{}
+	if err := upstream.ReadJSON(&msg); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if msg["type"] != "conversation.item.truncate" || msg["item_id"] != "item_1" {
+		t.Errorf("Expected only the explicit interrupt to truncate, got %v", msg)
+	}
+}
@@ -0,0 +1,435 @@
+package service
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// 预处理参数范围与默认值
+const (
+	defaultHighPassCutoffHz = 80.0
+	defaultGateThresholdDB  = -55.0
+	defaultAGCTargetDB      = -20.0
+	minHighPassCutoffHz     = 20.0
+	maxHighPassCutoffHz     = 300.0
+	minGateThresholdDB      = -80.0
+	maxGateThresholdDB      = -30.0
+	minAGCTargetDB          = -30.0
+	maxAGCTargetDB          = -6.0
+)
+
+// AudioPreprocessingConfig 上行音频预处理链，按高通、降噪、噪声门、自动增益的顺序处理，各级可单独开关
+type AudioPreprocessingConfig struct {
+	HighPass         bool    `json:"high_pass"`           // 去直流高通，滤除风噪和低频隆隆声
+	HighPassCutoffHz float64 `json:"high_pass_cutoff_hz"` // 高通截止频率
+	NoiseSuppression bool    `json:"noise_suppression"`   // 频谱降噪，增加约一帧（20ms 左右）延迟
+	NoiseGate        bool    `json:"noise_gate"`          // 低于门限时衰减，去掉说话间隙的底噪
+	GateThresholdDB  float64 `json:"gate_threshold_db"`   // 噪声门开启的峰值电平（dBFS）
+	AGC              bool    `json:"agc"`                 // 自动增益，输出峰值限制在 -1 dBFS
+	AGCTargetDB      float64 `json:"agc_target_db"`       // 自动增益的目标 RMS 电平（dBFS）
+}
+
+// DefaultAudioPreprocessingConfig 默认全部关闭
+func DefaultAudioPreprocessingConfig() AudioPreprocessingConfig {
+	return AudioPreprocessingConfig{
+		HighPassCutoffHz: defaultHighPassCutoffHz,
+		GateThresholdDB:  defaultGateThresholdDB,
+		AGCTargetDB:      defaultAGCTargetDB,
+	}
+}
+
+// enabled 是否有任何一级开启
+func (c AudioPreprocessingConfig) enabled() bool {
+	return c.HighPass || c.NoiseSuppression || c.NoiseGate || c.AGC
+}
+
+// validate 检查参数范围
+func (c AudioPreprocessingConfig) validate() error {
+	if c.HighPassCutoffHz < minHighPassCutoffHz || c.HighPassCutoffHz > maxHighPassCutoffHz {
+		return fmt.Errorf("high_pass_cutoff_hz must be between %.0f and %.0f", minHighPassCutoffHz, maxHighPassCutoffHz)
+	}
+	if c.GateThresholdDB < minGateThresholdDB || c.GateThresholdDB > maxGateThresholdDB {
+		return fmt.Errorf("gate_threshold_db must be between %.0f and %.0f", minGateThresholdDB, maxGateThresholdDB)
+	}
+	if c.AGCTargetDB < minAGCTargetDB || c.AGCTargetDB > maxAGCTargetDB {
+		return fmt.Errorf("agc_target_db must be between %.0f and %.0f", minAGCTargetDB, maxAGCTargetDB)
+	}
+	return nil
+}
+
+// preprocessor 单声道流式预处理链，各级在块之间保留状态
+type preprocessor struct {
+	highPass *biquad
+	denoiser *spectralDenoiser
+	gate     *noiseGate
+	agc      *autoGain
+}
+
+// newPreprocessor 按配置创建处理链，没有开启任何一级时返回 nil
+func newPreprocessor(cfg AudioPreprocessingConfig, rate int) *preprocessor {
+	if !cfg.enabled() {
+		return nil
+	}
+	p := &preprocessor{}
+	if cfg.HighPass {
+		p.highPass = newHighPassFilter(float64(rate), cfg.HighPassCutoffHz)
+	}
+	if cfg.NoiseSuppression {
+		p.denoiser = newSpectralDenoiser(rate)
+	}
+	if cfg.NoiseGate {
+		p.gate = newNoiseGate(float64(rate), cfg.GateThresholdDB)
+	}
+	if cfg.AGC {
+		p.agc = newAutoGain(float64(rate), cfg.AGCTargetDB)
+	}
+	return p
+}
+
+// Process 处理单声道 PCM16，开启频谱降噪时输出比输入滞后一帧，长度按帧移变化
+func (p *preprocessor) Process(pcm []byte) []byte {
+	samples := make([]float64, len(pcm)/2)
+	for i := range samples {
+		samples[i] = float64(int16(binary.LittleEndian.Uint16(pcm[i*2:])))
+	}
+
+	if p.highPass != nil {
+		for i, x := range samples {
+			samples[i] = p.highPass.process(x)
+		}
+	}
+	if p.denoiser != nil {
+		samples = p.denoiser.process(samples)
+	}
+	return p.finish(samples)
+}
+
+// Flush 输出降噪器滞后的尾部样本，提交音频前调用；未开启降噪时没有滞后，返回 nil
+func (p *preprocessor) Flush() []byte {
+	if p.denoiser == nil {
+		return nil
+	}
+	return p.finish(p.denoiser.flush())
+}
+
+// finish 执行降噪之后的噪声门和自动增益，转换为 PCM16
+func (p *preprocessor) finish(samples []float64) []byte {
+	for i, x := range samples {
+		if p.gate != nil {
+			x = p.gate.process(x)
+		}
+		if p.agc != nil {
+			x = p.agc.process(x)
+		}
+		samples[i] = x
+	}
+
+	out := make([]byte, len(samples)*2)
+	for i, x := range samples {
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(clampInt16(x)))
+	}
+	return out
+}
+
+// biquad 二阶 IIR 滤波器（直接 I 型）
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+// newHighPassFilter 二阶巴特沃斯高通，零点在直流处，同时去除直流偏置
+func newHighPassFilter(rate, cutoff float64) *biquad {
+	w0 := 2 * math.Pi * cutoff / rate
+	cosW, alpha := math.Cos(w0), math.Sin(w0)/math.Sqrt2 // Q = 1/√2
+	a0 := 1 + alpha
+	return &biquad{
+		b0: (1 + cosW) / 2 / a0,
+		b1: -(1 + cosW) / a0,
+		b2: (1 + cosW) / 2 / a0,
+		a1: -2 * cosW / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// smoothingCoef 一阶平滑系数，时间常数为 seconds
+func smoothingCoef(rate, seconds float64) float64 {
+	return 1 - math.Exp(-1/(rate*seconds))
+}
+
+// noiseGate 峰值包络低于门限并超过保持时间后，增益平滑降到 -40 dB
+type noiseGate struct {
+	threshold  float64
+	envelope   float64
+	envAttack  float64
+	envRelease float64
+	gain       float64
+	gainOpen   float64
+	gainClose  float64
+	hold       int
+	holdLeft   int
+	closedGain float64
+}
+
+func newNoiseGate(rate, thresholdDB float64) *noiseGate {
+	return &noiseGate{
+		threshold:  32768 * math.Pow(10, thresholdDB/20),
+		envAttack:  smoothingCoef(rate, 0.001),
+		envRelease: smoothingCoef(rate, 0.05),
+		gain:       1,
+		gainOpen:   smoothingCoef(rate, 0.002),
+		gainClose:  smoothingCoef(rate, 0.05),
+		hold:       int(rate * 0.1),
+		closedGain: 0.01,
+	}
+}
+
+func (g *noiseGate) process(x float64) float64 {
+	level := math.Abs(x)
+	if level > g.envelope {
+		g.envelope += (level - g.envelope) * g.envAttack
+	} else {
+		g.envelope += (level - g.envelope) * g.envRelease
+	}
+
+	target := g.closedGain
+	switch {
+	case g.envelope >= g.threshold:
+		g.holdLeft = g.hold
+		target = 1
+	case g.holdLeft > 0:
+		g.holdLeft--
+		target = 1
+	}
+	if target > g.gain {
+		g.gain += (target - g.gain) * g.gainOpen
+	} else {
+		g.gain += (target - g.gain) * g.gainClose
+	}
+	return x * g.gain
+}
+
+// autoGain 把平滑后的 RMS 电平拉向目标，增益限制在 -12 到 +20 dB；
+// 电平低于 -60 dBFS 时保持当前增益，不放大底噪；输出经过峰值限幅器，不会削波
+type autoGain struct {
+	target     float64 // 目标 RMS 幅度
+	power      float64 // 平滑后的信号功率
+	powerCoef  float64
+	gain       float64
+	gainCoef   float64
+	minPower   float64
+	limit      float64
+	limitGain  float64
+	limRelease float64
+}
+
+const (
+	agcMinGain = 0.25 // -12 dB
+	agcMaxGain = 10   // +20 dB
+)
+
+func newAutoGain(rate, targetDB float64) *autoGain {
+	floor := 32768 * math.Pow(10, -60.0/20)
+	return &autoGain{
+		target:     32768 * math.Pow(10, targetDB/20),
+		powerCoef:  smoothingCoef(rate, 0.1),
+		gain:       1,
+		gainCoef:   smoothingCoef(rate, 0.5),
+		minPower:   floor * floor,
+		limit:      32768 * math.Pow(10, -1.0/20),
+		limitGain:  1,
+		limRelease: smoothingCoef(rate, 0.05),
+	}
+}
+
+func (a *autoGain) process(x float64) float64 {
+	a.power += (x*x - a.power) * a.powerCoef
+	if a.power > a.minPower {
+		desired := math.Min(math.Max(a.target/math.Sqrt(a.power), agcMinGain), agcMaxGain)
+		a.gain += (desired - a.gain) * a.gainCoef
+	}
+
+	y := x * a.gain
+	// 峰值限幅：先按释放时间恢复，超过上限时立即压低
+	a.limitGain += (1 - a.limitGain) * a.limRelease
+	if math.Abs(y)*a.limitGain > a.limit {
+		a.limitGain = a.limit / math.Abs(y)
+	}
+	return y * a.limitGain
+}
+
+// spectralDenoiser 基于短时傅里叶变换的谱减降噪
+// 50% 重叠的平方根汉宁窗分析与合成，噪声谱按最小值跟踪估计，增益有下限并做时间平滑以减少音乐噪声
+type spectralDenoiser struct {
+	size    int
+	hop     int
+	window  []float64
+	input   []float64
+	overlap []float64
+	power   []float64 // 平滑后的各频点功率
+	noise   []float64 // 噪声功率估计
+	gain    []float64
+	frames  int
+	buf     []complex128
+}
+
+const (
+	denoiseFrameMs      = 20
+	denoiseOverSubtract = 2.0  // 最小值跟踪低估噪声均值，按 2 倍扣除
+	denoiseGainFloor    = 0.1  // 最多衰减 20 dB
+	denoiseNoiseRise    = 1.02 // 噪声估计每帧最多上升约 0.09 dB
+	denoiseInitFrames   = 5    // 前几帧直接作为噪声估计的初值
+)
+
+func newSpectralDenoiser(rate int) *spectralDenoiser {
+	size := 1
+	for size < rate*denoiseFrameMs/1000 {
+		size <<= 1
+	}
+	d := &spectralDenoiser{
+		size:    size,
+		hop:     size / 2,
+		window:  make([]float64, size),
+		overlap: make([]float64, size/2),
+		power:   make([]float64, size/2+1),
+		noise:   make([]float64, size/2+1),
+		gain:    make([]float64, size/2+1),
+		buf:     make([]complex128, size),
+	}
+	for i := range d.window {
+		// 周期平方根汉宁窗，分析和合成各乘一次，50% 重叠时平方和为 1
+		d.window[i] = math.Sqrt(0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(size)))
+	}
+	for i := range d.gain {
+		d.gain[i] = 1
+	}
+	return d
+}
+
+// process 输入任意长度的样本，输出已完成重叠相加的部分
+func (d *spectralDenoiser) process(samples []float64) []float64 {
+	d.input = append(d.input, samples...)
+	var out []float64
+	for len(d.input) >= d.size {
+		frame := d.processFrame(d.input[:d.size])
+		for i := 0; i < d.hop; i++ {
+			out = append(out, d.overlap[i]+frame[i])
+		}
+		copy(d.overlap, frame[d.hop:])
+		d.input = d.input[d.hop:]
+	}
+	d.input = append([]float64(nil), d.input...)
+	return out
+}
+
+// flush 补零把缓冲中的样本全部输出并清空重叠状态
+// 补零帧不代表真实的噪声，处理前后保留噪声估计和增益
+func (d *spectralDenoiser) flush() []float64 {
+	pending := len(d.input)
+	if pending == 0 {
+		return nil
+	}
+	power := append([]float64(nil), d.power...)
+	noise := append([]float64(nil), d.noise...)
+	gain := append([]float64(nil), d.gain...)
+	frames := d.frames
+
+	out := d.process(make([]float64, d.size))[:pending]
+
+	copy(d.power, power)
+	copy(d.noise, noise)
+	copy(d.gain, gain)
+	d.frames = frames
+	d.input = nil
+	clear(d.overlap)
+	return out
+}
+
+func (d *spectralDenoiser) processFrame(frame []float64) []float64 {
+	for i, x := range frame {
+		d.buf[i] = complex(x*d.window[i], 0)
+	}
+	fft(d.buf, false)
+
+	bins := d.size/2 + 1
+	for k := 0; k < bins; k++ {
+		p := real(d.buf[k])*real(d.buf[k]) + imag(d.buf[k])*imag(d.buf[k])
+		if d.frames == 0 {
+			d.power[k] = p
+		} else {
+			d.power[k] = 0.8*d.power[k] + 0.2*p
+		}
+
+		switch {
+		case d.frames < denoiseInitFrames:
+			d.noise[k] += d.power[k] / denoiseInitFrames
+		case d.power[k] < d.noise[k]:
+			d.noise[k] = d.power[k]
+		default:
+			d.noise[k] = math.Min(d.noise[k]*denoiseNoiseRise, d.power[k])
+		}
+
+		g := denoiseGainFloor
+		if p > 0 {
+			g = math.Max(1-denoiseOverSubtract*d.noise[k]/p, denoiseGainFloor)
+		}
+		d.gain[k] = 0.5*d.gain[k] + 0.5*g
+
+		d.buf[k] *= complex(d.gain[k], 0)
+		if k > 0 && k < d.size/2 {
+			d.buf[d.size-k] = cmplx.Conj(d.buf[k])
+		}
+	}
+	d.frames++
+
+	fft(d.buf, true)
+	out := make([]float64, d.size)
+	for i := range out {
+		out[i] = real(d.buf[i]) * d.window[i]
+	}
+	return out
+}
+
+// fft 原地基 2 快速傅里叶变换，长度必须是 2 的幂，inverse 时结果已除以长度
+func fft(x []complex128, inverse bool) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	sign := -1.0
+	if inverse {
+		sign = 1
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Rect(1, sign*2*math.Pi/float64(size))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], x[start+k+size/2]*w
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				w *= step
+			}
+		}
+	}
+	if inverse {
+		for i := range x {
+			x[i] /= complex(float64(n), 0)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package service
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// scalePCM 按增益缩放 PCM16 并加上直流偏置，超出范围时削波
+func scalePCM(pcm []byte, gain, offset float64) []byte {
+	out := make([]byte, len(pcm))
+	for i := 0; i+1 < len(pcm); i += 2 {
+		v := float64(int16(binary.LittleEndian.Uint16(pcm[i:])))*gain + offset
+		binary.LittleEndian.PutUint16(out[i:], uint16(clampInt16(v)))
+	}
+	return out
+}
+
+// mixPCM 逐样本相加两段等长的 PCM16
+func mixPCM(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := 0; i+1 < len(a); i += 2 {
+		v := float64(int16(binary.LittleEndian.Uint16(a[i:]))) + float64(int16(binary.LittleEndian.Uint16(b[i:])))
+		binary.LittleEndian.PutUint16(out[i:], uint16(clampInt16(v)))
+	}
+	return out
+}
+
+// newPreprocessingProcessor 创建 24kHz 单声道（与上游同采样率，不经重采样）并开启预处理的音频处理器
+func newPreprocessingProcessor(t *testing.T, cfg AudioPreprocessingConfig) *AudioProcessor {
+	t.Helper()
+	ap := NewAudioProcessor()
+	if err := ap.SetAudioConfig(AudioConfig{SampleRate: RealtimeSampleRate, ChannelCount: 1, BitDepth: 16}); err != nil {
+		t.Fatalf("SetAudioConfig failed: %v", err)
+	}
+	if err := ap.SetPreprocessing(cfg); err != nil {
+		t.Fatalf("SetPreprocessing failed: %v", err)
+	}
+	return ap
+}
+
+// preprocessChunks 按 20ms 分块依次经 ProcessRealtimeAudioChunk 和 ConvertInputAudio 处理，返回处理后的样本
+func preprocessChunks(t *testing.T, ap *AudioProcessor, pcm []byte) []float64 {
+	t.Helper()
+	var out []byte
+	for _, chunk := range splitChunks(pcm, RealtimeSampleRate/1000*20*2) {
+		processed, err := ap.ProcessRealtimeAudioChunk(chunk)
+		if err != nil {
+			t.Fatalf("ProcessRealtimeAudioChunk failed: %v", err)
+		}
+		converted, err := ap.ConvertInputAudio(processed)
+		if err != nil {
+			t.Fatalf("ConvertInputAudio failed: %v", err)
+		}
+		out = append(out, converted...)
+	}
+	return pcmSamples(out)
+}
+
+// tail 跳过开头 ms 毫秒的暖机段
+func tail(samples []float64, ms int) []float64 {
+	return samples[RealtimeSampleRate/1000*ms:]
+}
+
+func peak(samples []float64) float64 {
+	var p float64
+	for _, s := range samples {
+		p = math.Max(p, math.Abs(s))
+	}
+	return p
+}
+
+func TestPreprocessing_HighPass(t *testing.T) {
+	cfg := DefaultAudioPreprocessingConfig()
+	cfg.HighPass = true
+
+	// 30Hz 风噪衰减，1kHz 语音频段不受影响
+	ap := newPreprocessingProcessor(t, cfg)
+	rumble := ap.GenerateTestAudioData(500, 30)
+	if got, in := rms(tail(preprocessChunks(t, ap, rumble), 200)), rms(tail(pcmSamples(rumble), 200)); got > in*0.2 {
+		t.Errorf("Expected 30Hz to be attenuated by at least 14dB, got %.1f -> %.1f", in, got)
+	}
+
+	ap = newPreprocessingProcessor(t, cfg)
+	tone := ap.GenerateTestAudioData(500, 1000)
+	if amplitude, _ := analyzeTone(preprocessChunks(t, ap, tone), RealtimeSampleRate, 1000); math.Abs(amplitude-16383) > 16383*0.01 {
+		t.Errorf("Expected 1kHz amplitude 16383, got %.1f", amplitude)
+	}
+
+	// 直流偏置被去除
+	ap = newPreprocessingProcessor(t, cfg)
+	var sum float64
+	out := tail(preprocessChunks(t, ap, scalePCM(tone, 0.5, 5000)), 200)
+	for _, s := range out {
+		sum += s
+	}
+	if mean := sum / float64(len(out)); math.Abs(mean) > 20 {
+		t.Errorf("Expected DC offset to be removed, mean %.1f", mean)
+	}
+}
+
+func TestPreprocessing_NoiseGate(t *testing.T) {
+	cfg := DefaultAudioPreprocessingConfig()
+	cfg.NoiseGate = true
+
+	ap := newPreprocessingProcessor(t, cfg)
+	hiss := scalePCM(ap.GenerateTestAudioData(500, 3000), 0.002, 0) // 峰值约 -60 dBFS
+	if got := rms(tail(preprocessChunks(t, ap, hiss), 300)); got > rms(pcmSamples(hiss))*0.05 {
+		t.Errorf("Expected gate to close on low-level noise, got RMS %.2f", got)
+	}
+
+	// 语音电平开门，开门后信号不变
+	loud := ap.GenerateTestAudioData(300, 1000)
+	out := preprocessChunks(t, ap, loud)
+	if amplitude, _ := analyzeTone(out, RealtimeSampleRate, 1000); math.Abs(amplitude-16383) > 16383*0.02 {
+		t.Errorf("Expected open gate to pass speech unchanged, got amplitude %.1f", amplitude)
+	}
+
+	// 保持时间内不关门
+	held := preprocessChunks(t, ap, hiss[:RealtimeSampleRate/1000*60*2])
+	if got := rms(held); got < rms(pcmSamples(hiss))*0.9 {
+		t.Errorf("Expected gate to stay open during hold, got RMS %.2f", got)
+	}
+}
+
+func TestPreprocessing_AGC(t *testing.T) {
+	cfg := DefaultAudioPreprocessingConfig()
+	cfg.AGC = true
+	target := 32768 * math.Pow(10, defaultAGCTargetDB/20)
+
+	for _, gain := range []float64{0.05, 1} {
+		ap := newPreprocessingProcessor(t, cfg)
+		signal := scalePCM(ap.GenerateTestAudioData(4000, 440), gain, 0)
+		got := rms(tail(preprocessChunks(t, ap, signal), 3000))
+		if db := 20 * math.Log10(got/target); math.Abs(db) > 1.5 {
+			t.Errorf("gain %.2f: expected output within 1.5dB of %.0f dBFS, off by %.1fdB", gain, defaultAGCTargetDB, db)
+		}
+	}
+
+	// 增益已升高时的突发大声被限幅，不削波
+	ap := newPreprocessingProcessor(t, cfg)
+	quiet := scalePCM(ap.GenerateTestAudioData(2000, 440), 0.05, 0)
+	burst := scalePCM(ap.GenerateTestAudioData(200, 440), 2, 0)
+	out := preprocessChunks(t, ap, append(quiet, burst...))
+	limit := 32768 * math.Pow(10, -1.0/20)
+	if p := peak(out); p > limit+1 {
+		t.Errorf("Expected limiter to keep peaks under %.0f, got %.0f", limit, p)
+	}
+}
+
+func TestPreprocessing_NoiseSuppression(t *testing.T) {
+	cfg := DefaultAudioPreprocessingConfig()
+	cfg.NoiseSuppression = true
+
+	// 先有一段纯噪声，与实际说话前的环境噪声一致；持续不变的信号从一开始就会被当作噪声
+	ap := newPreprocessingProcessor(t, cfg)
+	lead := RealtimeSampleRate / 1000 * 500 * 2
+	tone := append(make([]byte, lead), scalePCM(ap.GenerateTestAudioData(1500, 1000), 0.25, 0)...)
+	noisy := mixPCM(tone, generateNoise(RealtimeSampleRate, 3000, 2000))
+
+	_, snrBefore := analyzeTone(pcmSamples(noisy[lead:]), RealtimeSampleRate, 1000)
+	out := preprocessChunks(t, ap, noisy)
+	if len(out) < len(noisy)/2-RealtimeSampleRate/1000*50 {
+		t.Fatalf("Expected at most one frame of latency, got %d of %d samples", len(out), len(noisy)/2)
+	}
+	// 输出滞后一帧，最小二乘拟合不依赖相位
+	amplitude, snrAfter := analyzeTone(out[lead/2:], RealtimeSampleRate, 1000)
+	if snrAfter-snrBefore < 6 {
+		t.Errorf("Expected noise suppression to improve SNR by 6dB, got %.1f -> %.1f dB", snrBefore, snrAfter)
+	}
+	if math.Abs(amplitude-4096) > 4096*0.2 {
+		t.Errorf("Expected tone amplitude about 4096, got %.1f", amplitude)
+	}
+
+	// 纯噪声段被衰减
+	if got, in := rms(out[RealtimeSampleRate/10:lead/2]), rms(pcmSamples(noisy[:lead])); got > in*0.5 {
+		t.Errorf("Expected noise-only input to be attenuated, got RMS %.1f -> %.1f", in, got)
+	}
+}
+
+func TestPreprocessing_G711Passthrough(t *testing.T) {
+	ap := NewAudioProcessor()
+	ap.setUpstreamFormat(FormatG711ULaw)
+	ap.SetAudioConfig(AudioConfig{SampleRate: 8000, ChannelCount: 1, BitDepth: 16, Format: FormatG711ULaw})
+	cfg := DefaultAudioPreprocessingConfig()
+	cfg.HighPass = true
+	ap.SetPreprocessing(cfg)
+
+	// 直通 G.711 时解码处理后重新编码
+	offset := encodeG711(scalePCM(ap.GenerateTestAudioData(500, 1000), 0.25, 4000), FormatG711ULaw)
+	processed, err := ap.ProcessRealtimeAudioChunk(offset)
+	if err != nil {
+		t.Fatalf("ProcessRealtimeAudioChunk failed: %v", err)
+	}
+	out, err := ap.ConvertInputAudio(processed)
+	if err != nil || len(out) != len(offset) {
+		t.Fatalf("Expected %d G.711 bytes, got %d (%v)", len(offset), len(out), err)
+	}
+	var sum float64
+	samples := pcmSamples(decodeG711(out, FormatG711ULaw))[2000:]
+	for _, s := range samples {
+		sum += s
+	}
+	if mean := sum / float64(len(samples)); math.Abs(mean) > 100 {
+		t.Errorf("Expected DC offset to be removed before re-encoding, mean %.1f", mean)
+	}
+}
+
+func TestPreprocessing_BeforeMeteringAndVAD(t *testing.T) {
+	cfg := DefaultAudioPreprocessingConfig()
+	cfg.HighPass = true
+	ap := newPreprocessingProcessor(t, cfg)
+	suppression := DefaultSilenceSuppressionConfig()
+	suppression.Enabled = true
+	if err := ap.SetSilenceSuppression(suppression); err != nil {
+		t.Fatalf("SetSilenceSuppression failed: %v", err)
+	}
+
+	// 麦克风的直流偏置经高通去除后，电平统计和本地 VAD 都不应把它当作语音
+	offset := scalePCM(make([]byte, RealtimeSampleRate*2), 1, 8000)
+	for _, chunk := range splitChunks(offset, RealtimeSampleRate/1000*20*2) {
+		if _, err := ap.ProcessRealtimeAudioChunk(chunk); err != nil {
+			t.Fatalf("ProcessRealtimeAudioChunk failed: %v", err)
+		}
+	}
+	if activity := ap.TakeVoiceActivity(); activity.SpeechMs > 100 {
+		t.Errorf("Expected the filtered offset to be treated as silence, got %+v", activity)
+	}
+	level, ok := ap.TakeAudioLevel(1000)
+	if !ok || level.RMSDBFS > -40 || math.Abs(level.DCOffset) > 0.01 {
+		t.Errorf("Expected the meter to see the filtered audio, got %+v (%v)", level, ok)
+	}
+}
+
+func TestPreprocessing_FlushDenoiserTail(t *testing.T) {
+	cfg := DefaultAudioPreprocessingConfig()
+	cfg.NoiseSuppression = true
+	ap := newPreprocessingProcessor(t, cfg)
+
+	// 降噪输出滞后于输入，提交前取出尾部后总长度与输入一致
+	tone := ap.GenerateTestAudioData(110, 1000)
+	var out []byte
+	for _, chunk := range splitChunks(tone, RealtimeSampleRate/1000*20*2) {
+		processed, err := ap.ProcessRealtimeAudioChunk(chunk)
+		if err != nil {
+			t.Fatalf("ProcessRealtimeAudioChunk failed: %v", err)
+		}
+		out = append(out, processed...)
+	}
+	if len(out) >= len(tone) {
+		t.Fatalf("Expected the denoiser to hold back a tail, got %d of %d bytes", len(out), len(tone))
+	}
+	out = append(out, ap.FlushPreprocessing()...)
+	if len(out) != len(tone) {
+		t.Errorf("Expected %d bytes after flush, got %d", len(tone), len(out))
+	}
+	if extra := ap.FlushPreprocessing(); len(extra) != 0 {
+		t.Errorf("Expected nothing left after flush, got %d bytes", len(extra))
+	}
+
+	// 未开启降噪时没有滞后
+	ap = newPreprocessingProcessor(t, DefaultAudioPreprocessingConfig())
+	if out := ap.FlushPreprocessing(); out != nil {
+		t.Errorf("Expected no tail without preprocessing, got %d bytes", len(out))
+	}
+}
+
+func TestResolveSessionConfig_Preprocessing(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+
+	on, cutoff := true, 120.0
+	cfg, err := service.ResolveSessionConfig(nil, &RealtimeSessionConfigRequest{
+		Preprocessing: &AudioPreprocessingRequest{HighPass: &on, HighPassCutoffHz: &cutoff, AGC: &on},
+	})
+	if err != nil {
+		t.Fatalf("ResolveSessionConfig failed: %v", err)
+	}
+	pp := cfg.Preprocessing
+	if !pp.HighPass || pp.HighPassCutoffHz != cutoff || !pp.AGC || pp.NoiseGate || pp.AGCTargetDB != defaultAGCTargetDB {
+		t.Errorf("Unexpected preprocessing config %+v", pp)
+	}
+
+	// 再次配置只改提交的字段
+	off := false
+	cfg, err = service.ResolveSessionConfig(cfg, &RealtimeSessionConfigRequest{Preprocessing: &AudioPreprocessingRequest{AGC: &off}})
+	if err != nil || !cfg.Preprocessing.HighPass || cfg.Preprocessing.AGC {
+		t.Errorf("Expected only AGC to be turned off, got %+v (%v)", cfg.Preprocessing, err)
+	}
+
+	badCutoff, badTarget := 1000.0, 0.0
+	for name, req := range map[string]*AudioPreprocessingRequest{
+		"cutoff": {HighPassCutoffHz: &badCutoff},
+		"target": {AGCTargetDB: &badTarget},
+	} {
+		if _, err := service.ResolveSessionConfig(nil, &RealtimeSessionConfigRequest{Preprocessing: req}); err == nil {
+			t.Errorf("%s: expected invalid preprocessing config to be rejected", name)
+		}
+	}
+}
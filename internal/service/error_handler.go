@@ -232,40 +232,70 @@ func (eh *ErrorHandler) ResetConnectionAttempts(endpoint string) {
 	log.Printf("Reset connection attempts for %s", endpoint)
 }
 
-// RetryWithBackoff 带退避的重试机制
-func (eh *ErrorHandler) RetryWithBackoff(ctx context.Context, operation func() error, maxRetries int) error {
-	var lastErr error
-	
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
+// BackoffPolicy 重试次数和指数退避间隔
+type BackoffPolicy struct {
+	MaxAttempts int           // 最多尝试次数
+	BaseDelay   time.Duration // 第一次退避的等待时间，之后每次翻倍
+	MaxDelay    time.Duration // 单次等待上限，0 表示不限
+	DelayFirst  bool          // 第一次尝试前也等待，用于连接刚断开时的重连
+}
 
-		err := operation()
-		if err == nil {
-			return nil // 成功
-		}
+// Delay 第 n 次退避的等待时间
+func (p BackoffPolicy) Delay(n int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < n && (p.MaxDelay == 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
 
-		lastErr = err
-		log.Printf("Operation failed (attempt %d/%d): %v", attempt, maxRetries, err)
+// RetryWithPolicy 按退避策略重试 operation，直到成功、次数用完或 ctx 结束
+// key 非空时在重连尝试记录中跟踪进行中的尝试次数，结束后清除；operation 收到当前的尝试序号
+func (eh *ErrorHandler) RetryWithPolicy(ctx context.Context, key string, policy BackoffPolicy, operation func(attempt int) error) error {
+	if key != "" {
+		defer eh.ResetConnectionAttempts(key)
+	}
 
-		if attempt < maxRetries {
-			// 指数退避
-			backoffDuration := time.Duration(attempt) * eh.retryInterval
-			log.Printf("Retrying in %v...", backoffDuration)
-			
+	var lastErr error
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		backoff := attempt - 1
+		if policy.DelayFirst {
+			backoff = attempt
+		}
+		if backoff > 0 {
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
-			case <-time.After(backoffDuration):
-				// 继续下一次重试
+			case <-time.After(policy.Delay(backoff)):
 			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if key != "" {
+			eh.mu.Lock()
+			eh.reconnectAttempts[key] = attempt
+			eh.mu.Unlock()
+		}
+
+		err := operation(attempt)
+		if err == nil {
+			return nil // 成功
 		}
+		lastErr = err
+		log.Printf("Operation failed (attempt %d/%d): %v", attempt, policy.MaxAttempts, err)
 	}
 
-	return fmt.Errorf("operation failed after %d attempts: %v", maxRetries, lastErr)
+	return fmt.Errorf("operation failed after %d attempts: %v", policy.MaxAttempts, lastErr)
+}
+
+// RetryWithBackoff 带退避的重试机制，从默认重试间隔开始指数退避
+func (eh *ErrorHandler) RetryWithBackoff(ctx context.Context, operation func() error, maxRetries int) error {
+	policy := BackoffPolicy{MaxAttempts: maxRetries, BaseDelay: eh.retryInterval}
+	return eh.RetryWithPolicy(ctx, "", policy, func(int) error { return operation() })
 }
 
 // HandleGPTAPIError 处理GPT API错误
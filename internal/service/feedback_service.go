@@ -0,0 +1,82 @@
+package service
+
+import (
+	"smart-glasses-backend/internal/model"
+	"smart-glasses-backend/internal/repository"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type FeedbackService struct {
+	translateRepo *repository.TranslateRepository
+	feedbackRepo  *repository.FeedbackRepository
+	memoryRepo    *repository.TranslationMemoryRepository
+}
+
+func NewFeedbackService(translateRepo *repository.TranslateRepository, feedbackRepo *repository.FeedbackRepository, memoryRepo *repository.TranslationMemoryRepository) *FeedbackService {
+	return &FeedbackService{
+		translateRepo: translateRepo,
+		feedbackRepo:  feedbackRepo,
+		memoryRepo:    memoryRepo,
+	}
+}
+
+// SubmitFeedback 对翻译记录评分，并在提交修正译文时写入用户的翻译记忆，重新提交时撤回或替换之前的修正
+func (s *FeedbackService) SubmitFeedback(userID, translationID uuid.UUID, req *model.FeedbackRequest) (*model.TranslationFeedback, error) {
+	translation, err := s.translateRepo.GetByID(userID, translationID)
+	if err != nil {
+		return nil, err
+	}
+
+	feedback := &model.TranslationFeedback{
+		TranslationID: translation.ID,
+		UserID:        userID,
+		Rating:        1,
+		Reason:        req.Reason,
+		CorrectedText: strings.TrimSpace(req.CorrectedText),
+	}
+	if req.Rating == "down" {
+		feedback.Rating = -1
+	}
+
+	if feedback.CorrectedText != "" {
+		// 与原译文相同的修正没有信息量，不进入翻译记忆
+		if feedback.CorrectedText == strings.TrimSpace(translation.TranslatedText) {
+			feedback.CorrectionStatus = model.CorrectionStatusRejected
+		} else {
+			feedback.CorrectionStatus = model.CorrectionStatusAccepted
+		}
+	}
+
+	if err := s.feedbackRepo.Upsert(feedback); err != nil {
+		return nil, err
+	}
+
+	if feedback.CorrectionStatus == model.CorrectionStatusAccepted {
+		entry := &model.TranslationMemoryEntry{
+			UserID:         userID,
+			SourceLanguage: translation.SourceLanguage,
+			TargetLanguage: translation.TargetLanguage,
+			SourceText:     translation.SourceText,
+			TranslatedText: feedback.CorrectedText,
+			Origin:         "correction",
+			TranslationID:  &translation.ID,
+		}
+		if err := s.memoryRepo.Upsert(entry); err != nil {
+			return nil, err
+		}
+	} else {
+		// 反馈按翻译记录覆盖，重新提交时撤回这条记录之前采纳的修正
+		if err := s.memoryRepo.DeleteCorrection(userID, translation.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return feedback, nil
+}
+
+// GetFeedbackStats 获取按语言对聚合的反馈统计（管理员）
+func (s *FeedbackService) GetFeedbackStats(days int) ([]*model.FeedbackStat, error) {
+	return s.feedbackRepo.GetStatsByLanguagePair(days)
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"database/sql/driver"
+	"errors"
+	"smart-glasses-backend/internal/model"
+	"smart-glasses-backend/internal/repository"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeFeedbackDB 只应答反馈流程用到的语句：查询翻译记录、写反馈、写入和删除翻译记忆
+type fakeFeedbackDB struct {
+	translation *model.Translation // nil 表示记录不存在
+	feedback    [][]driver.Value
+	memory      [][]driver.Value // 按用户、语言对和原文唯一，与表的唯一索引一致；第 8 列为来源翻译记录
+}
+
+// findMemory 按用户、语言对和原文查找翻译记忆，未找到时返回 -1
+func (f *fakeFeedbackDB) findMemory(user, sourceLanguage, targetLanguage, sourceText driver.Value) int {
+	for i, m := range f.memory {
+		if m[1] == user && m[2] == sourceLanguage && m[3] == targetLanguage && m[4] == sourceText {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *fakeFeedbackDB) query(query string, args []driver.Value) ([][]driver.Value, error) {
+	now := time.Now()
+	switch {
+	case strings.Contains(query, "FROM translation_history"):
+		t := f.translation
+		if t == nil {
+			return nil, nil
+		}
+		return [][]driver.Value{{
+			t.ID.String(), t.UserID.String(), t.SourceText, t.TranslatedText, t.SourceLanguage, t.TargetLanguage, t.CreatedAt,
+		}}, nil
+	case strings.HasPrefix(query, "INSERT INTO translation_feedback"):
+		f.feedback = append(f.feedback, args)
+		return [][]driver.Value{{args[0], now, now}}, nil
+	case strings.HasPrefix(query, "INSERT INTO translation_memory"):
+		if i := f.findMemory(args[1], args[2], args[3], args[4]); i >= 0 {
+			f.memory[i] = args
+		} else {
+			f.memory = append(f.memory, args)
+		}
+		return [][]driver.Value{{args[0]}}, nil
+	case strings.HasPrefix(query, "DELETE FROM translation_memory"):
+		var deleted [][]driver.Value
+		kept := f.memory[:0]
+		for _, m := range f.memory {
+			if m[1] == args[0] && m[7] == args[1] && m[6] == "correction" {
+				deleted = append(deleted, m)
+			} else {
+				kept = append(kept, m)
+			}
+		}
+		f.memory = kept
+		return deleted, nil
+	}
+	return nil, errors.New("unexpected query: " + query)
+}
+
+func newTestFeedbackService(t *testing.T, translation *model.Translation) (*FeedbackService, *fakeFeedbackDB) {
+	fake := &fakeFeedbackDB{translation: translation}
+	db := openScriptedDB(t, fake.query)
+	return NewFeedbackService(
+		repository.NewTranslateRepository(db),
+		repository.NewFeedbackRepository(db),
+		repository.NewTranslationMemoryRepository(db),
+	), fake
+}
+
+func TestSubmitFeedback(t *testing.T) {
+	userID := uuid.New()
+	translation := &model.Translation{
+		ID:             uuid.New(),
+		UserID:         userID,
+		SourceText:     "Good morning",
+		TranslatedText: "早上好 ",
+		SourceLanguage: "en",
+		TargetLanguage: "zh-CN",
+		CreatedAt:      time.Now(),
+	}
+
+	tests := []struct {
+		name       string
+		req        model.FeedbackRequest
+		wantRating int
+		wantStatus string
+		wantMemory bool
+	}{
+		{"thumbs up", model.FeedbackRequest{Rating: "up"}, 1, "", false},
+		{"thumbs down", model.FeedbackRequest{Rating: "down", Reason: "inaccurate"}, -1, "", false},
+		{"blank correction", model.FeedbackRequest{Rating: "down", CorrectedText: "  \n"}, -1, "", false},
+		{"accepted correction", model.FeedbackRequest{Rating: "down", CorrectedText: " 上午好 "}, -1, model.CorrectionStatusAccepted, true},
+		{"correction equal to translation", model.FeedbackRequest{Rating: "up", CorrectedText: "早上好"}, 1, model.CorrectionStatusRejected, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, fake := newTestFeedbackService(t, translation)
+
+			feedback, err := svc.SubmitFeedback(userID, translation.ID, &tt.req)
+			if err != nil {
+				t.Fatalf("SubmitFeedback failed: %v", err)
+			}
+			if feedback.Rating != tt.wantRating || feedback.CorrectionStatus != tt.wantStatus {
+				t.Errorf("Expected rating %d status %q, got %d %q", tt.wantRating, tt.wantStatus, feedback.Rating, feedback.CorrectionStatus)
+			}
+			if feedback.ID == uuid.Nil || feedback.TranslationID != translation.ID || feedback.UserID != userID {
+				t.Errorf("Unexpected feedback %+v", feedback)
+			}
+			if len(fake.feedback) != 1 {
+				t.Fatalf("Expected one feedback write, got %d", len(fake.feedback))
+			}
+			if args := fake.feedback[0]; args[3] != int64(tt.wantRating) || args[6] != tt.wantStatus {
+				t.Errorf("Expected stored rating %d status %q, got %v %v", tt.wantRating, tt.wantStatus, args[3], args[6])
+			}
+
+			if !tt.wantMemory {
+				if len(fake.memory) != 0 {
+					t.Errorf("Expected no translation memory write, got %v", fake.memory)
+				}
+				return
+			}
+			if len(fake.memory) != 1 {
+				t.Fatalf("Expected one translation memory write, got %d", len(fake.memory))
+			}
+			// user_id, source_language, target_language, source_text, translated_text, origin, translation_id
+			got := fake.memory[0][1:8]
+			want := []driver.Value{userID.String(), "en", "zh-CN", "Good morning", "上午好", "correction", translation.ID.String()}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("Memory argument %d: expected %v, got %v", i, want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSubmitFeedback_WithdrawnCorrection(t *testing.T) {
+	userID := uuid.New()
+	translation := &model.Translation{
+		ID:             uuid.New(),
+		UserID:         userID,
+		SourceText:     "Good morning",
+		TranslatedText: "早上好",
+		SourceLanguage: "en",
+		TargetLanguage: "zh-CN",
+		CreatedAt:      time.Now(),
+	}
+	svc, fake := newTestFeedbackService(t, translation)
+	memoryText := func() []driver.Value {
+		var texts []driver.Value
+		for _, m := range fake.memory {
+			texts = append(texts, m[5])
+		}
+		return texts
+	}
+
+	submit := func(req model.FeedbackRequest) {
+		t.Helper()
+		if _, err := svc.SubmitFeedback(userID, translation.ID, &req); err != nil {
+			t.Fatalf("SubmitFeedback failed: %v", err)
+		}
+	}
+
+	// 新的修正替换之前的修正
+	submit(model.FeedbackRequest{Rating: "down", CorrectedText: "上午好"})
+	submit(model.FeedbackRequest{Rating: "down", CorrectedText: "您早"})
+	if got := memoryText(); len(got) != 1 || got[0] != "您早" {
+		t.Fatalf("Expected the correction to be replaced, got %v", got)
+	}
+
+	// 不带修正重新提交时撤回修正
+	submit(model.FeedbackRequest{Rating: "up"})
+	if got := memoryText(); len(got) != 0 {
+		t.Errorf("Expected the withdrawn correction to be removed, got %v", got)
+	}
+
+	// 修正被拒绝时同样撤回
+	submit(model.FeedbackRequest{Rating: "down", CorrectedText: "上午好"})
+	submit(model.FeedbackRequest{Rating: "up", CorrectedText: "早上好"})
+	if got := memoryText(); len(got) != 0 {
+		t.Errorf("Expected a rejected correction to withdraw the previous one, got %v", got)
+	}
+
+	// 其他来源的翻译记忆不受影响
+	fake.memory = append(fake.memory, []driver.Value{"id", userID.String(), "en", "zh-CN", "Good morning", "早安", "import", nil})
+	submit(model.FeedbackRequest{Rating: "up"})
+	if got := memoryText(); len(got) != 1 || got[0] != "早安" {
+		t.Errorf("Expected memory from other origins to be kept, got %v", got)
+	}
+}
+
+func TestSubmitFeedback_WithdrawKeepsOtherRowsCorrection(t *testing.T) {
+	userID := uuid.New()
+	first := &model.Translation{
+		ID:             uuid.New(),
+		UserID:         userID,
+		SourceText:     "Good morning",
+		TranslatedText: "早上好",
+		SourceLanguage: "en",
+		TargetLanguage: "zh-CN",
+		CreatedAt:      time.Now(),
+	}
+	second := *first
+	second.ID = uuid.New()
+	svc, fake := newTestFeedbackService(t, first)
+
+	submit := func(translation *model.Translation, req model.FeedbackRequest) {
+		t.Helper()
+		fake.translation = translation
+		if _, err := svc.SubmitFeedback(userID, translation.ID, &req); err != nil {
+			t.Fatalf("SubmitFeedback failed: %v", err)
+		}
+	}
+
+	// 两条记录原文相同，后一次修正覆盖翻译记忆并成为它的来源
+	submit(first, model.FeedbackRequest{Rating: "down", CorrectedText: "上午好"})
+	submit(&second, model.FeedbackRequest{Rating: "down", CorrectedText: "您早"})
+
+	// 重新评价第一条记录不能删掉第二条记录的修正
+	submit(first, model.FeedbackRequest{Rating: "up"})
+	if len(fake.memory) != 1 || fake.memory[0][5] != "您早" || fake.memory[0][7] != second.ID.String() {
+		t.Fatalf("Expected the second row's correction to be kept, got %v", fake.memory)
+	}
+
+	submit(&second, model.FeedbackRequest{Rating: "up"})
+	if len(fake.memory) != 0 {
+		t.Errorf("Expected the second row's correction to be withdrawn, got %v", fake.memory)
+	}
+}
+
+func TestSubmitFeedback_TranslationNotFound(t *testing.T) {
+	svc, fake := newTestFeedbackService(t, nil)
+
+	_, err := svc.SubmitFeedback(uuid.New(), uuid.New(), &model.FeedbackRequest{Rating: "down", CorrectedText: "修正"})
+	if !errors.Is(err, repository.ErrTranslationNotFound) {
+		t.Errorf("Expected ErrTranslationNotFound, got %v", err)
+	}
+	if len(fake.feedback) != 0 || len(fake.memory) != 0 {
+		t.Errorf("Expected no writes, got %d feedback and %d memory", len(fake.feedback), len(fake.memory))
+	}
+}
@@ -0,0 +1,124 @@
+package service
+
+// G.711 固定为 8kHz 单声道，每个样本一个字节
+const (
+	g711SampleRate = 8000
+	g711BytesPerMs = g711SampleRate / 1000
+)
+
+// μ-law 编码参数（ITU-T G.711）
+const (
+	ulawBias = 0x84
+	ulawClip = 32635
+)
+
+// alawSegmentEnds A-law 各段 13 位幅度的上限
+var alawSegmentEnds = [8]int{0x1F, 0x3F, 0x7F, 0xFF, 0x1FF, 0x3FF, 0x7FF, 0xFFF}
+
+// isG711 是否为 G.711 μ-law 或 A-law
+func isG711(format AudioFormat) bool {
+	return format == FormatG711ULaw || format == FormatG711ALaw
+}
+
+// encodeG711 把 PCM16 单声道编码为 G.711，末尾不足一个样本的字节被忽略
+func encodeG711(pcm []byte, format AudioFormat) []byte {
+	out := make([]byte, len(pcm)/2)
+	for i := range out {
+		sample := int16(uint16(pcm[i*2]) | uint16(pcm[i*2+1])<<8)
+		if format == FormatG711ALaw {
+			out[i] = linearToALaw(sample)
+		} else {
+			out[i] = linearToULaw(sample)
+		}
+	}
+	return out
+}
+
+// decodeG711 把 G.711 解码为 PCM16 单声道
+func decodeG711(data []byte, format AudioFormat) []byte {
+	out := make([]byte, len(data)*2)
+	for i, b := range data {
+		var sample int16
+		if format == FormatG711ALaw {
+			sample = alawToLinear(b)
+		} else {
+			sample = ulawToLinear(b)
+		}
+		out[i*2] = byte(sample)
+		out[i*2+1] = byte(uint16(sample) >> 8)
+	}
+	return out
+}
+
+func linearToULaw(sample int16) byte {
+	v := int(sample)
+	sign := 0
+	if v < 0 {
+		v = -v
+		sign = 0x80
+	}
+	if v > ulawClip {
+		v = ulawClip
+	}
+	v += ulawBias
+
+	exponent := 7
+	for mask := 0x4000; v&mask == 0 && exponent > 0; mask >>= 1 {
+		exponent--
+	}
+	mantissa := (v >> (exponent + 3)) & 0x0F
+	return ^byte(sign | exponent<<4 | mantissa)
+}
+
+func ulawToLinear(u byte) int16 {
+	u = ^u
+	t := (int(u&0x0F) << 3) + ulawBias
+	t <<= (u & 0x70) >> 4
+	if u&0x80 != 0 {
+		return int16(ulawBias - t)
+	}
+	return int16(t - ulawBias)
+}
+
+func linearToALaw(sample int16) byte {
+	pcm := int(sample) >> 3 // A-law 使用 13 位幅度
+	mask := 0xD5
+	if pcm < 0 {
+		mask = 0x55
+		pcm = -pcm - 1
+	}
+
+	segment := 0
+	for segment < len(alawSegmentEnds) && pcm > alawSegmentEnds[segment] {
+		segment++
+	}
+	if segment >= len(alawSegmentEnds) {
+		return byte(0x7F ^ mask)
+	}
+
+	value := segment << 4
+	if segment < 2 {
+		value |= (pcm >> 1) & 0x0F
+	} else {
+		value |= (pcm >> segment) & 0x0F
+	}
+	return byte(value ^ mask)
+}
+
+func alawToLinear(a byte) int16 {
+	a ^= 0x55
+	t := int(a&0x0F) << 4
+	switch segment := int(a&0x70) >> 4; segment {
+	case 0:
+		t += 8
+	case 1:
+		t += 0x108
+	default:
+		t += 0x108
+		t <<= segment - 1
+	}
+	if a&0x80 != 0 {
+		return int16(t)
+	}
+	return int16(-t)
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"math"
+	"testing"
+)
+
+func TestG711_KnownValues(t *testing.T) {
+	cases := []struct {
+		format AudioFormat
+		code   byte
+		sample int16
+	}{
+		{FormatG711ULaw, 0x00, -32124},
+		{FormatG711ULaw, 0x80, 32124},
+		{FormatG711ULaw, 0xFF, 0},
+		{FormatG711ALaw, 0xD5, 8},
+		{FormatG711ALaw, 0x55, -8},
+		{FormatG711ALaw, 0xAA, 32256},
+	}
+	for _, tc := range cases {
+		pcm := decodeG711([]byte{tc.code}, tc.format)
+		if got := pcmSamples(pcm)[0]; got != float64(tc.sample) {
+			t.Errorf("%s 0x%02X: expected %d, got %v", tc.format, tc.code, tc.sample, got)
+		}
+	}
+}
+
+func TestG711_RoundTripsEveryCode(t *testing.T) {
+	for _, format := range []AudioFormat{FormatG711ULaw, FormatG711ALaw} {
+		for code := 0; code < 256; code++ {
+			if format == FormatG711ULaw && code == 0x7F {
+				continue // μ-law 的 0x7F 与 0xFF 都表示 0，编码时取 0xFF
+			}
+			encoded := encodeG711(decodeG711([]byte{byte(code)}, format), format)
+			if encoded[0] != byte(code) {
+				t.Errorf("%s: code 0x%02X round-trips to 0x%02X", format, code, encoded[0])
+			}
+		}
+	}
+}
+
+func TestConvertAudioFormat_G711(t *testing.T) {
+	ap := NewAudioProcessor()
+	tone := generateTone(16000, 1000, 8000, 500, 1)
+
+	for _, format := range []AudioFormat{FormatG711ULaw, FormatG711ALaw} {
+		encoded, err := ap.ConvertAudioFormat(tone, string(FormatPCM16), string(format))
+		if err != nil {
+			t.Fatalf("%s: encode failed: %v", format, err)
+		}
+		if len(encoded) != 4000 {
+			t.Errorf("%s: expected 4000 bytes of 8kHz audio, got %d", format, len(encoded))
+		}
+
+		decoded, err := ap.ConvertAudioFormat(encoded, string(format), string(FormatPCM16))
+		if err != nil {
+			t.Fatalf("%s: decode failed: %v", format, err)
+		}
+		// 8 位对数量化的信噪比约为 38dB
+		amplitude, snr := analyzeTone(pcmSamples(decoded), 16000, 1000)
+		if math.Abs(20*math.Log10(amplitude/8000)) > 0.2 || snr < 30 {
+			t.Errorf("%s: amplitude %.1f, SNR %.1fdB after round trip", format, amplitude, snr)
+		}
+	}
+}
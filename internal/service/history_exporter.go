@@ -0,0 +1,170 @@
+package service
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"smart-glasses-backend/internal/model"
+	"strings"
+	"time"
+)
+
+// ExportFormat 翻译历史导出格式
+type ExportFormat string
+
+const (
+	ExportFormatCSV   ExportFormat = "csv"
+	ExportFormatJSONL ExportFormat = "jsonl"
+	ExportFormatAnki  ExportFormat = "anki" // Anki 可导入的 TSV 卡片
+)
+
+// ParseExportFormat 解析导出格式参数
+func ParseExportFormat(format string) (ExportFormat, error) {
+	switch ExportFormat(strings.ToLower(format)) {
+	case ExportFormatCSV:
+		return ExportFormatCSV, nil
+	case ExportFormatJSONL, "ndjson":
+		return ExportFormatJSONL, nil
+	case ExportFormatAnki, "tsv":
+		return ExportFormatAnki, nil
+	default:
+		return "", fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// ContentType 返回导出格式对应的 HTTP Content-Type
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportFormatCSV:
+		return "text/csv; charset=utf-8"
+	case ExportFormatJSONL:
+		return "application/x-ndjson; charset=utf-8"
+	default:
+		return "text/tab-separated-values; charset=utf-8"
+	}
+}
+
+// FileExtension 返回导出文件扩展名
+func (f ExportFormat) FileExtension() string {
+	switch f {
+	case ExportFormatCSV:
+		return "csv"
+	case ExportFormatJSONL:
+		return "jsonl"
+	default:
+		return "txt" // Anki 导入对话框默认识别 .txt
+	}
+}
+
+// historyExporter 将翻译记录逐条写出
+type historyExporter interface {
+	writeHeader() error
+	writeRow(t *model.Translation) error
+	flush() error
+}
+
+func newHistoryExporter(format ExportFormat, w io.Writer) historyExporter {
+	switch format {
+	case ExportFormatCSV:
+		return &csvExporter{w: csv.NewWriter(w)}
+	case ExportFormatJSONL:
+		bw := bufio.NewWriter(w)
+		return &jsonlExporter{w: bw, enc: json.NewEncoder(bw)}
+	default:
+		return &ankiExporter{w: bufio.NewWriter(w)}
+	}
+}
+
+// csvExporter CSV 导出
+type csvExporter struct {
+	w *csv.Writer
+}
+
+func (e *csvExporter) writeHeader() error {
+	return e.w.Write([]string{"id", "created_at", "source_language", "target_language", "source_text", "translated_text"})
+}
+
+func (e *csvExporter) writeRow(t *model.Translation) error {
+	return e.w.Write([]string{
+		t.ID.String(),
+		t.CreatedAt.UTC().Format(time.RFC3339),
+		t.SourceLanguage,
+		t.TargetLanguage,
+		escapeSpreadsheetFormula(t.SourceText),
+		escapeSpreadsheetFormula(t.TranslatedText),
+	})
+}
+
+func (e *csvExporter) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// escapeSpreadsheetFormula 防止表格软件把以公式字符开头的单元格当作公式执行
+func escapeSpreadsheetFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// jsonlExporter JSON Lines 导出，每行一条记录
+type jsonlExporter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *jsonlExporter) writeHeader() error {
+	return nil
+}
+
+func (e *jsonlExporter) writeRow(t *model.Translation) error {
+	return e.enc.Encode(&model.TranslationHistoryResponse{
+		ID:             t.ID,
+		SourceText:     t.SourceText,
+		TranslatedText: t.TranslatedText,
+		SourceLanguage: t.SourceLanguage,
+		TargetLanguage: t.TargetLanguage,
+		CreatedAt:      t.CreatedAt,
+	})
+}
+
+func (e *jsonlExporter) flush() error {
+	return e.w.Flush()
+}
+
+// ankiExporter Anki 卡片导出：正面为原文，背面为译文，第三列为标签
+type ankiExporter struct {
+	w *bufio.Writer
+}
+
+func (e *ankiExporter) writeHeader() error {
+	_, err := e.w.WriteString("#separator:tab\n#html:true\n#tags column:3\n")
+	return err
+}
+
+func (e *ankiExporter) writeRow(t *model.Translation) error {
+	tags := "smart-glasses " + ankiTag(t.SourceLanguage+"_"+t.TargetLanguage)
+	_, err := fmt.Fprintf(e.w, "%s\t%s\t%s\n", ankiField(t.SourceText), ankiField(t.TranslatedText), tags)
+	return err
+}
+
+func (e *ankiExporter) flush() error {
+	return e.w.Flush()
+}
+
+// ankiField 转义 HTML 并把换行和制表符转换为 Anki 可识别的形式
+func ankiField(value string) string {
+	value = html.EscapeString(value)
+	value = strings.ReplaceAll(value, "\r\n", "<br>")
+	value = strings.ReplaceAll(value, "\n", "<br>")
+	return strings.ReplaceAll(value, "\t", " ")
+}
+
+// ankiTag 标签中不能包含空白字符
+func ankiTag(value string) string {
+	return strings.Join(strings.Fields(value), "-")
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"smart-glasses-backend/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func testTranslations() []*model.Translation {
+	createdAt := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	return []*model.Translation{
+		{
+			ID:             uuid.MustParse("11111111-1111-1111-1111-111111111111"),
+			SourceText:     "Hello,\nworld",
+			TranslatedText: "你好，世界",
+			SourceLanguage: "en",
+			TargetLanguage: "zh-CN",
+			CreatedAt:      createdAt,
+		},
+		{
+			ID:             uuid.MustParse("22222222-2222-2222-2222-222222222222"),
+			SourceText:     "=SUM(A1:A2)",
+			TranslatedText: "<b>bold</b>\tcell",
+			SourceLanguage: "en",
+			TargetLanguage: "ja",
+			CreatedAt:      createdAt,
+		},
+	}
+}
+
+func exportAll(t *testing.T, format ExportFormat) string {
+	var buf bytes.Buffer
+	exporter := newHistoryExporter(format, &buf)
+	if err := exporter.writeHeader(); err != nil {
+		t.Fatalf("writeHeader failed: %v", err)
+	}
+	for _, tr := range testTranslations() {
+		if err := exporter.writeRow(tr); err != nil {
+			t.Fatalf("writeRow failed: %v", err)
+		}
+	}
+	if err := exporter.flush(); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	return buf.String()
+}
+
+func TestParseExportFormat(t *testing.T) {
+	cases := map[string]ExportFormat{
+		"csv":    ExportFormatCSV,
+		"CSV":    ExportFormatCSV,
+		"jsonl":  ExportFormatJSONL,
+		"ndjson": ExportFormatJSONL,
+		"anki":   ExportFormatAnki,
+		"tsv":    ExportFormatAnki,
+	}
+	for input, expected := range cases {
+		format, err := ParseExportFormat(input)
+		if err != nil {
+			t.Errorf("ParseExportFormat(%q) returned error: %v", input, err)
+		}
+		if format != expected {
+			t.Errorf("ParseExportFormat(%q) = %s, expected %s", input, format, expected)
+		}
+	}
+
+	if _, err := ParseExportFormat("xlsx"); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
+
+func TestHistoryExporter_CSV(t *testing.T) {
+	records, err := csv.NewReader(strings.NewReader(exportAll(t, ExportFormatCSV))).ReadAll()
+	if err != nil {
+		t.Fatalf("Exported CSV is not parseable: %v", err)
+	}
+
+	if len(records) != 3 {
+		t.Fatalf("Expected header and 2 rows, got %d records", len(records))
+	}
+	if records[0][0] != "id" || records[0][5] != "translated_text" {
+		t.Errorf("Unexpected header: %v", records[0])
+	}
+	if records[1][4] != "Hello,\nworld" {
+		t.Errorf("Expected multi-line source text to round-trip, got %q", records[1][4])
+	}
+	if records[1][1] != "2024-05-01T08:30:00Z" {
+		t.Errorf("Expected RFC3339 timestamp, got %q", records[1][1])
+	}
+	if records[2][4] != "'=SUM(A1:A2)" {
+		t.Errorf("Expected formula to be escaped, got %q", records[2][4])
+	}
+}
+
+func TestHistoryExporter_JSONL(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(exportAll(t, ExportFormatJSONL)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+
+	var entry model.TranslationHistoryResponse
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Line is not valid JSON: %v", err)
+	}
+	if entry.TranslatedText != "你好，世界" || entry.TargetLanguage != "zh-CN" {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+}
+
+func TestHistoryExporter_Anki(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(exportAll(t, ExportFormatAnki)), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Expected 3 header lines and 2 cards, got %d lines", len(lines))
+	}
+	if lines[0] != "#separator:tab" || lines[2] != "#tags column:3" {
+		t.Errorf("Unexpected Anki headers: %v", lines[:3])
+	}
+
+	fields := strings.Split(lines[3], "\t")
+	if len(fields) != 3 {
+		t.Fatalf("Expected 3 fields, got %d: %q", len(fields), lines[3])
+	}
+	if fields[0] != "Hello,<br>world" {
+		t.Errorf("Expected newline converted to <br>, got %q", fields[0])
+	}
+	if fields[2] != "smart-glasses en_zh-CN" {
+		t.Errorf("Unexpected tags: %q", fields[2])
+	}
+
+	fields = strings.Split(lines[4], "\t")
+	if fields[1] != "&lt;b&gt;bold&lt;/b&gt; cell" {
+		t.Errorf("Expected HTML escaped and tab removed, got %q", fields[1])
+	}
+}
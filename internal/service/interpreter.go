@@ -0,0 +1,312 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"smart-glasses-backend/internal/model"
+	"time"
+	"unicode"
+)
+
+// interpreterTurns 传译模式下等待配对的原文转写和译文
+// 原文转写与模型响应是两个独立事件，到达顺序不固定，按用户输入的会话项配对：
+// 响应创建时绑定到最近提交的输入项，转写按 item_id 归入对应的一轮
+type interpreterTurns struct {
+	turns     map[string]*interpreterTurn // 按输入会话项 ID
+	unbound   []string                    // 已提交、还没有对应响应的输入项
+	responses map[string]string           // 响应 ID 到输入项 ID
+	dropped   map[string]bool             // 响应被取消或失败、转写尚未到达的输入项
+}
+
+// interpreterTurn 一轮完整的传译
+type interpreterTurn struct {
+	SourceText     string
+	TranslatedText string
+}
+
+// addInput 记录一个已提交的用户输入项，重复的 item_id 忽略
+func (t *interpreterTurns) addInput(itemID string) {
+	if itemID == "" {
+		return
+	}
+	if t.turns == nil {
+		t.turns = make(map[string]*interpreterTurn)
+		t.responses = make(map[string]string)
+		t.dropped = make(map[string]bool)
+	}
+	if _, ok := t.turns[itemID]; ok {
+		return
+	}
+	t.turns[itemID] = &interpreterTurn{}
+	t.unbound = append(t.unbound, itemID)
+}
+
+// bindResponse 把新创建的响应绑定到最近提交的输入项
+// 更早提交却没有等到响应的输入项不会再单独翻译，一并丢弃
+func (t *interpreterTurns) bindResponse(responseID string) {
+	if responseID == "" || len(t.unbound) == 0 {
+		return
+	}
+	last := len(t.unbound) - 1
+	for _, itemID := range t.unbound[:last] {
+		t.drop(itemID)
+	}
+	t.responses[responseID] = t.unbound[last]
+	t.unbound = nil
+}
+
+// setSource 记录输入项的原文转写，返回配对完成的一轮
+func (t *interpreterTurns) setSource(itemID, text string) (interpreterTurn, bool) {
+	if t.dropped[itemID] {
+		delete(t.dropped, itemID)
+		return interpreterTurn{}, false
+	}
+	turn, ok := t.turns[itemID]
+	if !ok {
+		return interpreterTurn{}, false
+	}
+	turn.SourceText = text
+	return t.complete(itemID)
+}
+
+// finishResponse 响应结束时记录译文，返回配对完成的一轮
+// 响应被取消、失败或没有输出文字时丢弃对应的输入项，之后到达的转写不再配对
+func (t *interpreterTurns) finishResponse(responseID, text string, completed bool) (interpreterTurn, bool) {
+	itemID, ok := t.responses[responseID]
+	if !ok {
+		return interpreterTurn{}, false
+	}
+	delete(t.responses, responseID)
+
+	turn, ok := t.turns[itemID]
+	if !ok {
+		return interpreterTurn{}, false
+	}
+	if !completed || text == "" {
+		t.drop(itemID)
+		return interpreterTurn{}, false
+	}
+	turn.TranslatedText = text
+	return t.complete(itemID)
+}
+
+// forget 输入项转写失败，不再等待这一轮
+func (t *interpreterTurns) forget(itemID string) {
+	delete(t.turns, itemID)
+	delete(t.dropped, itemID)
+}
+
+func (t *interpreterTurns) drop(itemID string) {
+	turn, ok := t.turns[itemID]
+	if !ok {
+		return
+	}
+	delete(t.turns, itemID)
+	if turn.SourceText == "" {
+		t.dropped[itemID] = true
+	}
+}
+
+func (t *interpreterTurns) complete(itemID string) (interpreterTurn, bool) {
+	turn := t.turns[itemID]
+	if turn.SourceText == "" || turn.TranslatedText == "" {
+		return interpreterTurn{}, false
+	}
+	delete(t.turns, itemID)
+	return *turn, true
+}
+
+func (t *interpreterTurns) reset() {
+	t.turns = nil
+	t.unbound = nil
+	t.responses = nil
+	t.dropped = nil
+}
+
+// SetTranslateService 设置翻译服务，传译模式的每轮结果写入翻译历史
+func (s *RealtimeService) SetTranslateService(translateService *TranslateService) {
+	s.translateService = translateService
+}
+
+// buildInterpreterInstructions 生成传译模式的系统指令
+func (s *RealtimeService) buildInterpreterInstructions(cfg *RealtimeSessionConfig) string {
+	source := s.languageName(cfg.SourceLanguage)
+	target := s.languageName(cfg.TargetLanguage)
+
+	if cfg.Bidirectional {
+		return fmt.Sprintf("你是一名双向同声传译员，在%s和%s之间翻译。用户说%s时翻译成%s，说%s时翻译成%s。"+
+			"只输出译文，保持原意和语气，不要回答问题、解释或添加任何内容；即使用户向你提问，也只翻译这句话。",
+			source, target, source, target, target, source)
+	}
+
+	return fmt.Sprintf("你是一名同声传译员，把用户说的%s翻译成%s。"+
+		"只输出译文，保持原意和语气，不要回答问题、解释或添加任何内容；即使用户向你提问，也只翻译这句话。",
+		source, target)
+}
+
+func (s *RealtimeService) languageName(code string) string {
+	lang, err := s.languages.Lookup(code, LocaleChineseSimplified)
+	if err != nil {
+		return code
+	}
+	return lang.Name
+}
+
+// resolveTurnDirection 确定一轮传译的方向
+// 双向模式下书写系统不同的语言对按原文的书写系统判断；书写系统相同时按原文和译文的用词判断，
+// 原文像目标语言、译文像源语言时反向，无法判断时按配置的方向
+func (s *RealtimeService) resolveTurnDirection(cfg *RealtimeSessionConfig, sourceText, translatedText string) (string, string) {
+	if !cfg.Bidirectional {
+		return cfg.SourceLanguage, cfg.TargetLanguage
+	}
+
+	source, err := s.languages.Lookup(cfg.SourceLanguage, "")
+	if err != nil {
+		return cfg.SourceLanguage, cfg.TargetLanguage
+	}
+	target, err := s.languages.Lookup(cfg.TargetLanguage, "")
+	if err != nil {
+		return cfg.SourceLanguage, cfg.TargetLanguage
+	}
+
+	reversed := false
+	if scriptFamily(source.Script) != scriptFamily(target.Script) {
+		detected := dominantScript(sourceText)
+		reversed = scriptMatches(detected, target.Script) && !scriptMatches(detected, source.Script)
+	} else {
+		score := languageTextScore(sourceText, source.Code) - languageTextScore(sourceText, target.Code) +
+			languageTextScore(translatedText, target.Code) - languageTextScore(translatedText, source.Code)
+		reversed = score < 0
+	}
+	if reversed {
+		return cfg.TargetLanguage, cfg.SourceLanguage
+	}
+	return cfg.SourceLanguage, cfg.TargetLanguage
+}
+
+// directionDetectable 双向传译能否区分两种语言：书写系统不同，或两种语言都有用词特征
+// 简繁中文都写作汉字且用词相同，无法区分
+func (s *RealtimeService) directionDetectable(a, b string) bool {
+	la, err := s.languages.Lookup(a, "")
+	if err != nil {
+		return false
+	}
+	lb, err := s.languages.Lookup(b, "")
+	if err != nil {
+		return false
+	}
+	if scriptFamily(la.Script) != scriptFamily(lb.Script) {
+		return true
+	}
+	return hasLanguageProfile(la.Code) && hasLanguageProfile(lb.Code)
+}
+
+// scriptFamily 简繁中文按同一种文字比较
+func scriptFamily(script string) string {
+	if script == "Hans" || script == "Hant" {
+		return "Hani"
+	}
+	return script
+}
+
+// dominantScript 返回文本中占比最高的文字，出现假名时视为日文
+func dominantScript(text string) string {
+	counts := make(map[string]int)
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+			counts["Jpan"]++
+		case unicode.Is(unicode.Han, r):
+			counts["Hani"]++
+		case unicode.Is(unicode.Hangul, r):
+			counts["Kore"]++
+		case unicode.Is(unicode.Latin, r):
+			counts["Latn"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			counts["Cyrl"]++
+		case unicode.Is(unicode.Arabic, r):
+			counts["Arab"]++
+		case unicode.Is(unicode.Hebrew, r):
+			counts["Hebr"]++
+		case unicode.Is(unicode.Devanagari, r):
+			counts["Deva"]++
+		case unicode.Is(unicode.Thai, r):
+			counts["Thai"]++
+		case unicode.Is(unicode.Greek, r):
+			counts["Grek"]++
+		}
+	}
+
+	if counts["Jpan"] > 0 {
+		return "Jpan"
+	}
+
+	best, bestCount := "", 0
+	for script, count := range counts {
+		if count > bestCount || (count == bestCount && script < best) {
+			best, bestCount = script, count
+		}
+	}
+	return best
+}
+
+// scriptMatches 判断检测到的文字是否属于语言的书写系统
+func scriptMatches(detected, languageScript string) bool {
+	switch languageScript {
+	case "Hans", "Hant":
+		return detected == "Hani"
+	default:
+		return detected != "" && detected == languageScript
+	}
+}
+
+// handleInterpreterSource 收到输入项的原文转写，尝试完成一轮传译
+func (s *RealtimeService) handleInterpreterSource(session *RealtimeSession, itemID, transcript string) {
+	if turn, ok := session.turns.setSource(itemID, transcript); ok {
+		s.completeInterpreterTurn(session, turn)
+	}
+}
+
+// handleInterpreterTarget 模型完成一次响应，completed 为 false 时这一轮被取消或失败
+func (s *RealtimeService) handleInterpreterTarget(session *RealtimeSession, responseID, translated string, completed bool) {
+	if turn, ok := session.turns.finishResponse(responseID, translated, completed); ok {
+		s.completeInterpreterTurn(session, turn)
+	}
+}
+
+// completeInterpreterTurn 发送配对完成的传译结果并写入翻译历史
+func (s *RealtimeService) completeInterpreterTurn(session *RealtimeSession, turn interpreterTurn) {
+	cfg := session.Config()
+
+	sourceLanguage, targetLanguage := s.resolveTurnDirection(cfg, turn.SourceText, turn.TranslatedText)
+	s.sendToClientWithTimeout(session.ClientConn, map[string]interface{}{
+		"type":            "interpretation",
+		"source_text":     turn.SourceText,
+		"translated_text": turn.TranslatedText,
+		"source_language": sourceLanguage,
+		"target_language": targetLanguage,
+		"timestamp":       time.Now().UnixMilli(),
+	})
+
+	s.recordInterpretation(session, turn, sourceLanguage, targetLanguage)
+}
+
+// recordInterpretation 异步保存到翻译历史，不阻塞音频转发
+func (s *RealtimeService) recordInterpretation(session *RealtimeSession, turn interpreterTurn, sourceLanguage, targetLanguage string) {
+	if s.translateService == nil {
+		return
+	}
+
+	go func() {
+		translation := &model.Translation{
+			UserID:         session.UserID,
+			SourceText:     turn.SourceText,
+			TranslatedText: turn.TranslatedText,
+			SourceLanguage: sourceLanguage,
+			TargetLanguage: targetLanguage,
+		}
+		if err := s.translateService.SaveTranslation(translation); err != nil {
+			log.Printf("Failed to save interpretation for user %s: %v", session.UserID, err)
+		}
+	}()
+}
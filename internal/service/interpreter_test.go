@@ -0,0 +1,178 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestResolveSessionConfig_Interpreter(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+
+	cfg, err := service.ResolveSessionConfig(nil, &RealtimeSessionConfigRequest{
+		Mode:           RealtimeModeInterpreter,
+		SourceLanguage: "English",
+		TargetLanguage: "zh",
+		Transcription:  boolPtr(false),
+	})
+	if err != nil {
+		t.Fatalf("ResolveSessionConfig failed: %v", err)
+	}
+	if cfg.SourceLanguage != "en" || cfg.TargetLanguage != "zh-Hans" || cfg.ReplyLanguage != "zh-Hans" {
+		t.Errorf("Unexpected languages: %+v", cfg)
+	}
+	if !cfg.Transcription {
+		t.Error("Expected transcription to be forced on in interpreter mode")
+	}
+
+	// 书写系统相同时仍可固定方向传译，书写系统不同时可以双向
+	for _, req := range []*RealtimeSessionConfigRequest{
+		{Mode: RealtimeModeInterpreter, SourceLanguage: "en", TargetLanguage: "fr"},
+		{Mode: RealtimeModeInterpreter, SourceLanguage: "zh-Hans", TargetLanguage: "ja", Bidirectional: boolPtr(true)},
+		// 书写系统相同的语言按用词判断方向
+		{Mode: RealtimeModeInterpreter, SourceLanguage: "en", TargetLanguage: "es", Bidirectional: boolPtr(true)},
+		{Mode: RealtimeModeInterpreter, SourceLanguage: "ru", TargetLanguage: "uk", Bidirectional: boolPtr(true)},
+	} {
+		if _, err := service.ResolveSessionConfig(nil, req); err != nil {
+			t.Errorf("Expected %s→%s to be accepted, got %v", req.SourceLanguage, req.TargetLanguage, err)
+		}
+	}
+
+	instructions := service.buildInstructions(cfg)
+	if !strings.Contains(instructions, "英语翻译成简体中文") {
+		t.Errorf("Unexpected interpreter instructions: %s", instructions)
+	}
+
+	for name, req := range map[string]*RealtimeSessionConfigRequest{
+		"missing target": {Mode: RealtimeModeInterpreter, SourceLanguage: "en"},
+		"same language":  {Mode: RealtimeModeInterpreter, SourceLanguage: "zh-CN", TargetLanguage: "zh"},
+		"unknown mode":   {Mode: "karaoke"},
+		// 简繁中文书写系统和用词都无法区分
+		"bidirectional chinese forms": {Mode: RealtimeModeInterpreter, SourceLanguage: "zh-Hans", TargetLanguage: "zh-Hant", Bidirectional: boolPtr(true)},
+	} {
+		if _, err := service.ResolveSessionConfig(nil, req); !errors.Is(err, ErrInvalidSessionConfig) {
+			t.Errorf("%s: expected ErrInvalidSessionConfig, got %v", name, err)
+		}
+	}
+}
+
+func TestResolveTurnDirection(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+	cfg := &RealtimeSessionConfig{
+		Mode:           RealtimeModeInterpreter,
+		SourceLanguage: "en",
+		TargetLanguage: "ja",
+		Bidirectional:  true,
+	}
+
+	cases := []struct {
+		text           string
+		expectedSource string
+	}{
+		{"Where is the station?", "en"},
+		{"駅はどこですか", "ja"},
+		{"", "en"},
+	}
+	for _, tc := range cases {
+		source, target := service.resolveTurnDirection(cfg, tc.text, "")
+		if source != tc.expectedSource || source == target {
+			t.Errorf("resolveTurnDirection(%q) = %s→%s, expected source %s", tc.text, source, target, tc.expectedSource)
+		}
+	}
+
+	cfg.Bidirectional = false
+	if source, _ := service.resolveTurnDirection(cfg, "駅はどこですか", ""); source != "en" {
+		t.Errorf("Expected fixed direction when not bidirectional, got source %s", source)
+	}
+}
+
+func TestResolveTurnDirection_SameScript(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+	cfg := &RealtimeSessionConfig{
+		Mode:           RealtimeModeInterpreter,
+		SourceLanguage: "en",
+		TargetLanguage: "es",
+		Bidirectional:  true,
+	}
+
+	cases := []struct {
+		source, target, sourceText, translatedText string
+		expectedSource                             string
+	}{
+		{"en", "es", "Where is the train station?", "¿Dónde está la estación de tren?", "en"},
+		{"en", "es", "¿Dónde está la estación de tren?", "Where is the train station?", "es"},
+		// 只有原文时按原文判断
+		{"en", "es", "Muchas gracias por la ayuda", "", "es"},
+		{"de", "en", "Thank you, where is my hotel?", "Danke, wo ist mein Hotel?", "en"},
+		{"fr", "en", "Où est la gare, s'il vous plaît ?", "Where is the station, please?", "fr"},
+		{"ru", "uk", "Дякую, де вокзал?", "Спасибо, где вокзал?", "uk"},
+		// 无法判断时按配置的方向
+		{"en", "es", "Taxi", "Taxi", "en"},
+	}
+	for _, tc := range cases {
+		cfg.SourceLanguage, cfg.TargetLanguage = tc.source, tc.target
+		source, target := service.resolveTurnDirection(cfg, tc.sourceText, tc.translatedText)
+		if source != tc.expectedSource || source == target {
+			t.Errorf("resolveTurnDirection(%q, %q) = %s→%s, expected source %s", tc.sourceText, tc.translatedText, source, target, tc.expectedSource)
+		}
+	}
+}
+
+func TestInterpreterTurns_PairsByItem(t *testing.T) {
+	var turns interpreterTurns
+
+	// 译文先于原文转写到达
+	turns.addInput("item_1")
+	turns.bindResponse("resp_1")
+	if _, ok := turns.finishResponse("resp_1", "你好", true); ok {
+		t.Fatal("Expected no turn without a source transcript")
+	}
+	turns.addInput("item_2")
+	turn, ok := turns.setSource("item_1", "Hello")
+	if !ok || turn.SourceText != "Hello" || turn.TranslatedText != "你好" {
+		t.Fatalf("Unexpected first turn: %+v", turn)
+	}
+
+	turns.bindResponse("resp_2")
+	turns.setSource("item_2", "Goodbye")
+	turn, ok = turns.finishResponse("resp_2", "再见", true)
+	if !ok || turn.SourceText != "Goodbye" || turn.TranslatedText != "再见" {
+		t.Fatalf("Unexpected second turn: %+v", turn)
+	}
+}
+
+func TestInterpreterTurns_DropsUnansweredInput(t *testing.T) {
+	var turns interpreterTurns
+
+	// 被取消的响应：转写稍后到达也不再配对
+	turns.addInput("item_1")
+	turns.bindResponse("resp_1")
+	turns.finishResponse("resp_1", "部分译", false)
+	if _, ok := turns.setSource("item_1", "Interrupted sentence"); ok {
+		t.Error("Expected the cancelled turn to be dropped")
+	}
+
+	// 没有输出文字的响应
+	turns.addInput("item_2")
+	turns.setSource("item_2", "Hmm")
+	turns.bindResponse("resp_2")
+	turns.finishResponse("resp_2", "", true)
+
+	// 没有等到响应的输入项在下一次响应绑定时丢弃
+	turns.addInput("item_3")
+	turns.addInput("item_4")
+	turns.bindResponse("resp_4")
+	turns.setSource("item_3", "Lost")
+	turn, ok := turns.setSource("item_4", "Next")
+	if ok {
+		t.Fatalf("Unexpected turn before translation: %+v", turn)
+	}
+	turn, ok = turns.finishResponse("resp_4", "下一句", true)
+	if !ok || turn.SourceText != "Next" || turn.TranslatedText != "下一句" {
+		t.Fatalf("Unexpected turn: %+v", turn)
+	}
+
+	if len(turns.turns) != 0 || len(turns.responses) != 0 || len(turns.dropped) != 0 {
+		t.Errorf("Expected no pending state, got %+v", turns)
+	}
+}
@@ -0,0 +1,216 @@
+package service
+
+import "time"
+
+// 抖动缓冲参数
+const (
+	defaultJitterDepth      = 4                      // 缺包时最多再缓存多少个后续包等待它到达
+	jitterMaxWait           = 120 * time.Millisecond // 缺包后最多等待的时间，超过即按丢包处理
+	jitterMaxConcealPackets = 5                      // 一段连续丢包最多补多少个静音包，更长的中断只计入丢包
+	jitterResyncGap         = 1000                   // 序号前后跳变超过该值视为客户端重新开始计数
+)
+
+// AudioPacket 一个带序号的客户端上行音频包
+type AudioPacket struct {
+	Sequence         uint32
+	CaptureTimestamp int64 // 客户端采集时间（Unix 毫秒），0 表示未提供，不参与抖动统计
+	Payload          []byte
+}
+
+// PacketStats 上行音频包的到达统计
+type PacketStats struct {
+	Received  int     `json:"received"`  // 按序送出的包数
+	Lost      int     `json:"lost"`      // 等待超时后按丢包处理的包数
+	Late      int     `json:"late"`      // 已按丢包处理后才到达而被丢弃的包数
+	Duplicate int     `json:"duplicate"` // 重复到达的包数
+	Concealed int     `json:"concealed"` // 以静音补齐的包数
+	JitterMs  float64 `json:"jitter_ms"` // RFC 3550 到达间隔抖动
+}
+
+// PacketLoss 丢包率，迟到的包已计入丢包
+func (s PacketStats) PacketLoss() float64 {
+	expected := s.Received + s.Lost
+	if expected == 0 {
+		return 0
+	}
+	return float64(s.Lost) / float64(expected)
+}
+
+// pendingPacket 等待前序包的乱序包
+type pendingPacket struct {
+	AudioPacket
+	arrival time.Time
+}
+
+// JitterBuffer 按序号重排客户端音频包，不是并发安全的
+// 包按序到达时立即送出；出现缺口时缓存后续包，缺失的包到达即补上，
+// 缓存超过 depth 个包或等待超过 jitterMaxWait 后放弃等待，以静音补齐缺口继续送出
+type JitterBuffer struct {
+	depth   int
+	conceal func(size int) []byte // 生成与丢失包等长的静音
+
+	started  bool
+	next     uint32 // 下一个应送出的序号
+	pending  map[uint32]pendingPacket
+	lastSize int // 上一个送出的包大小，丢失的包按此长度补静音
+
+	hasTransit  bool
+	lastTransit int64 // 上一个包的到达时间减采集时间（毫秒）
+	jitter      float64
+
+	stats PacketStats
+}
+
+// NewJitterBuffer 创建抖动缓冲，conceal 按字节数生成客户端格式的静音
+func NewJitterBuffer(depth int, conceal func(size int) []byte) *JitterBuffer {
+	if depth <= 0 {
+		depth = defaultJitterDepth
+	}
+	return &JitterBuffer{
+		depth:   depth,
+		conceal: conceal,
+		pending: make(map[uint32]pendingPacket),
+	}
+}
+
+// Push 放入一个包，返回可以按序送出的音频，丢失的包以静音补齐
+func (jb *JitterBuffer) Push(pkt AudioPacket, arrival time.Time) [][]byte {
+	jb.updateJitter(pkt.CaptureTimestamp, arrival)
+
+	if !jb.started {
+		jb.started = true
+		jb.next = pkt.Sequence
+	}
+
+	var out [][]byte
+	switch d := int32(pkt.Sequence - jb.next); {
+	case d < -jitterResyncGap || d > jitterResyncGap:
+		// 客户端重新开始计数，送出缓存后从新序号继续
+		out = jb.drain()
+		jb.next = pkt.Sequence
+	case d < 0:
+		jb.stats.Late++
+		return nil
+	}
+	if _, dup := jb.pending[pkt.Sequence]; dup {
+		jb.stats.Duplicate++
+		return out
+	}
+	jb.pending[pkt.Sequence] = pendingPacket{AudioPacket: pkt, arrival: arrival}
+
+	for {
+		out = append(out, jb.release()...)
+		if len(jb.pending) == 0 || (len(jb.pending) <= jb.depth && arrival.Sub(jb.oldestArrival()) < jitterMaxWait) {
+			return out
+		}
+		out = append(out, jb.skipGap()...)
+	}
+}
+
+// Flush 送出全部缓存，缺口以静音补齐，用于提交音频前
+func (jb *JitterBuffer) Flush() [][]byte {
+	return jb.drain()
+}
+
+// Expire 放弃等待已超过 jitterMaxWait 的缺口，返回可以按序送出的音频
+// 客户端停止发送后不会再有新包触发 Push，由定时器在 Deadline 时调用
+func (jb *JitterBuffer) Expire(now time.Time) [][]byte {
+	var out [][]byte
+	for len(jb.pending) > 0 && now.Sub(jb.oldestArrival()) >= jitterMaxWait {
+		out = append(out, jb.skipGap()...)
+		out = append(out, jb.release()...)
+	}
+	return out
+}
+
+// Deadline 缓存中最早到达的包等待到期的时间，缓存为空时返回 false
+func (jb *JitterBuffer) Deadline() (time.Time, bool) {
+	if len(jb.pending) == 0 {
+		return time.Time{}, false
+	}
+	return jb.oldestArrival().Add(jitterMaxWait), true
+}
+
+// Stats 返回累计统计
+func (jb *JitterBuffer) Stats() PacketStats {
+	stats := jb.stats
+	stats.JitterMs = jb.jitter
+	return stats
+}
+
+// release 送出从 next 开始连续到达的包
+func (jb *JitterBuffer) release() [][]byte {
+	var out [][]byte
+	for {
+		pkt, ok := jb.pending[jb.next]
+		if !ok {
+			return out
+		}
+		delete(jb.pending, jb.next)
+		jb.next++
+		jb.stats.Received++
+		jb.lastSize = len(pkt.Payload)
+		out = append(out, pkt.Payload)
+	}
+}
+
+// skipGap 放弃等待 next 开始的缺口，直到下一个已到达的包
+func (jb *JitterBuffer) skipGap() [][]byte {
+	nearest, found := uint32(0), false
+	for seq := range jb.pending {
+		if !found || int32(seq-jb.next) < int32(nearest-jb.next) {
+			nearest, found = seq, true
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	var out [][]byte
+	missing := int(nearest - jb.next)
+	jb.stats.Lost += missing
+	for i := 0; i < missing && i < jitterMaxConcealPackets && jb.lastSize > 0; i++ {
+		out = append(out, jb.conceal(jb.lastSize))
+		jb.stats.Concealed++
+	}
+	jb.next = nearest
+	return out
+}
+
+// drain 按序送出全部缓存，缺口以静音补齐
+func (jb *JitterBuffer) drain() [][]byte {
+	var out [][]byte
+	for len(jb.pending) > 0 {
+		out = append(out, jb.release()...)
+		out = append(out, jb.skipGap()...)
+	}
+	return out
+}
+
+// oldestArrival 缓存中最早到达的包的到达时间
+func (jb *JitterBuffer) oldestArrival() time.Time {
+	var oldest time.Time
+	for _, pkt := range jb.pending {
+		if oldest.IsZero() || pkt.arrival.Before(oldest) {
+			oldest = pkt.arrival
+		}
+	}
+	return oldest
+}
+
+// updateJitter 按 RFC 3550 用相邻包的传输时间差估计抖动，时钟偏差在差值中抵消
+func (jb *JitterBuffer) updateJitter(captureMs int64, arrival time.Time) {
+	if captureMs <= 0 {
+		return
+	}
+	transit := arrival.UnixMilli() - captureMs
+	if jb.hasTransit {
+		d := float64(transit - jb.lastTransit)
+		if d < 0 {
+			d = -d
+		}
+		jb.jitter += (d - jb.jitter) / 16
+	}
+	jb.hasTransit = true
+	jb.lastTransit = transit
+}
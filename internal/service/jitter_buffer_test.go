@@ -0,0 +1,281 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newTestJitterBuffer 静音以 0xEE 填充，便于与原始包区分
+func newTestJitterBuffer(depth int) *JitterBuffer {
+	return NewJitterBuffer(depth, func(size int) []byte { return bytes.Repeat([]byte{0xEE}, size) })
+}
+
+// packet 内容为序号，便于检查输出顺序
+func packet(seq uint32) AudioPacket {
+	return AudioPacket{Sequence: seq, Payload: []byte{byte(seq), byte(seq)}}
+}
+
+// sequences 把输出还原为序号，静音记为 -1
+func sequences(chunks [][]byte) []int {
+	var out []int
+	for _, c := range chunks {
+		if c[0] == 0xEE {
+			out = append(out, -1)
+		} else {
+			out = append(out, int(c[0]))
+		}
+	}
+	return out
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestJitterBuffer_Reorder(t *testing.T) {
+	jb := newTestJitterBuffer(4)
+	now := time.Now()
+
+	var got []int
+	for _, seq := range []uint32{10, 11, 13, 12, 14} {
+		got = append(got, sequences(jb.Push(packet(seq), now))...)
+	}
+	if want := []int{10, 11, 12, 13, 14}; !equalInts(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if s := jb.Stats(); s.Received != 5 || s.Lost != 0 || s.PacketLoss() != 0 {
+		t.Errorf("Expected 5 received and no loss, got %+v", s)
+	}
+}
+
+func TestJitterBuffer_LossConcealment(t *testing.T) {
+	jb := newTestJitterBuffer(2)
+	now := time.Now()
+
+	// 12 丢失：缓存超过 2 个包后放弃等待，以静音补齐
+	var got []int
+	for _, seq := range []uint32{10, 11, 13, 14} {
+		got = append(got, sequences(jb.Push(packet(seq), now))...)
+	}
+	if want := []int{10, 11}; !equalInts(got, want) {
+		t.Fatalf("Expected packets after the gap to be held, got %v", got)
+	}
+	got = sequences(jb.Push(packet(15), now))
+	if want := []int{-1, 13, 14, 15}; !equalInts(got, want) {
+		t.Errorf("Expected concealed gap, got %v", got)
+	}
+
+	// 已补齐后才到达的包被丢弃
+	if out := jb.Push(packet(12), now); len(out) != 0 {
+		t.Errorf("Expected late packet to be dropped, got %v", sequences(out))
+	}
+	// 重复的包被丢弃
+	jb.Push(packet(17), now)
+	jb.Push(packet(17), now)
+
+	s := jb.Stats()
+	if s.Received != 5 || s.Lost != 1 || s.Late != 1 || s.Duplicate != 1 || s.Concealed != 1 {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+	if math.Abs(s.PacketLoss()-1.0/6) > 1e-9 {
+		t.Errorf("Expected packet loss 1/6, got %f", s.PacketLoss())
+	}
+
+	// 提交前送出剩余缓存
+	if got := sequences(jb.Flush()); !equalInts(got, []int{-1, 17}) {
+		t.Errorf("Expected flush to conceal 16 and release 17, got %v", got)
+	}
+}
+
+func TestJitterBuffer_WaitTimeout(t *testing.T) {
+	jb := newTestJitterBuffer(8)
+	start := time.Now()
+
+	jb.Push(packet(1), start)
+	if out := jb.Push(packet(3), start.Add(20*time.Millisecond)); len(out) != 0 {
+		t.Fatalf("Expected packet 3 to wait for 2, got %v", sequences(out))
+	}
+	got := sequences(jb.Push(packet(4), start.Add(20*time.Millisecond+jitterMaxWait)))
+	if want := []int{-1, 3, 4}; !equalInts(got, want) {
+		t.Errorf("Expected gap to be concealed after waiting, got %v", got)
+	}
+}
+
+func TestJitterBuffer_Expire(t *testing.T) {
+	jb := newTestJitterBuffer(8)
+	start := time.Now()
+
+	if _, ok := jb.Deadline(); ok {
+		t.Error("Expected no deadline for an empty buffer")
+	}
+	jb.Push(packet(1), start)
+	jb.Push(packet(3), start.Add(20*time.Millisecond))
+	jb.Push(packet(4), start.Add(40*time.Millisecond))
+	deadline, ok := jb.Deadline()
+	if !ok || !deadline.Equal(start.Add(20*time.Millisecond+jitterMaxWait)) {
+		t.Fatalf("Expected the deadline to follow packet 3, got %v (%v)", deadline, ok)
+	}
+
+	// 客户端停止发送，到期前不送出，到期后补齐缺口
+	if out := jb.Expire(deadline.Add(-time.Millisecond)); len(out) != 0 {
+		t.Errorf("Expected nothing before the deadline, got %v", sequences(out))
+	}
+	got := sequences(jb.Expire(deadline))
+	if want := []int{-1, 3, 4}; !equalInts(got, want) {
+		t.Errorf("Expected gap to be concealed at the deadline, got %v", got)
+	}
+	if _, ok := jb.Deadline(); ok {
+		t.Error("Expected the buffer to be empty after expiring")
+	}
+}
+
+func TestJitterBuffer_LongGapAndWrap(t *testing.T) {
+	jb := newTestJitterBuffer(1)
+	now := time.Now()
+
+	// 序号回绕
+	jb.Push(packet(math.MaxUint32), now)
+	if got := sequences(jb.Push(packet(0), now)); !equalInts(got, []int{0}) {
+		t.Errorf("Expected sequence to wrap, got %v", got)
+	}
+
+	// 长时间中断只补有限的静音，但全部计入丢包
+	jb.Push(packet(21), now)
+	got := sequences(jb.Push(packet(22), now))
+	if len(got) != jitterMaxConcealPackets+2 || jb.Stats().Lost != 20 {
+		t.Errorf("Expected %d concealed packets and 20 lost, got %v / %+v", jitterMaxConcealPackets, got, jb.Stats())
+	}
+
+	// 序号大幅跳变视为重新计数，不计丢包
+	if got := sequences(jb.Push(packet(5000), now)); !equalInts(got, []int{5000 % 256}) {
+		t.Errorf("Expected resync to release the packet, got %v", got)
+	}
+	if jb.Stats().Lost != 20 {
+		t.Errorf("Expected resync not to count as loss, got %+v", jb.Stats())
+	}
+}
+
+func TestJitterBuffer_Jitter(t *testing.T) {
+	jb := newTestJitterBuffer(4)
+	capture := time.Now().UnixMilli()
+
+	// 每 20ms 采集一个包，到达间隔交替为 10ms 和 30ms，传输时间差恒为 10ms
+	arrival := time.UnixMilli(capture + 100)
+	for i := 0; i < 200; i++ {
+		pkt := packet(uint32(i))
+		pkt.CaptureTimestamp = capture + int64(i)*20
+		jb.Push(pkt, arrival)
+		if i%2 == 0 {
+			arrival = arrival.Add(10 * time.Millisecond)
+		} else {
+			arrival = arrival.Add(30 * time.Millisecond)
+		}
+	}
+	if j := jb.Stats().JitterMs; math.Abs(j-10) > 0.5 {
+		t.Errorf("Expected jitter about 10ms, got %.2f", j)
+	}
+
+	// 未提供采集时间的包不参与抖动统计
+	jb2 := newTestJitterBuffer(4)
+	for i := 0; i < 10; i++ {
+		jb2.Push(packet(uint32(i)), time.Now().Add(time.Duration(i*i)*time.Millisecond))
+	}
+	if j := jb2.Stats().JitterMs; j != 0 {
+		t.Errorf("Expected no jitter without capture timestamps, got %.2f", j)
+	}
+}
+
+func TestSessionAudio_SequencedPackets(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+
+	clientConn, _ := wsPair(t)
+	gptConn, upstream := wsPair(t)
+	cfg := DefaultRealtimeSessionConfig()
+	cfg.AudioFormat = string(FormatG711ULaw)
+	cfg.SampleRate = 8000
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, cfg)
+	t.Cleanup(func() { service.EndSession(session) })
+	service.securityMonitor.StartConnectionMonitoring(session.ID.String(), session.UserID.String())
+
+	// 每包 20ms 的 G.711，包 1 丢失、包 3 与 2 乱序
+	chunk := func(b byte) []byte { return bytes.Repeat([]byte{b}, 160) }
+	for _, seq := range []uint32{0, 3, 2, 4, 5, 6, 7} {
+		pkt := AudioPacket{Sequence: seq, CaptureTimestamp: time.Now().UnixMilli(), Payload: chunk(byte(0x10 + seq))}
+		if err := service.SendSessionAudioPacket(session, pkt); err != nil {
+			t.Fatalf("SendSessionAudioPacket failed: %v", err)
+		}
+	}
+
+	// 上游按序号收到音频，丢失的包以 G.711 静音补齐
+	silence := encodeG711(make([]byte, 2), FormatG711ULaw)[0]
+	want := []byte{0x10, silence, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17}
+	upstream.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for i, b := range want {
+		var appended map[string]interface{}
+		if err := upstream.ReadJSON(&appended); err != nil {
+			t.Fatalf("Failed to read upstream audio %d: %v", i, err)
+		}
+		sent, _ := base64.StdEncoding.DecodeString(appended["audio"].(string))
+		if len(sent) != 160 || sent[0] != b {
+			t.Errorf("Chunk %d: expected 160 bytes of 0x%02x, got %d bytes of 0x%02x", i, b, len(sent), sent[0])
+		}
+	}
+
+	metric := service.securityMonitor.GetConnectionQuality(session.ID.String())
+	if metric == nil {
+		t.Fatal("Expected connection metric")
+	}
+	if metric.PacketsReceived != 7 || metric.PacketsLost != 1 || math.Abs(metric.PacketLoss-1.0/8) > 1e-9 {
+		t.Errorf("Expected 1 of 8 packets lost, got %d/%d (%.3f)", metric.PacketsLost, metric.PacketsReceived, metric.PacketLoss)
+	}
+	if metric.Quality != "poor" {
+		t.Errorf("Expected 12.5%% loss to rate the connection poor, got %s", metric.Quality)
+	}
+}
+
+func TestSessionAudio_ReleasesBufferedPacketsWhenIdle(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+
+	clientConn, _ := wsPair(t)
+	gptConn, upstream := wsPair(t)
+	cfg := DefaultRealtimeSessionConfig()
+	cfg.AudioFormat = string(FormatG711ULaw)
+	cfg.SampleRate = 8000
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, cfg)
+	t.Cleanup(func() { service.EndSession(session) })
+
+	// 包 1 丢失后客户端停止发送，也不发送 commit_audio
+	chunk := func(b byte) []byte { return bytes.Repeat([]byte{b}, 160) }
+	for _, seq := range []uint32{0, 2, 3} {
+		if err := service.SendSessionAudioPacket(session, AudioPacket{Sequence: seq, Payload: chunk(byte(0x10 + seq))}); err != nil {
+			t.Fatalf("SendSessionAudioPacket failed: %v", err)
+		}
+	}
+
+	silence := encodeG711(make([]byte, 2), FormatG711ULaw)[0]
+	want := []byte{0x10, silence, 0x12, 0x13}
+	upstream.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for i, b := range want {
+		var appended map[string]interface{}
+		if err := upstream.ReadJSON(&appended); err != nil {
+			t.Fatalf("Failed to read upstream audio %d: %v", i, err)
+		}
+		sent, _ := base64.StdEncoding.DecodeString(appended["audio"].(string))
+		if len(sent) != 160 || sent[0] != b {
+			t.Errorf("Chunk %d: expected 160 bytes of 0x%02x, got %d bytes of 0x%02x", i, b, len(sent), sent[0])
+		}
+	}
+}
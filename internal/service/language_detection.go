@@ -0,0 +1,65 @@
+package service
+
+import (
+	"strings"
+	"unicode"
+)
+
+// languageProfile 按常用虚词和特有字母识别同一书写系统内的语言
+type languageProfile struct {
+	words   map[string]bool
+	letters string
+}
+
+func newLanguageProfile(words, letters string) languageProfile {
+	p := languageProfile{words: make(map[string]bool), letters: letters}
+	for _, w := range strings.Fields(words) {
+		p.words[w] = true
+	}
+	return p
+}
+
+// languageProfiles 书写系统相同的实时语音语言，双向传译时按原文和译文的用词判断方向
+var languageProfiles = map[string]languageProfile{
+	"en": newLanguageProfile("the and is are you to of it that what where this with for have i my your was not do how can please thank we be in on", ""),
+	"es": newLanguageProfile("el la los las de que y es en un una por para con no está dónde qué cómo gracias yo tú usted muy hay mi su del al se", "ñ¿¡"),
+	"fr": newLanguageProfile("le la les de des et est un une je vous tu il elle nous pas que qui où merci pour avec dans du au ce ne sont suis", "çèêùœ"),
+	"de": newLanguageProfile("der die das und ist ich sie du nicht ein eine wo was wie mit für auf zu den dem danke bitte es wir haben bin sind gibt mein ja", "ßäöü"),
+	"pt": newLanguageProfile("o a os as de que e é um uma não você eu onde obrigado obrigada para com do da em no na está isso como muito por favor meu", "ãõç"),
+	"it": newLanguageProfile("il lo la gli le di che e è un una non io tu lei dove grazie per con del della sono come questo mi ti ci anche molto prego", "ìò"),
+	"nl": newLanguageProfile("de het een en is ik je jij u niet van wat waar hoe met voor op dat zijn dank bedankt alstublieft wij er ook maar naar heb mijn", ""),
+	"pl": newLanguageProfile("i w nie to jest się na z że co jak gdzie czy ja ty pan pani dziękuję proszę tak do jestem mam ale o ten ta", "ąęłśżźćń"),
+	"sv": newLanguageProfile("och är jag du det en ett inte att på med för var vad hur tack som vi har den till av om min mycket", "å"),
+	"tr": newLanguageProfile("ve bir bu ne nerede nasıl mi mı değil var yok ben sen siz teşekkür lütfen için ile çok evet hayır da de", "ğşı"),
+	"vi": newLanguageProfile("là và của có không tôi bạn ở đâu cái này cảm ơn gì được một người với cho rất vâng", "ăâđêôơư"),
+	"id": newLanguageProfile("yang dan di ini itu saya anda tidak apa mana ada dengan untuk terima kasih ke dari bisa akan sudah kami kita", ""),
+	"ru": newLanguageProfile("и в не на я что это он она вы ты где как с да нет спасибо пожалуйста мы у есть меня так все по", "ыэъё"),
+	"uk": newLanguageProfile("і в не на я що це він вона ви ти де як з так ні дякую будь ласка ми у є мене все по", "іїєґ"),
+}
+
+// hasLanguageProfile 语言是否能按用词与同一书写系统的其他语言区分
+func hasLanguageProfile(code string) bool {
+	_, ok := languageProfiles[code]
+	return ok
+}
+
+// languageTextScore 文本中属于该语言的常用词和特有字母个数，没有用词特征的语言得 0 分
+func languageTextScore(text, code string) int {
+	profile, ok := languageProfiles[code]
+	if !ok {
+		return 0
+	}
+	text = strings.ToLower(text)
+	score := 0
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		if profile.words[word] {
+			score++
+		}
+	}
+	for _, r := range text {
+		if strings.ContainsRune(profile.letters, r) {
+			score++
+		}
+	}
+	return score
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"smart-glasses-backend/internal/model"
+	"strings"
+)
+
+// ErrUnsupportedLanguage 语言不在注册表中
+var ErrUnsupportedLanguage = errors.New("unsupported language")
+
+// 语言支持的服务能力
+const (
+	ProviderTranslate = "translate" // 文本翻译
+	ProviderRealtime  = "realtime"  // 实时语音
+)
+
+// 双向传译判断说话语言的方式
+const (
+	DirectionDetectionScript = "script" // 按书写系统
+	DirectionDetectionWords  = "words"  // 按用词，书写系统相同的语言也能区分
+)
+
+// 显示名称的界面语言
+const (
+	LocaleEnglish            = "en"
+	LocaleChineseSimplified  = "zh-Hans"
+	LocaleChineseTraditional = "zh-Hant"
+)
+
+// languageDefinition 注册表内置条目
+type languageDefinition struct {
+	code       string
+	nativeName string
+	names      [3]string // en, zh-Hans, zh-Hant
+	script     string
+	rtl        bool
+	realtime   bool
+	aliases    []string // 旧代码、ISO 639-2 代码和常见写法
+}
+
+var builtinLanguages = []languageDefinition{
+	{"en", "English", [3]string{"English", "英语", "英語"}, "Latn", false, true, []string{"eng", "英文"}},
+	{"zh-Hans", "简体中文", [3]string{"Chinese (Simplified)", "简体中文", "簡體中文"}, "Hans", false, true,
+		[]string{"zh", "zh-CN", "zh-SG", "zh-Hans-CN", "zho", "chi", "chs", "cn", "chinese", "mandarin", "中文", "汉语", "普通话", "简体"}},
+	{"zh-Hant", "繁體中文", [3]string{"Chinese (Traditional)", "繁体中文", "繁體中文"}, "Hant", false, true,
+		[]string{"zh-TW", "zh-HK", "zh-MO", "zh-Hant-TW", "cht", "繁体", "繁體", "中文繁體"}},
+	{"ja", "日本語", [3]string{"Japanese", "日语", "日語"}, "Jpan", false, true, []string{"jpn", "jp", "日文"}},
+	{"ko", "한국어", [3]string{"Korean", "韩语", "韓語"}, "Kore", false, true, []string{"kor", "kr", "韩文", "韓文"}},
+	{"fr", "Français", [3]string{"French", "法语", "法語"}, "Latn", false, true, []string{"fra", "fre", "法文"}},
+	{"de", "Deutsch", [3]string{"German", "德语", "德語"}, "Latn", false, true, []string{"deu", "ger", "德文"}},
+	{"es", "Español", [3]string{"Spanish", "西班牙语", "西班牙語"}, "Latn", false, true, []string{"spa", "西班牙文"}},
+	{"pt", "Português", [3]string{"Portuguese", "葡萄牙语", "葡萄牙語"}, "Latn", false, true, []string{"por"}},
+	{"it", "Italiano", [3]string{"Italian", "意大利语", "義大利語"}, "Latn", false, true, []string{"ita"}},
+	{"ru", "Русский", [3]string{"Russian", "俄语", "俄語"}, "Cyrl", false, true, []string{"rus", "俄文"}},
+	{"uk", "Українська", [3]string{"Ukrainian", "乌克兰语", "烏克蘭語"}, "Cyrl", false, true, []string{"ukr"}},
+	{"pl", "Polski", [3]string{"Polish", "波兰语", "波蘭語"}, "Latn", false, true, []string{"pol"}},
+	{"nl", "Nederlands", [3]string{"Dutch", "荷兰语", "荷蘭語"}, "Latn", false, true, []string{"nld", "dut"}},
+	{"sv", "Svenska", [3]string{"Swedish", "瑞典语", "瑞典語"}, "Latn", false, true, []string{"swe"}},
+	{"el", "Ελληνικά", [3]string{"Greek", "希腊语", "希臘語"}, "Grek", false, true, []string{"ell", "gre"}},
+	{"tr", "Türkçe", [3]string{"Turkish", "土耳其语", "土耳其語"}, "Latn", false, true, []string{"tur"}},
+	{"ar", "العربية", [3]string{"Arabic", "阿拉伯语", "阿拉伯語"}, "Arab", true, true, []string{"ara"}},
+	{"he", "עברית", [3]string{"Hebrew", "希伯来语", "希伯來語"}, "Hebr", true, true, []string{"heb", "iw"}},
+	{"fa", "فارسی", [3]string{"Persian", "波斯语", "波斯語"}, "Arab", true, false, []string{"fas", "per", "farsi"}},
+	{"ur", "اردو", [3]string{"Urdu", "乌尔都语", "烏爾都語"}, "Arab", true, false, []string{"urd"}},
+	{"hi", "हिन्दी", [3]string{"Hindi", "印地语", "印地語"}, "Deva", false, true, []string{"hin"}},
+	{"th", "ไทย", [3]string{"Thai", "泰语", "泰語"}, "Thai", false, true, []string{"tha"}},
+	{"vi", "Tiếng Việt", [3]string{"Vietnamese", "越南语", "越南語"}, "Latn", false, true, []string{"vie"}},
+	{"id", "Bahasa Indonesia", [3]string{"Indonesian", "印度尼西亚语", "印尼語"}, "Latn", false, true, []string{"ind", "in"}},
+	{"ms", "Bahasa Melayu", [3]string{"Malay", "马来语", "馬來語"}, "Latn", false, false, []string{"msa", "may"}},
+}
+
+// LanguageRegistry 支持的语言及其别名索引
+type LanguageRegistry struct {
+	languages []*model.Language
+	byCode    map[string]*model.Language
+	index     map[string]string // 小写别名 -> 规范代码
+}
+
+// NewLanguageRegistry 创建包含内置语言的注册表
+func NewLanguageRegistry() *LanguageRegistry {
+	r := &LanguageRegistry{
+		byCode: make(map[string]*model.Language),
+		index:  make(map[string]string),
+	}
+
+	for _, def := range builtinLanguages {
+		providers := []string{ProviderTranslate}
+		if def.realtime {
+			providers = append(providers, ProviderRealtime)
+		}
+
+		lang := &model.Language{
+			Code:       def.code,
+			Name:       def.names[0],
+			NativeName: def.nativeName,
+			Names: map[string]string{
+				LocaleEnglish:            def.names[0],
+				LocaleChineseSimplified:  def.names[1],
+				LocaleChineseTraditional: def.names[2],
+			},
+			Script:             def.script,
+			RTL:                def.rtl,
+			Providers:          providers,
+			DirectionDetection: DirectionDetectionScript,
+		}
+		if hasLanguageProfile(def.code) {
+			lang.DirectionDetection = DirectionDetectionWords
+		}
+		r.languages = append(r.languages, lang)
+		r.byCode[def.code] = lang
+
+		r.addAlias(def.code, def.code)
+		r.addAlias(def.nativeName, def.code)
+		for _, name := range def.names {
+			r.addAlias(name, def.code)
+		}
+		for _, alias := range def.aliases {
+			r.addAlias(alias, def.code)
+		}
+	}
+
+	return r
+}
+
+// addAlias 先注册的别名优先，避免显示名称覆盖规范代码
+func (r *LanguageRegistry) addAlias(alias, code string) {
+	key := languageKey(alias)
+	if _, exists := r.index[key]; !exists {
+		r.index[key] = code
+	}
+}
+
+// languageKey 统一大小写和分隔符，"zh_CN"、"ZH-cn" 得到相同的键
+func languageKey(value string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(value), "_", "-"))
+}
+
+// Normalize 将语言代码、别名或显示名称解析为规范 BCP-47 代码
+// 未注册的地区子标签会逐级回退，例如 "en-US" 解析为 "en"
+func (r *LanguageRegistry) Normalize(value string) (string, error) {
+	key := languageKey(value)
+	if key == "" {
+		return "", fmt.Errorf("%w: empty language", ErrUnsupportedLanguage)
+	}
+
+	for {
+		if code, ok := r.index[key]; ok {
+			return code, nil
+		}
+		i := strings.LastIndex(key, "-")
+		if i <= 0 {
+			break
+		}
+		key = key[:i]
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrUnsupportedLanguage, value)
+}
+
+// NormalizePair 同时解析源语言和目标语言
+func (r *LanguageRegistry) NormalizePair(sourceLanguage, targetLanguage string) (string, string, error) {
+	source, err := r.Normalize(sourceLanguage)
+	if err != nil {
+		return "", "", err
+	}
+	target, err := r.Normalize(targetLanguage)
+	if err != nil {
+		return "", "", err
+	}
+	return source, target, nil
+}
+
+// Lookup 按代码或别名查找语言，Name 按指定界面语言本地化
+func (r *LanguageRegistry) Lookup(value, locale string) (*model.Language, error) {
+	code, err := r.Normalize(value)
+	if err != nil {
+		return nil, err
+	}
+	return r.localize(r.byCode[code], locale), nil
+}
+
+// List 返回支持指定能力的语言，provider 为空时返回全部
+func (r *LanguageRegistry) List(locale, provider string) []*model.Language {
+	result := make([]*model.Language, 0, len(r.languages))
+	for _, lang := range r.languages {
+		if provider != "" && !hasProvider(lang, provider) {
+			continue
+		}
+		result = append(result, r.localize(lang, locale))
+	}
+	return result
+}
+
+// Supports 判断语言是否支持指定能力
+func (r *LanguageRegistry) Supports(code, provider string) bool {
+	lang, ok := r.byCode[code]
+	return ok && hasProvider(lang, provider)
+}
+
+// localize 返回副本，避免修改注册表中的共享条目
+func (r *LanguageRegistry) localize(lang *model.Language, locale string) *model.Language {
+	localized := *lang
+	if name, ok := lang.Names[r.resolveLocale(locale)]; ok {
+		localized.Name = name
+	}
+	return &localized
+}
+
+// resolveLocale 将界面语言映射到有显示名称的语言，未知时使用英文
+func (r *LanguageRegistry) resolveLocale(locale string) string {
+	code, err := r.Normalize(locale)
+	if err != nil {
+		return LocaleEnglish
+	}
+	if _, ok := r.byCode[code].Names[code]; ok {
+		return code
+	}
+	return LocaleEnglish
+}
+
+func hasProvider(lang *model.Language, provider string) bool {
+	for _, p := range lang.Providers {
+		if p == provider {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLanguageRegistry_Normalize(t *testing.T) {
+	registry := NewLanguageRegistry()
+
+	cases := map[string]string{
+		"zh":                   "zh-Hans",
+		"zh-CN":                "zh-Hans",
+		"zh_cn":                "zh-Hans",
+		"Chinese":              "zh-Hans",
+		"中文":                   "zh-Hans",
+		"zh-TW":                "zh-Hant",
+		"zh-Hant-HK":           "zh-Hant",
+		"en":                   "en",
+		"EN-us":                "en",
+		"English":              "en",
+		"英语":                   "en",
+		"jpn":                  "ja",
+		"iw":                   "he",
+		"pt-BR":                "pt",
+		"Chinese (Simplified)": "zh-Hans",
+	}
+	for input, expected := range cases {
+		code, err := registry.Normalize(input)
+		if err != nil {
+			t.Errorf("Normalize(%q) returned error: %v", input, err)
+			continue
+		}
+		if code != expected {
+			t.Errorf("Normalize(%q) = %q, expected %q", input, code, expected)
+		}
+	}
+
+	for _, input := range []string{"", "klingon", "x-unknown"} {
+		if _, err := registry.Normalize(input); !errors.Is(err, ErrUnsupportedLanguage) {
+			t.Errorf("Normalize(%q) expected ErrUnsupportedLanguage, got %v", input, err)
+		}
+	}
+}
+
+func TestLanguageRegistry_CanonicalCodesAreStable(t *testing.T) {
+	registry := NewLanguageRegistry()
+
+	for _, lang := range registry.List("", "") {
+		code, err := registry.Normalize(lang.Code)
+		if err != nil || code != lang.Code {
+			t.Errorf("Canonical code %q normalized to %q (%v)", lang.Code, code, err)
+		}
+		if len(lang.Code) > 35 {
+			t.Errorf("Code %q does not fit the language columns", lang.Code)
+		}
+	}
+}
+
+func TestLanguageRegistry_ListLocalized(t *testing.T) {
+	registry := NewLanguageRegistry()
+
+	lang, err := registry.Lookup("ar", "zh-CN")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if lang.Name != "阿拉伯语" || !lang.RTL || lang.Script != "Arab" || lang.DirectionDetection != DirectionDetectionScript {
+		t.Errorf("Unexpected Arabic entry: %+v", lang)
+	}
+	if lang, _ := registry.Lookup("es", ""); lang.DirectionDetection != DirectionDetectionWords {
+		t.Errorf("Expected Spanish to support word-based direction detection, got %q", lang.DirectionDetection)
+	}
+
+	lang, _ = registry.Lookup("ar", "xx")
+	if lang.Name != "Arabic" {
+		t.Errorf("Expected English fallback name, got %q", lang.Name)
+	}
+
+	all := registry.List("en", "")
+	realtime := registry.List("en", ProviderRealtime)
+	if len(realtime) == 0 || len(realtime) >= len(all) {
+		t.Errorf("Expected provider filter to narrow the list: %d of %d", len(realtime), len(all))
+	}
+	if registry.Supports("fa", ProviderRealtime) {
+		t.Error("Expected fa to be text-only")
+	}
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// oggCRCTable Ogg 页校验使用的 CRC-32（多项式 0x04C11DB7，不反转）
+var oggCRCTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04C11DB7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return table
+}()
+
+func oggCRC(page []byte) uint32 {
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// oggStream Ogg 中一条逻辑流的全部数据包
+type oggStream struct {
+	serial  uint32
+	packets [][]byte
+	granule int64 // 最后一页的 granule position，-1 表示未知
+}
+
+// oggFormatError 构造 Ogg 容器格式错误
+func oggFormatError(format string, args ...interface{}) error {
+	return &AudioProcessingError{
+		Type:    "format_error",
+		Message: "Invalid Ogg data",
+		Details: fmt.Sprintf(format, args...),
+	}
+}
+
+// demuxOgg 按页解析 Ogg 并重组数据包，返回第一条逻辑流
+// 末尾不完整的页被丢弃，兼容录音中途截断的文件
+func demuxOgg(data []byte) (*oggStream, error) {
+	var stream *oggStream
+	var partial []byte
+
+	pos := 0
+	for pos+27 <= len(data) {
+		if !bytes.Equal(data[pos:pos+4], []byte("OggS")) {
+			return nil, oggFormatError("Missing capture pattern at offset %d", pos)
+		}
+		if data[pos+4] != 0 {
+			return nil, oggFormatError("Unsupported Ogg version %d", data[pos+4])
+		}
+		headerType := data[pos+5]
+		granule := int64(binary.LittleEndian.Uint64(data[pos+6 : pos+14]))
+		serial := binary.LittleEndian.Uint32(data[pos+14 : pos+18])
+		segments := int(data[pos+26])
+		headerLen := 27 + segments
+		if pos+headerLen > len(data) {
+			break
+		}
+		lacing := data[pos+27 : pos+headerLen]
+		bodyLen := 0
+		for _, l := range lacing {
+			bodyLen += int(l)
+		}
+		if pos+headerLen+bodyLen > len(data) {
+			break
+		}
+
+		page := make([]byte, headerLen+bodyLen)
+		copy(page, data[pos:pos+headerLen+bodyLen])
+		expected := binary.LittleEndian.Uint32(page[22:26])
+		binary.LittleEndian.PutUint32(page[22:26], 0)
+		if oggCRC(page) != expected {
+			return nil, oggFormatError("CRC mismatch in page at offset %d", pos)
+		}
+
+		if stream == nil {
+			if headerType&0x02 == 0 {
+				return nil, oggFormatError("First page is not a beginning-of-stream page")
+			}
+			stream = &oggStream{serial: serial, granule: -1}
+		}
+		pos += headerLen + bodyLen
+		if serial != stream.serial {
+			continue // 只取第一条逻辑流
+		}
+
+		if headerType&0x01 == 0 {
+			partial = nil // 续页标记缺失时丢弃未完成的包
+		}
+		body := page[headerLen:]
+		for _, l := range lacing {
+			partial = append(partial, body[:l]...)
+			body = body[l:]
+			if l < 255 {
+				stream.packets = append(stream.packets, partial)
+				partial = nil
+			}
+		}
+		if granule != -1 {
+			stream.granule = granule
+		}
+		if headerType&0x04 != 0 {
+			break // EOS
+		}
+	}
+
+	if stream == nil {
+		return nil, oggFormatError("No Ogg pages found")
+	}
+	return stream, nil
+}
+
+// oggWriter 把数据包按页写入一条 Ogg 逻辑流，每页只包含完整的包
+type oggWriter struct {
+	w       io.Writer
+	serial  uint32
+	pageSeq uint32
+	lacing  []byte
+	body    []byte
+	granule int64
+	started bool
+}
+
+func newOggWriter(w io.Writer, serial uint32) *oggWriter {
+	return &oggWriter{w: w, serial: serial}
+}
+
+// writePacket 追加一个包，granule 为该包结束时的 granule position
+// 标识头需要独占一页时由调用方随后调用 flush
+func (o *oggWriter) writePacket(packet []byte, granule int64) error {
+	segments := len(packet)/255 + 1
+	if len(o.lacing)+segments > 255 {
+		if err := o.flush(false); err != nil {
+			return err
+		}
+	}
+	for n := len(packet); n >= 255; n -= 255 {
+		o.lacing = append(o.lacing, 255)
+	}
+	o.lacing = append(o.lacing, byte(len(packet)%255))
+	o.body = append(o.body, packet...)
+	o.granule = granule
+	return nil
+}
+
+// flush 写出当前页，eos 为 true 时标记流结束
+func (o *oggWriter) flush(eos bool) error {
+	if len(o.lacing) == 0 && !eos {
+		return nil
+	}
+	var headerType byte
+	if !o.started {
+		headerType |= 0x02
+		o.started = true
+	}
+	if eos {
+		headerType |= 0x04
+	}
+
+	page := make([]byte, 27+len(o.lacing), 27+len(o.lacing)+len(o.body))
+	copy(page, "OggS")
+	page[5] = headerType
+	binary.LittleEndian.PutUint64(page[6:14], uint64(o.granule))
+	binary.LittleEndian.PutUint32(page[14:18], o.serial)
+	binary.LittleEndian.PutUint32(page[18:22], o.pageSeq)
+	page[26] = byte(len(o.lacing))
+	copy(page[27:], o.lacing)
+	page = append(page, o.body...)
+	binary.LittleEndian.PutUint32(page[22:26], oggCRC(page))
+
+	o.pageSeq++
+	o.lacing = o.lacing[:0]
+	o.body = o.body[:0]
+	_, err := o.w.Write(page)
+	return err
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDemuxOgg_Opus(t *testing.T) {
+	stream, err := demuxOgg(readAudioFixture(t, "silence_opus.ogg"))
+	if err != nil {
+		t.Fatalf("demuxOgg failed: %v", err)
+	}
+	// OpusHead、OpusTags 和 10 个音频包
+	if len(stream.packets) != 12 {
+		t.Fatalf("Expected 12 packets, got %d", len(stream.packets))
+	}
+	if stream.granule != 10*960-100 {
+		t.Errorf("Unexpected final granule %d", stream.granule)
+	}
+	if _, err := parseOpusHead(stream.packets[0]); err != nil {
+		t.Errorf("parseOpusHead failed: %v", err)
+	}
+}
+
+func TestDemuxOgg_Errors(t *testing.T) {
+	valid := readAudioFixture(t, "silence_opus.ogg")
+
+	corrupt := append([]byte(nil), valid...)
+	corrupt[40] ^= 0xFF
+	if _, err := demuxOgg(corrupt); err == nil || !strings.Contains(err.Error(), "CRC") {
+		t.Errorf("Expected CRC error, got %v", err)
+	}
+
+	if _, err := demuxOgg([]byte("not an ogg file at all, really")); err == nil {
+		t.Error("Expected error for missing capture pattern")
+	}
+
+	// 末尾不完整的页被丢弃
+	stream, err := demuxOgg(valid[:len(valid)-10])
+	if err != nil {
+		t.Fatalf("demuxOgg failed on truncated data: %v", err)
+	}
+	if len(stream.packets) != 11 {
+		t.Errorf("Expected 11 packets from truncated data, got %d", len(stream.packets))
+	}
+}
+
+func TestConvertOggToPCM16_Unsupported(t *testing.T) {
+	if newOpusDecoder != nil {
+		t.Skip("built with an Opus decoder")
+	}
+	_, err := NewAudioProcessor().ConvertAudioFormat(readAudioFixture(t, "silence_opus.ogg"), string(FormatOgg), string(FormatPCM16))
+	if audioErr, ok := err.(*AudioProcessingError); !ok || audioErr.Type != "unsupported_format" {
+		t.Errorf("Expected unsupported_format without an Opus decoder, got %v", err)
+	}
+}
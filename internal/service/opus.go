@@ -0,0 +1,223 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// opusSampleRate Opus 解码输出的采样率
+const opusSampleRate = 48000
+
+// opusDecoder 把单个 Opus 包解码为 48kHz 交错 PCM16
+type opusDecoder interface {
+	Decode(packet []byte) ([]int16, error)
+	Close()
+}
+
+// newOpusDecoder 默认构建不包含 Opus 解码器；使用 -tags opus 构建时由 opus_libopus.go 设置为 libopus 实现
+var newOpusDecoder func(channels int) (opusDecoder, error)
+
+// opusEncoder 把固定帧长的 PCM16 样本编码为单个 Opus 包
+type opusEncoder interface {
+	Encode(pcm []int16) ([]byte, error)
+	Close()
+}
+
+// newOpusEncoder 与 newOpusDecoder 相同，只在 -tags opus 构建时可用
+var newOpusEncoder func(sampleRate, channels int) (opusEncoder, error)
+
+// OpusEncodingAvailable 当前构建是否包含 Opus 编码器
+func OpusEncodingAvailable() bool {
+	return newOpusEncoder != nil
+}
+
+// opusHead OpusHead 标识头（RFC 7845 5.1）
+type opusHead struct {
+	channels   int
+	preSkip    int
+	inputRate  int
+	outputGain int16 // Q7.8 dB
+}
+
+// parseOpusHead 解析 OpusHead，只支持映射族 0 的单声道和立体声
+func parseOpusHead(packet []byte) (*opusHead, error) {
+	if len(packet) < 19 || !bytes.HasPrefix(packet, []byte("OpusHead")) {
+		return nil, &AudioProcessingError{
+			Type:    "format_error",
+			Message: "Invalid Opus stream",
+			Details: "Missing or truncated OpusHead",
+		}
+	}
+	if packet[8]>>4 != 0 {
+		return nil, &AudioProcessingError{
+			Type:    "unsupported_format",
+			Message: "Unsupported Opus stream",
+			Details: fmt.Sprintf("OpusHead version %d is not supported", packet[8]),
+		}
+	}
+
+	head := &opusHead{
+		channels:   int(packet[9]),
+		preSkip:    int(binary.LittleEndian.Uint16(packet[10:12])),
+		inputRate:  int(binary.LittleEndian.Uint32(packet[12:16])),
+		outputGain: int16(binary.LittleEndian.Uint16(packet[16:18])),
+	}
+	if mapping := packet[18]; mapping != 0 || head.channels < 1 || head.channels > 2 {
+		return nil, &AudioProcessingError{
+			Type:    "unsupported_format",
+			Message: "Unsupported Opus stream",
+			Details: fmt.Sprintf("Channel mapping family %d with %d channels is not supported", mapping, head.channels),
+		}
+	}
+	return head, nil
+}
+
+// decodeOpusPackets 解码 Opus 包并平均为 48kHz 单声道 PCM16
+// 去掉 pre-skip 的样本；totalSamples 非负时按其截断末尾的填充
+func decodeOpusPackets(head *opusHead, packets [][]byte, totalSamples int64) ([]byte, error) {
+	if newOpusDecoder == nil {
+		return nil, &AudioProcessingError{
+			Type:    "unsupported_format",
+			Message: "Opus decoding is not available",
+			Details: "This server was built without an Opus decoder; rebuild with -tags opus and libopus installed",
+		}
+	}
+	decoder, err := newOpusDecoder(head.channels)
+	if err != nil {
+		return nil, &AudioProcessingError{Type: "decode_error", Message: "Failed to create Opus decoder", Details: err.Error()}
+	}
+	defer decoder.Close()
+
+	gain := 1.0
+	if head.outputGain != 0 {
+		gain = math.Pow(10, float64(head.outputGain)/(20*256))
+	}
+
+	var out []byte
+	skip := head.preSkip
+	for i, packet := range packets {
+		pcm, err := decoder.Decode(packet)
+		if err != nil {
+			return nil, &AudioProcessingError{
+				Type:    "decode_error",
+				Message: "Failed to decode Opus packet",
+				Details: fmt.Sprintf("packet %d: %v", i, err),
+			}
+		}
+		for f := 0; f+head.channels <= len(pcm); f += head.channels {
+			if skip > 0 {
+				skip--
+				continue
+			}
+			var sum float64
+			for c := 0; c < head.channels; c++ {
+				sum += float64(pcm[f+c])
+			}
+			out = binary.LittleEndian.AppendUint16(out, uint16(clampInt16(sum/float64(head.channels)*gain)))
+		}
+	}
+
+	if totalSamples >= 0 && int64(len(out)/2) > totalSamples {
+		out = out[:totalSamples*2]
+	}
+	return out, nil
+}
+
+// Ogg Opus 编码参数
+const (
+	opusEncodeFrameMs = 20
+	opusEncodePreSkip = 312 // libopus 在 48kHz 下的编码延迟（6.5ms）
+	opusPagePackets   = 50  // 每页最多 1 秒的包
+	opusVendor        = "smart-glasses-backend"
+)
+
+// encodeOggOpus 把单声道 PCM16 流编码为 Ogg Opus（RFC 7845），sampleRate 须为 8/12/16/24/48kHz
+// 末尾不足一帧时补零，最后一页的 granule position 标出实际时长，解码端据此去掉填充
+func encodeOggOpus(w io.Writer, pcm io.Reader, sampleRate int) error {
+	if newOpusEncoder == nil {
+		return &AudioProcessingError{
+			Type:    "unsupported_format",
+			Message: "Opus encoding is not available",
+			Details: "This server was built without an Opus encoder; rebuild with -tags opus and libopus installed",
+		}
+	}
+	if opusSampleRate%sampleRate != 0 {
+		return fmt.Errorf("unsupported Opus input rate %d", sampleRate)
+	}
+	encoder, err := newOpusEncoder(sampleRate, 1)
+	if err != nil {
+		return err
+	}
+	defer encoder.Close()
+
+	ogg := newOggWriter(w, uint32(time.Now().UnixNano()))
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8] = 1
+	head[9] = 1
+	binary.LittleEndian.PutUint16(head[10:12], opusEncodePreSkip)
+	binary.LittleEndian.PutUint32(head[12:16], uint32(sampleRate))
+	tags := []byte("OpusTags")
+	tags = binary.LittleEndian.AppendUint32(tags, uint32(len(opusVendor)))
+	tags = append(tags, opusVendor...)
+	tags = binary.LittleEndian.AppendUint32(tags, 0)
+	for _, header := range [][]byte{head, tags} {
+		if err := ogg.writePacket(header, 0); err != nil {
+			return err
+		}
+		if err := ogg.flush(false); err != nil {
+			return err
+		}
+	}
+
+	scale := int64(opusSampleRate / sampleRate)
+	frame := sampleRate * opusEncodeFrameMs / 1000
+	buf := make([]byte, frame*2)
+	var samples, packets int64
+	encode := func() error {
+		packet, err := encoder.Encode(pcm16Samples(buf))
+		if err != nil {
+			return err
+		}
+		// 新包到来时才写出满页，最后一页总有包，可以带上流结束标记和最终时长
+		if packets > 0 && packets%opusPagePackets == 0 {
+			if err := ogg.flush(false); err != nil {
+				return err
+			}
+		}
+		packets++
+		return ogg.writePacket(packet, packets*int64(frame)*scale)
+	}
+
+	for {
+		n, err := io.ReadFull(pcm, buf)
+		if n > 0 {
+			n -= n % 2
+			clear(buf[n:])
+			samples += int64(n / 2)
+			if err := encode(); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// 编码延迟把末尾的语音推到下一帧时多编码一帧静音
+	end := samples*scale + opusEncodePreSkip
+	if end > packets*int64(frame)*scale {
+		clear(buf)
+		if err := encode(); err != nil {
+			return err
+		}
+	}
+	ogg.granule = end
+	return ogg.flush(true)
+}
@@ -0,0 +1,98 @@
+//go:build opus
+
+package service
+
+/*
+#cgo pkg-config: opus
+#include <opus.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// opusMaxFrameSamples 单个 Opus 包最长 120ms
+const opusMaxFrameSamples = opusSampleRate * 120 / 1000
+
+// opusMaxPacketBytes 单个 Opus 包的最大长度
+const opusMaxPacketBytes = 4000
+
+func init() {
+	newOpusDecoder = newLibopusDecoder
+	newOpusEncoder = newLibopusEncoder
+}
+
+// libopusDecoder 基于 libopus 的解码器，需要安装 libopus 开发包
+type libopusDecoder struct {
+	dec      *C.OpusDecoder
+	channels int
+	pcm      []int16
+}
+
+func newLibopusDecoder(channels int) (opusDecoder, error) {
+	var status C.int
+	dec := C.opus_decoder_create(C.opus_int32(opusSampleRate), C.int(channels), &status)
+	if status != C.OPUS_OK {
+		return nil, fmt.Errorf("opus_decoder_create: %s", C.GoString(C.opus_strerror(status)))
+	}
+	return &libopusDecoder{
+		dec:      dec,
+		channels: channels,
+		pcm:      make([]int16, opusMaxFrameSamples*channels),
+	}, nil
+}
+
+// Decode 解码一个包，返回的切片在下次调用前有效；空包按丢包做补偿
+func (d *libopusDecoder) Decode(packet []byte) ([]int16, error) {
+	var data *C.uchar
+	if len(packet) > 0 {
+		data = (*C.uchar)(unsafe.Pointer(&packet[0]))
+	}
+	n := C.opus_decode(d.dec, data, C.opus_int32(len(packet)),
+		(*C.opus_int16)(unsafe.Pointer(&d.pcm[0])), C.int(opusMaxFrameSamples), 0)
+	if n < 0 {
+		return nil, fmt.Errorf("opus_decode: %s", C.GoString(C.opus_strerror(n)))
+	}
+	return d.pcm[:int(n)*d.channels], nil
+}
+
+func (d *libopusDecoder) Close() {
+	if d.dec != nil {
+		C.opus_decoder_destroy(d.dec)
+		d.dec = nil
+	}
+}
+
+// libopusEncoder 基于 libopus 的编码器，使用语音模式和默认码率
+type libopusEncoder struct {
+	enc *C.OpusEncoder
+	out []byte
+}
+
+func newLibopusEncoder(sampleRate, channels int) (opusEncoder, error) {
+	var status C.int
+	enc := C.opus_encoder_create(C.opus_int32(sampleRate), C.int(channels), C.OPUS_APPLICATION_VOIP, &status)
+	if status != C.OPUS_OK {
+		return nil, fmt.Errorf("opus_encoder_create: %s", C.GoString(C.opus_strerror(status)))
+	}
+	return &libopusEncoder{enc: enc, out: make([]byte, opusMaxPacketBytes)}, nil
+}
+
+// Encode 编码一帧，pcm 长度必须是 libopus 支持的帧长；返回的切片在下次调用前有效
+func (e *libopusEncoder) Encode(pcm []int16) ([]byte, error) {
+	n := C.opus_encode(e.enc, (*C.opus_int16)(unsafe.Pointer(&pcm[0])), C.int(len(pcm)),
+		(*C.uchar)(unsafe.Pointer(&e.out[0])), C.opus_int32(len(e.out)))
+	if n < 0 {
+		return nil, fmt.Errorf("opus_encode: %s", C.GoString(C.opus_strerror(n)))
+	}
+	return e.out[:int(n)], nil
+}
+
+func (e *libopusEncoder) Close() {
+	if e.enc != nil {
+		C.opus_encoder_destroy(e.enc)
+		e.enc = nil
+	}
+}
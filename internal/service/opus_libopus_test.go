@@ -0,0 +1,66 @@
+//go:build opus
+
+package service
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestConvertOpusToPCM16_Libopus(t *testing.T) {
+	ap := NewAudioProcessor()
+
+	// 10 个 20ms 包，去掉 312 个 pre-skip 样本；Ogg 还按最后的 granule 去掉 100 个填充样本
+	cases := []struct {
+		fixture, format string
+		samples48k      int
+	}{
+		{"silence_opus.webm", string(FormatWebM), 10*960 - 312},
+		{"silence_opus.ogg", string(FormatOgg), 10*960 - 100 - 312},
+	}
+	for _, tc := range cases {
+		pcm, err := ap.ConvertAudioFormat(readAudioFixture(t, tc.fixture), tc.format, string(FormatPCM16))
+		if err != nil {
+			t.Fatalf("%s: ConvertAudioFormat failed: %v", tc.fixture, err)
+		}
+		expected := tc.samples48k / 3
+		if n := len(pcm) / 2; n < expected-1 || n > expected+1 {
+			t.Errorf("%s: expected about %d samples at 16kHz, got %d", tc.fixture, expected, n)
+		}
+		for _, v := range pcmSamples(pcm) {
+			if v != 0 {
+				t.Fatalf("%s: expected silence, got sample %v", tc.fixture, v)
+			}
+		}
+	}
+}
+
+// TestConvertOpusToPCM16_LibopusTone 用 libopus 编码真实的 Ogg Opus 流，解码后检查音调的频率、幅度和信噪比
+func TestConvertOpusToPCM16_LibopusTone(t *testing.T) {
+	var ogg bytes.Buffer
+	tone := generateTone(RealtimeSampleRate, 440, 8000, 1000, 1)
+	if err := encodeOggOpus(&ogg, bytes.NewReader(tone), RealtimeSampleRate); err != nil {
+		t.Fatalf("encodeOggOpus failed: %v", err)
+	}
+
+	ap := NewAudioProcessor()
+	pcm, err := ap.ConvertAudioFormat(ogg.Bytes(), string(FormatOgg), string(FormatPCM16))
+	if err != nil {
+		t.Fatalf("ConvertAudioFormat failed: %v", err)
+	}
+	// 去掉 pre-skip 和末尾填充后时长与输入一致
+	if n := len(pcm) / 2; n < 15990 || n > 16010 {
+		t.Errorf("Expected about 16000 samples at 16kHz, got %d", n)
+	}
+
+	amplitude, snr := analyzeTone(pcmSamples(pcm), 16000, 440)
+	if amplitude < 8000*0.8 || amplitude > 8000*1.2 {
+		t.Errorf("Expected 440Hz amplitude near 8000, got %.0f", amplitude)
+	}
+	if snr < 15 {
+		t.Errorf("Expected SNR of at least 15dB at 440Hz, got %.1fdB", snr)
+	}
+	if amplitude, _ := analyzeTone(pcmSamples(pcm), 16000, 1000); amplitude > 800 {
+		t.Errorf("Expected no energy at 1kHz, got amplitude %.0f", amplitude)
+	}
+}
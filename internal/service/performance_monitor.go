@@ -37,6 +37,20 @@ type AudioLatencyMetric struct {
 	SampleCount         int           `json:"sample_count"`         // 样本数量
 	LastMeasurement     time.Time     `json:"last_measurement"`     // 最后测量时间
 	QualityScore        float64       `json:"quality_score"`        // 质量评分 (0-100)
+
+	// 本地 VAD 统计，会话开启静音抑制时更新
+	SpeechDuration     time.Duration `json:"speech_duration"`
+	SilenceDuration    time.Duration `json:"silence_duration"`
+	SuppressedDuration time.Duration `json:"suppressed_duration"` // 未发送给上游的静音
+	SpeechRatio        float64       `json:"speech_ratio"`        // 语音占上行音频的比例
+	SuppressedRatio    float64       `json:"suppressed_ratio"`    // 被抑制的比例
+
+	// 上行音频电平，削波或无输入持续超过阈值时告警，恢复后重新计时
+	InputLevel      *AudioLevel   `json:"input_level,omitempty"` // 最近一个统计窗口
+	ClippedDuration time.Duration `json:"clipped_duration"`      // 连续削波时长
+	SilentDuration  time.Duration `json:"silent_duration"`       // 连续无输入时长
+	clippedAlerted  bool
+	silentAlerted   bool
 }
 
 // WebSocketMetric WebSocket性能指标
@@ -106,8 +120,22 @@ type PerformanceThresholds struct {
 	MaxGoroutines       int           `json:"max_goroutines"`        // 最大Goroutine数量
 	MinQualityScore     float64       `json:"min_quality_score"`     // 最小质量评分
 	AlertCooldown       time.Duration `json:"alert_cooldown"`        // 告警冷却时间
+
+	// 上行音频质量，0 表示使用默认值
+	MaxInputClippingRate float64       `json:"max_input_clipping_rate"` // 削波样本比例上限
+	MinInputLevelDBFS    float64       `json:"min_input_level_dbfs"`    // 低于该电平视为麦克风无输入
+	ClippedInputDuration time.Duration `json:"clipped_input_duration"`  // 持续削波多久告警
+	SilentInputDuration  time.Duration `json:"silent_input_duration"`   // 持续无输入多久告警
 }
 
+// 上行音频质量阈值默认值
+const (
+	defaultMaxInputClippingRate = 0.01  // 削波样本超过 1%
+	defaultMinInputLevelDBFS    = -80.0 // 正常环境噪声远高于此，持续低于说明麦克风静音或故障
+	defaultClippedInputDuration = 5 * time.Second
+	defaultSilentInputDuration  = 10 * time.Second
+)
+
 // AlertEvent 性能告警事件
 type AlertEvent struct {
 	Type        string                 `json:"type"`
@@ -139,6 +167,11 @@ func NewPerformanceMonitor() *PerformanceMonitor {
 			MaxGoroutines:     1000,
 			MinQualityScore:   70.0,
 			AlertCooldown:     5 * time.Minute,
+
+			MaxInputClippingRate: defaultMaxInputClippingRate,
+			MinInputLevelDBFS:    defaultMinInputLevelDBFS,
+			ClippedInputDuration: defaultClippedInputDuration,
+			SilentInputDuration:  defaultSilentInputDuration,
 		},
 		alertCallbacks:    make(map[string]func(AlertEvent)),
 		monitoringEnabled: true,
@@ -205,6 +238,14 @@ func (pm *PerformanceMonitor) MeasureAudioLatency(sessionID string, processingSt
 		return
 	}
 
+	// triggerAlert 需要读锁，释放写锁后再触发
+	var alert *AlertEvent
+	defer func() {
+		if alert != nil {
+			pm.triggerAlert(*alert)
+		}
+	}()
+
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
@@ -247,7 +288,7 @@ func (pm *PerformanceMonitor) MeasureAudioLatency(sessionID string, processingSt
 
 	// 检查性能阈值
 	if totalLatency > pm.performanceThresholds.MaxAudioLatency {
-		pm.triggerAlert(AlertEvent{
+		alert = &AlertEvent{
 			Type:      "audio_latency_high",
 			Severity:  "warning",
 			Message:   fmt.Sprintf("Audio latency %v exceeds threshold %v", totalLatency, pm.performanceThresholds.MaxAudioLatency),
@@ -257,13 +298,118 @@ func (pm *PerformanceMonitor) MeasureAudioLatency(sessionID string, processingSt
 			SessionID: sessionID,
 			UserID:    metric.UserID,
 			Timestamp: now,
-		})
+		}
 	}
 
 	log.Printf("Audio latency measured for session %s: processing=%v, transmission=%v, total=%v, quality=%.1f", 
 		sessionID, processingLatency, transmissionLatency, totalLatency, metric.QualityScore)
 }
 
+// RecordVoiceActivity 累计会话的语音和静音时长，更新语音占比和静音抑制比例
+func (pm *PerformanceMonitor) RecordVoiceActivity(sessionID string, activity VoiceActivity) {
+	if !pm.monitoringEnabled {
+		return
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	metric, exists := pm.audioLatencyMetrics[sessionID]
+	if !exists {
+		return
+	}
+
+	ms := func(v float64) time.Duration { return time.Duration(v * float64(time.Millisecond)) }
+	metric.SpeechDuration += ms(activity.SpeechMs)
+	metric.SilenceDuration += ms(activity.SilenceMs)
+	metric.SuppressedDuration += ms(activity.SuppressedMs)
+	if total := metric.SpeechDuration + metric.SilenceDuration; total > 0 {
+		metric.SpeechRatio = float64(metric.SpeechDuration) / float64(total)
+		metric.SuppressedRatio = float64(metric.SuppressedDuration) / float64(total)
+	}
+}
+
+// RecordInputLevel 记录会话上行音频一个统计窗口的电平
+// 削波比例或电平持续超过阈值时触发 audio_input_clipping 或 audio_input_silent 告警，每次异常只告警一次
+func (pm *PerformanceMonitor) RecordInputLevel(sessionID string, level AudioLevel) {
+	if !pm.monitoringEnabled {
+		return
+	}
+
+	var alerts []AlertEvent
+	defer func() {
+		for _, alert := range alerts {
+			pm.triggerAlert(alert)
+		}
+	}()
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	metric, exists := pm.audioLatencyMetrics[sessionID]
+	if !exists {
+		return
+	}
+	metric.InputLevel = &level
+
+	t := pm.performanceThresholds
+	maxClipping, minLevel := t.MaxInputClippingRate, t.MinInputLevelDBFS
+	clippedFor, silentFor := t.ClippedInputDuration, t.SilentInputDuration
+	if maxClipping <= 0 {
+		maxClipping = defaultMaxInputClippingRate
+	}
+	if minLevel == 0 {
+		minLevel = defaultMinInputLevelDBFS
+	}
+	if clippedFor <= 0 {
+		clippedFor = defaultClippedInputDuration
+	}
+	if silentFor <= 0 {
+		silentFor = defaultSilentInputDuration
+	}
+
+	window := time.Duration(level.DurationMs * float64(time.Millisecond))
+	now := time.Now()
+	newAlert := func(alertType, message, metricName string, value, threshold interface{}, duration time.Duration) AlertEvent {
+		return AlertEvent{
+			Type:      alertType,
+			Severity:  "warning",
+			Message:   message,
+			Metric:    metricName,
+			Value:     value,
+			Threshold: threshold,
+			SessionID: sessionID,
+			UserID:    metric.UserID,
+			Timestamp: now,
+			Metadata:  map[string]interface{}{"duration": duration.String()},
+		}
+	}
+
+	if level.ClippingRate > maxClipping {
+		metric.ClippedDuration += window
+		if metric.ClippedDuration >= clippedFor && !metric.clippedAlerted {
+			metric.clippedAlerted = true
+			alerts = append(alerts, newAlert("audio_input_clipping",
+				fmt.Sprintf("Input audio clipped for %v (%.1f%% of samples)", metric.ClippedDuration, level.ClippingRate*100),
+				"input_clipping_rate", level.ClippingRate, maxClipping, metric.ClippedDuration))
+		}
+	} else {
+		metric.ClippedDuration, metric.clippedAlerted = 0, false
+	}
+
+	if level.RMSDBFS < minLevel {
+		metric.SilentDuration += window
+		if metric.SilentDuration >= silentFor && !metric.silentAlerted {
+			metric.silentAlerted = true
+			alerts = append(alerts, newAlert("audio_input_silent",
+				fmt.Sprintf("No input audio above %.0f dBFS for %v, microphone may be muted or faulty", minLevel, metric.SilentDuration),
+				"input_rms_dbfs", level.RMSDBFS, minLevel, metric.SilentDuration))
+		}
+	} else {
+		metric.SilentDuration, metric.silentAlerted = 0, false
+	}
+}
+
 // calculateAudioQualityScore 计算音频质量评分
 func (pm *PerformanceMonitor) calculateAudioQualityScore(latency time.Duration) float64 {
 	// 基于延迟计算质量评分 (0-100)
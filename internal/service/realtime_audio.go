@@ -0,0 +1,312 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// newSessionAudioProcessor 按会话配置创建会话自己的音频处理器
+func (s *RealtimeService) newSessionAudioProcessor(cfg *RealtimeSessionConfig) *AudioProcessor {
+	ap := NewAudioProcessor()
+	if err := s.applyAudioConfig(ap, cfg); err != nil {
+		log.Printf("Invalid audio config %d Hz/%d ch, using defaults: %v", cfg.SampleRate, cfg.Channels, err)
+	}
+	return ap
+}
+
+// SetTranscodeG711 设置是否把 G.711 客户端的音频转换为 pcm16 再发给上游
+// 默认直接以 G.711 收发，上游部署不支持 G.711 时开启
+func (s *RealtimeService) SetTranscodeG711(enabled bool) {
+	s.transcodeG711 = enabled
+}
+
+// upstreamAudioFormat 与 Realtime API 之间使用的音频格式
+// 客户端使用 G.711 且允许直通时上游直接收发 G.711，否则在服务端转换为 pcm16
+func (s *RealtimeService) upstreamAudioFormat(cfg *RealtimeSessionConfig) AudioFormat {
+	if format := AudioFormat(cfg.AudioFormat); isG711(format) && !s.transcodeG711 {
+		return format
+	}
+	return FormatPCM16
+}
+
+// applyAudioConfig 设置客户端格式、上行采样率、声道数、播放采样率、上游格式、静音抑制和预处理，配置已由 ResolveSessionConfig 校验
+func (s *RealtimeService) applyAudioConfig(ap *AudioProcessor, cfg *RealtimeSessionConfig) error {
+	ap.setUpstreamFormat(s.upstreamAudioFormat(cfg))
+	if err := ap.SetAudioConfig(AudioConfig{
+		SampleRate:   cfg.SampleRate,
+		ChannelCount: cfg.Channels,
+		BitDepth:     16,
+		Format:       AudioFormat(cfg.AudioFormat),
+	}); err != nil {
+		return err
+	}
+	if err := ap.SetPlaybackSampleRate(cfg.PlaybackSampleRate()); err != nil {
+		return err
+	}
+
+	suppression := cfg.SilenceSuppression
+	if cfg.VAD.Enabled && suppression.HangoverMs < cfg.VAD.SilenceDurationMs {
+		// 服务器 VAD 要收到足够长的静音才会结束本轮，拖尾期不能比它短
+		suppression.HangoverMs = cfg.VAD.SilenceDurationMs
+	}
+	if err := ap.SetSilenceSuppression(suppression); err != nil {
+		return err
+	}
+	return ap.SetPreprocessing(cfg.Preprocessing)
+}
+
+// SendSessionAudio 把客户端音频转换为上游格式后发送，并计入会话的语音时长
+// 开启静音抑制时静音不发送；未启用服务器 VAD 且开启自动提交时，语音结束后提交音频并请求回复
+func (s *RealtimeService) SendSessionAudio(session *RealtimeSession, audioData []byte) error {
+	chunk, err := session.audio.ProcessRealtimeAudioChunk(audioData)
+	if err != nil {
+		return err
+	}
+	activity := session.audio.TakeVoiceActivity()
+	if activity != (VoiceActivity{}) {
+		s.performanceMonitor.RecordVoiceActivity(session.ID.String(), activity)
+	}
+
+	if err := s.forwardAudio(session, chunk); err != nil {
+		return err
+	}
+
+	s.reportAudioLevel(session)
+
+	if activity.SpeechEnded {
+		cfg := session.Config()
+		if cfg.SilenceSuppression.AutoCommit && !cfg.VAD.Enabled {
+			return s.autoCommitAudio(session)
+		}
+	}
+	return nil
+}
+
+// forwardAudio 把处理后的客户端音频转换为上游格式发送，并计入用量和录音
+func (s *RealtimeService) forwardAudio(session *RealtimeSession, chunk []byte) error {
+	if len(chunk) == 0 {
+		return nil
+	}
+	converted, err := session.audio.ConvertInputAudio(chunk)
+	if err != nil {
+		return err
+	}
+	// 不足一个输出样本时留在重采样器中与下一块一起发送
+	if len(converted) == 0 {
+		return nil
+	}
+	if err := s.SendAudioData(session.Upstream(), converted); err != nil {
+		return err
+	}
+	session.audioInMicros.Add(int64(session.audio.upstreamAudioMs(len(converted)) * 1000))
+	s.recordAudio(session, recordingInbound, converted)
+	s.checkAudioUsage(session)
+	return nil
+}
+
+// flushPreprocessing 提交前送出降噪器滞后的尾部音频，否则一句话的最后一帧要等到下一句才发送
+func (s *RealtimeService) flushPreprocessing(session *RealtimeSession) error {
+	return s.forwardAudio(session, session.audio.FlushPreprocessing())
+}
+
+// reportAudioLevel 每个统计窗口把上行电平记入会话指标并发送 audio_level 供客户端显示麦克风指示
+// 客户端关闭 audio_level 消息时仍按默认窗口统计，用于削波和无输入告警
+func (s *RealtimeService) reportAudioLevel(session *RealtimeSession) {
+	interval := session.Config().AudioLevelInterval
+	window := interval
+	if window == 0 {
+		window = defaultAudioLevelIntervalMs
+	}
+	level, ok := session.audio.TakeAudioLevel(float64(window))
+	if !ok {
+		return
+	}
+	s.performanceMonitor.RecordInputLevel(session.ID.String(), level)
+	if interval == 0 {
+		return
+	}
+	s.sendToClientWithTimeout(session.ClientConn, map[string]interface{}{
+		"type":          "audio_level",
+		"rms_dbfs":      math.Round(level.RMSDBFS*10) / 10,
+		"peak_dbfs":     math.Round(level.PeakDBFS*10) / 10,
+		"clipping_rate": level.ClippingRate,
+		"dc_offset":     level.DCOffset,
+		"zero_run_ms":   level.ZeroRunMs,
+		"duration_ms":   level.DurationMs,
+		"timestamp":     time.Now().UnixMilli(),
+	})
+}
+
+// autoCommitAudio 本地 VAD 检测到语音结束，代替客户端提交音频并请求回复
+func (s *RealtimeService) autoCommitAudio(session *RealtimeSession) error {
+	if err := s.flushPreprocessing(session); err != nil {
+		return err
+	}
+	if err := s.CommitAudioBuffer(session.Upstream()); err != nil {
+		return err
+	}
+	s.sendToClientWithTimeout(session.ClientConn, map[string]interface{}{
+		"type":      "audio_committed",
+		"reason":    "speech_ended",
+		"timestamp": time.Now().UnixMilli(),
+	})
+	return s.RequestResponse(session, nil)
+}
+
+// EnableBinaryAudio 客户端在握手时协商了二进制音频帧，助手语音改为以二进制帧发送
+func (rs *RealtimeSession) EnableBinaryAudio() {
+	rs.binaryAudio.Store(true)
+}
+
+// BinaryAudio 是否使用二进制音频帧
+func (rs *RealtimeSession) BinaryAudio() bool {
+	return rs.binaryAudio.Load()
+}
+
+// SendSessionAudioFrame 处理客户端的二进制音频帧，帧头的序号和时间戳经抖动缓冲重排
+func (s *RealtimeService) SendSessionAudioFrame(session *RealtimeSession, frame *AudioFrame) error {
+	if frame.Type != AudioFrameTypeInput {
+		return fmt.Errorf("%w: expected input frame, got type 0x%02x", ErrInvalidAudioFrame, frame.Type)
+	}
+	return s.SendSessionAudioPacket(session, AudioPacket{
+		Sequence:         frame.Sequence,
+		CaptureTimestamp: frame.Timestamp,
+		Payload:          frame.Payload,
+	})
+}
+
+// SendSessionAudioPacket 带序号的上行音频先经抖动缓冲按序号重排，丢失的包以静音补齐后发送
+// 丢包率和抖动计入会话的连接质量指标
+func (s *RealtimeService) SendSessionAudioPacket(session *RealtimeSession, pkt AudioPacket) error {
+	session.jitterMu.Lock()
+	defer session.jitterMu.Unlock()
+
+	before := session.jitter.Stats()
+	chunks := session.jitter.Push(pkt, time.Now())
+	stats := session.jitter.Stats()
+	if stats.Lost > before.Lost {
+		log.Printf("Audio packets lost in session %s: %d before sequence %d", session.ID, stats.Lost-before.Lost, pkt.Sequence)
+	}
+	s.securityMonitor.UpdatePacketStats(session.ID.String(), stats)
+	err := s.sendSessionChunks(session, chunks)
+	s.scheduleJitterExpiry(session)
+	return err
+}
+
+// scheduleJitterExpiry 缓存中有等待缺包的音频时在等待到期后送出，调用方持有 jitterMu
+// 开启服务器 VAD 时客户端不发送 commit_audio，缺口后客户端停止发送时仍需把语音尾部送到上游
+func (s *RealtimeService) scheduleJitterExpiry(session *RealtimeSession) {
+	deadline, ok := session.jitter.Deadline()
+	if !ok {
+		if session.jitterTimer != nil {
+			session.jitterTimer.Stop()
+		}
+		return
+	}
+	wait := time.Until(deadline)
+	if session.jitterTimer == nil {
+		session.jitterTimer = time.AfterFunc(wait, func() { s.expireSessionAudio(session) })
+		return
+	}
+	session.jitterTimer.Reset(wait)
+}
+
+// expireSessionAudio 缺包等待到期，以静音补齐缺口并送出后续音频；上游重连期间丢弃
+func (s *RealtimeService) expireSessionAudio(session *RealtimeSession) {
+	session.jitterMu.Lock()
+	defer session.jitterMu.Unlock()
+	if session.closed() {
+		return
+	}
+
+	before := session.jitter.Stats()
+	chunks := session.jitter.Expire(time.Now())
+	stats := session.jitter.Stats()
+	if stats.Lost > before.Lost {
+		log.Printf("Audio packets lost in session %s: %d after the client stopped sending", session.ID, stats.Lost-before.Lost)
+	}
+	s.securityMonitor.UpdatePacketStats(session.ID.String(), stats)
+	if !session.Reconnecting() {
+		if err := s.sendSessionChunks(session, chunks); err != nil {
+			log.Printf("Failed to send buffered audio for session %s: %v", session.ID, err)
+		}
+	}
+	s.scheduleJitterExpiry(session)
+}
+
+// FlushSessionAudio 提交音频前送出抖动缓冲中等待缺包的音频和降噪器滞后的尾部
+func (s *RealtimeService) FlushSessionAudio(session *RealtimeSession) error {
+	session.jitterMu.Lock()
+	defer session.jitterMu.Unlock()
+
+	if chunks := session.jitter.Flush(); len(chunks) > 0 {
+		s.securityMonitor.UpdatePacketStats(session.ID.String(), session.jitter.Stats())
+		if err := s.sendSessionChunks(session, chunks); err != nil {
+			return err
+		}
+	}
+	s.scheduleJitterExpiry(session)
+	return s.flushPreprocessing(session)
+}
+
+// sendSessionChunks 依次发送抖动缓冲送出的音频，空包跳过
+func (s *RealtimeService) sendSessionChunks(session *RealtimeSession, chunks [][]byte) error {
+	for _, chunk := range chunks {
+		if len(chunk) == 0 {
+			continue
+		}
+		if err := s.SendSessionAudio(session, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendClientAudio 把助手语音转发给客户端，协商了二进制帧时发送原始音频，否则发送 audio_response
+func (s *RealtimeService) sendClientAudio(session *RealtimeSession, audioData string) {
+	if !session.BinaryAudio() {
+		s.sendToClientWithTimeout(session.ClientConn, map[string]interface{}{
+			"type":        "audio_response",
+			"audio":       s.clientAudio(session, audioData),
+			"format":      session.audio.GetAudioConfig().Format,
+			"sample_rate": session.audio.PlaybackSampleRate(),
+			"timestamp":   time.Now().UnixMilli(),
+		})
+		return
+	}
+
+	audio, err := base64.StdEncoding.DecodeString(audioData)
+	if err != nil {
+		log.Printf("Invalid audio delta for session %s: %v", session.ID, err)
+		return
+	}
+	if !session.audio.passthroughOutput() {
+		audio = session.audio.ConvertOutputAudio(audio)
+	}
+	frame := EncodeAudioFrame(&AudioFrame{
+		Type:      AudioFrameTypeOutput,
+		Sequence:  session.outputSeq,
+		Timestamp: time.Now().UnixMilli(),
+		Payload:   audio,
+	})
+	session.outputSeq++
+	if err := s.WriteBinary(session.ClientConn, frame, 2*time.Second); err != nil {
+		log.Printf("Failed to send audio frame to session %s: %v", session.ID, err)
+	}
+}
+
+// clientAudio 把上游语音转换为客户端格式和播放采样率，无需转换时原样转发
+func (s *RealtimeService) clientAudio(session *RealtimeSession, audioData string) string {
+	if session.audio.passthroughOutput() {
+		return audioData
+	}
+	pcm, err := base64.StdEncoding.DecodeString(audioData)
+	if err != nil {
+		log.Printf("Invalid audio delta for session %s: %v", session.ID, err)
+		return audioData
+	}
+	return base64.StdEncoding.EncodeToString(session.audio.ConvertOutputAudio(pcm))
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+func TestSessionAudio_ConvertsToAndFromClientRate(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+
+	clientConn, client := wsPair(t)
+	gptConn, upstream := wsPair(t)
+	cfg := DefaultRealtimeSessionConfig()
+	cfg.SampleRate = 16000
+	cfg.Channels = 2
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, cfg)
+	t.Cleanup(func() { service.EndSession(session) })
+	go service.HandleRealtimeResponse(session)
+
+	// 16kHz 立体声上行，上游收到 24kHz 单声道
+	if err := service.SendSessionAudio(session, generateTone(16000, 1000, 8000, 200, 2)); err != nil {
+		t.Fatalf("SendSessionAudio failed: %v", err)
+	}
+	upstream.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var appended map[string]interface{}
+	if err := upstream.ReadJSON(&appended); err != nil {
+		t.Fatalf("Failed to read upstream audio: %v", err)
+	}
+	pcm, _ := base64.StdEncoding.DecodeString(appended["audio"].(string))
+	if n := len(pcm) / 2; n != RealtimeSampleRate/5 {
+		t.Errorf("Expected %d upstream samples, got %d", RealtimeSampleRate/5, n)
+	}
+	if amplitude, _ := analyzeTone(pcmSamples(pcm), RealtimeSampleRate, 1000); math.Abs(amplitude-8000) > 80 {
+		t.Errorf("Expected upstream tone amplitude 8000, got %.1f", amplitude)
+	}
+	if session.audioInMicros.Load() != 200000 {
+		t.Errorf("Expected 200ms of converted audio to be metered, got %dus", session.audioInMicros.Load())
+	}
+
+	// 24kHz 助手语音转换回客户端的 16kHz
+	upstream.WriteJSON(map[string]interface{}{"type": "response.created", "response": map[string]interface{}{"id": "resp_1"}})
+	upstream.WriteJSON(map[string]interface{}{
+		"type":        "response.audio.delta",
+		"response_id": "resp_1",
+		"delta":       base64.StdEncoding.EncodeToString(generateTone(RealtimeSampleRate, 1000, 8000, 200, 1)),
+	})
+	msg := readClientMessages(t, client, "audio_response")["audio_response"]
+	if msg["sample_rate"] != float64(16000) {
+		t.Errorf("Expected sample_rate 16000, got %v", msg["sample_rate"])
+	}
+	out, _ := base64.StdEncoding.DecodeString(msg["audio"].(string))
+	if n := len(out) / 2; n != 16000/5 {
+		t.Errorf("Expected %d client samples, got %d", 16000/5, n)
+	}
+}
+
+// 客户端声明的 sample_rate 和 channels 在 session_configured 中返回，上行音频必须按它们转换
+func TestSessionAudio_AppliesResolvedClientFormat(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+
+	clientConn, _ := wsPair(t)
+	gptConn, upstream := wsPair(t)
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, nil)
+	t.Cleanup(func() { service.EndSession(session) })
+
+	cfg, err := service.ResolveSessionConfig(session.Config(), &RealtimeSessionConfigRequest{SampleRate: 48000, Channels: 2})
+	if err != nil {
+		t.Fatalf("ResolveSessionConfig failed: %v", err)
+	}
+	service.UpdateSessionConfig(session, cfg)
+
+	// 48kHz 立体声 100ms 上行，上游收到 24kHz 单声道 pcm16
+	if err := service.SendSessionAudio(session, generateTone(48000, 1000, 8000, 100, 2)); err != nil {
+		t.Fatalf("SendSessionAudio failed: %v", err)
+	}
+	upstream.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var appended map[string]interface{}
+	if err := upstream.ReadJSON(&appended); err != nil {
+		t.Fatalf("Failed to read upstream audio: %v", err)
+	}
+	pcm, _ := base64.StdEncoding.DecodeString(appended["audio"].(string))
+	if n := len(pcm) / 2; n != RealtimeSampleRate/10 {
+		t.Errorf("Expected %d upstream samples, got %d", RealtimeSampleRate/10, n)
+	}
+	if amplitude, _ := analyzeTone(pcmSamples(pcm), RealtimeSampleRate, 1000); math.Abs(amplitude-8000) > 80 {
+		t.Errorf("Expected upstream tone amplitude 8000, got %.1f", amplitude)
+	}
+}
+
+func TestSessionAudio_G711PassthroughSkipsPCMValidation(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+	clientConn, _ := wsPair(t)
+	gptConn, upstream := wsPair(t)
+	cfg, err := service.ResolveSessionConfig(nil, &RealtimeSessionConfigRequest{AudioFormat: string(FormatG711ULaw)})
+	if err != nil {
+		t.Fatalf("ResolveSessionConfig failed: %v", err)
+	}
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, cfg)
+	t.Cleanup(func() { service.EndSession(session) })
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	// 20ms 的 G.711 只有 160 字节，奇数长度也是合法的
+	for _, size := range []int{160, 161} {
+		chunk := encodeG711(generateTone(8000, 1000, 8000, 20, 1), FormatG711ULaw)[:160]
+		chunk = append(chunk, chunk[:size-160]...)
+		if err := service.SendSessionAudio(session, chunk); err != nil {
+			t.Fatalf("SendSessionAudio failed: %v", err)
+		}
+		upstream.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var appended map[string]interface{}
+		if err := upstream.ReadJSON(&appended); err != nil {
+			t.Fatalf("Failed to read upstream audio: %v", err)
+		}
+		if sent, _ := base64.StdEncoding.DecodeString(appended["audio"].(string)); len(sent) != size {
+			t.Errorf("Expected %d bytes upstream, got %d", size, len(sent))
+		}
+	}
+	if strings.Contains(logs.String(), "error") {
+		t.Errorf("Expected no validation errors for G.711 chunks, got:\n%s", logs.String())
+	}
+}
+
+func TestSessionAudio_G711(t *testing.T) {
+	ulaw := encodeG711(generateTone(8000, 1000, 8000, 100, 1), FormatG711ULaw)
+
+	cases := []struct {
+		name         string
+		transcode    bool
+		upstreamSize int
+	}{
+		{"native", false, len(ulaw)},
+		{"transcoded", true, RealtimeSampleRate / 10 * 2},
+	}
+	for _, tc := range cases {
+		service := NewRealtimeService("test", "test", "test", "test")
+		service.SetTranscodeG711(tc.transcode)
+
+		clientConn, client := wsPair(t)
+		gptConn, upstream := wsPair(t)
+		cfg, err := service.ResolveSessionConfig(nil, &RealtimeSessionConfigRequest{AudioFormat: string(FormatG711ULaw)})
+		if err != nil {
+			t.Fatalf("%s: ResolveSessionConfig failed: %v", tc.name, err)
+		}
+		session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, cfg)
+		t.Cleanup(func() { service.EndSession(session) })
+		go service.HandleRealtimeResponse(session)
+
+		update := service.buildSessionUpdate(cfg)["session"].(map[string]interface{})
+		expectedFormat := "g711_ulaw"
+		if tc.transcode {
+			expectedFormat = "pcm16"
+		}
+		if update["input_audio_format"] != expectedFormat || update["output_audio_format"] != expectedFormat {
+			t.Errorf("%s: expected upstream format %s, got %v/%v", tc.name, expectedFormat, update["input_audio_format"], update["output_audio_format"])
+		}
+
+		if err := service.SendSessionAudio(session, ulaw); err != nil {
+			t.Fatalf("%s: SendSessionAudio failed: %v", tc.name, err)
+		}
+		upstream.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var appended map[string]interface{}
+		if err := upstream.ReadJSON(&appended); err != nil {
+			t.Fatalf("%s: failed to read upstream audio: %v", tc.name, err)
+		}
+		sent, _ := base64.StdEncoding.DecodeString(appended["audio"].(string))
+		if len(sent) != tc.upstreamSize {
+			t.Errorf("%s: expected %d upstream bytes, got %d", tc.name, tc.upstreamSize, len(sent))
+		}
+		if session.audioInMicros.Load() != 100000 {
+			t.Errorf("%s: expected 100ms metered, got %dus", tc.name, session.audioInMicros.Load())
+		}
+
+		// 助手语音以客户端的 G.711 格式返回
+		reply := ulaw
+		if tc.transcode {
+			reply = generateTone(RealtimeSampleRate, 1000, 8000, 100, 1)
+		}
+		upstream.WriteJSON(map[string]interface{}{
+			"type":        "response.audio.delta",
+			"response_id": "resp_1",
+			"delta":       base64.StdEncoding.EncodeToString(reply),
+		})
+		msg := readClientMessages(t, client, "audio_response")["audio_response"]
+		out, _ := base64.StdEncoding.DecodeString(msg["audio"].(string))
+		if msg["format"] != "g711_ulaw" || msg["sample_rate"] != float64(8000) || len(out) != 800 {
+			t.Errorf("%s: unexpected audio_response %v/%v with %d bytes", tc.name, msg["format"], msg["sample_rate"], len(out))
+		}
+	}
+}
+
+func TestSessionAudio_BinaryFrames(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+
+	clientConn, client := wsPair(t)
+	gptConn, upstream := wsPair(t)
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, DefaultRealtimeSessionConfig())
+	session.EnableBinaryAudio()
+	t.Cleanup(func() { service.EndSession(session) })
+	go service.HandleRealtimeResponse(session)
+
+	// 上行二进制帧的原始 PCM 转发给上游
+	pcm := generateTone(RealtimeSampleRate, 1000, 8000, 100, 1)
+	if err := service.SendSessionAudioFrame(session, &AudioFrame{Type: AudioFrameTypeInput, Sequence: 7, Payload: pcm}); err != nil {
+		t.Fatalf("SendSessionAudioFrame failed: %v", err)
+	}
+	upstream.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var appended map[string]interface{}
+	if err := upstream.ReadJSON(&appended); err != nil {
+		t.Fatalf("Failed to read upstream audio: %v", err)
+	}
+	if appended["audio"] != base64.StdEncoding.EncodeToString(pcm) {
+		t.Error("Expected frame payload to be forwarded unchanged")
+	}
+	if err := service.SendSessionAudioFrame(session, &AudioFrame{Type: AudioFrameTypeOutput}); !errors.Is(err, ErrInvalidAudioFrame) {
+		t.Errorf("Expected output frame from client to be rejected, got %v", err)
+	}
+
+	// 助手语音以带递增序号的二进制帧下发
+	for i := 0; i < 2; i++ {
+		upstream.WriteJSON(map[string]interface{}{
+			"type":        "response.audio.delta",
+			"response_id": "resp_1",
+			"delta":       base64.StdEncoding.EncodeToString(pcm),
+		})
+	}
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for seq := uint32(0); seq < 2; {
+		messageType, data, err := client.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read client message: %v", err)
+		}
+		if messageType != websocket.BinaryMessage {
+			continue
+		}
+		frame, err := ParseAudioFrame(data)
+		if err != nil {
+			t.Fatalf("ParseAudioFrame failed: %v", err)
+		}
+		if frame.Type != AudioFrameTypeOutput || frame.Sequence != seq || len(frame.Payload) != len(pcm) {
+			t.Errorf("Unexpected output frame type %d seq %d with %d bytes", frame.Type, frame.Sequence, len(frame.Payload))
+		}
+		seq++
+	}
+}
+
+func TestFlushSessionAudio_SendsDenoiserTail(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+
+	clientConn, _ := wsPair(t)
+	gptConn, upstream := wsPair(t)
+	cfg := DefaultRealtimeSessionConfig()
+	cfg.SampleRate = RealtimeSampleRate
+	cfg.Preprocessing.NoiseSuppression = true
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, cfg)
+	t.Cleanup(func() { service.EndSession(session) })
+
+	// 降噪滞后的尾部在提交前送出，上游收到的音频与客户端发送的等长
+	audio := generateTone(RealtimeSampleRate, 1000, 8000, 100, 1)
+	for _, chunk := range splitChunks(audio, RealtimeSampleRate/1000*20*2) {
+		if err := service.SendSessionAudio(session, chunk); err != nil {
+			t.Fatalf("SendSessionAudio failed: %v", err)
+		}
+	}
+	if err := service.FlushSessionAudio(session); err != nil {
+		t.Fatalf("FlushSessionAudio failed: %v", err)
+	}
+	if err := service.CommitAudioBuffer(gptConn); err != nil {
+		t.Fatalf("CommitAudioBuffer failed: %v", err)
+	}
+
+	upstream.SetReadDeadline(time.Now().Add(2 * time.Second))
+	sent := 0
+	for {
+		var msg map[string]interface{}
+		if err := upstream.ReadJSON(&msg); err != nil {
+			t.Fatalf("Failed to read upstream message: %v", err)
+		}
+		if msg["type"] == "input_audio_buffer.commit" {
+			break
+		}
+		pcm, _ := base64.StdEncoding.DecodeString(msg["audio"].(string))
+		sent += len(pcm)
+	}
+	if sent != len(audio) {
+		t.Errorf("Expected %d bytes upstream before the commit, got %d", len(audio), sent)
+	}
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// StartMonitoredSession 为一个实时连接分配会话 ID，登记安全会话并开始连接和性能监控
+// 同一 ID 用于会话记录、监控接口和性能指标；并发会话数达到上限时返回 ErrMaxSessionsReached
+// Requirements: 9.1, 9.2, 9.5, 10.4, 10.5
+func (s *RealtimeService) StartMonitoredSession(userID uuid.UUID, userEmail, clientIP, userAgent string) (uuid.UUID, error) {
+	sessionID := uuid.New()
+	key := sessionID.String()
+
+	if _, err := s.securityMonitor.StartSessionWithID(key, userID.String(), userEmail, clientIP, userAgent); err != nil {
+		return uuid.Nil, err
+	}
+
+	s.securityMonitor.StartConnectionMonitoring(key, userID.String())
+	s.performanceMonitor.StartAudioLatencyMonitoring(key, userID.String())
+	s.performanceMonitor.StartWebSocketMonitoring(key)
+	return sessionID, nil
+}
+
+// EndMonitoredSession 结束安全会话，reason 为 completed、timeout 或 error
+// 指标保留到监控清理任务删除，结束后仍可查询
+func (s *RealtimeService) EndMonitoredSession(sessionID uuid.UUID, reason string) {
+	s.securityMonitor.EndSession(sessionID.String(), reason)
+}
+
+// RecordClientMessage 记录一条客户端上行消息，更新会话活动时间和传输统计
+func (s *RealtimeService) RecordClientMessage(sessionID uuid.UUID, msgType string, size int) {
+	key := sessionID.String()
+	s.securityMonitor.UpdateSessionActivity(key)
+	s.performanceMonitor.RecordWebSocketMessage(key, "received", size, 0, false)
+	if msgType == "audio_data" || msgType == "audio_frame" {
+		s.securityMonitor.UpdateConnectionMetric(key, 0, int64(size), false)
+	}
+}
+
+// bindConn 关联连接与会话 ID，按连接发送的消息据此记录到会话的指标
+func (s *RealtimeService) bindConn(conn *websocket.Conn, sessionID uuid.UUID) {
+	if conn != nil {
+		s.connSessions.Store(conn, sessionID.String())
+	}
+}
+
+// metricsKey 返回连接所属会话的 ID，未关联的连接返回空字符串，不记录指标
+func (s *RealtimeService) metricsKey(conn *websocket.Conn) string {
+	if key, ok := s.connSessions.Load(conn); ok {
+		return key.(string)
+	}
+	return ""
+}
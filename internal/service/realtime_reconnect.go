@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"smart-glasses-backend/internal/model"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+)
+
+// upstreamReconnectPolicy 上游断开后立即开始退避重连
+var upstreamReconnectPolicy = BackoffPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    8 * time.Second,
+	DelayFirst:  true,
+}
+
+// upstreamConnectTimeout 单次重连的连接超时
+const upstreamConnectTimeout = 30 * time.Second
+
+// upstreamKeepalive 上游保活参数：定期发送 ping，收到 pong 时延长读超时
+type upstreamKeepalive struct {
+	pingInterval time.Duration
+	readTimeout  time.Duration
+}
+
+var defaultUpstreamKeepalive = upstreamKeepalive{
+	pingInterval: 20 * time.Second,
+	readTimeout:  60 * time.Second,
+}
+
+// replayItemPrefix 回放会话项的 ID 前缀，上游回传的 conversation.item.created 据此忽略
+const replayItemPrefix = "replay_"
+
+// 对话回放参数
+const (
+	maxHistoryTurns    = 50   // 会话内保留的轮数
+	replayRecentTurns  = 10   // 原样回放的最近轮数，更早的合并为摘要
+	maxReplayTextRunes = 1000 // 单轮回放文本的最大字符数
+	summaryLineRunes   = 80   // 摘要中每轮保留的字符数
+)
+
+// historyTurn 会话内的一轮对话，用于上游重连后恢复上下文
+type historyTurn struct {
+	role string
+	text string
+}
+
+// appendHistory 记录一轮对话，只在 HandleRealtimeResponse 协程中调用
+func (rs *RealtimeSession) appendHistory(role, text string) {
+	rs.history = append(rs.history, historyTurn{role: role, text: text})
+	if len(rs.history) > maxHistoryTurns {
+		rs.history = rs.history[len(rs.history)-maxHistoryTurns:]
+	}
+}
+
+// resetResponseState 丢弃断线前未完成的响应状态
+func (rs *RealtimeSession) resetResponseState() {
+	rs.playbackMu.Lock()
+	rs.playback = responsePlayback{}
+	rs.playbackMu.Unlock()
+
+	rs.responseText.Reset()
+	rs.turns.reset()
+	rs.toolCalls = nil
+}
+
+// watchUpstreamPongs 收到上游的 pong 时延长读超时，上游长时间没有事件不会被当作断线
+// 只在 HandleRealtimeResponse 协程中调用，pong 处理函数由读取方执行
+func (s *RealtimeService) watchUpstreamPongs(conn *websocket.Conn) {
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(s.keepalive.readTimeout))
+	})
+}
+
+// keepUpstreamAlive 定期向当前上游连接发送 ping，stop 关闭或会话结束时退出；重连期间跳过
+func (s *RealtimeService) keepUpstreamAlive(session *RealtimeSession, stop <-chan struct{}) {
+	ticker := time.NewTicker(s.keepalive.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-session.ctx.Done():
+			return
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if session.reconnecting.Load() {
+			continue
+		}
+		// 控制帧可以与 WriteJSON 并发发送，不需要连接写锁
+		if err := session.Upstream().WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second)); err != nil {
+			log.Printf("Failed to ping upstream for session %s: %v", session.ID, err)
+		}
+	}
+}
+
+// reconnectUpstream 上游断开后按退避策略重连，恢复会话配置并回放对话
+// 客户端连接保持打开，通过 reconnecting/reconnected 消息了解状态；全部失败时返回 false
+func (s *RealtimeService) reconnectUpstream(session *RealtimeSession, cause error) bool {
+	session.reconnecting.Store(true)
+	defer session.reconnecting.Store(false)
+
+	if old := session.Upstream(); old != nil {
+		old.Close()
+		s.releaseConn(old)
+	}
+	log.Printf("Upstream connection lost for session %s: %v", session.ID, cause)
+
+	policy := upstreamReconnectPolicy
+	notify := func(attempt int) {
+		s.sendToClientWithTimeout(session.ClientConn, map[string]interface{}{
+			"type":         "reconnecting",
+			"session_id":   session.ID,
+			"attempt":      attempt,
+			"max_attempts": policy.MaxAttempts,
+			"timestamp":    time.Now().UnixMilli(),
+		})
+	}
+
+	// 等待前通知第一次尝试，之后每次失败时通知下一次
+	notify(1)
+	var conn *websocket.Conn
+	var replayed, attempts int
+	err := s.errorHandler.RetryWithPolicy(session.ctx, "upstream:"+session.ID.String(), policy, func(attempt int) error {
+		attempts = attempt
+		c, n, err := s.reconnectOnce(session)
+		if err != nil {
+			log.Printf("Upstream reconnect attempt %d/%d for session %s failed: %v",
+				attempt, policy.MaxAttempts, session.ID, err)
+			if attempt < policy.MaxAttempts {
+				notify(attempt + 1)
+			}
+			return err
+		}
+		conn, replayed = c, n
+		return nil
+	})
+	if err != nil {
+		return false
+	}
+
+	session.setUpstream(conn)
+	s.bindConn(conn, session.ID)
+	if session.closed() {
+		// 重连期间客户端已断开，EndSession 可能拿到的是旧连接
+		conn.Close()
+		s.releaseConn(conn)
+		return false
+	}
+	session.resetResponseState()
+
+	log.Printf("Upstream reconnected for session %s after %d attempts, replayed %d items",
+		session.ID, attempts, replayed)
+	s.sendToClientWithTimeout(session.ClientConn, map[string]interface{}{
+		"type":           "reconnected",
+		"session_id":     session.ID,
+		"attempt":        attempts,
+		"replayed_turns": replayed,
+		"timestamp":      time.Now().UnixMilli(),
+	})
+	return true
+}
+
+// reconnectOnce 建立一次上游连接并恢复会话，失败时关闭新连接
+func (s *RealtimeService) reconnectOnce(session *RealtimeSession) (*websocket.Conn, int, error) {
+	ctx, cancel := context.WithTimeout(session.ctx, upstreamConnectTimeout)
+	conn, err := s.ConnectToGPTRealtime(ctx)
+	cancel()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	replayed, err := s.restoreSession(session, conn)
+	if err != nil {
+		conn.Close()
+		s.releaseConn(conn)
+		return nil, 0, fmt.Errorf("restore session: %w", err)
+	}
+	return conn, replayed, nil
+}
+
+// restoreSession 在新连接上恢复会话配置和对话上下文，返回实际回放的会话项数
+// 较早的对话合并为一条摘要；传译模式每轮相互独立，只恢复配置
+func (s *RealtimeService) restoreSession(session *RealtimeSession, conn *websocket.Conn) (int, error) {
+	cfg := session.Config()
+	if err := s.ConfigureSession(conn, cfg); err != nil {
+		return 0, err
+	}
+	if cfg.Mode == RealtimeModeInterpreter {
+		return 0, nil
+	}
+
+	items := buildReplayItems(session.history)
+	for _, item := range items {
+		if err := s.WriteJSON(conn, map[string]interface{}{
+			"type": "conversation.item.create",
+			"item": item,
+		}, 5*time.Second); err != nil {
+			return 0, err
+		}
+	}
+	return len(items), nil
+}
+
+// buildReplayItems 最近几轮原样回放，更早的对话压缩为一条系统消息摘要
+func buildReplayItems(history []historyTurn) []map[string]interface{} {
+	var items []map[string]interface{}
+
+	recent := history
+	if len(history) > replayRecentTurns {
+		older := history[:len(history)-replayRecentTurns]
+		recent = history[len(history)-replayRecentTurns:]
+
+		var summary strings.Builder
+		summary.WriteString("以下是较早对话的摘要，请结合后续消息继续对话：")
+		for _, turn := range older {
+			summary.WriteString("\n")
+			summary.WriteString(historyRoleLabel(turn.role))
+			summary.WriteString("：")
+			summary.WriteString(truncateRunes(turn.text, summaryLineRunes))
+		}
+		items = append(items, map[string]interface{}{
+			"id":   replayItemPrefix + "summary",
+			"type": "message",
+			"role": "system",
+			"content": []map[string]interface{}{
+				{"type": "input_text", "text": summary.String()},
+			},
+		})
+	}
+
+	for i, turn := range recent {
+		contentType := "input_text"
+		if turn.role == model.RealtimeRoleAssistant {
+			contentType = "text"
+		}
+		items = append(items, map[string]interface{}{
+			"id":   fmt.Sprintf("%s%d", replayItemPrefix, i),
+			"type": "message",
+			"role": turn.role,
+			"content": []map[string]interface{}{
+				{"type": contentType, "text": truncateRunes(turn.text, maxReplayTextRunes)},
+			},
+		})
+	}
+
+	return items
+}
+
+func historyRoleLabel(role string) string {
+	if role == model.RealtimeRoleAssistant {
+		return "助手"
+	}
+	return "用户"
+}
+
+// truncateRunes 按字符截断，超出部分以省略号表示
+func truncateRunes(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	return string([]rune(text)[:limit]) + "…"
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestUpstreamReconnectPolicy_Delay(t *testing.T) {
+	expected := map[int]time.Duration{
+		1:  500 * time.Millisecond,
+		2:  time.Second,
+		4:  4 * time.Second,
+		5:  8 * time.Second,
+		10: 8 * time.Second,
+	}
+	for attempt, delay := range expected {
+		if got := upstreamReconnectPolicy.Delay(attempt); got != delay {
+			t.Errorf("Delay(%d) = %v, want %v", attempt, got, delay)
+		}
+	}
+}
+
+func TestRetryWithPolicy_TracksAttempts(t *testing.T) {
+	eh := NewErrorHandler()
+	policy := BackoffPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, DelayFirst: true}
+
+	var tracked []int
+	err := eh.RetryWithPolicy(context.Background(), "upstream:test", policy, func(attempt int) error {
+		tracked = append(tracked, eh.GetConnectionAttempts("upstream:test"))
+		if attempt < 2 {
+			return fmt.Errorf("attempt %d failed", attempt)
+		}
+		return nil
+	})
+	if err != nil || fmt.Sprint(tracked) != "[1 2]" {
+		t.Errorf("Expected success on the second attempt with tracked attempts [1 2], got %v (%v)", tracked, err)
+	}
+	if n := eh.GetConnectionAttempts("upstream:test"); n != 0 {
+		t.Errorf("Expected attempts to be cleared after retrying, got %d", n)
+	}
+
+	calls := 0
+	err = eh.RetryWithPolicy(context.Background(), "", policy, func(int) error {
+		calls++
+		return fmt.Errorf("down")
+	})
+	if err == nil || calls != 3 {
+		t.Errorf("Expected 3 failed attempts, got %d (%v)", calls, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := eh.RetryWithPolicy(ctx, "", policy, func(int) error { return nil }); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestBuildReplayItems(t *testing.T) {
+	items := buildReplayItems([]historyTurn{
+		{role: "user", text: "你好"},
+		{role: "assistant", text: "你好，有什么可以帮你？"},
+	})
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(items))
+	}
+	content := items[1]["content"].([]map[string]interface{})[0]
+	if items[1]["role"] != "assistant" || content["type"] != "text" {
+		t.Errorf("Unexpected assistant item: %v", items[1])
+	}
+
+	var history []historyTurn
+	for i := 0; i < replayRecentTurns+5; i++ {
+		history = append(history, historyTurn{role: "user", text: fmt.Sprintf("第%d轮%s", i, strings.Repeat("长", 200))})
+	}
+	items = buildReplayItems(history)
+	if len(items) != replayRecentTurns+1 {
+		t.Fatalf("Expected summary plus %d recent turns, got %d items", replayRecentTurns, len(items))
+	}
+
+	summary := items[0]["content"].([]map[string]interface{})[0]["text"].(string)
+	if items[0]["role"] != "system" || !strings.Contains(summary, "第0轮") || strings.Contains(summary, "第5轮") {
+		t.Errorf("Unexpected summary: %s", summary)
+	}
+	if strings.Contains(summary, strings.Repeat("长", summaryLineRunes)) {
+		t.Error("Expected summary lines to be truncated")
+	}
+}
+
+func TestAppendHistory_Capped(t *testing.T) {
+	session := &RealtimeSession{}
+	for i := 0; i < maxHistoryTurns+10; i++ {
+		session.appendHistory("user", fmt.Sprint(i))
+	}
+	if len(session.history) != maxHistoryTurns || session.history[0].text != "10" {
+		t.Errorf("Expected the oldest turns to be dropped, got %d turns starting at %q", len(session.history), session.history[0].text)
+	}
+}
+
+func TestHandleRealtimeResponse_NoReconnectAfterEndSession(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+
+	clientConn, client := wsPair(t)
+	gptConn, _ := wsPair(t)
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, nil)
+
+	done := make(chan struct{})
+	go func() {
+		service.HandleRealtimeResponse(session)
+		close(done)
+	}()
+
+	service.EndSession(session)
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("HandleRealtimeResponse did not stop after the session ended")
+	}
+
+	client.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	var msg map[string]interface{}
+	if err := client.ReadJSON(&msg); err == nil {
+		t.Errorf("Expected no message after the session ended, got %v", msg)
+	}
+}
+
+func TestRestoreSession_CountsReplayedItems(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+	clientConn, _ := wsPair(t)
+	gptConn, upstream := wsPair(t)
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, nil)
+	t.Cleanup(func() { service.EndSession(session) })
+
+	for i := 0; i < replayRecentTurns+5; i++ {
+		session.appendHistory("user", fmt.Sprint(i))
+	}
+	replayed, err := service.restoreSession(session, gptConn)
+	if err != nil {
+		t.Fatalf("restoreSession failed: %v", err)
+	}
+	// 较早的 5 轮合并为一条摘要
+	if replayed != replayRecentTurns+1 {
+		t.Errorf("Expected %d replayed items, got %d", replayRecentTurns+1, replayed)
+	}
+
+	upstream.SetReadDeadline(time.Now().Add(2 * time.Second))
+	created := 0
+	for created < replayed {
+		var msg map[string]interface{}
+		if err := upstream.ReadJSON(&msg); err != nil {
+			t.Fatalf("read failed after %d items: %v", created, err)
+		}
+		if msg["type"] == "conversation.item.create" {
+			created++
+		}
+	}
+}
+
+func TestHandleRealtimeResponse_IdleUpstreamKeptAlive(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+	service.keepalive = upstreamKeepalive{pingInterval: 50 * time.Millisecond, readTimeout: 300 * time.Millisecond}
+
+	clientConn, client := wsPair(t)
+	gptConn, upstream := wsPair(t)
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, nil)
+	t.Cleanup(func() { service.EndSession(session) })
+
+	// 上游一侧只负责读取，读取时自动回复 pong
+	go func() {
+		for {
+			if _, _, err := upstream.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	go service.HandleRealtimeResponse(session)
+
+	// 空闲时间超过读超时数倍，连接仍然可用，也不会触发重连
+	time.Sleep(time.Second)
+	if err := upstream.WriteJSON(map[string]interface{}{"type": "response.done"}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	messages := readClientMessages(t, client, "response_complete")
+	if _, ok := messages["reconnecting"]; ok {
+		t.Errorf("Idle upstream must not be treated as disconnected: %v", messages["reconnecting"])
+	}
+}
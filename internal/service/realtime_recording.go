@@ -0,0 +1,107 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"log"
+	"time"
+)
+
+var (
+	// ErrRecordingDisabled 服务端未开启录音
+	ErrRecordingDisabled = errors.New("recording is not enabled on this server")
+	// ErrRecordingConsentRequired 用户未同意录音
+	ErrRecordingConsentRequired = errors.New("recording requires the user's consent")
+)
+
+// SetRecordingService 开启经用户同意的会话录音，并在隐私状态中说明录音的保存时长；传 nil 关闭录音
+func (s *RealtimeService) SetRecordingService(recordings *RecordingService) {
+	s.recordings = recordings
+	var retention time.Duration
+	if recordings != nil {
+		retention = recordings.Retention()
+	}
+	s.securityMonitor.SetRecordingRetention(retention)
+}
+
+// CheckRecording 会话配置要求录音时查询用户是否同意，未同意时一律拒绝
+func (s *RealtimeService) CheckRecording(session *RealtimeSession, cfg *RealtimeSessionConfig) error {
+	if !cfg.Recording {
+		return nil
+	}
+	consented := s.recordings != nil && s.recordings.HasConsent(session.UserID)
+	return s.securityMonitor.AuthorizeRecording(session.ID.String(), consented)
+}
+
+// syncRecording 按会话配置开始或停止录音，并通知客户端
+func (s *RealtimeService) syncRecording(session *RealtimeSession, cfg *RealtimeSessionConfig) {
+	active := session.recording.Load() != nil
+	switch {
+	case cfg.Recording && !active:
+		if err := s.CheckRecording(session, cfg); err != nil {
+			return
+		}
+		rec, err := s.recordings.start(session.ID, session.UserID)
+		if err != nil {
+			log.Printf("Failed to start recording for session %s: %v", session.ID, err)
+			s.sendErrorToClient(session.ClientConn, "recording_failed", "录音启动失败", err.Error())
+			return
+		}
+		session.recording.Store(rec)
+		log.Printf("Recording started for session %s", session.ID)
+		s.sendToClientWithTimeout(session.ClientConn, map[string]interface{}{
+			"type":           "recording_started",
+			"session_id":     session.ID,
+			"format":         s.recordings.cfg.Format,
+			"retention_days": int(s.recordings.Retention() / (24 * time.Hour)),
+			"timestamp":      time.Now().UnixMilli(),
+		})
+
+	case !cfg.Recording && active:
+		s.StopRecording(session)
+		s.sendToClientWithTimeout(session.ClientConn, map[string]interface{}{
+			"type":       "recording_stopped",
+			"session_id": session.ID,
+			"timestamp":  time.Now().UnixMilli(),
+		})
+	}
+}
+
+// StopRecording 停止会话录音，在后台编码、加密并上传
+func (s *RealtimeService) StopRecording(session *RealtimeSession) {
+	rec := session.recording.Swap(nil)
+	if rec == nil {
+		return
+	}
+	log.Printf("Recording stopped for session %s", session.ID)
+	s.recordings.stop(rec)
+}
+
+// recordAudio 把上游格式的一段音频写入录音
+func (s *RealtimeService) recordAudio(session *RealtimeSession, direction int, audio []byte) {
+	rec := session.recording.Load()
+	if rec == nil || len(audio) == 0 {
+		return
+	}
+	pcm, rate := session.audio.upstreamPCM(audio)
+	rec.write(direction, pcm, rate)
+}
+
+// recordOutputAudio 把上游 base64 编码的助手语音写入录音
+func (s *RealtimeService) recordOutputAudio(session *RealtimeSession, audioData string) {
+	if session.recording.Load() == nil {
+		return
+	}
+	audio, err := base64.StdEncoding.DecodeString(audioData)
+	if err != nil {
+		return
+	}
+	s.recordAudio(session, recordingOutbound, audio)
+}
+
+// recordTranscriptTurn 把一条转写加入录音的转写文件
+func (s *RealtimeService) recordTranscriptTurn(session *RealtimeSession, role, itemID, text string) {
+	if rec := session.recording.Load(); rec != nil {
+		rec.addTurn(role, itemID, text)
+	}
+}
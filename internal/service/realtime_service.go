@@ -7,7 +7,9 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
+	"smart-glasses-backend/internal/repository"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -25,17 +27,17 @@ type RealtimeService struct {
 	errorHandler       *ErrorHandler              // 错误处理组件
 	securityMonitor    *SecurityMonitor           // 安全监控组件
 	performanceMonitor *PerformanceMonitor        // 性能监控组件
-}
-
-// SessionConfig 会话配置结构
-type SessionConfig struct {
-	Model             string                 `json:"model"`
-	Modalities        []string               `json:"modalities"`
-	Instructions      string                 `json:"instructions"`
-	Voice             string                 `json:"voice"`
-	InputAudioFormat  string                 `json:"input_audio_format"`
-	OutputAudioFormat string                 `json:"output_audio_format"`
-	TurnDetection     map[string]interface{} `json:"turn_detection"`
+	languages          *LanguageRegistry          // 回复语言校验
+	translateService   *TranslateService          // 传译结果写入翻译历史
+	realtimeRepo       *repository.RealtimeRepository // 会话转写存储
+	tools              *ToolRegistry                  // 助手模式可调用的工具
+	writeLocks         sync.Map                       // 每个连接的写锁
+	connSessions       sync.Map                       // 连接所属的会话 ID，用于按会话记录指标
+	quota              RealtimeQuota                  // 每用户实时对话配额
+	usage              sync.Map                       // 用户当日和当月的用量
+	transcodeG711      bool                           // G.711 客户端的音频在服务端转换为 pcm16 再发给上游
+	recordings         *RecordingService              // 经用户同意的会话录音，未设置时不录音
+	keepalive          upstreamKeepalive              // 上游空闲时的 ping 间隔和读超时
 }
 
 // RealtimeMessage GPT Realtime API 消息结构
@@ -75,6 +77,9 @@ func NewRealtimeService(apiKey, endpoint, deploymentName, apiVersion string) *Re
 		errorHandler:       NewErrorHandler(),       // 初始化错误处理组件
 		securityMonitor:    NewSecurityMonitor(),    // 初始化安全监控组件
 		performanceMonitor: NewPerformanceMonitor(), // 初始化性能监控组件
+		languages:          NewLanguageRegistry(),
+		quota:              RealtimeQuota{WarnRatio: defaultQuotaWarnRatio},
+		keepalive:          defaultUpstreamKeepalive,
 	}
 }
 
@@ -93,7 +98,13 @@ func (s *RealtimeService) ConnectToGPTRealtime(ctx context.Context) (*websocket.
 	}
 
 	// 设置WebSocket协议和路径
-	u.Scheme = "wss"
+	// ws:// 或 http:// 端点用于本地模拟上游（internal/realtimefake），其余一律使用 wss
+	switch u.Scheme {
+	case "ws", "http":
+		u.Scheme = "ws"
+	default:
+		u.Scheme = "wss"
+	}
 	u.Path = "/openai/realtime"
 
 	// 设置查询参数
@@ -110,7 +121,8 @@ func (s *RealtimeService) ConnectToGPTRealtime(ctx context.Context) (*websocket.
 	log.Printf("Connecting to Azure OpenAI Realtime API: %s", u.String())
 
 	// 连接 WebSocket
-	dialer := websocket.DefaultDialer
+	// 复制默认 Dialer，重连时多个会话会并发拨号，不能修改共享实例
+	dialer := *websocket.DefaultDialer
 	dialer.HandshakeTimeout = 30 * time.Second
 
 	conn, resp, err := dialer.DialContext(ctx, u.String(), headers)
@@ -132,7 +144,7 @@ func (s *RealtimeService) ConnectToGPTRealtime(ctx context.Context) (*websocket.
 	}
 
 	// 设置连接参数
-	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	conn.SetReadDeadline(time.Now().Add(s.keepalive.readTimeout))
 	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 
 	// 重置连接尝试次数（连接成功）
@@ -142,35 +154,19 @@ func (s *RealtimeService) ConnectToGPTRealtime(ctx context.Context) (*websocket.
 }
 
 // ConfigureSession 配置会话
-// Requirements: 4.2-4.8 - 发送会话配置消息，配置模型、音频格式、语音、指令和VAD
-func (s *RealtimeService) ConfigureSession(conn *websocket.Conn) error {
-	// 根据Azure官方文档的会话配置格式
-	config := map[string]interface{}{
-		"type": "session.update",
-		"session": map[string]interface{}{
-			"model":             "gpt-4o-realtime-preview", // Requirement 4.3
-			"modalities":        []string{"text", "audio"},
-			"instructions":      "你是一个友好的AI助手，可以进行语音对话。请用中文回复。", // Requirement 4.7
-			"voice":             "alloy",                        // Requirement 4.6
-			"input_audio_format":  "pcm16",                      // Requirement 4.4
-			"output_audio_format": "pcm16",                      // Requirement 4.5
-			"input_audio_transcription": map[string]interface{}{
-				"model": "whisper-1",
-			},
-			"turn_detection": map[string]interface{}{ // Requirement 4.8 - 启用服务器端VAD
-				"type":                "server_vad",
-				"threshold":           0.5,
-				"prefix_padding_ms":   300,
-				"silence_duration_ms": 200,
-			},
-		},
+// Requirements: 4.2-4.8 - 发送会话配置消息，配置音频格式、语音、指令和VAD
+// cfg 为 nil 时使用默认配置
+func (s *RealtimeService) ConfigureSession(conn *websocket.Conn, cfg *RealtimeSessionConfig) error {
+	if cfg == nil {
+		cfg = DefaultRealtimeSessionConfig()
 	}
 
-	if err := conn.WriteJSON(config); err != nil {
+	if err := s.WriteJSON(conn, s.buildSessionUpdate(cfg), 10*time.Second); err != nil {
 		return fmt.Errorf("failed to send session config: %v", err)
 	}
 
-	log.Println("Session configuration sent successfully")
+	log.Printf("Session configuration sent successfully: voice=%s, preset=%s, reply_language=%s, vad=%v",
+		cfg.Voice, cfg.InstructionsPreset, cfg.ReplyLanguage, cfg.VAD.Enabled)
 	return nil
 }
 
@@ -195,16 +191,8 @@ func (s *RealtimeService) SendAudioData(conn *websocket.Conn, audioData []byte)
 		return fmt.Errorf("audio data privacy validation failed: %v", err)
 	}
 
-	// 使用音频处理器验证和处理音频数据
-	err := s.audioProcessor.ValidateAudioFormat(audioData)
-	if err != nil {
-		// Requirements: 3.4 - 记录错误并继续处理
-		log.Printf("Audio format validation failed: %v", err)
-		if recoveredErr := s.audioProcessor.RecoverFromError(err, "SendAudioData"); recoveredErr != nil {
-			return recoveredErr
-		}
-		// 如果错误已恢复，继续处理
-	}
+	// 客户端音频已由 ProcessRealtimeAudioChunk 按会话格式校验，这里是转换后的上游格式
+	// （PCM16 或直通的 G.711，20ms 的 G.711 只有 160 字节且可以是奇数长度），不再按 16kHz PCM16 校验
 
 	// 编码为Base64用于传输 (Requirement 7.4)
 	encodedAudio := s.audioProcessor.EncodeAudioToBase64(audioData)
@@ -219,17 +207,13 @@ func (s *RealtimeService) SendAudioData(conn *websocket.Conn, audioData []byte)
 	// 传输开始时间
 	transmissionStart := time.Now()
 
-	// 设置写入超时
-	conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
-
-	if err := conn.WriteJSON(msg); err != nil {
+	if err := s.WriteJSON(conn, msg, 5*time.Second); err != nil {
 		log.Printf("Error sending audio data: %v", err) // Requirement 3.4 - 记录错误
 		return fmt.Errorf("failed to send audio data: %v", err)
 	}
 
 	// 记录性能指标 - 音频延迟监控 (Requirements: 9.1)
-	sessionID := fmt.Sprintf("conn_%p", conn)
-	s.performanceMonitor.MeasureAudioLatency(sessionID, processingStart, transmissionStart)
+	s.performanceMonitor.MeasureAudioLatency(s.metricsKey(conn), processingStart, transmissionStart)
 
 	return nil
 }
@@ -244,9 +228,7 @@ func (s *RealtimeService) CommitAudioBuffer(conn *websocket.Conn) error {
 		"type": "input_audio_buffer.commit",
 	}
 
-	conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
-
-	if err := conn.WriteJSON(msg); err != nil {
+	if err := s.WriteJSON(conn, msg, 5*time.Second); err != nil {
 		return fmt.Errorf("failed to commit audio buffer: %v", err)
 	}
 
@@ -255,7 +237,8 @@ func (s *RealtimeService) CommitAudioBuffer(conn *websocket.Conn) error {
 
 // HandleRealtimeResponse 处理来自 GPT Realtime API 的响应
 // Requirements: 5.1-5.5 - 转发音频/文本响应，发送完成信号，处理错误，保持低延迟
-func (s *RealtimeService) HandleRealtimeResponse(gptConn *websocket.Conn, clientConn *websocket.Conn) {
+func (s *RealtimeService) HandleRealtimeResponse(session *RealtimeSession) {
+	clientConn := session.ClientConn
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("Panic in HandleRealtimeResponse: %v", r)
@@ -266,13 +249,34 @@ func (s *RealtimeService) HandleRealtimeResponse(gptConn *websocket.Conn, client
 		}
 	}()
 
+	// 上游空闲时靠 ping/pong 保活，读超时只在上游真正失联时触发
+	stopKeepalive := make(chan struct{})
+	defer close(stopKeepalive)
+	go s.keepUpstreamAlive(session, stopKeepalive)
+
+	var watched *websocket.Conn
 	for {
+		// 上游重连后连接会被替换，每次读取前重新获取
+		gptConn := session.Upstream()
+		if gptConn != watched {
+			s.watchUpstreamPongs(gptConn)
+			watched = gptConn
+		}
+
 		// 设置读取超时
-		gptConn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		gptConn.SetReadDeadline(time.Now().Add(s.keepalive.readTimeout))
 
 		var response map[string]interface{}
 		err := gptConn.ReadJSON(&response)
 		if err != nil {
+			if session.closed() {
+				return // 客户端已断开，上游连接由 EndSession 关闭
+			}
+			// Requirement 8.1 - 上游断开时保持客户端连接，重连并回放会话
+			if s.reconnectUpstream(session, err) {
+				continue
+			}
+
 			// 使用错误处理器处理连接错误
 			connErr := &ConnectionError{
 				Endpoint: "GPT Realtime API",
@@ -299,14 +303,17 @@ func (s *RealtimeService) HandleRealtimeResponse(gptConn *websocket.Conn, client
 		switch responseType {
 		case "response.audio.delta":
 			// Requirement 5.1 - 转发音频响应到客户端
+			if session.isCancelled(eventResponseID(response)) {
+				continue // 已被打断的响应，丢弃尚未转发的语音
+			}
 			if audioData, ok := response["delta"].(string); ok {
 				log.Printf("Sending audio response to client: %d bytes", len(audioData))
-				clientMsg := map[string]interface{}{
-					"type":      "audio_response",
-					"audio":     audioData,
-					"timestamp": time.Now().UnixMilli(),
-				}
-				s.sendToClientWithTimeout(clientConn, clientMsg)
+				s.sendClientAudio(session, audioData)
+				s.recordOutputAudio(session, audioData)
+				itemID, _ := response["item_id"].(string)
+				audioMs := session.audio.upstreamAudioMs(len(audioData) * 3 / 4)
+				session.recordAudioSent(itemID, audioMs)
+				session.audioOutMicros.Add(int64(audioMs * 1000))
 				log.Printf("Audio response sent to client successfully")
 			} else {
 				log.Printf("No audio data in response.audio.delta: %v", response)
@@ -314,7 +321,11 @@ func (s *RealtimeService) HandleRealtimeResponse(gptConn *websocket.Conn, client
 
 		case "response.text.delta":
 			// Requirement 5.2 - 转发文本响应到客户端
+			if session.isCancelled(eventResponseID(response)) {
+				continue
+			}
 			if textData, ok := response["delta"].(string); ok {
+				session.responseText.WriteString(textData)
 				clientMsg := map[string]interface{}{
 					"type":      "text_response",
 					"text":      textData,
@@ -323,14 +334,70 @@ func (s *RealtimeService) HandleRealtimeResponse(gptConn *websocket.Conn, client
 				s.sendToClientWithTimeout(clientConn, clientMsg)
 			}
 
+		case "response.audio_transcript.delta":
+			// 语音输出对应的文字
+			if session.isCancelled(eventResponseID(response)) {
+				continue
+			}
+			if textData, ok := response["delta"].(string); ok {
+				session.responseText.WriteString(textData)
+				s.sendToClientWithTimeout(clientConn, map[string]interface{}{
+					"type":      "assistant_transcript_delta",
+					"text":      textData,
+					"timestamp": time.Now().UnixMilli(),
+				})
+			}
+
+		case "conversation.item.input_audio_transcription.completed":
+			// 用户语音的转写
+			if transcript, ok := response["transcript"].(string); ok && strings.TrimSpace(transcript) != "" {
+				itemID, _ := response["item_id"].(string)
+				s.handleUserTranscript(session, itemID, strings.TrimSpace(transcript))
+			}
+
+		case "conversation.item.input_audio_transcription.failed":
+			log.Printf("Input audio transcription failed for session %s: %v", session.ID, response["error"])
+			itemID, _ := response["item_id"].(string)
+			session.turns.forget(itemID)
+
+		case "response.audio_transcript.done":
+			if transcript, ok := response["transcript"].(string); ok && strings.TrimSpace(transcript) != "" {
+				itemID, _ := response["item_id"].(string)
+				s.handleAssistantTranscript(session, itemID, strings.TrimSpace(transcript))
+			}
+
+		case "input_audio_buffer.speech_started":
+			// 用户开口时打断正在播放的助手语音；传译模式下说话人会接着说下一句，
+			// 上一句的译文要继续播完，只响应客户端显式的 interrupt
+			if session.Config().Mode == RealtimeModeInterpreter {
+				continue
+			}
+			if err := s.InterruptResponse(session, InterruptReasonSpeech, -1); err != nil {
+				log.Printf("Failed to interrupt response for session %s: %v", session.ID, err)
+			}
+
 		case "response.done":
 			// Requirement 5.3 - 发送完成信号
+			responseID := eventResponseID(response)
+			session.finishResponse(responseID)
+			s.finishToolCalls(session, responseID, responseStatus(response) == "cancelled")
 			clientMsg := map[string]interface{}{
 				"type":      "response_complete",
 				"timestamp": time.Now().UnixMilli(),
 			}
 			s.sendToClientWithTimeout(clientConn, clientMsg)
 
+			if session.Config().Mode == RealtimeModeInterpreter {
+				text := strings.TrimSpace(session.responseText.String())
+				s.handleInterpreterTarget(session, responseID, text, responseStatus(response) == "completed")
+			}
+			session.responseText.Reset()
+			s.recordResponseUsage(session, response)
+
+		case "response.function_call_arguments.done":
+			// 模型请求调用工具，以当前用户身份执行
+			s.startToolCall(session, response)
+
 		case "error":
 			// Requirement 5.4 - 发送错误信息到客户端，使用错误处理器
 			log.Printf("GPT API error: %v", response)
@@ -356,18 +423,37 @@ func (s *RealtimeService) HandleRealtimeResponse(gptConn *websocket.Conn, client
 
 		case "session.updated":
 			log.Println("Session updated successfully")
+			// 配置变更后丢弃未配对的传译片段，避免跨模式或跨语言配对
+			session.turns.reset()
 
 		case "input_audio_buffer.committed":
 			log.Println("Audio buffer committed")
+			if session.Config().Mode == RealtimeModeInterpreter {
+				itemID, _ := response["item_id"].(string)
+				session.turns.addInput(itemID)
+			}
 
 		case "input_audio_buffer.cleared":
 			log.Println("Audio buffer cleared")
 
 		case "conversation.item.created":
 			log.Println("Conversation item created")
+			// 文字输入没有转写事件，以创建的会话项作为用户这一轮的内容
+			if itemID, text, ok := userTextItem(response); ok {
+				if session.Config().Mode == RealtimeModeInterpreter {
+					session.turns.addInput(itemID)
+				}
+				s.handleUserTranscript(session, itemID, text)
+			}
 
 		case "response.created":
 			log.Println("Response created")
+			session.responseText.Reset()
+			session.startResponse(eventResponseID(response))
+			if session.Config().Mode == RealtimeModeInterpreter {
+				session.turns.bindResponse(eventResponseID(response))
+			}
+			session.audio.ResetOutput()
 
 		case "response.output_item.added":
 			log.Println("Response output item added")
@@ -380,6 +466,10 @@ func (s *RealtimeService) HandleRealtimeResponse(gptConn *websocket.Conn, client
 
 		case "response.text.done":
 			log.Println("Text response completed")
+			if text, ok := response["text"].(string); ok && strings.TrimSpace(text) != "" {
+				itemID, _ := response["item_id"].(string)
+				s.handleAssistantTranscript(session, itemID, strings.TrimSpace(text))
+			}
 
 		default:
 			log.Printf("Unhandled response type: %s", responseType)
@@ -387,6 +477,28 @@ func (s *RealtimeService) HandleRealtimeResponse(gptConn *websocket.Conn, client
 	}
 }
 
+// eventResponseID 取事件所属的响应 ID，response.* 生命周期事件放在 response.id 中
+func eventResponseID(event map[string]interface{}) string {
+	if id, ok := event["response_id"].(string); ok {
+		return id
+	}
+	if resp, ok := event["response"].(map[string]interface{}); ok {
+		if id, ok := resp["id"].(string); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+// responseStatus 取 response.done 事件中的响应状态
+func responseStatus(event map[string]interface{}) string {
+	if resp, ok := event["response"].(map[string]interface{}); ok {
+		status, _ := resp["status"].(string)
+		return status
+	}
+	return ""
+}
+
 // sendToClientWithTimeout 带超时的客户端消息发送
 func (s *RealtimeService) sendToClientWithTimeout(clientConn *websocket.Conn, message interface{}) {
 	if clientConn == nil {
@@ -396,7 +508,7 @@ func (s *RealtimeService) sendToClientWithTimeout(clientConn *websocket.Conn, me
 
 	// 记录WebSocket消息性能 (Requirements: 9.2)
 	messageStart := time.Now()
-	sessionID := fmt.Sprintf("conn_%p", clientConn)
+	sessionID := s.metricsKey(clientConn)
 
 	// 记录发送的消息类型
 	if msgMap, ok := message.(map[string]interface{}); ok {
@@ -405,8 +517,7 @@ func (s *RealtimeService) sendToClientWithTimeout(clientConn *websocket.Conn, me
 		}
 	}
 
-	clientConn.SetWriteDeadline(time.Now().Add(2 * time.Second))
-	err := clientConn.WriteJSON(message)
+	err := s.WriteJSON(clientConn, message, 2*time.Second)
 	
 	// 计算消息延迟并记录
 	messageLatency := time.Since(messageStart)
@@ -440,15 +551,10 @@ func (s *RealtimeService) sendErrorToClient(clientConn *websocket.Conn, code, me
 // ValidateAudioData 验证音频数据格式
 // Requirements: 3.1 - 验证数据格式为Base64编码
 func (s *RealtimeService) ValidateAudioData(audioData string) ([]byte, error) {
-	// 使用音频处理器进行验证和解码
+	// 使用音频处理器进行验证和解码，空数据和 base64 错误直接返回，由调用方丢弃该消息
 	decodedData, err := s.audioProcessor.DecodeBase64Audio(audioData)
 	if err != nil {
-		// 尝试错误恢复
-		if recoveredErr := s.audioProcessor.RecoverFromError(err, "ValidateAudioData"); recoveredErr != nil {
-			return nil, recoveredErr
-		}
-		// 如果错误已恢复，返回空数据但不报错
-		return []byte{}, nil
+		return nil, err
 	}
 
 	return decodedData, nil
@@ -5,6 +5,8 @@ import (
 	"encoding/base64"
 	"testing"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 func TestNewRealtimeService(t *testing.T) {
@@ -120,3 +122,24 @@ func TestRealtimeServiceIntegration(t *testing.T) {
 		t.Error("Context should not be cancelled immediately")
 	}
 }
+
+func TestRecordClientMessage_AudioMetrics(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+	sessionID, err := service.StartMonitoredSession(uuid.New(), "user@example.com", "127.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("StartMonitoredSession failed: %v", err)
+	}
+
+	// JSON 音频消息和二进制音频帧都计入上行音频，控制消息不计入
+	service.RecordClientMessage(sessionID, "audio_data", 4000)
+	service.RecordClientMessage(sessionID, "audio_frame", 660)
+	service.RecordClientMessage(sessionID, "commit_audio", 30)
+
+	metric := service.GetSecurityMonitor().GetConnectionQuality(sessionID.String())
+	if metric == nil {
+		t.Fatal("Expected connection metrics for the session")
+	}
+	if metric.BytesReceived != 4660 || metric.AudioChunksCount != 2 {
+		t.Errorf("Expected 4660 bytes in 2 chunks, got %d bytes in %d chunks", metric.BytesReceived, metric.AudioChunksCount)
+	}
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// RealtimeSession 一个客户端实时连接的会话状态
+type RealtimeSession struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	ClientConn *websocket.Conn
+
+	mu       sync.RWMutex
+	config   *RealtimeSessionConfig
+	upstream *websocket.Conn // 上游重连后会被替换，通过 Upstream() 访问
+
+	ctx          context.Context // 会话结束时取消，停止上游重连
+	cancel       context.CancelFunc
+	reconnecting atomic.Bool
+
+	persisted bool         // 会话记录已写入数据库，转写可以关联保存
+	turnSeq   atomic.Int32 // 转写序号，异步保存时用于保持顺序
+
+	// 自上次记录用量以来的上行和下行语音时长（微秒）
+	audioInMicros  atomic.Int64
+	audioOutMicros atomic.Int64
+	quotaWarned    atomic.Bool // 已提醒过用量接近配额
+
+	audio       *AudioProcessor // 会话的音频格式转换，随会话配置更新
+	binaryAudio atomic.Bool     // 客户端协商了二进制音频帧
+
+	recording atomic.Pointer[sessionRecording] // 进行中的录音，客户端消息循环和响应处理协程都会写入
+
+	// 带序号的上行音频按序号重排；客户端消息循环和缺包等待到期的定时器都会访问，
+	// jitterMu 同时保证两边送出的音频按序发往上游
+	jitterMu    sync.Mutex
+	jitter      *JitterBuffer
+	jitterTimer *time.Timer
+
+	// 助手语音播放进度，响应处理协程和客户端消息循环都会访问
+	playbackMu sync.Mutex
+	playback   responsePlayback
+
+	// 以下字段只在 HandleRealtimeResponse 协程中访问
+	responseText strings.Builder // 当前响应的文本或语音转写
+	turns        interpreterTurns
+	toolCalls    map[string][]*pendingToolCall // 按响应 ID 分组的工具调用
+	history      []historyTurn                 // 上游重连后回放的对话
+	outputSeq    uint32                        // 下一个助手语音二进制帧的序号
+}
+
+// NewSession 创建会话，id 由 StartMonitoredSession 分配，cfg 为 nil 时使用默认配置
+func (s *RealtimeService) NewSession(id, userID uuid.UUID, clientConn, gptConn *websocket.Conn, cfg *RealtimeSessionConfig) *RealtimeSession {
+	if cfg == nil {
+		cfg = DefaultRealtimeSessionConfig()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	session := &RealtimeSession{
+		ID:         id,
+		UserID:     userID,
+		ClientConn: clientConn,
+		upstream:   gptConn,
+		config:     cfg,
+		audio:      s.newSessionAudioProcessor(cfg),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	session.jitter = NewJitterBuffer(defaultJitterDepth, session.audio.silence)
+	s.bindConn(clientConn, id)
+	s.bindConn(gptConn, id)
+	s.startTranscript(session)
+	return session
+}
+
+// Config 返回当前生效的会话配置
+func (rs *RealtimeSession) Config() *RealtimeSessionConfig {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.config
+}
+
+// SetConfig 替换会话配置，配置对象本身不会被修改
+func (rs *RealtimeSession) SetConfig(cfg *RealtimeSessionConfig) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.config = cfg
+}
+
+// Upstream 返回当前的上游连接
+func (rs *RealtimeSession) Upstream() *websocket.Conn {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.upstream
+}
+
+func (rs *RealtimeSession) setUpstream(conn *websocket.Conn) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.upstream = conn
+}
+
+// Reconnecting 上游正在重连，期间客户端上行的音频会被丢弃
+func (rs *RealtimeSession) Reconnecting() bool {
+	return rs.reconnecting.Load()
+}
+
+// closed 会话已结束，上游断开时不再重连
+func (rs *RealtimeSession) closed() bool {
+	return rs.ctx.Err() != nil
+}
+
+// WriteJSON 串行化同一连接上的写入
+// gorilla/websocket 不允许并发写，响应处理协程和客户端消息循环会同时写客户端和上游连接
+func (s *RealtimeService) WriteJSON(conn *websocket.Conn, v interface{}, timeout time.Duration) error {
+	if conn == nil {
+		return fmt.Errorf("connection is nil")
+	}
+
+	mu := s.connWriteLock(conn)
+	mu.Lock()
+	defer mu.Unlock()
+
+	conn.SetWriteDeadline(time.Now().Add(timeout))
+	return conn.WriteJSON(v)
+}
+
+// WriteBinary 与 WriteJSON 共用写锁发送二进制帧
+func (s *RealtimeService) WriteBinary(conn *websocket.Conn, data []byte, timeout time.Duration) error {
+	if conn == nil {
+		return fmt.Errorf("connection is nil")
+	}
+
+	mu := s.connWriteLock(conn)
+	mu.Lock()
+	defer mu.Unlock()
+
+	conn.SetWriteDeadline(time.Now().Add(timeout))
+	return conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+func (s *RealtimeService) connWriteLock(conn *websocket.Conn) *sync.Mutex {
+	mu, _ := s.writeLocks.LoadOrStore(conn, &sync.Mutex{})
+	return mu.(*sync.Mutex)
+}
+
+// releaseConn 连接关闭后释放写锁和会话关联
+func (s *RealtimeService) releaseConn(conn *websocket.Conn) {
+	if conn != nil {
+		s.writeLocks.Delete(conn)
+		s.connSessions.Delete(conn)
+	}
+}
@@ -0,0 +1,470 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidSessionConfig 客户端会话配置未通过校验
+var ErrInvalidSessionConfig = errors.New("invalid session config")
+
+// 服务端允许的会话参数
+var (
+	allowedRealtimeVoices = []string{"alloy", "ash", "ballad", "coral", "echo", "sage", "shimmer", "verse"}
+	allowedSampleRates    = []int{8000, 16000, 24000, 44100, 48000}
+	// 实时上行按块转换，只接受可逐块解码的裸流格式；WAV、WebM 等容器格式只用于整段音频转换
+	allowedAudioFormats = []string{string(FormatPCM16), string(FormatG711ULaw), string(FormatG711ALaw)}
+)
+
+// 指令预设，回复语言由 ReplyLanguage 追加，客户端不能直接传入指令文本
+var instructionPresets = map[string]string{
+	"assistant":      "你是一个友好的AI助手，可以进行语音对话。",
+	"concise":        "你是一个简洁的语音助手，回答控制在一到两句话内，不要使用列表或格式化文本。",
+	"language_tutor": "你是一位耐心的语言老师，用简单的句子对话，并在用户出现语法或发音错误时温和地指出并给出正确说法。",
+}
+
+// VAD 参数范围
+const (
+	minVADThreshold     = 0.1
+	maxVADThreshold     = 0.9
+	minVADSilenceMs     = 100
+	maxVADSilenceMs     = 2000
+	maxVADPrefixPadding = 1000
+)
+
+const transcriptionModel = "whisper-1"
+
+// 实时会话模式
+const (
+	RealtimeModeAssistant   = "assistant"   // 通用语音助手
+	RealtimeModeInterpreter = "interpreter" // 同声传译
+)
+
+// VADConfig 服务器端语音活动检测参数
+type VADConfig struct {
+	Enabled           bool    `json:"enabled"`
+	Threshold         float64 `json:"threshold"`
+	SilenceDurationMs int     `json:"silence_duration_ms"`
+	PrefixPaddingMs   int     `json:"prefix_padding_ms"`
+}
+
+// RealtimeSessionConfig 生效的会话配置
+type RealtimeSessionConfig struct {
+	Mode               string    `json:"mode"`
+	SourceLanguage     string    `json:"source_language,omitempty"` // 传译模式的说话语言
+	TargetLanguage     string    `json:"target_language,omitempty"` // 传译模式的输出语言
+	Bidirectional      bool      `json:"bidirectional"`             // 传译模式下按说话语言自动切换方向
+	Voice              string    `json:"voice"`
+	InstructionsPreset string    `json:"instructions_preset"`
+	ReplyLanguage      string    `json:"reply_language"`
+	Modalities         []string  `json:"modalities"`
+	VAD                VADConfig `json:"vad"`
+	Transcription      bool      `json:"transcription"`
+	AudioFormat        string    `json:"audio_format"` // 客户端音频格式，G.711 时上下行都使用该格式
+	SampleRate         int       `json:"sample_rate"`
+	Channels           int       `json:"channels"`
+	OutputSampleRate   int       `json:"output_sample_rate,omitempty"` // 助手语音的播放采样率，0 表示与上行相同
+
+	SilenceSuppression SilenceSuppressionConfig `json:"silence_suppression"`     // 服务端本地 VAD，静音不发送给上游
+	AudioLevelInterval int                      `json:"audio_level_interval_ms"` // audio_level 消息间隔（按上行音频时长），0 表示不发送
+	Preprocessing      AudioPreprocessingConfig `json:"preprocessing"`           // 上行音频的高通、降噪、噪声门和自动增益
+	Recording          bool                     `json:"recording"`               // 录制会话音频，需要用户同意且服务端开启录音
+}
+
+// PlaybackSampleRate 助手语音转换到的采样率
+func (c *RealtimeSessionConfig) PlaybackSampleRate() int {
+	if c.OutputSampleRate != 0 {
+		return c.OutputSampleRate
+	}
+	return c.SampleRate
+}
+
+// VADConfigRequest 客户端提交的 VAD 参数，未提供的字段保持当前值
+type VADConfigRequest struct {
+	Enabled           *bool    `json:"enabled,omitempty"`
+	Threshold         *float64 `json:"threshold,omitempty"`
+	SilenceDurationMs *int     `json:"silence_duration_ms,omitempty"`
+	PrefixPaddingMs   *int     `json:"prefix_padding_ms,omitempty"`
+}
+
+// SilenceSuppressionRequest 客户端提交的静音抑制参数，未提供的字段保持当前值
+type SilenceSuppressionRequest struct {
+	Enabled     *bool    `json:"enabled,omitempty"`
+	Mode        string   `json:"mode,omitempty"`
+	HangoverMs  *int     `json:"hangover_ms,omitempty"`
+	ThresholdDB *float64 `json:"threshold_db,omitempty"`
+	AutoCommit  *bool    `json:"auto_commit,omitempty"`
+}
+
+// AudioPreprocessingRequest 客户端提交的预处理参数，未提供的字段保持当前值
+type AudioPreprocessingRequest struct {
+	HighPass         *bool    `json:"high_pass,omitempty"`
+	HighPassCutoffHz *float64 `json:"high_pass_cutoff_hz,omitempty"`
+	NoiseSuppression *bool    `json:"noise_suppression,omitempty"`
+	NoiseGate        *bool    `json:"noise_gate,omitempty"`
+	GateThresholdDB  *float64 `json:"gate_threshold_db,omitempty"`
+	AGC              *bool    `json:"agc,omitempty"`
+	AGCTargetDB      *float64 `json:"agc_target_db,omitempty"`
+}
+
+// RealtimeSessionConfigRequest 客户端 configure_session 消息中的 config 字段
+type RealtimeSessionConfigRequest struct {
+	Mode               string            `json:"mode,omitempty"`
+	SourceLanguage     string            `json:"source_language,omitempty"`
+	TargetLanguage     string            `json:"target_language,omitempty"`
+	Bidirectional      *bool             `json:"bidirectional,omitempty"`
+	Voice              string            `json:"voice,omitempty"`
+	InstructionsPreset string            `json:"instructions_preset,omitempty"`
+	ReplyLanguage      string            `json:"reply_language,omitempty"`
+	Modalities         []string          `json:"modalities,omitempty"`
+	VAD                *VADConfigRequest `json:"vad,omitempty"`
+	Transcription      *bool             `json:"transcription,omitempty"`
+	AudioFormat        string            `json:"audio_format,omitempty"`
+	SampleRate         int               `json:"sample_rate,omitempty"`
+	Channels           int               `json:"channels,omitempty"`
+	OutputSampleRate   int               `json:"output_sample_rate,omitempty"`
+
+	SilenceSuppression *SilenceSuppressionRequest `json:"silence_suppression,omitempty"`
+	AudioLevelInterval *int                       `json:"audio_level_interval_ms,omitempty"`
+	Preprocessing      *AudioPreprocessingRequest `json:"preprocessing,omitempty"`
+	Recording          *bool                      `json:"recording,omitempty"`
+}
+
+// DefaultRealtimeSessionConfig 未配置时使用的会话参数
+func DefaultRealtimeSessionConfig() *RealtimeSessionConfig {
+	return &RealtimeSessionConfig{
+		Mode:               RealtimeModeAssistant,
+		Voice:              "alloy",
+		InstructionsPreset: "assistant",
+		ReplyLanguage:      "zh-Hans",
+		Modalities:         []string{"text", "audio"},
+		VAD: VADConfig{
+			Enabled:           true,
+			Threshold:         0.5,
+			SilenceDurationMs: 200,
+			PrefixPaddingMs:   300,
+		},
+		Transcription: true,
+		AudioFormat:   "pcm16",
+		SampleRate:    24000,
+		Channels:      1,
+
+		SilenceSuppression: DefaultSilenceSuppressionConfig(),
+		AudioLevelInterval: defaultAudioLevelIntervalMs,
+		Preprocessing:      DefaultAudioPreprocessingConfig(),
+	}
+}
+
+// ResolveSessionConfig 将客户端请求合并到当前配置并按允许列表校验
+func (s *RealtimeService) ResolveSessionConfig(current *RealtimeSessionConfig, req *RealtimeSessionConfigRequest) (*RealtimeSessionConfig, error) {
+	if current == nil {
+		current = DefaultRealtimeSessionConfig()
+	}
+	cfg := *current
+	cfg.Modalities = append([]string(nil), current.Modalities...)
+	if req == nil {
+		return &cfg, nil
+	}
+
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidSessionConfig, fmt.Sprintf(format, args...))
+	}
+
+	if req.Voice != "" {
+		voice := strings.ToLower(req.Voice)
+		if !containsString(allowedRealtimeVoices, voice) {
+			return nil, invalid("unsupported voice %q", req.Voice)
+		}
+		cfg.Voice = voice
+	}
+
+	if req.InstructionsPreset != "" {
+		if _, ok := instructionPresets[req.InstructionsPreset]; !ok {
+			return nil, invalid("unknown instructions preset %q", req.InstructionsPreset)
+		}
+		cfg.InstructionsPreset = req.InstructionsPreset
+	}
+
+	if req.Mode != "" {
+		if req.Mode != RealtimeModeAssistant && req.Mode != RealtimeModeInterpreter {
+			return nil, invalid("unsupported mode %q", req.Mode)
+		}
+		cfg.Mode = req.Mode
+	}
+
+	var err error
+	if req.ReplyLanguage != "" {
+		if cfg.ReplyLanguage, err = s.normalizeRealtimeLanguage(req.ReplyLanguage); err != nil {
+			return nil, invalid("%v", err)
+		}
+	}
+	if req.SourceLanguage != "" {
+		if cfg.SourceLanguage, err = s.normalizeRealtimeLanguage(req.SourceLanguage); err != nil {
+			return nil, invalid("%v", err)
+		}
+	}
+	if req.TargetLanguage != "" {
+		if cfg.TargetLanguage, err = s.normalizeRealtimeLanguage(req.TargetLanguage); err != nil {
+			return nil, invalid("%v", err)
+		}
+	}
+	if req.Bidirectional != nil {
+		cfg.Bidirectional = *req.Bidirectional
+	}
+
+	if req.Modalities != nil {
+		modalities, err := normalizeModalities(req.Modalities)
+		if err != nil {
+			return nil, invalid("%v", err)
+		}
+		cfg.Modalities = modalities
+	}
+
+	if req.VAD != nil {
+		if req.VAD.Enabled != nil {
+			cfg.VAD.Enabled = *req.VAD.Enabled
+		}
+		if req.VAD.Threshold != nil {
+			if *req.VAD.Threshold < minVADThreshold || *req.VAD.Threshold > maxVADThreshold {
+				return nil, invalid("vad threshold must be between %.1f and %.1f", minVADThreshold, maxVADThreshold)
+			}
+			cfg.VAD.Threshold = *req.VAD.Threshold
+		}
+		if req.VAD.SilenceDurationMs != nil {
+			if *req.VAD.SilenceDurationMs < minVADSilenceMs || *req.VAD.SilenceDurationMs > maxVADSilenceMs {
+				return nil, invalid("vad silence_duration_ms must be between %d and %d", minVADSilenceMs, maxVADSilenceMs)
+			}
+			cfg.VAD.SilenceDurationMs = *req.VAD.SilenceDurationMs
+		}
+		if req.VAD.PrefixPaddingMs != nil {
+			if *req.VAD.PrefixPaddingMs < 0 || *req.VAD.PrefixPaddingMs > maxVADPrefixPadding {
+				return nil, invalid("vad prefix_padding_ms must be between 0 and %d", maxVADPrefixPadding)
+			}
+			cfg.VAD.PrefixPaddingMs = *req.VAD.PrefixPaddingMs
+		}
+	}
+
+	if req.Transcription != nil {
+		cfg.Transcription = *req.Transcription
+	}
+
+	if req.AudioFormat != "" {
+		if !containsString(allowedAudioFormats, req.AudioFormat) {
+			return nil, invalid("unsupported audio format %q", req.AudioFormat)
+		}
+		cfg.AudioFormat = req.AudioFormat
+	}
+	if req.SampleRate != 0 {
+		if !containsInt(allowedSampleRates, req.SampleRate) {
+			return nil, invalid("unsupported sample rate %d", req.SampleRate)
+		}
+		cfg.SampleRate = req.SampleRate
+	}
+	if req.Channels != 0 {
+		if req.Channels < 1 || req.Channels > 2 {
+			return nil, invalid("channels must be 1 or 2")
+		}
+		cfg.Channels = req.Channels
+	}
+	if req.OutputSampleRate != 0 {
+		if !containsInt(allowedSampleRates, req.OutputSampleRate) {
+			return nil, invalid("unsupported output sample rate %d", req.OutputSampleRate)
+		}
+		cfg.OutputSampleRate = req.OutputSampleRate
+	}
+	if req.SilenceSuppression != nil {
+		ss := req.SilenceSuppression
+		if ss.Enabled != nil {
+			cfg.SilenceSuppression.Enabled = *ss.Enabled
+		}
+		if ss.Mode != "" {
+			cfg.SilenceSuppression.Mode = ss.Mode
+		}
+		if ss.HangoverMs != nil {
+			cfg.SilenceSuppression.HangoverMs = *ss.HangoverMs
+		}
+		if ss.ThresholdDB != nil {
+			cfg.SilenceSuppression.ThresholdDB = *ss.ThresholdDB
+		}
+		if ss.AutoCommit != nil {
+			cfg.SilenceSuppression.AutoCommit = *ss.AutoCommit
+		}
+		if err := cfg.SilenceSuppression.validate(); err != nil {
+			return nil, invalid("%v", err)
+		}
+	}
+	if req.AudioLevelInterval != nil {
+		interval := *req.AudioLevelInterval
+		if interval != 0 && (interval < minAudioLevelIntervalMs || interval > maxAudioLevelIntervalMs) {
+			return nil, invalid("audio_level_interval_ms must be 0 or between %d and %d", minAudioLevelIntervalMs, maxAudioLevelIntervalMs)
+		}
+		cfg.AudioLevelInterval = interval
+	}
+	if req.Preprocessing != nil {
+		pp := req.Preprocessing
+		if pp.HighPass != nil {
+			cfg.Preprocessing.HighPass = *pp.HighPass
+		}
+		if pp.HighPassCutoffHz != nil {
+			cfg.Preprocessing.HighPassCutoffHz = *pp.HighPassCutoffHz
+		}
+		if pp.NoiseSuppression != nil {
+			cfg.Preprocessing.NoiseSuppression = *pp.NoiseSuppression
+		}
+		if pp.NoiseGate != nil {
+			cfg.Preprocessing.NoiseGate = *pp.NoiseGate
+		}
+		if pp.GateThresholdDB != nil {
+			cfg.Preprocessing.GateThresholdDB = *pp.GateThresholdDB
+		}
+		if pp.AGC != nil {
+			cfg.Preprocessing.AGC = *pp.AGC
+		}
+		if pp.AGCTargetDB != nil {
+			cfg.Preprocessing.AGCTargetDB = *pp.AGCTargetDB
+		}
+		if err := cfg.Preprocessing.validate(); err != nil {
+			return nil, invalid("%v", err)
+		}
+	}
+	if req.Recording != nil {
+		cfg.Recording = *req.Recording
+	}
+	if isG711(AudioFormat(cfg.AudioFormat)) {
+		// G.711 固定为 8kHz 单声道，上下行相同
+		if (req.SampleRate != 0 && req.SampleRate != g711SampleRate) ||
+			(req.OutputSampleRate != 0 && req.OutputSampleRate != g711SampleRate) || req.Channels > 1 {
+			return nil, invalid("%s requires 8000 Hz mono audio", cfg.AudioFormat)
+		}
+		cfg.SampleRate = g711SampleRate
+		cfg.OutputSampleRate = 0
+		cfg.Channels = 1
+	}
+
+	if cfg.Mode == RealtimeModeInterpreter {
+		if cfg.SourceLanguage == "" || cfg.TargetLanguage == "" {
+			return nil, invalid("interpreter mode requires source_language and target_language")
+		}
+		if cfg.SourceLanguage == cfg.TargetLanguage {
+			return nil, invalid("source_language and target_language must differ")
+		}
+		if cfg.Bidirectional && !s.directionDetectable(cfg.SourceLanguage, cfg.TargetLanguage) {
+			return nil, invalid("bidirectional mode cannot tell %s and %s apart: they share a script and one of them has no word-based detection (see direction_detection in /languages)", cfg.SourceLanguage, cfg.TargetLanguage)
+		}
+		// 需要原文转写来生成字幕并记录翻译历史
+		cfg.Transcription = true
+		cfg.ReplyLanguage = cfg.TargetLanguage
+	}
+
+	// 返回给客户端的是实际生效的配置，会话音频处理器不接受的配置直接拒绝，不回退到默认值
+	if err := s.applyAudioConfig(NewAudioProcessor(), &cfg); err != nil {
+		return nil, invalid("audio config cannot be applied: %v", err)
+	}
+
+	return &cfg, nil
+}
+
+// normalizeRealtimeLanguage 解析语言并要求支持实时语音
+func (s *RealtimeService) normalizeRealtimeLanguage(value string) (string, error) {
+	code, err := s.languages.Normalize(value)
+	if err != nil {
+		return "", err
+	}
+	if !s.languages.Supports(code, ProviderRealtime) {
+		return "", fmt.Errorf("language %q is not supported for realtime speech", code)
+	}
+	return code, nil
+}
+
+// normalizeModalities Realtime API 只接受 ["text"] 或 ["text", "audio"]
+func normalizeModalities(modalities []string) ([]string, error) {
+	hasAudio := false
+	for _, m := range modalities {
+		switch strings.ToLower(m) {
+		case "text":
+		case "audio":
+			hasAudio = true
+		default:
+			return nil, fmt.Errorf("unsupported modality %q", m)
+		}
+	}
+	if hasAudio {
+		return []string{"text", "audio"}, nil
+	}
+	return []string{"text"}, nil
+}
+
+// buildInstructions 由预设和回复语言生成系统指令
+func (s *RealtimeService) buildInstructions(cfg *RealtimeSessionConfig) string {
+	if cfg.Mode == RealtimeModeInterpreter {
+		return s.buildInterpreterInstructions(cfg)
+	}
+
+	instructions := instructionPresets[cfg.InstructionsPreset]
+	if lang, err := s.languages.Lookup(cfg.ReplyLanguage, LocaleChineseSimplified); err == nil {
+		instructions += fmt.Sprintf("请用%s回复。", lang.Name)
+	}
+	return instructions
+}
+
+// buildSessionUpdate 生成发送到 GPT Realtime API 的 session.update 消息
+func (s *RealtimeService) buildSessionUpdate(cfg *RealtimeSessionConfig) map[string]interface{} {
+	tools := s.toolDefinitions(cfg)
+	toolChoice := "auto"
+	if len(tools) == 0 {
+		toolChoice = "none"
+	}
+
+	upstreamFormat := string(s.upstreamAudioFormat(cfg))
+	session := map[string]interface{}{
+		"modalities":          cfg.Modalities,
+		"instructions":        s.buildInstructions(cfg),
+		"voice":               cfg.Voice,
+		"input_audio_format":  upstreamFormat, // 上行音频在服务端转换为 pcm16，G.711 直通时除外
+		"output_audio_format": upstreamFormat,
+		"turn_detection":      nil, // 关闭 VAD 时由客户端 commit_audio 手动提交
+		"tools":               tools,
+		"tool_choice":         toolChoice,
+	}
+
+	if cfg.Transcription {
+		session["input_audio_transcription"] = map[string]interface{}{
+			"model": transcriptionModel,
+		}
+	} else {
+		session["input_audio_transcription"] = nil
+	}
+
+	if cfg.VAD.Enabled {
+		session["turn_detection"] = map[string]interface{}{
+			"type":                "server_vad",
+			"threshold":           cfg.VAD.Threshold,
+			"prefix_padding_ms":   cfg.VAD.PrefixPaddingMs,
+			"silence_duration_ms": cfg.VAD.SilenceDurationMs,
+		}
+	}
+
+	return map[string]interface{}{
+		"type":    "session.update",
+		"session": session,
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func boolPtr(v bool) *bool        { return &v }
+func intPtr(v int) *int           { return &v }
+func floatPtr(v float64) *float64 { return &v }
+
+func TestResolveSessionConfig_AppliesRequest(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+
+	cfg, err := service.ResolveSessionConfig(nil, &RealtimeSessionConfigRequest{
+		Voice:              "Shimmer",
+		InstructionsPreset: "concise",
+		ReplyLanguage:      "ja-JP",
+		Modalities:         []string{"audio"},
+		VAD:                &VADConfigRequest{Threshold: floatPtr(0.7), SilenceDurationMs: intPtr(600)},
+		Transcription:      boolPtr(false),
+		SampleRate:         16000,
+	})
+	if err != nil {
+		t.Fatalf("ResolveSessionConfig failed: %v", err)
+	}
+
+	if cfg.Voice != "shimmer" || cfg.InstructionsPreset != "concise" || cfg.ReplyLanguage != "ja" {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+	if len(cfg.Modalities) != 2 {
+		t.Errorf("Expected audio modality to include text, got %v", cfg.Modalities)
+	}
+	if !cfg.VAD.Enabled || cfg.VAD.Threshold != 0.7 || cfg.VAD.SilenceDurationMs != 600 || cfg.VAD.PrefixPaddingMs != 300 {
+		t.Errorf("Unexpected VAD config: %+v", cfg.VAD)
+	}
+	if cfg.Transcription || cfg.SampleRate != 16000 || cfg.AudioFormat != "pcm16" {
+		t.Errorf("Unexpected audio config: %+v", cfg)
+	}
+
+	update := service.buildSessionUpdate(cfg)["session"].(map[string]interface{})
+	if update["voice"] != "shimmer" || update["input_audio_transcription"] != nil {
+		t.Errorf("Unexpected session.update: %+v", update)
+	}
+	if update["instructions"] != instructionPresets["concise"]+"请用日语回复。" {
+		t.Errorf("Unexpected instructions: %v", update["instructions"])
+	}
+}
+
+func TestResolveSessionConfig_DisableVAD(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+
+	cfg, err := service.ResolveSessionConfig(nil, &RealtimeSessionConfigRequest{
+		VAD: &VADConfigRequest{Enabled: boolPtr(false)},
+	})
+	if err != nil {
+		t.Fatalf("ResolveSessionConfig failed: %v", err)
+	}
+
+	update := service.buildSessionUpdate(cfg)["session"].(map[string]interface{})
+	if update["turn_detection"] != nil {
+		t.Errorf("Expected turn_detection to be null, got %v", update["turn_detection"])
+	}
+}
+
+func TestResolveSessionConfig_RejectsInvalid(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+	current := DefaultRealtimeSessionConfig()
+
+	cases := map[string]*RealtimeSessionConfigRequest{
+		"voice":        {Voice: "darth-vader"},
+		"preset":       {InstructionsPreset: "jailbreak"},
+		"language":     {ReplyLanguage: "klingon"},
+		"text-only":    {ReplyLanguage: "fa"},
+		"modality":     {Modalities: []string{"video"}},
+		"threshold":    {VAD: &VADConfigRequest{Threshold: floatPtr(1.5)}},
+		"silence":      {VAD: &VADConfigRequest{SilenceDurationMs: intPtr(10)}},
+		"audio format": {AudioFormat: "mp3"},
+		"container":    {AudioFormat: "webm"},
+		"wav":          {AudioFormat: "wav"},
+		"sample rate":  {SampleRate: 11025},
+		"channels":     {Channels: 6},
+	}
+	for name, req := range cases {
+		if _, err := service.ResolveSessionConfig(current, req); !errors.Is(err, ErrInvalidSessionConfig) {
+			t.Errorf("%s: expected ErrInvalidSessionConfig, got %v", name, err)
+		}
+	}
+
+	if current.Voice != "alloy" || current.VAD.Threshold != 0.5 {
+		t.Errorf("Rejected requests must not modify the current config: %+v", current)
+	}
+}
+
+func TestResolveSessionConfig_OutputSampleRate(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+	cfg, err := service.ResolveSessionConfig(nil, &RealtimeSessionConfigRequest{SampleRate: 48000, OutputSampleRate: 44100})
+	if err != nil {
+		t.Fatalf("ResolveSessionConfig failed: %v", err)
+	}
+	if cfg.PlaybackSampleRate() != 44100 {
+		t.Errorf("Expected playback rate 44100, got %d", cfg.PlaybackSampleRate())
+	}
+
+	if _, err := service.ResolveSessionConfig(nil, &RealtimeSessionConfigRequest{OutputSampleRate: 11025}); err == nil {
+		t.Error("Expected unsupported output sample rate to be rejected")
+	}
+}
+
+func TestResolveSessionConfig_G711(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+	cfg, err := service.ResolveSessionConfig(nil, &RealtimeSessionConfigRequest{AudioFormat: "g711_alaw", OutputSampleRate: 8000})
+	if err != nil {
+		t.Fatalf("ResolveSessionConfig failed: %v", err)
+	}
+	if cfg.SampleRate != 8000 || cfg.Channels != 1 || cfg.PlaybackSampleRate() != 8000 {
+		t.Errorf("Expected 8000 Hz mono for G.711, got %+v", cfg)
+	}
+
+	for _, req := range []*RealtimeSessionConfigRequest{
+		{AudioFormat: "g711_ulaw", SampleRate: 16000},
+		{AudioFormat: "g711_ulaw", Channels: 2},
+		{AudioFormat: "g711_ulaw", OutputSampleRate: 24000},
+	} {
+		if _, err := service.ResolveSessionConfig(nil, req); err == nil {
+			t.Errorf("Expected %+v to be rejected", req)
+		}
+	}
+}
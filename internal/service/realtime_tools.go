@@ -0,0 +1,360 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	_ "time/tzdata" // 容器镜像可能没有系统时区数据
+
+	"smart-glasses-backend/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// ErrUnknownTool 模型调用了未注册的工具
+var ErrUnknownTool = errors.New("unknown tool")
+
+// toolTimeout 单次工具调用的最长执行时间
+const toolTimeout = 15 * time.Second
+
+// maxToolHistory get_last_translation 最多返回的条数
+const maxToolHistory = 10
+
+// ToolHandler 以调用者身份执行工具，args 为模型生成的 JSON 参数，返回值序列化后交给模型
+type ToolHandler func(ctx context.Context, userID uuid.UUID, args json.RawMessage) (interface{}, error)
+
+// Tool 一个可供模型调用的服务端工具
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{} // JSON Schema
+	Handler     ToolHandler
+}
+
+// ToolRegistry 实时会话可用的工具，按注册顺序声明给模型
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]*Tool
+	order []string
+}
+
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: make(map[string]*Tool)}
+}
+
+// Register 注册工具，名称不能重复
+func (r *ToolRegistry) Register(tool *Tool) error {
+	if tool == nil || tool.Name == "" || tool.Handler == nil {
+		return fmt.Errorf("tool must have a name and a handler")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tools[tool.Name]; exists {
+		return fmt.Errorf("tool %q already registered", tool.Name)
+	}
+	r.tools[tool.Name] = tool
+	r.order = append(r.order, tool.Name)
+	return nil
+}
+
+// Len 已注册的工具数量
+func (r *ToolRegistry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.order)
+}
+
+// Definitions 生成 session.update 中的 tools 声明
+func (r *ToolRegistry) Definitions() []map[string]interface{} {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	defs := make([]map[string]interface{}, 0, len(r.order))
+	for _, name := range r.order {
+		tool := r.tools[name]
+		defs = append(defs, map[string]interface{}{
+			"type":        "function",
+			"name":        tool.Name,
+			"description": tool.Description,
+			"parameters":  tool.Parameters,
+		})
+	}
+	return defs
+}
+
+// Execute 执行工具调用，返回交给模型的 JSON 输出
+// 工具自身的错误也作为输出返回，让模型向用户说明原因
+func (r *ToolRegistry) Execute(ctx context.Context, userID uuid.UUID, name string, args json.RawMessage) (string, error) {
+	r.mu.RLock()
+	tool, ok := r.tools[name]
+	r.mu.RUnlock()
+	if !ok {
+		return toolErrorOutput(fmt.Errorf("%w: %s", ErrUnknownTool, name)), ErrUnknownTool
+	}
+
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, toolTimeout)
+	defer cancel()
+
+	result, err := tool.Handler(ctx, userID, args)
+	if err != nil {
+		return toolErrorOutput(err), err
+	}
+
+	output, err := json.Marshal(result)
+	if err != nil {
+		return toolErrorOutput(err), err
+	}
+	return string(output), nil
+}
+
+func toolErrorOutput(err error) string {
+	output, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return string(output)
+}
+
+// NewRealtimeToolRegistry 创建内置工具；translateService 为 nil 时不提供翻译相关工具
+func NewRealtimeToolRegistry(translateService *TranslateService) *ToolRegistry {
+	registry := NewToolRegistry()
+	registry.Register(currentTimeTool())
+	if translateService != nil {
+		registry.Register(lastTranslationTool(translateService))
+		registry.Register(translateTextTool(translateService))
+	}
+	return registry
+}
+
+// currentTimeTool 查询指定时区的当前时间
+func currentTimeTool() *Tool {
+	return &Tool{
+		Name:        "get_current_time",
+		Description: "Get the current date and time. Use it for questions like \"what time is it\" or \"what time is it in Tokyo\".",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"timezone": map[string]interface{}{
+					"type":        "string",
+					"description": "IANA time zone name such as Asia/Tokyo or Europe/Paris. Omit for UTC.",
+				},
+			},
+		},
+		Handler: func(ctx context.Context, userID uuid.UUID, args json.RawMessage) (interface{}, error) {
+			var params struct {
+				Timezone string `json:"timezone"`
+			}
+			if err := json.Unmarshal(args, &params); err != nil {
+				return nil, fmt.Errorf("invalid arguments: %v", err)
+			}
+
+			loc := time.UTC
+			if params.Timezone != "" {
+				var err error
+				if loc, err = time.LoadLocation(params.Timezone); err != nil {
+					return nil, fmt.Errorf("unknown time zone %q", params.Timezone)
+				}
+			}
+
+			now := time.Now().In(loc)
+			_, offset := now.Zone()
+			return map[string]interface{}{
+				"timezone":   loc.String(),
+				"time":       now.Format(time.RFC3339),
+				"local_time": now.Format("2006-01-02 15:04"),
+				"weekday":    now.Weekday().String(),
+				"utc_offset": fmt.Sprintf("%+03d:%02d", offset/3600, abs(offset%3600)/60),
+			}, nil
+		},
+	}
+}
+
+// lastTranslationTool 查询用户最近的翻译记录
+func lastTranslationTool(translateService *TranslateService) *Tool {
+	return &Tool{
+		Name:        "get_last_translation",
+		Description: "Get the user's most recent translations from their translation history.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"count": map[string]interface{}{
+					"type":        "integer",
+					"description": "Number of recent translations to return.",
+					"minimum":     1,
+					"maximum":     maxToolHistory,
+				},
+			},
+		},
+		Handler: func(ctx context.Context, userID uuid.UUID, args json.RawMessage) (interface{}, error) {
+			var params struct {
+				Count int `json:"count"`
+			}
+			if err := json.Unmarshal(args, &params); err != nil {
+				return nil, fmt.Errorf("invalid arguments: %v", err)
+			}
+			if params.Count < 1 {
+				params.Count = 1
+			}
+			if params.Count > maxToolHistory {
+				params.Count = maxToolHistory
+			}
+
+			history, err := translateService.GetHistory(userID, &model.HistoryFilter{Limit: params.Count})
+			if err != nil {
+				return nil, fmt.Errorf("failed to load translation history")
+			}
+			return map[string]interface{}{
+				"translations": history,
+			}, nil
+		},
+	}
+}
+
+// translateTextTool 翻译一段文字并写入用户的翻译历史
+func translateTextTool(translateService *TranslateService) *Tool {
+	return &Tool{
+		Name:        "translate_text",
+		Description: "Translate text, for example a sign or menu the user reads out, and save it to the user's translation history.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"text": map[string]interface{}{
+					"type":        "string",
+					"description": "The text to translate.",
+				},
+				"source_language": map[string]interface{}{
+					"type":        "string",
+					"description": "BCP-47 code of the text's language, e.g. ja or fr.",
+				},
+				"target_language": map[string]interface{}{
+					"type":        "string",
+					"description": "BCP-47 code of the language to translate into, e.g. zh-Hans or en.",
+				},
+			},
+			"required": []string{"text", "source_language", "target_language"},
+		},
+		Handler: func(ctx context.Context, userID uuid.UUID, args json.RawMessage) (interface{}, error) {
+			var req model.TranslateRequest
+			if err := json.Unmarshal(args, &req); err != nil {
+				return nil, fmt.Errorf("invalid arguments: %v", err)
+			}
+			if req.Text == "" {
+				return nil, fmt.Errorf("text is required")
+			}
+
+			resp, err := translateService.TranslateText(ctx, userID, &req)
+			if errors.Is(err, ErrUnsupportedLanguage) {
+				return nil, err
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, fmt.Errorf("translation failed: %v", ctx.Err())
+				}
+				return nil, fmt.Errorf("translation failed")
+			}
+			return resp, nil
+		},
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// SetToolRegistry 设置助手模式可调用的工具，为 nil 时不声明工具
+func (s *RealtimeService) SetToolRegistry(tools *ToolRegistry) {
+	s.tools = tools
+}
+
+// toolDefinitions 会话声明的工具；传译模式只翻译，不声明工具
+func (s *RealtimeService) toolDefinitions(cfg *RealtimeSessionConfig) []map[string]interface{} {
+	if s.tools == nil || cfg.Mode == RealtimeModeInterpreter {
+		return []map[string]interface{}{}
+	}
+	return s.tools.Definitions()
+}
+
+// pendingToolCall 一次执行中的工具调用，结果写入带缓冲的通道
+type pendingToolCall struct {
+	callID string
+	name   string
+	result chan string
+	cancel context.CancelFunc // 响应被取消或会话结束时中止执行
+}
+
+// startToolCall 模型生成完调用参数后立即执行，不阻塞响应事件转发
+func (s *RealtimeService) startToolCall(session *RealtimeSession, event map[string]interface{}) {
+	callID, _ := event["call_id"].(string)
+	name, _ := event["name"].(string)
+	arguments, _ := event["arguments"].(string)
+	if callID == "" || name == "" || s.tools == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(session.ctx)
+	call := &pendingToolCall{callID: callID, name: name, result: make(chan string, 1), cancel: cancel}
+	responseID := eventResponseID(event)
+	if session.toolCalls == nil {
+		session.toolCalls = make(map[string][]*pendingToolCall)
+	}
+	session.toolCalls[responseID] = append(session.toolCalls[responseID], call)
+
+	go func() {
+		defer cancel()
+		started := time.Now()
+		output, err := s.tools.Execute(ctx, session.UserID, name, json.RawMessage(arguments))
+		if err != nil {
+			log.Printf("Tool %s failed for user %s: %v", name, session.UserID, err)
+		} else {
+			log.Printf("Tool %s completed for user %s in %v", name, session.UserID, time.Since(started))
+		}
+		call.result <- output
+	}()
+}
+
+// finishToolCalls 响应结束后回传工具结果并请求模型继续回复
+// 上游同一时刻只允许一个进行中的响应，因此必须等 response.done 之后再请求；响应被取消时中止仍在执行的工具
+func (s *RealtimeService) finishToolCalls(session *RealtimeSession, responseID string, cancelled bool) {
+	calls := session.toolCalls[responseID]
+	delete(session.toolCalls, responseID)
+	if cancelled {
+		for _, call := range calls {
+			call.cancel()
+		}
+		return
+	}
+	if len(calls) == 0 {
+		return
+	}
+
+	go func() {
+		for _, call := range calls {
+			output := <-call.result
+			if err := s.WriteJSON(session.Upstream(), map[string]interface{}{
+				"type": "conversation.item.create",
+				"item": map[string]interface{}{
+					"type":    "function_call_output",
+					"call_id": call.callID,
+					"output":  output,
+				},
+			}, 5*time.Second); err != nil {
+				log.Printf("Failed to send tool output for session %s: %v", session.ID, err)
+				return
+			}
+		}
+
+		if err := s.RequestResponse(session, nil); err != nil {
+			log.Printf("Failed to request response after tool calls for session %s: %v", session.ID, err)
+		}
+	}()
+}
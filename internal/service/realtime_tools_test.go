@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"smart-glasses-backend/pkg/azure"
+
+	"github.com/google/uuid"
+)
+
+func echoTool() *Tool {
+	return &Tool{
+		Name:       "echo",
+		Parameters: map[string]interface{}{"type": "object"},
+		Handler: func(ctx context.Context, userID uuid.UUID, args json.RawMessage) (interface{}, error) {
+			var params struct {
+				Value string `json:"value"`
+			}
+			if err := json.Unmarshal(args, &params); err != nil {
+				return nil, err
+			}
+			if params.Value == "" {
+				return nil, errors.New("value is required")
+			}
+			return map[string]string{"value": params.Value, "user_id": userID.String()}, nil
+		},
+	}
+}
+
+func TestToolRegistry(t *testing.T) {
+	registry := NewToolRegistry()
+	if err := registry.Register(echoTool()); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := registry.Register(echoTool()); err == nil {
+		t.Error("Expected duplicate tool registration to fail")
+	}
+	if err := registry.Register(&Tool{Name: "no_handler"}); err == nil {
+		t.Error("Expected tool without handler to be rejected")
+	}
+
+	defs := registry.Definitions()
+	if len(defs) != 1 || defs[0]["name"] != "echo" || defs[0]["type"] != "function" {
+		t.Errorf("Unexpected definitions: %v", defs)
+	}
+
+	userID := uuid.New()
+	output, err := registry.Execute(context.Background(), userID, "echo", json.RawMessage(`{"value":"hi"}`))
+	if err != nil || !strings.Contains(output, `"value":"hi"`) || !strings.Contains(output, userID.String()) {
+		t.Errorf("Unexpected output %s (%v)", output, err)
+	}
+
+	// 工具错误作为输出交给模型
+	output, err = registry.Execute(context.Background(), userID, "echo", nil)
+	if err == nil || !strings.Contains(output, "value is required") {
+		t.Errorf("Expected tool error in output, got %s (%v)", output, err)
+	}
+
+	if _, err := registry.Execute(context.Background(), userID, "missing", nil); !errors.Is(err, ErrUnknownTool) {
+		t.Errorf("Expected ErrUnknownTool, got %v", err)
+	}
+}
+
+func TestCurrentTimeTool(t *testing.T) {
+	registry := NewRealtimeToolRegistry(nil)
+	if registry.Len() != 1 {
+		t.Fatalf("Expected only get_current_time without a translate service, got %d tools", registry.Len())
+	}
+
+	output, err := registry.Execute(context.Background(), uuid.New(), "get_current_time", json.RawMessage(`{"timezone":"Asia/Tokyo"}`))
+	if err != nil {
+		t.Fatalf("get_current_time failed: %v", err)
+	}
+	var result map[string]string
+	json.Unmarshal([]byte(output), &result)
+	if result["timezone"] != "Asia/Tokyo" || result["utc_offset"] != "+09:00" {
+		t.Errorf("Unexpected result: %v", result)
+	}
+
+	if _, err := registry.Execute(context.Background(), uuid.New(), "get_current_time", json.RawMessage(`{"timezone":"Mars/Olympus"}`)); err == nil {
+		t.Error("Expected unknown time zone to fail")
+	}
+}
+
+func TestSessionUpdate_Tools(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+	cfg := DefaultRealtimeSessionConfig()
+
+	session := service.buildSessionUpdate(cfg)["session"].(map[string]interface{})
+	if session["tool_choice"] != "none" {
+		t.Errorf("Expected no tools without a registry, got %v", session["tool_choice"])
+	}
+
+	registry := NewToolRegistry()
+	registry.Register(echoTool())
+	service.SetToolRegistry(registry)
+
+	session = service.buildSessionUpdate(cfg)["session"].(map[string]interface{})
+	if tools := session["tools"].([]map[string]interface{}); len(tools) != 1 || session["tool_choice"] != "auto" {
+		t.Errorf("Expected tools in assistant mode, got %v", session["tools"])
+	}
+
+	interpreter := *cfg
+	interpreter.Mode = RealtimeModeInterpreter
+	session = service.buildSessionUpdate(&interpreter)["session"].(map[string]interface{})
+	if tools := session["tools"].([]map[string]interface{}); len(tools) != 0 {
+		t.Errorf("Expected no tools in interpreter mode, got %v", tools)
+	}
+}
+
+func TestHandleRealtimeResponse_FunctionCall(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+	registry := NewToolRegistry()
+	registry.Register(echoTool())
+	service.SetToolRegistry(registry)
+
+	clientConn, _ := wsPair(t)
+	gptConn, upstream := wsPair(t)
+	userID := uuid.New()
+	session := service.NewSession(uuid.New(), userID, clientConn, gptConn, nil)
+	t.Cleanup(func() { service.EndSession(session) })
+	go service.HandleRealtimeResponse(session)
+
+	for _, event := range []map[string]interface{}{
+		{"type": "response.created", "response": map[string]interface{}{"id": "resp_1"}},
+		{"type": "response.function_call_arguments.done", "response_id": "resp_1", "call_id": "call_1", "name": "echo", "arguments": `{"value":"hi"}`},
+		{"type": "response.done", "response": map[string]interface{}{"id": "resp_1", "status": "completed"}},
+	} {
+		if err := upstream.WriteJSON(event); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+
+	create := readUpstream(t, upstream, "conversation.item.create")
+	item := create["item"].(map[string]interface{})
+	output, _ := item["output"].(string)
+	if item["type"] != "function_call_output" || item["call_id"] != "call_1" || !strings.Contains(output, userID.String()) {
+		t.Errorf("Unexpected tool output item: %v", item)
+	}
+
+	readUpstream(t, upstream, "response.create")
+}
+
+func TestTranslateTextTool_StopsOnContextDone(t *testing.T) {
+	// 上游一直不返回，直到请求被取消
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body) // 读完请求体后服务端才能发现客户端断开
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+
+	client := azure.NewOpenAIClient(server.URL, "key", "gpt-4", "2024-10-21")
+	tool := translateTextTool(NewTranslateService(client, nil, nil, nil, NewLanguageRegistry()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := tool.Handler(ctx, uuid.New(), json.RawMessage(`{"text":"こんにちは","source_language":"ja","target_language":"zh-Hans"}`))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+			t.Errorf("Expected a deadline error, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("translate_text ignored the context deadline")
+	}
+}
+
+func TestHandleRealtimeResponse_CancelledResponseStopsTools(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	registry := NewToolRegistry()
+	registry.Register(&Tool{
+		Name: "slow",
+		Handler: func(ctx context.Context, userID uuid.UUID, args json.RawMessage) (interface{}, error) {
+			close(started)
+			<-ctx.Done()
+			close(stopped)
+			return nil, ctx.Err()
+		},
+	})
+	service.SetToolRegistry(registry)
+
+	clientConn, _ := wsPair(t)
+	gptConn, upstream := wsPair(t)
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, nil)
+	t.Cleanup(func() { service.EndSession(session) })
+	go service.HandleRealtimeResponse(session)
+
+	for _, event := range []map[string]interface{}{
+		{"type": "response.created", "response": map[string]interface{}{"id": "resp_1"}},
+		{"type": "response.function_call_arguments.done", "response_id": "resp_1", "call_id": "call_1", "name": "slow", "arguments": "{}"},
+	} {
+		if err := upstream.WriteJSON(event); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Tool was not started")
+	}
+
+	if err := upstream.WriteJSON(map[string]interface{}{
+		"type": "response.done", "response": map[string]interface{}{"id": "resp_1", "status": "cancelled"},
+	}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the cancelled response to stop the running tool")
+	}
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"log"
+	"smart-glasses-backend/internal/model"
+	"smart-glasses-backend/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SetRealtimeRepository 设置会话转写存储，未设置时转写只转发给客户端
+func (s *RealtimeService) SetRealtimeRepository(repo *repository.RealtimeRepository) {
+	s.realtimeRepo = repo
+}
+
+// startTranscript 写入会话记录，失败时该会话的转写不再保存
+func (s *RealtimeService) startTranscript(session *RealtimeSession) {
+	if s.realtimeRepo == nil {
+		return
+	}
+
+	cfg := session.Config()
+	err := s.realtimeRepo.CreateSession(&model.RealtimeConversation{
+		ID:             session.ID,
+		UserID:         session.UserID,
+		Mode:           cfg.Mode,
+		SourceLanguage: cfg.SourceLanguage,
+		TargetLanguage: cfg.TargetLanguage,
+	})
+	if err != nil {
+		log.Printf("Failed to create realtime session record %s: %v", session.ID, err)
+		return
+	}
+	session.persisted = true
+}
+
+// UpdateSessionConfig 应用新的会话配置，按配置开始或停止录音，并同步会话记录中的模式和语言
+func (s *RealtimeService) UpdateSessionConfig(session *RealtimeSession, cfg *RealtimeSessionConfig) {
+	session.SetConfig(cfg)
+	if err := s.applyAudioConfig(session.audio, cfg); err != nil {
+		log.Printf("Failed to apply audio config for session %s: %v", session.ID, err)
+	}
+	s.syncRecording(session, cfg)
+
+	if s.realtimeRepo == nil || !session.persisted {
+		return
+	}
+	if err := s.realtimeRepo.UpdateSessionMode(session.ID, cfg.Mode, cfg.SourceLanguage, cfg.TargetLanguage); err != nil {
+		log.Printf("Failed to update realtime session record %s: %v", session.ID, err)
+	}
+}
+
+// EndSession 停止上游重连，关闭当前上游连接，释放连接写锁，保存录音并记录会话结束
+func (s *RealtimeService) EndSession(session *RealtimeSession) {
+	session.cancel()
+	session.jitterMu.Lock()
+	if session.jitterTimer != nil {
+		session.jitterTimer.Stop()
+	}
+	session.jitterMu.Unlock()
+	if upstream := session.Upstream(); upstream != nil {
+		upstream.Close()
+		s.releaseConn(upstream)
+	}
+	s.releaseConn(session.ClientConn)
+	s.flushUsage(session)
+	s.StopRecording(session)
+
+	if s.realtimeRepo == nil || !session.persisted {
+		return
+	}
+	if err := s.realtimeRepo.EndSession(session.ID, time.Now()); err != nil {
+		log.Printf("Failed to end realtime session record %s: %v", session.ID, err)
+	}
+}
+
+// recordTurn 异步保存一条转写，序号在调用时分配以保持对话顺序，录音时同时写入录音的转写文件
+func (s *RealtimeService) recordTurn(session *RealtimeSession, role, itemID, text string) {
+	s.recordTranscriptTurn(session, role, itemID, text)
+	if s.realtimeRepo == nil || !session.persisted {
+		return
+	}
+
+	turn := &model.RealtimeTurn{
+		SessionID: session.ID,
+		Seq:       int(session.turnSeq.Add(1)),
+		Role:      role,
+		ItemID:    itemID,
+		Text:      text,
+		CreatedAt: time.Now(),
+	}
+	go func() {
+		if err := s.realtimeRepo.AddTurn(turn); err != nil {
+			log.Printf("Failed to save realtime turn for session %s: %v", session.ID, err)
+		}
+	}()
+}
+
+// handleUserTranscript 用户语音转写完成
+func (s *RealtimeService) handleUserTranscript(session *RealtimeSession, itemID, transcript string) {
+	cfg := session.Config()
+
+	msg := map[string]interface{}{
+		"type":       "user_transcript",
+		"session_id": session.ID,
+		"item_id":    itemID,
+		"text":       transcript,
+		"timestamp":  time.Now().UnixMilli(),
+	}
+	if cfg.Mode == RealtimeModeInterpreter {
+		msg["language"], _ = s.resolveTurnDirection(cfg, transcript, "")
+	}
+	s.sendToClientWithTimeout(session.ClientConn, msg)
+
+	session.appendHistory(model.RealtimeRoleUser, transcript)
+	s.recordTurn(session, model.RealtimeRoleUser, itemID, transcript)
+
+	if cfg.Mode == RealtimeModeInterpreter {
+		s.handleInterpreterSource(session, itemID, transcript)
+	}
+}
+
+// handleAssistantTranscript 助手一次输出的完整文本（语音转写或文本回复）
+func (s *RealtimeService) handleAssistantTranscript(session *RealtimeSession, itemID, transcript string) {
+	s.sendToClientWithTimeout(session.ClientConn, map[string]interface{}{
+		"type":       "assistant_transcript",
+		"session_id": session.ID,
+		"item_id":    itemID,
+		"text":       transcript,
+		"timestamp":  time.Now().UnixMilli(),
+	})
+
+	session.appendHistory(model.RealtimeRoleAssistant, transcript)
+	s.recordTurn(session, model.RealtimeRoleAssistant, itemID, transcript)
+}
+
+// ListSessions 列出用户的实时会话
+func (s *RealtimeService) ListSessions(userID uuid.UUID, limit, offset int) ([]*model.RealtimeConversation, error) {
+	return s.realtimeRepo.ListSessions(userID, limit, offset)
+}
+
+// GetSessionTranscript 获取一次会话的完整转写
+func (s *RealtimeService) GetSessionTranscript(userID, sessionID uuid.UUID) (*model.RealtimeConversation, error) {
+	return s.realtimeRepo.GetSession(userID, sessionID)
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// wsPair 返回服务端一侧的连接和测试持有的另一侧连接
+func wsPair(t *testing.T) (*websocket.Conn, *websocket.Conn) {
+	t.Helper()
+
+	accepted := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		accepted <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	peer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() { peer.Close() })
+
+	conn := <-accepted
+	t.Cleanup(func() { conn.Close() })
+	return conn, peer
+}
+
+// readClientMessages 读取发给客户端的消息，直到出现指定类型
+func readClientMessages(t *testing.T, conn *websocket.Conn, until string) map[string]map[string]interface{} {
+	t.Helper()
+
+	messages := make(map[string]map[string]interface{})
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var msg map[string]interface{}
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("Did not receive %s: %v", until, err)
+		}
+		msgType, _ := msg["type"].(string)
+		messages[msgType] = msg
+		if msgType == until {
+			return messages
+		}
+	}
+}
+
+func TestHandleRealtimeResponse_ForwardsTranscripts(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+
+	clientConn, client := wsPair(t)
+	gptConn, upstream := wsPair(t)
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, nil)
+	t.Cleanup(func() { service.EndSession(session) })
+	go service.HandleRealtimeResponse(session)
+
+	events := []map[string]interface{}{
+		{"type": "conversation.item.input_audio_transcription.completed", "item_id": "item_user", "transcript": " What time is it? "},
+		{"type": "response.created"},
+		{"type": "response.audio_transcript.delta", "item_id": "item_assistant", "delta": "现在"},
+		{"type": "response.audio_transcript.done", "item_id": "item_assistant", "transcript": "现在三点。"},
+		{"type": "response.done"},
+	}
+	for _, event := range events {
+		if err := upstream.WriteJSON(event); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+
+	messages := readClientMessages(t, client, "response_complete")
+
+	user := messages["user_transcript"]
+	if user == nil || user["text"] != "What time is it?" || user["item_id"] != "item_user" {
+		t.Errorf("Unexpected user transcript: %v", user)
+	}
+	if user["session_id"] != session.ID.String() {
+		t.Errorf("Expected session ID %s, got %v", session.ID, user["session_id"])
+	}
+	if delta := messages["assistant_transcript_delta"]; delta == nil || delta["text"] != "现在" {
+		t.Errorf("Unexpected assistant transcript delta: %v", delta)
+	}
+	if assistant := messages["assistant_transcript"]; assistant == nil || assistant["text"] != "现在三点。" {
+		t.Errorf("Unexpected assistant transcript: %v", assistant)
+	}
+}
+
+func TestHandleRealtimeResponse_InterpreterTurn(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+	cfg, err := service.ResolveSessionConfig(nil, &RealtimeSessionConfigRequest{
+		Mode:           RealtimeModeInterpreter,
+		SourceLanguage: "en",
+		TargetLanguage: "zh-Hans",
+		Bidirectional:  boolPtr(true),
+	})
+	if err != nil {
+		t.Fatalf("ResolveSessionConfig failed: %v", err)
+	}
+
+	clientConn, client := wsPair(t)
+	gptConn, upstream := wsPair(t)
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, cfg)
+	t.Cleanup(func() { service.EndSession(session) })
+	go service.HandleRealtimeResponse(session)
+
+	// 译文先于原文转写到达
+	for _, event := range []map[string]interface{}{
+		{"type": "input_audio_buffer.committed", "item_id": "item_1"},
+		{"type": "response.created", "response": map[string]interface{}{"id": "resp_1"}},
+		{"type": "response.text.delta", "response_id": "resp_1", "delta": "Where is the station?"},
+		{"type": "response.done", "response": map[string]interface{}{"id": "resp_1", "status": "completed"}},
+		{"type": "conversation.item.input_audio_transcription.completed", "item_id": "item_1", "transcript": "车站在哪里？"},
+	} {
+		if err := upstream.WriteJSON(event); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+
+	turn := readClientMessages(t, client, "interpretation")["interpretation"]
+	if turn["source_text"] != "车站在哪里？" || turn["translated_text"] != "Where is the station?" {
+		t.Errorf("Unexpected interpretation: %v", turn)
+	}
+	if turn["source_language"] != "zh-Hans" || turn["target_language"] != "en" {
+		t.Errorf("Expected reversed direction for Chinese speech, got %v", turn)
+	}
+}
+
+func TestHandleRealtimeResponse_InterpreterCancelledTurn(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+	cfg, err := service.ResolveSessionConfig(nil, &RealtimeSessionConfigRequest{
+		Mode:           RealtimeModeInterpreter,
+		SourceLanguage: "en",
+		TargetLanguage: "zh-Hans",
+	})
+	if err != nil {
+		t.Fatalf("ResolveSessionConfig failed: %v", err)
+	}
+
+	clientConn, client := wsPair(t)
+	gptConn, upstream := wsPair(t)
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, cfg)
+	t.Cleanup(func() { service.EndSession(session) })
+	go service.HandleRealtimeResponse(session)
+
+	// 第一轮的响应被取消，转写在取消之后才到达；第二轮正常完成
+	for _, event := range []map[string]interface{}{
+		{"type": "input_audio_buffer.committed", "item_id": "item_1"},
+		{"type": "response.created", "response": map[string]interface{}{"id": "resp_1"}},
+		{"type": "response.text.delta", "response_id": "resp_1", "delta": "早上"},
+		{"type": "response.done", "response": map[string]interface{}{"id": "resp_1", "status": "cancelled"}},
+		{"type": "conversation.item.input_audio_transcription.completed", "item_id": "item_1", "transcript": "Good morning everyone"},
+		{"type": "input_audio_buffer.committed", "item_id": "item_2"},
+		{"type": "response.created", "response": map[string]interface{}{"id": "resp_2"}},
+		{"type": "conversation.item.input_audio_transcription.completed", "item_id": "item_2", "transcript": "Thank you"},
+		{"type": "response.text.delta", "response_id": "resp_2", "delta": "谢谢"},
+		{"type": "response.done", "response": map[string]interface{}{"id": "resp_2", "status": "completed"}},
+	} {
+		if err := upstream.WriteJSON(event); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+
+	turn := readClientMessages(t, client, "interpretation")["interpretation"]
+	if turn["source_text"] != "Thank you" || turn["translated_text"] != "谢谢" {
+		t.Errorf("Expected the second turn to pair with its own translation, got %v", turn)
+	}
+}
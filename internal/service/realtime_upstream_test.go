@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"smart-glasses-backend/internal/realtimefake"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// startFakeSession 连接脚本驱动的模拟上游并启动响应处理，返回模拟上游和客户端一侧的连接
+func startFakeSession(t *testing.T, service *RealtimeService, scenarioFile string) (*realtimefake.Server, *RealtimeSession, *websocket.Conn) {
+	t.Helper()
+
+	scenario, err := realtimefake.LoadScenario(filepath.Join("..", "realtimefake", "testdata", scenarioFile))
+	if err != nil {
+		t.Fatalf("LoadScenario failed: %v", err)
+	}
+	fake := realtimefake.NewServer(scenario)
+	fake.APIKey = "test-key"
+	httpServer := httptest.NewServer(fake)
+	t.Cleanup(httpServer.Close)
+
+	service.endpoint = httpServer.URL
+	gptConn, err := service.ConnectToGPTRealtime(context.Background())
+	if err != nil {
+		t.Fatalf("ConnectToGPTRealtime failed: %v", err)
+	}
+
+	clientConn, client := wsPair(t)
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, nil)
+	t.Cleanup(func() { service.EndSession(session) })
+
+	if err := service.ConfigureSession(gptConn, session.Config()); err != nil {
+		t.Fatalf("ConfigureSession failed: %v", err)
+	}
+	go service.HandleRealtimeResponse(session)
+	return fake, session, client
+}
+
+func TestRealtimeUpstream_ReconnectReplaysConversation(t *testing.T) {
+	service := NewRealtimeService("test-key", "", "test", "test")
+	fake, session, client := startFakeSession(t, service, "reconnect.yaml")
+
+	if err := service.CommitAudioBuffer(session.Upstream()); err != nil {
+		t.Fatalf("CommitAudioBuffer failed: %v", err)
+	}
+
+	messages := readClientMessages(t, client, "reconnected")
+	if messages["user_transcript"]["text"] != "请记住数字七" {
+		t.Errorf("Unexpected user transcript: %v", messages["user_transcript"])
+	}
+	if messages["reconnecting"]["attempt"] != float64(1) {
+		t.Errorf("Expected reconnecting notice, got %v", messages["reconnecting"])
+	}
+	if messages["reconnected"]["replayed_turns"] != float64(2) {
+		t.Errorf("Expected 2 replayed turns, got %v", messages["reconnected"])
+	}
+
+	// 新连接上重新配置会话并回放对话
+	if _, err := fake.WaitFor("session.update", 2, 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := fake.WaitFor("conversation.item.create", 2, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := replayed[0]["item"].(map[string]interface{})
+	if first["role"] != "user" || !strings.HasPrefix(first["id"].(string), replayItemPrefix) {
+		t.Errorf("Unexpected replayed item: %v", first)
+	}
+
+	// 回放的历史不会被当作新的用户输入，会话可以继续
+	if err := service.RequestResponse(session, nil); err != nil {
+		t.Fatalf("RequestResponse failed: %v", err)
+	}
+	messages = readClientMessages(t, client, "assistant_transcript")
+	if _, ok := messages["user_transcript"]; ok {
+		t.Errorf("Replayed history must not be forwarded as user input: %v", messages["user_transcript"])
+	}
+	if messages["assistant_transcript"]["text"] != "你让我记住的是数字七。" {
+		t.Errorf("Unexpected reply after reconnect: %v", messages["assistant_transcript"])
+	}
+	if fake.Connections() != 2 {
+		t.Errorf("Expected 2 upstream connections, got %d", fake.Connections())
+	}
+}
+
+func TestRealtimeUpstream_ToolCall(t *testing.T) {
+	service := NewRealtimeService("test-key", "", "test", "test")
+	service.SetToolRegistry(NewRealtimeToolRegistry(nil))
+	fake, session, client := startFakeSession(t, service, "tool_call.yaml")
+
+	if err := service.CommitAudioBuffer(session.Upstream()); err != nil {
+		t.Fatalf("CommitAudioBuffer failed: %v", err)
+	}
+
+	messages := readClientMessages(t, client, "assistant_transcript")
+	if messages["assistant_transcript"]["text"] != "东京现在是下午三点。" {
+		t.Errorf("Unexpected reply: %v", messages["assistant_transcript"])
+	}
+
+	outputs, err := fake.WaitFor("conversation.item.create", 1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := outputs[0]["item"].(map[string]interface{})
+	if item["type"] != "function_call_output" || !strings.Contains(item["output"].(string), "Asia/Tokyo") {
+		t.Errorf("Unexpected tool output: %v", item)
+	}
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"smart-glasses-backend/internal/model"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrQuotaExceeded 用户的实时对话用量已达配额
+var ErrQuotaExceeded = errors.New("realtime quota exceeded")
+
+// defaultQuotaWarnRatio 未配置提醒比例时，用量达到配额的 80% 提醒客户端
+const defaultQuotaWarnRatio = 0.8
+
+// usageAudioCheckMicros 上行语音每累计 5 秒记录一次用量并检查配额，一直不触发回复的会话也会在超额时结束
+const usageAudioCheckMicros = 5 * 1e6
+
+// RealtimeQuota 每个用户的实时对话配额，0 表示不限制
+// 语音配额按上行和下行时长合计，token 配额按输入和输出合计
+type RealtimeQuota struct {
+	DailyAudioSeconds   float64 `json:"daily_audio_seconds"`
+	MonthlyAudioSeconds float64 `json:"monthly_audio_seconds"`
+	DailyTokens         int     `json:"daily_tokens"`
+	MonthlyTokens       int     `json:"monthly_tokens"`
+	WarnRatio           float64 `json:"warn_ratio"`
+}
+
+// RealtimeQuotaStatus 用户当日和当月的用量及配额状态
+type RealtimeQuotaStatus struct {
+	Daily    model.RealtimeUsage `json:"daily"`
+	Monthly  model.RealtimeUsage `json:"monthly"`
+	Quota    RealtimeQuota       `json:"quota"`
+	Ratio    float64             `json:"ratio"`           // 各项配额中最高的用量比例
+	Limit    string              `json:"limit,omitempty"` // 比例最高的配额项
+	Warning  bool                `json:"warning"`
+	Exceeded bool                `json:"exceeded"`
+}
+
+// userUsage 用户当日和当月的用量，同一用户的多个会话共享
+type userUsage struct {
+	mu      sync.Mutex
+	day     time.Time
+	month   time.Time
+	daily   model.RealtimeUsage
+	monthly model.RealtimeUsage
+}
+
+// SetQuota 设置实时对话配额
+func (s *RealtimeService) SetQuota(quota RealtimeQuota) {
+	if quota.WarnRatio <= 0 || quota.WarnRatio >= 1 {
+		quota.WarnRatio = defaultQuotaWarnRatio
+	}
+	s.quota = quota
+}
+
+// GetRealtimeUsage 返回用户当日和当月的用量，配置了数据库时与数据库汇总逐项取较大值
+// 本实例的用量异步写入数据库，刚结束的会话可能还没写完，不能直接用数据库汇总覆盖
+func (s *RealtimeService) GetRealtimeUsage(userID uuid.UUID) *RealtimeQuotaStatus {
+	u := s.userUsageFor(userID)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := time.Now()
+	u.rollover(now)
+	if s.realtimeRepo != nil {
+		// 多实例部署时其他实例的用量只在数据库中，每次连接前重新汇总
+		daily, err := s.realtimeRepo.GetUserUsageSince(userID, u.day)
+		if err == nil {
+			var monthly *model.RealtimeUsage
+			monthly, err = s.realtimeRepo.GetUserUsageSince(userID, u.month)
+			if err == nil {
+				u.daily, u.monthly = maxRealtimeUsage(u.daily, *daily), maxRealtimeUsage(u.monthly, *monthly)
+			}
+		}
+		if err != nil {
+			log.Printf("Failed to load realtime usage for user %s: %v", userID, err)
+		}
+	}
+	return s.quotaStatus(u)
+}
+
+// CheckRealtimeQuota 打开实时连接前检查配额，已用完时返回 ErrQuotaExceeded
+func (s *RealtimeService) CheckRealtimeQuota(userID uuid.UUID) (*RealtimeQuotaStatus, error) {
+	status := s.GetRealtimeUsage(userID)
+	if status.Exceeded {
+		return status, ErrQuotaExceeded
+	}
+	return status, nil
+}
+
+// recordResponseUsage 在 response.done 时记录本轮用量
+func (s *RealtimeService) recordResponseUsage(session *RealtimeSession, event map[string]interface{}) {
+	s.recordUsage(session, eventResponseID(event), parseResponseUsage(event))
+}
+
+// checkAudioUsage 上行语音累计到检查间隔时记录用量，客户端消息循环在发送音频后调用
+func (s *RealtimeService) checkAudioUsage(session *RealtimeSession) {
+	if session.audioInMicros.Load() >= usageAudioCheckMicros {
+		s.recordUsage(session, "", model.RealtimeUsage{})
+	}
+}
+
+// recordUsage 记录用量，接近配额时提醒，超过配额时结束会话
+func (s *RealtimeService) recordUsage(session *RealtimeSession, responseID string, usage model.RealtimeUsage) {
+	status := s.addUsage(session, responseID, usage)
+	if status == nil {
+		return
+	}
+
+	switch {
+	case status.Exceeded:
+		log.Printf("Realtime quota exceeded for user %s (%s), closing session %s", session.UserID, status.Limit, session.ID)
+		s.sendToClientWithTimeout(session.ClientConn, map[string]interface{}{
+			"type":      "quota_exceeded",
+			"usage":     status,
+			"message":   "实时对话用量已达上限",
+			"timestamp": time.Now().UnixMilli(),
+		})
+		// 关闭客户端连接后消息循环退出，由 EndSession 关闭上游
+		session.ClientConn.Close()
+	case status.Warning && session.quotaWarned.CompareAndSwap(false, true):
+		s.sendToClientWithTimeout(session.ClientConn, map[string]interface{}{
+			"type":      "quota_warning",
+			"usage":     status,
+			"message":   "实时对话用量即将达到上限",
+			"timestamp": time.Now().UnixMilli(),
+		})
+	}
+}
+
+// flushUsage 会话结束时记录最后一轮响应之后的语音时长
+func (s *RealtimeService) flushUsage(session *RealtimeSession) {
+	s.addUsage(session, "", model.RealtimeUsage{})
+}
+
+// addUsage 把本轮 token 用量和自上次记录以来的语音时长计入用户用量并异步保存
+// 用量为零时不记录并返回 nil
+func (s *RealtimeService) addUsage(session *RealtimeSession, responseID string, usage model.RealtimeUsage) *RealtimeQuotaStatus {
+	usage.AudioInSeconds = float64(session.audioInMicros.Swap(0)) / 1e6
+	usage.AudioOutSeconds = float64(session.audioOutMicros.Swap(0)) / 1e6
+	if usage.AudioSeconds() == 0 && usage.TotalTokens() == 0 {
+		return nil
+	}
+
+	u := s.userUsageFor(session.UserID)
+	u.mu.Lock()
+	u.rollover(time.Now())
+	u.daily.Add(usage)
+	u.monthly.Add(usage)
+	status := s.quotaStatus(u)
+	u.mu.Unlock()
+
+	if s.realtimeRepo != nil {
+		record := &model.RealtimeUsageRecord{
+			SessionID:     session.ID,
+			UserID:        session.UserID,
+			ResponseID:    responseID,
+			RealtimeUsage: usage,
+		}
+		go func() {
+			if err := s.realtimeRepo.AddUsage(record); err != nil {
+				log.Printf("Failed to save realtime usage for session %s: %v", session.ID, err)
+			}
+		}()
+	}
+	return status
+}
+
+// maxRealtimeUsage 逐项取较大值
+func maxRealtimeUsage(a, b model.RealtimeUsage) model.RealtimeUsage {
+	return model.RealtimeUsage{
+		AudioInSeconds:    max(a.AudioInSeconds, b.AudioInSeconds),
+		AudioOutSeconds:   max(a.AudioOutSeconds, b.AudioOutSeconds),
+		InputTextTokens:   max(a.InputTextTokens, b.InputTextTokens),
+		InputAudioTokens:  max(a.InputAudioTokens, b.InputAudioTokens),
+		OutputTextTokens:  max(a.OutputTextTokens, b.OutputTextTokens),
+		OutputAudioTokens: max(a.OutputAudioTokens, b.OutputAudioTokens),
+	}
+}
+
+func (s *RealtimeService) userUsageFor(userID uuid.UUID) *userUsage {
+	u, _ := s.usage.LoadOrStore(userID, &userUsage{})
+	return u.(*userUsage)
+}
+
+// rollover 进入新的一天或新的一月时清零对应用量，调用方需持有 u.mu
+func (u *userUsage) rollover(now time.Time) {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	if !u.day.Equal(day) {
+		u.day = day
+		u.daily = model.RealtimeUsage{}
+	}
+	if !u.month.Equal(month) {
+		u.month = month
+		u.monthly = model.RealtimeUsage{}
+	}
+}
+
+// quotaStatus 计算各项配额的用量比例，调用方需持有 u.mu
+func (s *RealtimeService) quotaStatus(u *userUsage) *RealtimeQuotaStatus {
+	status := &RealtimeQuotaStatus{Daily: u.daily, Monthly: u.monthly, Quota: s.quota}
+
+	check := func(name string, used, limit float64) {
+		if limit <= 0 {
+			return
+		}
+		if ratio := used / limit; ratio > status.Ratio {
+			status.Ratio, status.Limit = ratio, name
+		}
+	}
+	check("daily_audio_seconds", u.daily.AudioSeconds(), s.quota.DailyAudioSeconds)
+	check("monthly_audio_seconds", u.monthly.AudioSeconds(), s.quota.MonthlyAudioSeconds)
+	check("daily_tokens", float64(u.daily.TotalTokens()), float64(s.quota.DailyTokens))
+	check("monthly_tokens", float64(u.monthly.TotalTokens()), float64(s.quota.MonthlyTokens))
+
+	status.Exceeded = status.Ratio >= 1
+	status.Warning = !status.Exceeded && status.Ratio >= s.quota.WarnRatio && status.Ratio > 0
+	return status
+}
+
+// parseResponseUsage 解析 response.done 中的 token 用量
+// 缺少明细时把输入和输出 token 都计为文本
+func parseResponseUsage(event map[string]interface{}) model.RealtimeUsage {
+	var usage model.RealtimeUsage
+	resp, _ := event["response"].(map[string]interface{})
+	raw, ok := resp["usage"].(map[string]interface{})
+	if !ok {
+		return usage
+	}
+
+	if details, ok := raw["input_token_details"].(map[string]interface{}); ok {
+		usage.InputTextTokens = intField(details, "text_tokens")
+		usage.InputAudioTokens = intField(details, "audio_tokens")
+	} else {
+		usage.InputTextTokens = intField(raw, "input_tokens")
+	}
+	if details, ok := raw["output_token_details"].(map[string]interface{}); ok {
+		usage.OutputTextTokens = intField(details, "text_tokens")
+		usage.OutputAudioTokens = intField(details, "audio_tokens")
+	} else {
+		usage.OutputTextTokens = intField(raw, "output_tokens")
+	}
+	return usage
+}
+
+// intField 读取 JSON 解码后的数字字段
+func intField(m map[string]interface{}, key string) int {
+	if v, ok := m[key].(float64); ok && v > 0 {
+		return int(v)
+	}
+	return 0
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"database/sql/driver"
+	"errors"
+	"smart-glasses-backend/internal/model"
+	"smart-glasses-backend/internal/repository"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParseResponseUsage(t *testing.T) {
+	detailed := parseResponseUsage(map[string]interface{}{
+		"type": "response.done",
+		"response": map[string]interface{}{
+			"usage": map[string]interface{}{
+				"input_tokens":         float64(30),
+				"output_tokens":        float64(50),
+				"input_token_details":  map[string]interface{}{"text_tokens": float64(10), "audio_tokens": float64(20)},
+				"output_token_details": map[string]interface{}{"text_tokens": float64(5), "audio_tokens": float64(45)},
+			},
+		},
+	})
+	if detailed.InputTextTokens != 10 || detailed.InputAudioTokens != 20 ||
+		detailed.OutputTextTokens != 5 || detailed.OutputAudioTokens != 45 {
+		t.Errorf("Unexpected detailed usage: %+v", detailed)
+	}
+
+	// 没有明细时全部计为文本
+	plain := parseResponseUsage(map[string]interface{}{
+		"response": map[string]interface{}{
+			"usage": map[string]interface{}{"input_tokens": float64(7), "output_tokens": float64(9)},
+		},
+	})
+	if plain.InputTextTokens != 7 || plain.OutputTextTokens != 9 || plain.TotalTokens() != 16 {
+		t.Errorf("Unexpected plain usage: %+v", plain)
+	}
+
+	if empty := parseResponseUsage(map[string]interface{}{"type": "response.done"}); empty.TotalTokens() != 0 {
+		t.Errorf("Expected no usage, got %+v", empty)
+	}
+}
+
+func TestRealtimeUsage_WarnsNearQuota(t *testing.T) {
+	service := NewRealtimeService("test-key", "", "test", "test")
+	// greeting.yaml 的回复约 1.3 秒语音
+	service.SetQuota(RealtimeQuota{DailyAudioSeconds: 1.5})
+	_, session, client := startFakeSession(t, service, "greeting.yaml")
+
+	if err := service.SendSessionAudio(session, make([]byte, 48*100)); err != nil {
+		t.Fatalf("SendSessionAudio failed: %v", err)
+	}
+	if err := service.CommitAudioBuffer(session.Upstream()); err != nil {
+		t.Fatalf("CommitAudioBuffer failed: %v", err)
+	}
+
+	messages := readClientMessages(t, client, "quota_warning")
+	status, _ := messages["quota_warning"]["usage"].(map[string]interface{})
+	if status["limit"] != "daily_audio_seconds" || status["warning"] != true {
+		t.Errorf("Unexpected quota status: %v", status)
+	}
+
+	usage := service.GetRealtimeUsage(session.UserID)
+	if usage.Daily.AudioInSeconds != 0.1 {
+		t.Errorf("Expected 0.1s audio in, got %v", usage.Daily.AudioInSeconds)
+	}
+	if usage.Daily.AudioOutSeconds < 1.2 || usage.Daily.OutputAudioTokens == 0 {
+		t.Errorf("Expected output audio to be metered, got %+v", usage.Daily)
+	}
+	if _, err := service.CheckRealtimeQuota(session.UserID); err != nil {
+		t.Errorf("Expected quota to allow new sessions, got %v", err)
+	}
+}
+
+func TestRealtimeUsage_ClosesSessionWhenQuotaExceeded(t *testing.T) {
+	service := NewRealtimeService("test-key", "", "test", "test")
+	service.SetQuota(RealtimeQuota{DailyAudioSeconds: 1})
+	_, session, client := startFakeSession(t, service, "greeting.yaml")
+
+	if err := service.CommitAudioBuffer(session.Upstream()); err != nil {
+		t.Fatalf("CommitAudioBuffer failed: %v", err)
+	}
+	readClientMessages(t, client, "quota_exceeded")
+
+	// 服务端关闭了客户端连接
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := client.ReadMessage(); err == nil {
+		t.Error("Expected client connection to be closed")
+	}
+
+	if _, err := service.CheckRealtimeQuota(session.UserID); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded, got %v", err)
+	}
+	if _, err := service.CheckRealtimeQuota(uuid.New()); err != nil {
+		t.Errorf("Expected other users to be unaffected, got %v", err)
+	}
+}
+
+func TestRealtimeUsage_ClosesStreamingSessionWithoutResponse(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+	service.SetQuota(RealtimeQuota{DailyAudioSeconds: 4})
+
+	clientConn, client := wsPair(t)
+	gptConn, _ := wsPair(t)
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, nil)
+	t.Cleanup(func() { service.EndSession(session) })
+
+	// 只发音频不触发回复，上行语音累计到检查间隔时按配额结束会话
+	chunk := make([]byte, RealtimeSampleRate/10*2)
+	for i := 0; i < 50; i++ {
+		if err := service.SendSessionAudio(session, chunk); err != nil {
+			t.Fatalf("SendSessionAudio failed: %v", err)
+		}
+	}
+	readClientMessages(t, client, "quota_exceeded")
+
+	status, err := service.CheckRealtimeQuota(session.UserID)
+	if !errors.Is(err, ErrQuotaExceeded) || status.Daily.AudioInSeconds != 5 {
+		t.Errorf("Expected 5s of audio to exceed the quota, got %+v (%v)", status.Daily, err)
+	}
+}
+
+func TestGetRealtimeUsage_KeepsUnsavedUsage(t *testing.T) {
+	// 汇总查询返回 stored，写入直接丢弃，模拟异步写入尚未落库
+	var stored model.RealtimeUsage
+	db := openScriptedDB(t, func(query string, args []driver.Value) ([][]driver.Value, error) {
+		if !strings.HasPrefix(query, "SELECT") {
+			return nil, nil
+		}
+		return [][]driver.Value{{
+			stored.AudioInSeconds, stored.AudioOutSeconds,
+			int64(stored.InputTextTokens), int64(stored.InputAudioTokens), int64(stored.OutputTextTokens), int64(stored.OutputAudioTokens),
+		}}, nil
+	})
+	service := NewRealtimeService("test", "test", "test", "test")
+	service.SetRealtimeRepository(repository.NewRealtimeRepository(db))
+	service.SetQuota(RealtimeQuota{DailyAudioSeconds: 60})
+
+	// 会话刚结束，用量还没写入数据库
+	session := &RealtimeSession{ID: uuid.New(), UserID: uuid.New()}
+	session.audioInMicros.Store(61 * 1e6)
+	service.flushUsage(session)
+	if _, err := service.CheckRealtimeQuota(session.UserID); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected in-flight usage to count against the quota, got %v", err)
+	}
+
+	// 其他实例写入的用量更多时以数据库为准
+	stored = model.RealtimeUsage{AudioInSeconds: 61, AudioOutSeconds: 30, OutputAudioTokens: 500}
+	usage := service.GetRealtimeUsage(session.UserID)
+	if usage.Daily.AudioSeconds() != 91 || usage.Monthly.OutputAudioTokens != 500 {
+		t.Errorf("Expected database totals to be merged, got %+v %+v", usage.Daily, usage.Monthly)
+	}
+}
+
+func TestUserUsage_Rollover(t *testing.T) {
+	u := &userUsage{}
+	now := time.Date(2026, 3, 31, 23, 59, 0, 0, time.Local)
+	u.rollover(now)
+	u.daily.InputTextTokens = 10
+	u.monthly.InputTextTokens = 10
+
+	u.rollover(now.Add(30 * time.Second))
+	if u.daily.InputTextTokens != 10 {
+		t.Errorf("Usage should not reset within the same day")
+	}
+
+	u.rollover(now.Add(2 * time.Minute))
+	if u.daily.InputTextTokens != 0 || u.monthly.InputTextTokens != 0 {
+		t.Errorf("Expected daily and monthly usage to reset, got %+v %+v", u.daily, u.monthly)
+	}
+}
@@ -0,0 +1,620 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"smart-glasses-backend/internal/model"
+	"smart-glasses-backend/internal/repository"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 录音音频格式
+const (
+	RecordingFormatWAV  = "wav"
+	RecordingFormatOpus = "opus"
+)
+
+// 录音参数
+const (
+	defaultRecordingRetention = 30 * 24 * time.Hour
+	recordingPurgeInterval    = time.Hour
+	recordingUploadTimeout    = 10 * time.Minute
+	maxRecordingSeconds       = 2 * 60 * 60 // 每个方向最多录制的语音时长，超出部分丢弃
+	recordingTranscriptFile   = "transcript.json"
+	recordingEncryptedSuffix  = ".enc"
+)
+
+// 录音的两个方向，按上游格式解码并重采样为 24kHz 单声道 PCM16 后分别保存
+const (
+	recordingInbound  = 0 // 用户上行语音，即发给上游的音频
+	recordingOutbound = 1 // 助手语音
+)
+
+var recordingTrackNames = [2]string{"inbound", "outbound"}
+
+// RecordingConfig 会话录音配置
+type RecordingConfig struct {
+	Format        string        // wav 或 opus，opus 需要 -tags opus 构建
+	Retention     time.Duration // 录音保存时长，到期自动删除
+	EncryptionKey []byte        // AES-256 密钥，录音和转写都加密后再写入存储
+	SpoolDir      string        // 会话进行中的临时文件目录，默认为系统临时目录；不能与其他运行中的实例共用
+}
+
+// RecordingInfo 用户一次会话的录音
+type RecordingInfo struct {
+	SessionID uuid.UUID       `json:"session_id"`
+	Files     []RecordingFile `json:"files"`
+	CreatedAt time.Time       `json:"created_at"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// RecordingFile 录音中的一个文件，Size 为加密后的大小
+type RecordingFile struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// recordingTranscript 与录音一起保存的转写文件
+type recordingTranscript struct {
+	SessionID  uuid.UUID                 `json:"session_id"`
+	UserID     uuid.UUID                 `json:"user_id"`
+	StartedAt  time.Time                 `json:"started_at"`
+	EndedAt    time.Time                 `json:"ended_at"`
+	Format     string                    `json:"format"`
+	SampleRate int                       `json:"sample_rate"`
+	Tracks     map[string]recordingTrack `json:"tracks"`
+	Turns      []recordingTurn           `json:"turns"`
+}
+
+type recordingTrack struct {
+	File       string  `json:"file"`
+	DurationMs float64 `json:"duration_ms"`
+	Truncated  bool    `json:"truncated,omitempty"`
+}
+
+type recordingTurn struct {
+	Role     string `json:"role"`
+	ItemID   string `json:"item_id,omitempty"`
+	Text     string `json:"text"`
+	OffsetMs int64  `json:"offset_ms"` // 相对录音开始的时间
+}
+
+// RecordingService 管理用户的录音同意、会话录音的加密保存和到期清理
+type RecordingService struct {
+	store RecordingStore
+	cfg   RecordingConfig
+	repo  *repository.RecordingRepository
+
+	consents sync.Map // 未配置数据库时在内存中保存同意，uuid.UUID -> time.Time
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	pending sync.WaitGroup // 正在编码上传的录音
+}
+
+// NewRecordingService 创建录音服务，需要 32 字节的加密密钥
+func NewRecordingService(store RecordingStore, cfg RecordingConfig) (*RecordingService, error) {
+	if store == nil {
+		return nil, errors.New("recording store is required")
+	}
+	if _, err := newRecordingCipher(cfg.EncryptionKey); err != nil {
+		return nil, err
+	}
+	switch cfg.Format {
+	case "":
+		cfg.Format = RecordingFormatWAV
+	case RecordingFormatWAV:
+	case RecordingFormatOpus:
+		if !OpusEncodingAvailable() {
+			return nil, errors.New("opus recording requires a build with -tags opus")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported recording format %q", cfg.Format)
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = defaultRecordingRetention
+	}
+	if cfg.SpoolDir == "" {
+		cfg.SpoolDir = os.TempDir()
+	}
+	removeStaleSpool(cfg.SpoolDir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &RecordingService{store: store, cfg: cfg, ctx: ctx, cancel: cancel}, nil
+}
+
+// SetRecordingRepository 设置同意记录的存储，未设置时只保存在内存中
+func (rs *RecordingService) SetRecordingRepository(repo *repository.RecordingRepository) {
+	rs.repo = repo
+}
+
+// Retention 录音保存时长
+func (rs *RecordingService) Retention() time.Duration {
+	return rs.cfg.Retention
+}
+
+// GetConsent 获取用户的录音同意，未同意时返回 nil
+func (rs *RecordingService) GetConsent(userID uuid.UUID) (*model.RecordingConsent, error) {
+	if rs.repo != nil {
+		return rs.repo.GetConsent(userID)
+	}
+	if at, ok := rs.consents.Load(userID); ok {
+		return &model.RecordingConsent{UserID: userID, ConsentedAt: at.(time.Time)}, nil
+	}
+	return nil, nil
+}
+
+// GrantConsent 记录用户同意录制之后的会话
+func (rs *RecordingService) GrantConsent(userID uuid.UUID) (*model.RecordingConsent, error) {
+	consent := &model.RecordingConsent{UserID: userID, ConsentedAt: time.Now()}
+	if rs.repo != nil {
+		if err := rs.repo.SetConsent(consent); err != nil {
+			return nil, err
+		}
+		return consent, nil
+	}
+	rs.consents.Store(userID, consent.ConsentedAt)
+	return consent, nil
+}
+
+// RevokeConsent 撤回同意并删除用户已保存的全部录音，返回删除的会话数
+// 进行中的录音在会话结束时发现同意已撤回，不会上传；正在上传的录音在上传后复查同意并自行删除
+func (rs *RecordingService) RevokeConsent(userID uuid.UUID) (int, error) {
+	if rs.repo != nil {
+		if err := rs.repo.DeleteConsent(userID); err != nil {
+			return 0, err
+		}
+	} else {
+		rs.consents.Delete(userID)
+	}
+
+	recordings, err := rs.ListRecordings(userID)
+	if err != nil {
+		return 0, err
+	}
+	for _, rec := range recordings {
+		prefix := recordingPrefix(userID, rec.SessionID)
+		for _, f := range rec.Files {
+			if err := rs.store.Delete(rs.ctx, prefix+f.Name+recordingEncryptedSuffix); err != nil {
+				return 0, err
+			}
+		}
+	}
+	log.Printf("Recording consent revoked for user %s, deleted %d recordings", userID, len(recordings))
+	return len(recordings), nil
+}
+
+// HasConsent 用户当前是否同意录音，查询失败时视为未同意
+func (rs *RecordingService) HasConsent(userID uuid.UUID) bool {
+	consent, err := rs.GetConsent(userID)
+	if err != nil {
+		log.Printf("Failed to load recording consent for user %s: %v", userID, err)
+		return false
+	}
+	return consent != nil
+}
+
+// recordingPrefix 一次会话的录音在存储中的目录
+func recordingPrefix(userID, sessionID uuid.UUID) string {
+	return userID.String() + "/" + sessionID.String() + "/"
+}
+
+// parseRecordingKey 解析 <用户ID>/<会话ID>/<文件名>.enc 形式的存储路径，其他对象不属于录音
+func parseRecordingKey(key string) (userID, sessionID uuid.UUID, name string, ok bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 3 || !strings.HasSuffix(parts[2], recordingEncryptedSuffix) {
+		return uuid.Nil, uuid.Nil, "", false
+	}
+	name = strings.TrimSuffix(parts[2], recordingEncryptedSuffix)
+	userID, err := uuid.Parse(parts[0])
+	if err != nil || name == "" {
+		return uuid.Nil, uuid.Nil, "", false
+	}
+	if sessionID, err = uuid.Parse(parts[1]); err != nil {
+		return uuid.Nil, uuid.Nil, "", false
+	}
+	return userID, sessionID, name, true
+}
+
+// ListRecordings 按时间倒序列出用户的录音
+func (rs *RecordingService) ListRecordings(userID uuid.UUID) ([]*RecordingInfo, error) {
+	objects, err := rs.store.List(rs.ctx, userID.String()+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	bySession := make(map[uuid.UUID]*RecordingInfo)
+	for _, obj := range objects {
+		_, sessionID, name, ok := parseRecordingKey(obj.Key)
+		if !ok {
+			continue
+		}
+		info, ok := bySession[sessionID]
+		if !ok {
+			info = &RecordingInfo{SessionID: sessionID, CreatedAt: obj.LastModified}
+			bySession[sessionID] = info
+		}
+		if obj.LastModified.Before(info.CreatedAt) {
+			info.CreatedAt = obj.LastModified
+		}
+		info.Files = append(info.Files, RecordingFile{
+			Name: name,
+			Size: obj.Size,
+		})
+	}
+
+	recordings := make([]*RecordingInfo, 0, len(bySession))
+	for _, info := range bySession {
+		info.ExpiresAt = info.CreatedAt.Add(rs.cfg.Retention)
+		recordings = append(recordings, info)
+	}
+	sort.Slice(recordings, func(i, j int) bool { return recordings[i].CreatedAt.After(recordings[j].CreatedAt) })
+	return recordings, nil
+}
+
+// OpenRecording 打开一个录音文件并解密，调用方负责关闭
+func (rs *RecordingService) OpenRecording(userID, sessionID uuid.UUID, name string) (io.ReadCloser, error) {
+	if name == "" || strings.Contains(name, "/") {
+		return nil, ErrRecordingNotFound
+	}
+	body, err := rs.store.Get(rs.ctx, recordingPrefix(userID, sessionID)+name+recordingEncryptedSuffix)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := newRecordingDecrypter(body, rs.cfg.EncryptionKey)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{plain, body}, nil
+}
+
+// Purge 删除超过保存时长的录音文件，返回删除的文件数
+// 只处理录音路径形式的对象，存储中的其他对象不受影响
+func (rs *RecordingService) Purge(now time.Time) (int, error) {
+	objects, err := rs.store.List(rs.ctx, "")
+	if err != nil {
+		return 0, err
+	}
+	cutoff := now.Add(-rs.cfg.Retention)
+	deleted := 0
+	for _, obj := range objects {
+		if _, _, _, ok := parseRecordingKey(obj.Key); !ok || !obj.LastModified.Before(cutoff) {
+			continue
+		}
+		if err := rs.store.Delete(rs.ctx, obj.Key); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		log.Printf("Purged %d recording files older than %v", deleted, rs.cfg.Retention)
+	}
+	return deleted, nil
+}
+
+// StartPurge 启动后台清理任务，立即清理一次，之后每小时清理一次
+func (rs *RecordingService) StartPurge() {
+	go func() {
+		ticker := time.NewTicker(recordingPurgeInterval)
+		defer ticker.Stop()
+		for {
+			if _, err := rs.Purge(time.Now()); err != nil {
+				log.Printf("Failed to purge expired recordings: %v", err)
+			}
+			select {
+			case <-rs.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Shutdown 等待已结束会话的录音上传完成并停止清理任务，由调用方在退出前调用
+// 进程未经 Shutdown 退出时，未上传的临时文件在下次 NewRecordingService 时删除
+func (rs *RecordingService) Shutdown() {
+	rs.pending.Wait()
+	rs.cancel()
+}
+
+// sessionRecording 一次会话进行中的录音
+// 音频先以随机的临时密钥加密写入本地临时文件，会话结束后再编码、以配置的密钥加密并上传
+type sessionRecording struct {
+	mu        sync.Mutex
+	sessionID uuid.UUID
+	userID    uuid.UUID
+	startedAt time.Time
+	dir       string
+	spoolKey  []byte
+	tracks    [2]*spoolTrack
+	turns     []recordingTurn
+	closed    bool
+}
+
+// spoolTrack 一个方向的临时录音文件
+type spoolTrack struct {
+	path      string
+	file      *os.File
+	enc       io.WriteCloser
+	bytes     int64
+	resampler *Resampler // 上游为 G.711 时 8kHz -> 24kHz
+	truncated bool
+	err       error
+}
+
+// removeStaleSpool 删除上次进程退出时遗留的会话临时目录
+// 临时文件以只保存在内存中的随机密钥加密，进程退出后无法再上传
+func removeStaleSpool(spoolDir string) {
+	dirs, _ := filepath.Glob(filepath.Join(spoolDir, "recording-*"))
+	removed := 0
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("Failed to remove stale recording spool %s: %v", dir, err)
+			continue
+		}
+		removed++
+	}
+	if removed > 0 {
+		log.Printf("Removed %d stale recording spool directories", removed)
+	}
+}
+
+// start 为会话创建临时录音文件
+func (rs *RecordingService) start(sessionID, userID uuid.UUID) (*sessionRecording, error) {
+	dir, err := os.MkdirTemp(rs.cfg.SpoolDir, "recording-"+sessionID.String()+"-")
+	if err != nil {
+		return nil, err
+	}
+	rec := &sessionRecording{
+		sessionID: sessionID,
+		userID:    userID,
+		startedAt: time.Now(),
+		dir:       dir,
+		spoolKey:  make([]byte, recordingEncryptionKey),
+	}
+	if _, err := rand.Read(rec.spoolKey); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	for i, name := range recordingTrackNames {
+		track := &spoolTrack{path: dir + "/" + name + ".pcm"}
+		if track.file, err = os.OpenFile(track.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600); err == nil {
+			track.enc, err = newRecordingEncrypter(track.file, rec.spoolKey)
+		}
+		if err != nil {
+			rec.close()
+			os.RemoveAll(dir)
+			return nil, err
+		}
+		rec.tracks[i] = track
+	}
+	return rec, nil
+}
+
+// write 追加一个方向的 PCM16 单声道音频，非 24kHz 时先重采样
+func (r *sessionRecording) write(direction int, pcm []byte, rate int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	track := r.tracks[direction]
+	if r.closed || track.err != nil || track.truncated || len(pcm) == 0 {
+		return
+	}
+
+	if rate != RealtimeSampleRate {
+		if track.resampler == nil || track.resampler.InputRate() != rate {
+			resampler, err := NewResampler(rate, RealtimeSampleRate)
+			if err != nil {
+				track.err = err
+				return
+			}
+			track.resampler = resampler
+		}
+		pcm = track.resampler.Process(pcm)
+	}
+	if track.bytes+int64(len(pcm)) > maxRecordingSeconds*RealtimeSampleRate*2 {
+		track.truncated = true
+		log.Printf("Recording %s of session %s reached %ds, dropping further audio", recordingTrackNames[direction], r.sessionID, maxRecordingSeconds)
+		return
+	}
+	if _, err := track.enc.Write(pcm); err != nil {
+		track.err = err
+		log.Printf("Failed to write recording for session %s: %v", r.sessionID, err)
+		return
+	}
+	track.bytes += int64(len(pcm))
+}
+
+// addTurn 记录一条转写及其相对录音开始的时间
+func (r *sessionRecording) addTurn(role, itemID, text string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	r.turns = append(r.turns, recordingTurn{
+		Role:     role,
+		ItemID:   itemID,
+		Text:     text,
+		OffsetMs: time.Since(r.startedAt).Milliseconds(),
+	})
+}
+
+// close 停止录音并关闭临时文件，之后的写入被忽略
+func (r *sessionRecording) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	r.closed = true
+	for _, track := range r.tracks {
+		if track == nil {
+			continue
+		}
+		if track.enc != nil {
+			if err := track.enc.Close(); err != nil && track.err == nil {
+				track.err = err
+			}
+		}
+		if err := track.file.Close(); err != nil && track.err == nil {
+			track.err = err
+		}
+	}
+}
+
+// stop 停止录音并在后台保存，Shutdown 会等待保存完成
+func (rs *RecordingService) stop(rec *sessionRecording) {
+	rec.close()
+	rs.pending.Add(1)
+	go func() {
+		defer rs.pending.Done()
+		rs.finish(rec)
+	}()
+}
+
+// finish 编码并加密上传两个方向的音频和转写，随后删除临时文件
+// 会话期间用户撤回了同意时直接丢弃；上传期间撤回时 RevokeConsent 可能列不到正在上传的文件，上传后复查并删除
+func (rs *RecordingService) finish(rec *sessionRecording) {
+	rec.close()
+	endedAt := time.Now()
+	defer os.RemoveAll(rec.dir)
+
+	if !rs.HasConsent(rec.userID) {
+		log.Printf("Recording consent for user %s was revoked, discarding recording of session %s", rec.userID, rec.sessionID)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), recordingUploadTimeout)
+	defer cancel()
+	prefix := recordingPrefix(rec.userID, rec.sessionID)
+	var uploaded []string
+	defer func() {
+		if len(uploaded) == 0 || rs.HasConsent(rec.userID) {
+			return
+		}
+		log.Printf("Recording consent for user %s was revoked during upload, deleting recording of session %s", rec.userID, rec.sessionID)
+		for _, key := range uploaded {
+			if err := rs.store.Delete(ctx, key); err != nil {
+				log.Printf("Failed to delete %s after consent was revoked: %v", key, err)
+			}
+		}
+	}()
+	transcript := recordingTranscript{
+		SessionID:  rec.sessionID,
+		UserID:     rec.userID,
+		StartedAt:  rec.startedAt,
+		EndedAt:    endedAt,
+		Format:     rs.cfg.Format,
+		SampleRate: RealtimeSampleRate,
+		Tracks:     make(map[string]recordingTrack),
+		Turns:      rec.turns,
+	}
+	if transcript.Turns == nil {
+		transcript.Turns = []recordingTurn{}
+	}
+
+	for i, track := range rec.tracks {
+		if track.err != nil {
+			log.Printf("Discarding %s recording of session %s: %v", recordingTrackNames[i], rec.sessionID, track.err)
+			continue
+		}
+		if track.bytes == 0 {
+			continue
+		}
+		name := recordingTrackNames[i] + "." + rs.cfg.Format
+		err := rs.upload(ctx, rec.dir, prefix+name, func(w io.Writer) error {
+			return rs.encodeTrack(w, track, rec.spoolKey)
+		})
+		if err != nil {
+			log.Printf("Failed to save %s for session %s: %v", name, rec.sessionID, err)
+			continue
+		}
+		uploaded = append(uploaded, prefix+name+recordingEncryptedSuffix)
+		transcript.Tracks[recordingTrackNames[i]] = recordingTrack{
+			File:       name,
+			DurationMs: float64(track.bytes) / realtimeAudioBytesPerMs,
+			Truncated:  track.truncated,
+		}
+	}
+
+	err := rs.upload(ctx, rec.dir, prefix+recordingTranscriptFile, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(transcript)
+	})
+	if err != nil {
+		log.Printf("Failed to save recording transcript for session %s: %v", rec.sessionID, err)
+		return
+	}
+	uploaded = append(uploaded, prefix+recordingTranscriptFile+recordingEncryptedSuffix)
+	log.Printf("Saved recording of session %s (%d tracks, %d turns)", rec.sessionID, len(transcript.Tracks), len(transcript.Turns))
+}
+
+// encodeTrack 解密临时文件并编码为配置的格式
+func (rs *RecordingService) encodeTrack(w io.Writer, track *spoolTrack, spoolKey []byte) error {
+	f, err := os.Open(track.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	pcm, err := newRecordingDecrypter(f, spoolKey)
+	if err != nil {
+		return err
+	}
+
+	if rs.cfg.Format == RecordingFormatOpus {
+		return encodeOggOpus(w, pcm, RealtimeSampleRate)
+	}
+	if err := writeWAVHeader(w, RealtimeSampleRate, 1, uint32(track.bytes)); err != nil {
+		return err
+	}
+	_, err = io.Copy(w, pcm)
+	return err
+}
+
+// upload 把 write 写出的内容加密到临时文件，再以已知大小上传
+func (rs *RecordingService) upload(ctx context.Context, dir, name string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(dir, "upload-*")
+	if err != nil {
+		return err
+	}
+	defer tmp.Close()
+
+	enc, err := newRecordingEncrypter(tmp, rs.cfg.EncryptionKey)
+	if err != nil {
+		return err
+	}
+	if err := write(enc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return rs.store.Put(ctx, name+recordingEncryptedSuffix, tmp, size)
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 录音加密格式：魔数(4) 随机 nonce 前缀(8)，之后为若干段，每段为密文长度(4，大端)和 AES-256-GCM 密文
+// 每段明文最多 64KB，nonce 为前缀加段序号，附加数据标记是否为最后一段，截断或拼接的文件无法解密
+const (
+	recordingCryptoMagic   = "SGR1"
+	recordingNoncePrefix   = 8
+	recordingSegmentSize   = 64 * 1024
+	recordingEncryptionKey = 32
+)
+
+// ErrRecordingCorrupted 录音密文被截断、篡改或密钥不匹配
+var ErrRecordingCorrupted = errors.New("recording is corrupted or the key does not match")
+
+// newRecordingCipher 以 32 字节密钥创建 AES-256-GCM
+func newRecordingCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) != recordingEncryptionKey {
+		return nil, fmt.Errorf("recording encryption key must be %d bytes, got %d", recordingEncryptionKey, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// segmentNonce 由 nonce 前缀和段序号组成 12 字节 nonce
+func segmentNonce(prefix []byte, counter uint32) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[recordingNoncePrefix:], counter)
+	return nonce
+}
+
+// segmentAAD 最后一段的附加数据不同，防止截断后仍能解密
+func segmentAAD(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// recordingEncrypter 分段加密写入，Close 时写出最后一段
+type recordingEncrypter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+	closed  bool
+}
+
+// newRecordingEncrypter 写出文件头，返回的 Writer 必须 Close 才能得到完整的密文
+func newRecordingEncrypter(w io.Writer, key []byte) (io.WriteCloser, error) {
+	aead, err := newRecordingCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, recordingNoncePrefix)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append([]byte(recordingCryptoMagic), prefix...)); err != nil {
+		return nil, err
+	}
+	return &recordingEncrypter{w: w, aead: aead, prefix: prefix, buf: make([]byte, 0, recordingSegmentSize)}, nil
+}
+
+func (e *recordingEncrypter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write to closed recording encrypter")
+	}
+	written := 0
+	for len(p) > 0 {
+		// 缓冲区满且还有后续数据时才写出，保证最后一段在 Close 时写出
+		if len(e.buf) == recordingSegmentSize {
+			if err := e.writeSegment(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):recordingSegmentSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (e *recordingEncrypter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.writeSegment(true)
+}
+
+func (e *recordingEncrypter) writeSegment(final bool) error {
+	sealed := e.aead.Seal(nil, segmentNonce(e.prefix, e.counter), e.buf, segmentAAD(final))
+	e.counter++
+	e.buf = e.buf[:0]
+
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(sealed)))
+	if _, err := e.w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+// recordingDecrypter 逐段解密，读到最后一段后返回 io.EOF
+type recordingDecrypter struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	plain   bytes.Reader
+	done    bool
+}
+
+// newRecordingDecrypter 校验文件头，返回解密后的明文流
+func newRecordingDecrypter(r io.Reader, key []byte) (io.Reader, error) {
+	aead, err := newRecordingCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, len(recordingCryptoMagic)+recordingNoncePrefix)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(recordingCryptoMagic)]) != recordingCryptoMagic {
+		return nil, ErrRecordingCorrupted
+	}
+	return &recordingDecrypter{r: r, aead: aead, prefix: header[len(recordingCryptoMagic):]}, nil
+}
+
+func (d *recordingDecrypter) Read(p []byte) (int, error) {
+	for d.plain.Len() == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.readSegment(); err != nil {
+			return 0, err
+		}
+	}
+	return d.plain.Read(p)
+}
+
+func (d *recordingDecrypter) readSegment() error {
+	var header [4]byte
+	if _, err := io.ReadFull(d.r, header[:]); err != nil {
+		// 没有遇到最后一段就结束，文件被截断
+		return ErrRecordingCorrupted
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size < uint32(d.aead.Overhead()) || size > recordingSegmentSize+uint32(d.aead.Overhead()) {
+		return ErrRecordingCorrupted
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return ErrRecordingCorrupted
+	}
+
+	nonce := segmentNonce(d.prefix, d.counter)
+	plain, err := d.aead.Open(nil, nonce, sealed, segmentAAD(false))
+	if err != nil {
+		if plain, err = d.aead.Open(nil, nonce, sealed, segmentAAD(true)); err != nil {
+			return ErrRecordingCorrupted
+		}
+		// 最后一段之后不应再有数据
+		if n, _ := d.r.Read(make([]byte, 1)); n > 0 {
+			return ErrRecordingCorrupted
+		}
+		d.done = true
+	}
+	d.counter++
+	d.plain.Reset(plain)
+	return nil
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func testRecordingKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, recordingEncryptionKey)
+}
+
+func encryptRecording(t *testing.T, plain []byte, key []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc, err := newRecordingEncrypter(&buf, key)
+	if err != nil {
+		t.Fatalf("newRecordingEncrypter failed: %v", err)
+	}
+	// 分多次写入，跨越段边界
+	for len(plain) > 0 {
+		n := 10000
+		if n > len(plain) {
+			n = len(plain)
+		}
+		if _, err := enc.Write(plain[:n]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		plain = plain[n:]
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func decryptRecording(sealed []byte, key []byte) ([]byte, error) {
+	dec, err := newRecordingDecrypter(bytes.NewReader(sealed), key)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(dec)
+}
+
+func TestRecordingCrypto_RoundTrip(t *testing.T) {
+	key := testRecordingKey(7)
+	for _, size := range []int{0, 1, recordingSegmentSize, recordingSegmentSize + 1, 3*recordingSegmentSize + 123} {
+		plain := make([]byte, size)
+		rand.New(rand.NewSource(int64(size))).Read(plain)
+
+		sealed := encryptRecording(t, plain, key)
+		if size > 16 && bytes.Contains(sealed, plain[:16]) {
+			t.Errorf("size %d: ciphertext contains plaintext", size)
+		}
+		got, err := decryptRecording(sealed, key)
+		if err != nil {
+			t.Fatalf("size %d: decrypt failed: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("size %d: roundtrip mismatch, got %d bytes", size, len(got))
+		}
+	}
+
+	// 相同内容两次加密的密文不同
+	a := encryptRecording(t, []byte("hello"), key)
+	b := encryptRecording(t, []byte("hello"), key)
+	if bytes.Equal(a, b) {
+		t.Error("Expected a fresh nonce prefix for every file")
+	}
+}
+
+func TestRecordingCrypto_DetectsTampering(t *testing.T) {
+	key := testRecordingKey(7)
+	plain := make([]byte, 2*recordingSegmentSize+500)
+	sealed := encryptRecording(t, plain, key)
+	segment := 4 + recordingSegmentSize + 16 // 每段的长度头和密文
+
+	cases := map[string][]byte{
+		"wrong key":             nil,
+		"truncated at segment":  sealed[:len(recordingCryptoMagic)+recordingNoncePrefix+segment],
+		"truncated mid segment": sealed[:len(sealed)-10],
+		"flipped bit":           append([]byte(nil), sealed...),
+		"trailing data":         append(append([]byte(nil), sealed...), 0),
+		"bad magic":             append([]byte("XXXX"), sealed[4:]...),
+	}
+	cases["flipped bit"][100] ^= 1
+
+	for name, data := range cases {
+		k := key
+		if data == nil {
+			data, k = sealed, testRecordingKey(8)
+		}
+		if _, err := decryptRecording(data, k); !errors.Is(err, ErrRecordingCorrupted) {
+			t.Errorf("%s: expected ErrRecordingCorrupted, got %v", name, err)
+		}
+	}
+
+	if _, err := newRecordingEncrypter(io.Discard, []byte("short")); err == nil {
+		t.Error("Expected a short key to be rejected")
+	}
+}
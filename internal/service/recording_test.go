@@ -0,0 +1,479 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestRecordingService(t *testing.T) (*RecordingService, *LocalRecordingStore) {
+	t.Helper()
+	store, err := NewLocalRecordingStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalRecordingStore failed: %v", err)
+	}
+	rs, err := NewRecordingService(store, RecordingConfig{EncryptionKey: testRecordingKey(1), SpoolDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewRecordingService failed: %v", err)
+	}
+	return rs, store
+}
+
+// readRecording 解密读取一个录音文件
+func readRecording(t *testing.T, rs *RecordingService, userID, sessionID uuid.UUID, name string) []byte {
+	t.Helper()
+	body, err := rs.OpenRecording(userID, sessionID, name)
+	if err != nil {
+		t.Fatalf("OpenRecording %s failed: %v", name, err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("Failed to decrypt %s: %v", name, err)
+	}
+	return data
+}
+
+// fakeOpusEncoder 记录收到的帧，每个包为帧序号
+type fakeOpusEncoder struct {
+	frames [][]int16
+}
+
+func (e *fakeOpusEncoder) Encode(pcm []int16) ([]byte, error) {
+	e.frames = append(e.frames, append([]int16(nil), pcm...))
+	return []byte{0xFC, byte(len(e.frames))}, nil
+}
+
+func (e *fakeOpusEncoder) Close() {}
+
+func TestEncodeOggOpus(t *testing.T) {
+	encoder := &fakeOpusEncoder{}
+	saved := newOpusEncoder
+	newOpusEncoder = func(sampleRate, channels int) (opusEncoder, error) { return encoder, nil }
+	t.Cleanup(func() { newOpusEncoder = saved })
+
+	// 1 秒加 5ms：50 个整帧和 1 个补零的帧
+	pcm := generateTone(RealtimeSampleRate, 440, 8000, 1005, 1)
+	var out bytes.Buffer
+	if err := encodeOggOpus(&out, bytes.NewReader(pcm), RealtimeSampleRate); err != nil {
+		t.Fatalf("encodeOggOpus failed: %v", err)
+	}
+
+	stream, err := demuxOgg(out.Bytes())
+	if err != nil {
+		t.Fatalf("demuxOgg failed: %v", err)
+	}
+	head, err := parseOpusHead(stream.packets[0])
+	if err != nil {
+		t.Fatalf("parseOpusHead failed: %v", err)
+	}
+	if head.channels != 1 || head.preSkip != opusEncodePreSkip || head.inputRate != RealtimeSampleRate {
+		t.Errorf("Unexpected OpusHead %+v", head)
+	}
+	if !bytes.HasPrefix(stream.packets[1], []byte("OpusTags")) {
+		t.Errorf("Expected OpusTags as the second packet")
+	}
+
+	if len(encoder.frames) != 51 || len(stream.packets) != 2+51 {
+		t.Fatalf("Expected 51 frames and packets, got %d frames, %d packets", len(encoder.frames), len(stream.packets)-2)
+	}
+	for i, frame := range encoder.frames {
+		if len(frame) != 480 {
+			t.Fatalf("Frame %d: expected 480 samples, got %d", i, len(frame))
+		}
+	}
+	if last := encoder.frames[50]; last[119] == 0 || last[120] != 0 || last[479] != 0 {
+		t.Errorf("Expected the last frame to hold 120 samples followed by padding")
+	}
+	// 最后一页的 granule 为实际时长加编码延迟（48kHz）
+	if want := int64(len(pcm)/2)*2 + opusEncodePreSkip; stream.granule != want {
+		t.Errorf("Expected final granule %d, got %d", want, stream.granule)
+	}
+
+	// 末尾语音被编码延迟推到下一帧时多编一帧
+	encoder.frames = nil
+	out.Reset()
+	if err := encodeOggOpus(&out, bytes.NewReader(make([]byte, 480*2)), RealtimeSampleRate); err != nil {
+		t.Fatalf("encodeOggOpus failed: %v", err)
+	}
+	if len(encoder.frames) != 2 {
+		t.Errorf("Expected an extra frame to cover the pre-skip, got %d", len(encoder.frames))
+	}
+
+	newOpusEncoder = nil
+	if err := encodeOggOpus(io.Discard, bytes.NewReader(pcm), RealtimeSampleRate); err == nil {
+		t.Error("Expected an error without an Opus encoder")
+	}
+}
+
+func TestSessionRecording_EndToEnd(t *testing.T) {
+	rs, store := newTestRecordingService(t)
+	service := NewRealtimeService("test", "test", "test", "test")
+	service.SetRecordingService(rs)
+
+	clientConn, client := wsPair(t)
+	gptConn, _ := wsPair(t)
+	cfg := DefaultRealtimeSessionConfig()
+	cfg.AudioFormat = string(FormatG711ULaw)
+	cfg.SampleRate = 8000
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, cfg)
+
+	// 未同意时不录音
+	recordingCfg := *cfg
+	recordingCfg.Recording = true
+	if err := service.CheckRecording(session, &recordingCfg); !errors.Is(err, ErrRecordingConsentRequired) {
+		t.Fatalf("Expected ErrRecordingConsentRequired, got %v", err)
+	}
+	service.UpdateSessionConfig(session, &recordingCfg)
+	if session.recording.Load() != nil {
+		t.Fatal("Expected no recording without consent")
+	}
+
+	if _, err := rs.GrantConsent(session.UserID); err != nil {
+		t.Fatalf("GrantConsent failed: %v", err)
+	}
+	if err := service.CheckRecording(session, &recordingCfg); err != nil {
+		t.Fatalf("Expected recording to be allowed, got %v", err)
+	}
+	service.UpdateSessionConfig(session, &recordingCfg)
+	started := readClientMessages(t, client, "recording_started")["recording_started"]
+	if started["format"] != RecordingFormatWAV || started["retention_days"] != float64(30) {
+		t.Errorf("Unexpected recording_started %v", started)
+	}
+
+	// 上行 1 秒 G.711，下行 0.5 秒，解码并重采样为 24kHz 保存
+	inbound := encodeG711(generateTone(8000, 440, 9000, 1000, 1), FormatG711ULaw)
+	for i := 0; i < len(inbound); i += 160 {
+		if err := service.SendSessionAudio(session, inbound[i:i+160]); err != nil {
+			t.Fatalf("SendSessionAudio failed: %v", err)
+		}
+	}
+	outbound := encodeG711(generateTone(8000, 880, 9000, 500, 1), FormatG711ULaw)
+	service.recordOutputAudio(session, base64.StdEncoding.EncodeToString(outbound))
+	service.handleUserTranscript(session, "item_user", "现在几点？")
+	service.handleAssistantTranscript(session, "item_assistant", "现在三点。")
+
+	service.EndSession(session)
+	rs.pending.Wait()
+
+	recordings, err := rs.ListRecordings(session.UserID)
+	if err != nil || len(recordings) != 1 {
+		t.Fatalf("Expected one recording, got %v (%v)", recordings, err)
+	}
+	var names []string
+	for _, f := range recordings[0].Files {
+		names = append(names, f.Name)
+	}
+	if strings.Join(names, ",") != "inbound.wav,outbound.wav,transcript.json" {
+		t.Errorf("Unexpected recording files %v", names)
+	}
+	if d := recordings[0].ExpiresAt.Sub(recordings[0].CreatedAt); d != defaultRecordingRetention {
+		t.Errorf("Expected expiry after the retention period, got %v", d)
+	}
+
+	// 存储中只有密文
+	raw, err := store.Get(context.Background(), recordingPrefix(session.UserID, session.ID)+"inbound.wav.enc")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	sealed, _ := io.ReadAll(raw)
+	raw.Close()
+	if !bytes.HasPrefix(sealed, []byte(recordingCryptoMagic)) || bytes.Contains(sealed, []byte("RIFF")) {
+		t.Error("Expected the stored recording to be encrypted")
+	}
+
+	for name, tc := range map[string]struct {
+		freq       float64
+		durationMs float64
+	}{"inbound.wav": {440, 1000}, "outbound.wav": {880, 500}} {
+		format, data, err := parseWAV(readRecording(t, rs, session.UserID, session.ID, name))
+		if err != nil {
+			t.Fatalf("%s: parseWAV failed: %v", name, err)
+		}
+		if format.sampleRate != RealtimeSampleRate || format.channels != 1 {
+			t.Errorf("%s: expected 24kHz mono, got %+v", name, format)
+		}
+		if ms := float64(len(data)) / realtimeAudioBytesPerMs; math.Abs(ms-tc.durationMs) > 5 {
+			t.Errorf("%s: expected about %.0fms, got %.1fms", name, tc.durationMs, ms)
+		}
+		samples := pcmSamples(data)
+		if amp, snr := analyzeTone(samples[len(samples)/4:], RealtimeSampleRate, tc.freq); math.Abs(amp-9000) > 500 || snr < 25 {
+			t.Errorf("%s: expected a %.0fHz tone, got amplitude %.3f, SNR %.1fdB", name, tc.freq, amp, snr)
+		}
+	}
+
+	var transcript recordingTranscript
+	if err := json.Unmarshal(readRecording(t, rs, session.UserID, session.ID, "transcript.json"), &transcript); err != nil {
+		t.Fatalf("Invalid transcript: %v", err)
+	}
+	if transcript.SessionID != session.ID || transcript.SampleRate != RealtimeSampleRate || len(transcript.Turns) != 2 {
+		t.Fatalf("Unexpected transcript %+v", transcript)
+	}
+	if turn := transcript.Turns[1]; turn.Role != "assistant" || turn.ItemID != "item_assistant" || turn.Text != "现在三点。" {
+		t.Errorf("Unexpected assistant turn %+v", turn)
+	}
+	if track := transcript.Tracks["inbound"]; track.File != "inbound.wav" || math.Abs(track.DurationMs-1000) > 5 {
+		t.Errorf("Unexpected inbound track %+v", track)
+	}
+
+	// 临时文件已删除
+	if entries, _ := os.ReadDir(rs.cfg.SpoolDir); len(entries) != 0 {
+		t.Errorf("Expected spool directory to be empty, got %d entries", len(entries))
+	}
+
+	if _, err := rs.OpenRecording(session.UserID, session.ID, "../transcript.json"); !errors.Is(err, ErrRecordingNotFound) {
+		t.Errorf("Expected invalid names to be rejected, got %v", err)
+	}
+	if _, err := rs.OpenRecording(uuid.New(), session.ID, "inbound.wav"); !errors.Is(err, ErrRecordingNotFound) {
+		t.Errorf("Expected other users' recordings to be hidden, got %v", err)
+	}
+}
+
+func TestSessionRecording_StopAndRevoke(t *testing.T) {
+	rs, _ := newTestRecordingService(t)
+	service := NewRealtimeService("test", "test", "test", "test")
+	service.SetRecordingService(rs)
+
+	clientConn, client := wsPair(t)
+	gptConn, _ := wsPair(t)
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, nil)
+	rs.GrantConsent(session.UserID)
+
+	cfg := *session.Config()
+	cfg.Recording = true
+	service.UpdateSessionConfig(session, &cfg)
+	readClientMessages(t, client, "recording_started")
+	pcm := generateTone(RealtimeSampleRate, 440, 9000, 200, 1)
+	service.SendSessionAudio(session, pcm)
+
+	// 关闭录音后保存，之后的音频不再录制
+	stopped := cfg
+	stopped.Recording = false
+	service.UpdateSessionConfig(session, &stopped)
+	readClientMessages(t, client, "recording_stopped")
+	service.SendSessionAudio(session, pcm)
+	rs.pending.Wait()
+
+	wav := readRecording(t, rs, session.UserID, session.ID, "inbound.wav")
+	if _, data, _ := parseWAV(wav); len(data) != len(pcm) {
+		t.Errorf("Expected only audio sent while recording (%d bytes), got %d", len(pcm), len(data))
+	}
+	if _, err := rs.OpenRecording(session.UserID, session.ID, "outbound.wav"); !errors.Is(err, ErrRecordingNotFound) {
+		t.Errorf("Expected no outbound track without assistant audio, got %v", err)
+	}
+
+	// 再次录音期间撤回同意：已保存的录音被删除，进行中的录音被丢弃
+	service.UpdateSessionConfig(session, &cfg)
+	service.SendSessionAudio(session, pcm)
+	deleted, err := rs.RevokeConsent(session.UserID)
+	if err != nil || deleted != 1 {
+		t.Fatalf("Expected one recording deleted, got %d (%v)", deleted, err)
+	}
+	service.EndSession(session)
+	rs.pending.Wait()
+
+	if recordings, _ := rs.ListRecordings(session.UserID); len(recordings) != 0 {
+		t.Errorf("Expected no recordings after revoking consent, got %d", len(recordings))
+	}
+	if rs.HasConsent(session.UserID) {
+		t.Error("Expected consent to be revoked")
+	}
+}
+
+// revokingStore 第一次上传时先执行 onPut，模拟上传期间用户撤回同意
+type revokingStore struct {
+	*LocalRecordingStore
+	once  sync.Once
+	onPut func()
+}
+
+func (s *revokingStore) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	s.once.Do(s.onPut)
+	return s.LocalRecordingStore.Put(ctx, key, body, size)
+}
+
+func TestSessionRecording_RevokeDuringUpload(t *testing.T) {
+	local, err := NewLocalRecordingStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalRecordingStore failed: %v", err)
+	}
+	store := &revokingStore{LocalRecordingStore: local}
+	rs, err := NewRecordingService(store, RecordingConfig{EncryptionKey: testRecordingKey(1), SpoolDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewRecordingService failed: %v", err)
+	}
+	service := NewRealtimeService("test", "test", "test", "test")
+	service.SetRecordingService(rs)
+
+	clientConn, _ := wsPair(t)
+	gptConn, _ := wsPair(t)
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, nil)
+	rs.GrantConsent(session.UserID)
+	cfg := *session.Config()
+	cfg.Recording = true
+	service.UpdateSessionConfig(session, &cfg)
+	service.SendSessionAudio(session, generateTone(RealtimeSampleRate, 440, 9000, 200, 1))
+
+	// 撤回时还没有文件可删，上传完成后录音自行删除
+	revoked := -1
+	store.onPut = func() {
+		revoked, _ = rs.RevokeConsent(session.UserID)
+	}
+	service.EndSession(session)
+	rs.pending.Wait()
+
+	if revoked != 0 {
+		t.Fatalf("Expected the revoke to land before anything was uploaded, deleted %d", revoked)
+	}
+	if objects, _ := local.List(context.Background(), session.UserID.String()+"/"); len(objects) != 0 {
+		t.Errorf("Expected uploads to be deleted after consent was revoked, got %v", objects)
+	}
+}
+
+func TestRecordingPrivacyGate(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+	clientConn, _ := wsPair(t)
+	gptConn, _ := wsPair(t)
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, nil)
+	t.Cleanup(func() { service.EndSession(session) })
+
+	cfg := *session.Config()
+	cfg.Recording = true
+	if err := service.CheckRecording(session, &cfg); !errors.Is(err, ErrRecordingDisabled) {
+		t.Errorf("Expected ErrRecordingDisabled, got %v", err)
+	}
+
+	// 未同意时无论隐私模式是否开启都拒绝，关闭录音后恢复 ErrRecordingDisabled
+	rs, _ := newTestRecordingService(t)
+	service.SetRecordingService(rs)
+	if err := service.CheckRecording(session, &cfg); !errors.Is(err, ErrRecordingConsentRequired) {
+		t.Errorf("Expected ErrRecordingConsentRequired, got %v", err)
+	}
+	service.securityMonitor.DisablePrivacyMode()
+	if err := service.CheckRecording(session, &cfg); !errors.Is(err, ErrRecordingConsentRequired) {
+		t.Errorf("Expected recording without consent to be refused outside privacy mode, got %v", err)
+	}
+	service.SetRecordingService(nil)
+	if err := service.CheckRecording(session, &cfg); !errors.Is(err, ErrRecordingDisabled) {
+		t.Errorf("Expected ErrRecordingDisabled after disabling recording, got %v", err)
+	}
+
+	sm := NewSecurityMonitor()
+	defer sm.Shutdown()
+	if err := sm.AuthorizeRecording("s1", true); !errors.Is(err, ErrRecordingDisabled) {
+		t.Errorf("Expected recording to be refused before retention is configured, got %v", err)
+	}
+	sm.SetRecordingRetention(7 * 24 * time.Hour)
+	if err := sm.AuthorizeRecording("s1", false); !errors.Is(err, ErrRecordingConsentRequired) {
+		t.Errorf("Expected privacy mode to refuse recording without consent, got %v", err)
+	}
+	sm.DisablePrivacyMode()
+	if err := sm.AuthorizeRecording("s1", false); !errors.Is(err, ErrRecordingConsentRequired) {
+		t.Errorf("Expected recording without consent to be refused outside privacy mode, got %v", err)
+	}
+	sm.EnablePrivacyMode()
+	if err := sm.AuthorizeRecording("s1", true); err != nil {
+		t.Errorf("Expected consented recording to be allowed, got %v", err)
+	}
+	// 未经同意的音频仍然不能保存
+	if err := sm.ValidateAudioDataHandling("store_audio"); err == nil {
+		t.Error("Expected store_audio to remain forbidden")
+	}
+}
+
+func TestRecordingService_Purge(t *testing.T) {
+	rs, store := newTestRecordingService(t)
+	ctx := context.Background()
+	userID := uuid.New()
+	oldSession, newSession := uuid.New(), uuid.New()
+	for _, key := range []string{
+		recordingPrefix(userID, oldSession) + "inbound.wav.enc",
+		recordingPrefix(userID, oldSession) + "transcript.json.enc",
+		recordingPrefix(userID, newSession) + "inbound.wav.enc",
+	} {
+		store.Put(ctx, key, strings.NewReader("x"), 1)
+	}
+	old := time.Now().Add(-defaultRecordingRetention - time.Hour)
+	for _, name := range []string{"inbound.wav.enc", "transcript.json.enc"} {
+		os.Chtimes(filepath.Join(store.dir, userID.String(), oldSession.String(), name), old, old)
+	}
+
+	deleted, err := rs.Purge(time.Now())
+	if err != nil || deleted != 2 {
+		t.Fatalf("Expected 2 expired files purged, got %d (%v)", deleted, err)
+	}
+	recordings, _ := rs.ListRecordings(userID)
+	if len(recordings) != 1 || recordings[0].SessionID != newSession {
+		t.Errorf("Expected only the recent recording to remain, got %v", recordings)
+	}
+
+	if _, err := NewRecordingService(store, RecordingConfig{EncryptionKey: []byte("short")}); err == nil {
+		t.Error("Expected a short encryption key to be rejected")
+	}
+	if _, err := NewRecordingService(store, RecordingConfig{EncryptionKey: testRecordingKey(1), Format: "mp3"}); err == nil {
+		t.Error("Expected an unsupported format to be rejected")
+	}
+}
+
+// 上次进程遗留的会话临时目录在创建服务时删除
+func TestNewRecordingService_RemovesStaleSpool(t *testing.T) {
+	store, err := NewLocalRecordingStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalRecordingStore failed: %v", err)
+	}
+	spool := t.TempDir()
+	stale := filepath.Join(spool, "recording-"+uuid.New().String()+"-123")
+	os.Mkdir(stale, 0o700)
+	os.WriteFile(filepath.Join(stale, "inbound.pcm"), []byte("x"), 0o600)
+	other := filepath.Join(spool, "unrelated")
+	os.Mkdir(other, 0o700)
+
+	if _, err := NewRecordingService(store, RecordingConfig{EncryptionKey: testRecordingKey(1), SpoolDir: spool}); err != nil {
+		t.Fatalf("NewRecordingService failed: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("Expected stale spool directory to be removed, got %v", err)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("Expected unrelated directory to be kept, got %v", err)
+	}
+}
+
+// 录音到达时长上限后丢弃后续音频并在转写中标记
+func TestSessionRecording_Truncated(t *testing.T) {
+	rs, _ := newTestRecordingService(t)
+	rs.GrantConsent(uuid.Nil)
+	rec, err := rs.start(uuid.New(), uuid.Nil)
+	if err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	track := rec.tracks[recordingInbound]
+	track.bytes = maxRecordingSeconds*RealtimeSampleRate*2 - 4
+	rec.write(recordingInbound, make([]byte, 4), RealtimeSampleRate)
+	rec.write(recordingInbound, make([]byte, 4), RealtimeSampleRate)
+	if !track.truncated || track.bytes != maxRecordingSeconds*RealtimeSampleRate*2 {
+		t.Errorf("Expected the track to stop at the limit, got %d bytes, truncated=%v", track.bytes, track.truncated)
+	}
+	rec.close()
+	os.RemoveAll(rec.dir)
+
+	// WAV 头的长度字段
+	var hdr bytes.Buffer
+	writeWAVHeader(&hdr, RealtimeSampleRate, 1, 4800)
+	if b := hdr.Bytes(); len(b) != 44 || binary.LittleEndian.Uint32(b[40:44]) != 4800 || binary.LittleEndian.Uint32(b[4:8]) != 36+4800 {
+		t.Errorf("Unexpected WAV header % x", b)
+	}
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// RealtimeSampleRate Realtime API pcm16 音频的采样率
+const RealtimeSampleRate = 24000
+
+// realtimeAudioBytesPerMs Realtime API pcm16 24kHz 单声道每毫秒的字节数
+const realtimeAudioBytesPerMs = RealtimeSampleRate * 2 / 1000
+
+// 重采样滤波器参数：阻带衰减约 80dB，过渡带占较低一侧奈奎斯特频率的 15%
+const (
+	resamplerStopbandDB      = 80.0
+	resamplerTransitionRatio = 0.15
+)
+
+// maxResamplerFactor 插值或抽取倍数的上限；滤波器长度与倍数成正比，
+// 采样率互质时（如 47999 -> 24000）倍数可达数万，滤波器设计的时间和内存不可接受
+// 常见采样率之间的倍数不超过 320（11025 -> 24000）
+const maxResamplerFactor = 1000
+
+// Resampler 多相加窗 sinc 重采样器，按 up/down 的有理数比例转换单声道 PCM16
+// 低通截止频率取输入和输出中较低的奈奎斯特频率，降采样时同时完成抗混叠
+// 保留块间的历史样本，流式分块处理与整段处理的结果一致；不能并发使用
+type Resampler struct {
+	inRate  int
+	outRate int
+	up      int         // 插值倍数 L
+	down    int         // 抽取倍数 M
+	phases  [][]float64 // phases[p][k] 为原型滤波器第 p+k*L 个系数
+	history []float64   // 上一块末尾的 taps-1 个输入样本
+	pos     int         // 下一个输出样本在插值域中相对当前块起点的位置
+}
+
+// NewResampler 创建从 inRate 到 outRate 的重采样器
+// 化简后的插值或抽取倍数超过 maxResamplerFactor 时返回错误
+func NewResampler(inRate, outRate int) (*Resampler, error) {
+	up, down, err := resampleRatio(inRate, outRate)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Resampler{
+		inRate:  inRate,
+		outRate: outRate,
+		up:      up,
+		down:    down,
+	}
+	if r.up == 1 && r.down == 1 {
+		return r, nil
+	}
+
+	r.phases = designPolyphaseFilter(r.up, r.down)
+	r.history = make([]float64, len(r.phases[0])-1)
+	return r, nil
+}
+
+// resampleRatio 从 inRate 到 outRate 化简后的插值倍数和抽取倍数
+func resampleRatio(inRate, outRate int) (up, down int, err error) {
+	if inRate <= 0 || outRate <= 0 {
+		return 0, 0, fmt.Errorf("invalid sample rates %d -> %d", inRate, outRate)
+	}
+	g := gcd(inRate, outRate)
+	up, down = outRate/g, inRate/g
+	if up > maxResamplerFactor || down > maxResamplerFactor {
+		return 0, 0, fmt.Errorf("cannot resample %d -> %d: ratio %d/%d exceeds %d", inRate, outRate, up, down, maxResamplerFactor)
+	}
+	return up, down, nil
+}
+
+// InputRate 输入采样率
+func (r *Resampler) InputRate() int { return r.inRate }
+
+// OutputRate 输出采样率
+func (r *Resampler) OutputRate() int { return r.outRate }
+
+// Process 转换一块 little-endian PCM16 单声道数据，末尾不足一个样本的字节被忽略
+func (r *Resampler) Process(pcm []byte) []byte {
+	samples := len(pcm) / 2
+	if r.phases == nil {
+		return append([]byte(nil), pcm[:samples*2]...)
+	}
+
+	taps := len(r.phases[0])
+	hist := len(r.history)
+	buf := make([]float64, hist+samples)
+	copy(buf, r.history)
+	for i := 0; i < samples; i++ {
+		buf[hist+i] = float64(int16(binary.LittleEndian.Uint16(pcm[i*2:])))
+	}
+
+	out := make([]byte, 0, (samples*r.up/r.down+1)*2)
+	for {
+		inIdx := r.pos / r.up
+		if inIdx >= samples {
+			break
+		}
+		coeffs := r.phases[r.pos%r.up]
+		base := hist + inIdx
+
+		var acc float64
+		for k := 0; k < taps; k++ {
+			acc += coeffs[k] * buf[base-k]
+		}
+		out = binary.LittleEndian.AppendUint16(out, uint16(clampInt16(acc)))
+		r.pos += r.down
+	}
+
+	r.pos -= samples * r.up
+	copy(r.history, buf[len(buf)-hist:])
+	return out
+}
+
+// Reset 清除历史样本，开始一段新的音频
+func (r *Resampler) Reset() {
+	for i := range r.history {
+		r.history[i] = 0
+	}
+	r.pos = 0
+}
+
+// designPolyphaseFilter 设计 Kaiser 窗 sinc 低通原型滤波器并拆分为 up 个相位
+func designPolyphaseFilter(up, down int) [][]float64 {
+	factor := up
+	if down > factor {
+		factor = down
+	}
+	// 以插值域为单位的频率，较低一侧的奈奎斯特频率为 0.5/factor
+	nyquist := 0.5 / float64(factor)
+	transition := nyquist * resamplerTransitionRatio
+	cutoff := nyquist - transition/2
+
+	// Kaiser 经验公式估算滤波器长度，并补齐为 up 的整数倍
+	length := int(math.Ceil((resamplerStopbandDB-8)/(2.285*2*math.Pi*transition))) + 1
+	taps := (length + up - 1) / up
+	length = taps * up
+	beta := 0.1102 * (resamplerStopbandDB - 8.7)
+
+	proto := make([]float64, length)
+	center := float64(length-1) / 2
+	norm := besselI0(beta)
+	var sum float64
+	for n := range proto {
+		x := float64(n) - center
+		ratio := 2*float64(n)/float64(length-1) - 1
+		window := besselI0(beta*math.Sqrt(1-ratio*ratio)) / norm
+		proto[n] = 2 * cutoff * sinc(2*cutoff*x) * window
+		sum += proto[n]
+	}
+
+	// 归一化直流增益；插值补零后每个相位的增益需要乘以 up
+	phases := make([][]float64, up)
+	for p := range phases {
+		phases[p] = make([]float64, taps)
+		for k := 0; k < taps; k++ {
+			phases[p][k] = proto[p+k*up] / sum * float64(up)
+		}
+	}
+	return phases
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// besselI0 第一类零阶修正贝塞尔函数，级数展开
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+		if term < sum*1e-12 {
+			break
+		}
+	}
+	return sum
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func clampInt16(v float64) int16 {
+	v = math.Round(v)
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
+
+// upmixFromMono 把单声道 PCM16 复制到各声道，生成交错的多声道 PCM16
+func upmixFromMono(pcm []byte, channels int) []byte {
+	if channels <= 1 {
+		return pcm
+	}
+	out := make([]byte, 0, len(pcm)*channels)
+	for i := 0; i+1 < len(pcm); i += 2 {
+		for c := 0; c < channels; c++ {
+			out = append(out, pcm[i], pcm[i+1])
+		}
+	}
+	return out
+}
+
+// DownmixToMono 把交错的多声道 PCM16 平均为单声道
+func DownmixToMono(pcm []byte, channels int) []byte {
+	if channels <= 1 {
+		return pcm
+	}
+	frameSize := channels * 2
+	frames := len(pcm) / frameSize
+	out := make([]byte, frames*2)
+	for i := 0; i < frames; i++ {
+		var sum int
+		for c := 0; c < channels; c++ {
+			sum += int(int16(binary.LittleEndian.Uint16(pcm[i*frameSize+c*2:])))
+		}
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(int16(sum/channels)))
+	}
+	return out
+}
+
+// pcm16Samples 把小端 PCM16 字节转换为样本
+func pcm16Samples(pcm []byte) []int16 {
+	samples := make([]int16, len(pcm)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(pcm[i*2:]))
+	}
+	return samples
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// generateTone 生成指定采样率、频率和幅度的 PCM16 正弦波，channels 个声道内容相同
+func generateTone(rate int, freq, amplitude float64, durationMs, channels int) []byte {
+	samples := rate * durationMs / 1000
+	out := make([]byte, 0, samples*channels*2)
+	for i := 0; i < samples; i++ {
+		v := int16(math.Round(amplitude * math.Sin(2*math.Pi*freq*float64(i)/float64(rate))))
+		for c := 0; c < channels; c++ {
+			out = binary.LittleEndian.AppendUint16(out, uint16(v))
+		}
+	}
+	return out
+}
+
+func pcmSamples(pcm []byte) []float64 {
+	out := make([]float64, len(pcm)/2)
+	for i := range out {
+		out[i] = float64(int16(binary.LittleEndian.Uint16(pcm[i*2:])))
+	}
+	return out
+}
+
+// analyzeTone 对跳过滤波器暖机段后的信号做已知频率的最小二乘拟合，返回幅度和信噪比（dB）
+func analyzeTone(samples []float64, rate int, freq float64) (amplitude, snrDB float64) {
+	skip := len(samples) / 5
+	signal := samples[skip : len(samples)-skip]
+
+	var ss, sc, cc, ys, yc float64
+	for i, y := range signal {
+		w := 2 * math.Pi * freq * float64(i+skip) / float64(rate)
+		s, c := math.Sin(w), math.Cos(w)
+		ss += s * s
+		cc += c * c
+		sc += s * c
+		ys += y * s
+		yc += y * c
+	}
+	det := ss*cc - sc*sc
+	a := (ys*cc - yc*sc) / det
+	b := (yc*ss - ys*sc) / det
+
+	var signalPower, noisePower float64
+	for i, y := range signal {
+		w := 2 * math.Pi * freq * float64(i+skip) / float64(rate)
+		fit := a*math.Sin(w) + b*math.Cos(w)
+		signalPower += fit * fit
+		noisePower += (y - fit) * (y - fit)
+	}
+	return math.Hypot(a, b), 10 * math.Log10(signalPower/noisePower)
+}
+
+func rms(samples []float64) float64 {
+	skip := len(samples) / 5
+	var sum float64
+	for _, v := range samples[skip : len(samples)-skip] {
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(len(samples)-2*skip))
+}
+
+func TestResampler_PreservesTones(t *testing.T) {
+	cases := []struct {
+		inRate, outRate int
+		freq            float64
+	}{
+		{8000, RealtimeSampleRate, 1000},
+		{16000, RealtimeSampleRate, 1000},
+		{16000, RealtimeSampleRate, 5000},
+		{44100, RealtimeSampleRate, 440},
+		{48000, RealtimeSampleRate, 3000},
+		{RealtimeSampleRate, 44100, 1000},
+		{RealtimeSampleRate, 16000, 1000},
+		{RealtimeSampleRate, 8000, 2500},
+	}
+
+	for _, tc := range cases {
+		r, err := NewResampler(tc.inRate, tc.outRate)
+		if err != nil {
+			t.Fatalf("NewResampler(%d, %d) failed: %v", tc.inRate, tc.outRate, err)
+		}
+		out := r.Process(generateTone(tc.inRate, tc.freq, 10000, 500, 1))
+
+		expectedLen := tc.outRate / 2
+		if n := len(out) / 2; n < expectedLen-1 || n > expectedLen+1 {
+			t.Errorf("%d->%d: expected about %d samples, got %d", tc.inRate, tc.outRate, expectedLen, n)
+		}
+
+		amplitude, snr := analyzeTone(pcmSamples(out), tc.outRate, tc.freq)
+		if math.Abs(20*math.Log10(amplitude/10000)) > 0.1 {
+			t.Errorf("%d->%d %.0fHz: amplitude %.1f, expected 10000", tc.inRate, tc.outRate, tc.freq, amplitude)
+		}
+		if snr < 60 {
+			t.Errorf("%d->%d %.0fHz: SNR %.1fdB is below 60dB", tc.inRate, tc.outRate, tc.freq, snr)
+		}
+	}
+}
+
+func TestResampler_AttenuatesAliases(t *testing.T) {
+	// 高于输出奈奎斯特频率的分量必须被滤除，而不是折叠回可听频段
+	cases := []struct {
+		inRate, outRate int
+		freq            float64
+	}{
+		{48000, RealtimeSampleRate, 12500},
+		{44100, RealtimeSampleRate, 18000},
+		{RealtimeSampleRate, 8000, 6000},
+	}
+
+	for _, tc := range cases {
+		r, _ := NewResampler(tc.inRate, tc.outRate)
+		out := pcmSamples(r.Process(generateTone(tc.inRate, tc.freq, 10000, 500, 1)))
+		level := 20 * math.Log10(rms(out)/(10000/math.Sqrt2))
+		if level > -60 {
+			t.Errorf("%d->%d %.0fHz: alias level %.1fdB, expected below -60dB", tc.inRate, tc.outRate, tc.freq, level)
+		}
+	}
+}
+
+func TestResampler_StreamingMatchesWholeBuffer(t *testing.T) {
+	tone := generateTone(44100, 1000, 8000, 300, 1)
+
+	whole, _ := NewResampler(44100, RealtimeSampleRate)
+	expected := whole.Process(tone)
+
+	// 奇数大小的分块，覆盖相位跨块的情况
+	streaming, _ := NewResampler(44100, RealtimeSampleRate)
+	var actual []byte
+	for start := 0; start < len(tone); start += 882 {
+		end := start + 882
+		if end > len(tone) {
+			end = len(tone)
+		}
+		actual = append(actual, streaming.Process(tone[start:end])...)
+	}
+
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("Streaming output (%d bytes) differs from whole-buffer output (%d bytes)", len(actual), len(expected))
+	}
+}
+
+func TestResampler_SameRatePassesThrough(t *testing.T) {
+	r, _ := NewResampler(RealtimeSampleRate, RealtimeSampleRate)
+	tone := generateTone(RealtimeSampleRate, 1000, 8000, 20, 1)
+	if out := r.Process(tone); !bytes.Equal(out, tone) {
+		t.Error("Expected audio to pass through unchanged")
+	}
+
+	if _, err := NewResampler(0, RealtimeSampleRate); err == nil {
+		t.Error("Expected error for invalid sample rate")
+	}
+	// 47999 与 24000 互质，倍数超过上限
+	if _, err := NewResampler(47999, RealtimeSampleRate); err == nil {
+		t.Error("Expected error for coprime sample rates")
+	}
+	if _, err := NewResampler(RealtimeSampleRate, 47999); err == nil {
+		t.Error("Expected error for coprime playback rate")
+	}
+}
+
+func TestDownmixToMono(t *testing.T) {
+	stereo := generateTone(16000, 1000, 8000, 20, 2)
+	mono := DownmixToMono(stereo, 2)
+	if !bytes.Equal(mono, generateTone(16000, 1000, 8000, 20, 1)) {
+		t.Error("Identical channels should downmix to the same signal")
+	}
+
+	// 反相的左右声道相互抵消
+	inverted := make([]byte, len(stereo))
+	copy(inverted, stereo)
+	for i := 2; i < len(inverted); i += 4 {
+		v := int16(binary.LittleEndian.Uint16(inverted[i:]))
+		binary.LittleEndian.PutUint16(inverted[i:], uint16(-v))
+	}
+	for _, v := range pcmSamples(DownmixToMono(inverted, 2)) {
+		if v != 0 {
+			t.Fatalf("Expected silence from out-of-phase channels, got %v", v)
+		}
+	}
+}
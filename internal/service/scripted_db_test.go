@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+)
+
+// scriptedQuery 按语句和参数返回查询结果；Exec 时返回的行数作为受影响的行数
+type scriptedQuery func(query string, args []driver.Value) ([][]driver.Value, error)
+
+// openScriptedDB 打开由 script 应答所有语句的数据库，script 调用之间互斥，测试结束时关闭
+func openScriptedDB(t *testing.T, script scriptedQuery) *sql.DB {
+	t.Helper()
+	db := sql.OpenDB(&scriptedConnector{script: script})
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+type scriptedConnector struct {
+	mu     sync.Mutex
+	script scriptedQuery
+}
+
+func (c *scriptedConnector) Connect(context.Context) (driver.Conn, error) { return c, nil }
+func (c *scriptedConnector) Driver() driver.Driver                        { return nil }
+
+func (c *scriptedConnector) Prepare(query string) (driver.Stmt, error) {
+	return &scriptedStmt{db: c, query: query}, nil
+}
+
+func (c *scriptedConnector) Close() error { return nil }
+func (c *scriptedConnector) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions not supported")
+}
+
+func (c *scriptedConnector) run(query string, args []driver.Value) ([][]driver.Value, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.script(query, args)
+}
+
+type scriptedStmt struct {
+	db    *scriptedConnector
+	query string
+}
+
+func (s *scriptedStmt) Close() error  { return nil }
+func (s *scriptedStmt) NumInput() int { return -1 }
+
+func (s *scriptedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	rows, err := s.db.run(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(len(rows)), nil
+}
+
+func (s *scriptedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, err := s.db.run(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &scriptedRows{rows: rows}, nil
+}
+
+type scriptedRows struct {
+	rows [][]driver.Value
+}
+
+func (r *scriptedRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *scriptedRows) Close() error { return nil }
+
+func (r *scriptedRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
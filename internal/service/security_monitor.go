@@ -2,12 +2,18 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
 	"time"
+
+	"github.com/google/uuid"
 )
 
+// ErrMaxSessionsReached 并发会话数已达上限
+var ErrMaxSessionsReached = errors.New("maximum concurrent sessions reached")
+
 // SecurityMonitor 安全和监控服务
 type SecurityMonitor struct {
 	mu                    sync.RWMutex
@@ -19,6 +25,7 @@ type SecurityMonitor struct {
 	cleanupInterval       time.Duration                // 清理间隔
 	logRetentionDays      int                          // 日志保留天数
 	privacyMode           bool                         // 隐私保护模式
+	recordingRetention    time.Duration                // 经用户同意的录音保存时长，0 表示未开启录音
 	monitoringEnabled     bool                         // 监控开关
 	ctx                   context.Context
 	cancel                context.CancelFunc
@@ -63,7 +70,11 @@ type ConnectionMetric struct {
 	AvgLatencyMs     float64       `json:"avg_latency_ms"`
 	MaxLatencyMs     float64       `json:"max_latency_ms"`
 	MinLatencyMs     float64       `json:"min_latency_ms"`
-	PacketLoss       float64       `json:"packet_loss"`
+	PacketLoss       float64       `json:"packet_loss"` // 上行音频包丢包率
+	JitterMs         float64       `json:"jitter_ms"`   // 上行音频包到达抖动
+	PacketsReceived  int           `json:"packets_received"`
+	PacketsLost      int           `json:"packets_lost"`
+	PacketsLate      int           `json:"packets_late"`
 	BytesSent        int64         `json:"bytes_sent"`
 	BytesReceived    int64         `json:"bytes_received"`
 	AudioChunksCount int           `json:"audio_chunks_count"`
@@ -121,12 +132,21 @@ func NewSecurityMonitor() *SecurityMonitor {
 	return sm
 }
 
-// StartSession 开始新会话
+// StartSession 开始新会话，会话 ID 为新生成的 UUID
 // Requirements: 10.1, 10.4, 10.5 - JWT认证、会话超时、访问日志
 func (sm *SecurityMonitor) StartSession(userID, userEmail, clientIP, userAgent string) (*VoiceSession, error) {
+	return sm.StartSessionWithID(uuid.New().String(), userID, userEmail, clientIP, userAgent)
+}
+
+// StartSessionWithID 以调用方分配的 ID 开始新会话，实时连接用它让会话记录和监控指标共用同一 ID
+func (sm *SecurityMonitor) StartSessionWithID(sessionID, userID, userEmail, clientIP, userAgent string) (*VoiceSession, error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
+	if _, exists := sm.sessions[sessionID]; exists {
+		return nil, fmt.Errorf("session %s already exists", sessionID)
+	}
+
 	// 检查并发会话数限制
 	activeCount := 0
 	for _, session := range sm.sessions {
@@ -136,11 +156,10 @@ func (sm *SecurityMonitor) StartSession(userID, userEmail, clientIP, userAgent s
 	}
 
 	if activeCount >= sm.maxSessions {
-		return nil, fmt.Errorf("maximum concurrent sessions reached: %d", sm.maxSessions)
+		return nil, fmt.Errorf("%w: %d", ErrMaxSessionsReached, sm.maxSessions)
 	}
 
 	// 创建新会话
-	sessionID := fmt.Sprintf("session_%d_%s", time.Now().UnixNano(), userID[:8])
 	session := &VoiceSession{
 		ID:            sessionID,
 		UserID:        userID,
@@ -159,7 +178,7 @@ func (sm *SecurityMonitor) StartSession(userID, userEmail, clientIP, userAgent s
 	sm.sessions[sessionID] = session
 
 	// 记录访问日志 (Requirement 10.5)
-	sm.logAccess(AccessLog{
+	sm.appendAccessLog(AccessLog{
 		ID:         fmt.Sprintf("log_%d", time.Now().UnixNano()),
 		Timestamp:  time.Now(),
 		UserID:     userID,
@@ -195,8 +214,8 @@ func (sm *SecurityMonitor) EndSession(sessionID string, reason string) {
 	defer sm.mu.Unlock()
 
 	session, exists := sm.sessions[sessionID]
-	if !exists {
-		return
+	if !exists || session.EndTime != nil {
+		return // 超时检查可能已经结束了该会话
 	}
 
 	now := time.Now()
@@ -213,7 +232,7 @@ func (sm *SecurityMonitor) EndSession(sessionID string, reason string) {
 
 	// 记录会话结束日志
 	duration := now.Sub(session.StartTime)
-	sm.logAccess(AccessLog{
+	sm.appendAccessLog(AccessLog{
 		ID:         fmt.Sprintf("log_%d", time.Now().UnixNano()),
 		Timestamp:  now,
 		UserID:     session.UserID,
@@ -349,17 +368,42 @@ func (sm *SecurityMonitor) UpdateConnectionMetric(sessionID string, latencyMs fl
 	metric.LastUpdated = time.Now()
 }
 
+// UpdatePacketStats 更新上行音频包的丢包和抖动统计，来自会话的抖动缓冲
+func (sm *SecurityMonitor) UpdatePacketStats(sessionID string, stats PacketStats) {
+	if !sm.monitoringEnabled {
+		return
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	metric, exists := sm.connectionMetrics[sessionID]
+	if !exists {
+		return
+	}
+
+	metric.PacketLoss = stats.PacketLoss()
+	metric.JitterMs = stats.JitterMs
+	metric.PacketsReceived = stats.Received
+	metric.PacketsLost = stats.Lost
+	metric.PacketsLate = stats.Late
+	metric.Quality = sm.calculateConnectionQuality(metric)
+	metric.LastUpdated = time.Now()
+}
+
 // calculateConnectionQuality 计算连接质量
 func (sm *SecurityMonitor) calculateConnectionQuality(metric *ConnectionMetric) string {
-	// 基于延迟和错误率计算质量
+	// 基于延迟、错误率、丢包率和抖动计算质量
 	avgLatency := metric.AvgLatencyMs
 	errorRate := float64(metric.ErrorCount) / float64(metric.PingCount+1)
+	loss := metric.PacketLoss
+	jitter := metric.JitterMs
 
-	if avgLatency < 100 && errorRate < 0.01 {
+	if avgLatency < 100 && errorRate < 0.01 && loss < 0.01 && jitter < 20 {
 		return "excellent"
-	} else if avgLatency < 200 && errorRate < 0.05 {
+	} else if avgLatency < 200 && errorRate < 0.05 && loss < 0.03 && jitter < 50 {
 		return "good"
-	} else if avgLatency < 500 && errorRate < 0.1 {
+	} else if avgLatency < 500 && errorRate < 0.1 && loss < 0.1 && jitter < 100 {
 		return "fair"
 	} else {
 		return "poor"
@@ -398,14 +442,13 @@ func (sm *SecurityMonitor) LogAccess(userID, userEmail, sessionID, action, resou
 		ErrorMsg:   errorMsg,
 	}
 
-	sm.logAccess(accessLog)
-}
-
-// logAccess 内部日志记录方法
-func (sm *SecurityMonitor) logAccess(accessLog AccessLog) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+	sm.appendAccessLog(accessLog)
+}
 
+// appendAccessLog 内部日志记录方法，调用方需持有 sm.mu
+func (sm *SecurityMonitor) appendAccessLog(accessLog AccessLog) {
 	// 在隐私模式下，不记录敏感信息
 	if sm.privacyMode {
 		accessLog.UserEmail = "" // 不记录邮箱
@@ -471,6 +514,37 @@ func (sm *SecurityMonitor) ValidateAudioDataHandling(operation string) error {
 	return nil
 }
 
+// SetRecordingRetention 开启录音时设置录音保存时长，用于隐私状态说明
+func (sm *SecurityMonitor) SetRecordingRetention(retention time.Duration) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.recordingRetention = retention
+}
+
+// RecordingRetention 录音保存时长，0 表示不保存任何音频
+func (sm *SecurityMonitor) RecordingRetention() time.Duration {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.recordingRetention
+}
+
+// AuthorizeRecording 检查会话录音是否符合隐私策略
+// 无论是否开启隐私模式都只允许录制已同意录音的用户的会话，服务端未开启录音时一律拒绝
+func (sm *SecurityMonitor) AuthorizeRecording(sessionID string, consented bool) error {
+	sm.mu.RLock()
+	retention := sm.recordingRetention
+	sm.mu.RUnlock()
+
+	if retention == 0 {
+		return ErrRecordingDisabled
+	}
+	if !consented {
+		return fmt.Errorf("%w: recording session %s without consent violates audio data privacy policy", ErrRecordingConsentRequired, sessionID)
+	}
+	log.Printf("Recording authorized for session %s", sessionID)
+	return nil
+}
+
 // GetActiveSessions 获取活跃会话列表
 func (sm *SecurityMonitor) GetActiveSessions() []*VoiceSession {
 	sm.mu.RLock()
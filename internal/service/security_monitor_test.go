@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"testing"
 	"time"
 )
@@ -158,4 +159,52 @@ func TestSecurityMonitor_SessionStats(t *testing.T) {
 	if !ok || activeSessions != 2 {
 		t.Errorf("Expected active_sessions 2, got %v", stats["active_sessions"])
 	}
-}
\ No newline at end of file
+}
+
+func TestSecurityMonitor_MaxSessions(t *testing.T) {
+	sm := NewSecurityMonitor()
+	defer sm.Shutdown()
+
+	sm.SetMaxSessions(1)
+
+	first, err := sm.StartSessionWithID("session-1", "user1", "", "127.0.0.1", "Agent1")
+	if err != nil {
+		t.Fatalf("Failed to start session: %v", err)
+	}
+	if first.ID != "session-1" {
+		t.Errorf("Expected session ID session-1, got %s", first.ID)
+	}
+
+	if _, err := sm.StartSession("user2", "", "127.0.0.2", "Agent2"); !errors.Is(err, ErrMaxSessionsReached) {
+		t.Fatalf("Expected ErrMaxSessionsReached, got %v", err)
+	}
+
+	// 结束的会话释放名额，重复结束不会再写日志
+	sm.EndSession("session-1", "completed")
+	sm.EndSession("session-1", "completed")
+	if _, err := sm.StartSession("user2", "", "127.0.0.2", "Agent2"); err != nil {
+		t.Fatalf("Expected slot to be released, got %v", err)
+	}
+
+	ends := 0
+	for _, entry := range sm.GetAccessLogs(0) {
+		if entry.Action == "session_end" {
+			ends++
+		}
+	}
+	if ends != 1 {
+		t.Errorf("Expected 1 session_end log, got %d", ends)
+	}
+}
+
+func TestSecurityMonitor_StartSessionWithDuplicateID(t *testing.T) {
+	sm := NewSecurityMonitor()
+	defer sm.Shutdown()
+
+	if _, err := sm.StartSessionWithID("session-1", "user1", "", "127.0.0.1", "Agent1"); err != nil {
+		t.Fatalf("Failed to start session: %v", err)
+	}
+	if _, err := sm.StartSessionWithID("session-1", "user2", "", "127.0.0.2", "Agent2"); err == nil {
+		t.Error("Expected error for duplicate session ID")
+	}
+}
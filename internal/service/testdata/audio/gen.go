@@ -0,0 +1,207 @@
+//go:build ignore
+
+// gen 生成 WebM/Ogg 容器测试用例：go run testdata/audio/gen.go
+// 容器结构与 Chrome MediaRecorder 的输出一致（Segment 和 Cluster 为未知长度）
+// Opus 用例的每个包只有 TOC 字节（20ms CELT 零长度帧），解码结果为静音，用于验证包边界、pre-skip 和时长
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+const opusPreSkip = 312
+
+func main() {
+	dir := filepath.Join("testdata", "audio")
+	write := func(name string, data []byte) {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			panic(err)
+		}
+	}
+
+	// 48kHz 单声道 32 位浮点，1kHz 正弦 300ms，每块 10ms
+	var floatBlocks [][]byte
+	for b := 0; b < 30; b++ {
+		var frame []byte
+		for i := 0; i < 480; i++ {
+			n := b*480 + i
+			v := float32(0.5 * math.Sin(2*math.Pi*1000*float64(n)/48000))
+			frame = binary.LittleEndian.AppendUint32(frame, math.Float32bits(v))
+		}
+		floatBlocks = append(floatBlocks, simpleBlock(1, frame))
+	}
+	write("tone_pcm_float.webm", webm(audioTrack("A_PCM/FLOAT/IEEE", 48000, 1, 32, nil), floatBlocks))
+
+	// 16kHz 立体声 16 位整数，440Hz 正弦 200ms，BlockGroup 内 Xiph lacing，每块 4 帧各 5ms
+	var lacedBlocks [][]byte
+	for b := 0; b < 10; b++ {
+		var frames [][]byte
+		for f := 0; f < 4; f++ {
+			var frame []byte
+			for i := 0; i < 80; i++ {
+				n := (b*4+f)*80 + i
+				v := int16(math.Round(16384 * math.Sin(2*math.Pi*440*float64(n)/16000)))
+				frame = binary.LittleEndian.AppendUint16(frame, uint16(v))
+				frame = binary.LittleEndian.AppendUint16(frame, uint16(v))
+			}
+			frames = append(frames, frame)
+		}
+		lacedBlocks = append(lacedBlocks, element(0xA0, element(0xA1, xiphLacedBlock(1, frames))))
+	}
+	write("tone_pcm_int16_laced.webm", webm(audioTrack("A_PCM/INT/LIT", 16000, 2, 16, nil), lacedBlocks))
+
+	// 10 个 20ms 的 Opus 包
+	var opusBlocks [][]byte
+	var opusPackets [][]byte
+	for i := 0; i < 10; i++ {
+		opusBlocks = append(opusBlocks, simpleBlock(1, []byte{0xF8}))
+		opusPackets = append(opusPackets, []byte{0xF8})
+	}
+	write("silence_opus.webm", webm(audioTrack("A_OPUS", 48000, 1, 0, opusHead()), opusBlocks))
+	write("silence_opus.ogg", oggOpus(opusPackets))
+}
+
+func opusHead() []byte {
+	head := append([]byte("OpusHead"), 1, 1)
+	head = binary.LittleEndian.AppendUint16(head, opusPreSkip)
+	head = binary.LittleEndian.AppendUint32(head, 48000)
+	return append(head, 0, 0, 0)
+}
+
+// element 编码一个 EBML 元素，长度统一使用 8 字节
+func element(id uint32, body []byte) []byte {
+	out := idBytes(id)
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(body)))
+	size[0] = 0x01
+	return append(append(out, size...), body...)
+}
+
+// unknownSizeElement 编码未知长度的主元素头
+func unknownSizeElement(id uint32) []byte {
+	return append(idBytes(id), 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+}
+
+func idBytes(id uint32) []byte {
+	switch {
+	case id > 0xFFFFFF:
+		return []byte{byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)}
+	case id > 0xFFFF:
+		return []byte{byte(id >> 16), byte(id >> 8), byte(id)}
+	case id > 0xFF:
+		return []byte{byte(id >> 8), byte(id)}
+	}
+	return []byte{byte(id)}
+}
+
+func uintBody(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+func audioTrack(codec string, rate float64, channels, bits int, private []byte) []byte {
+	audio := append(element(0xB5, uintBody(math.Float64bits(rate))), element(0x9F, uintBody(uint64(channels)))...)
+	if bits > 0 {
+		audio = append(audio, element(0x6264, uintBody(uint64(bits)))...)
+	}
+	entry := element(0xD7, uintBody(1))
+	entry = append(entry, element(0x83, uintBody(2))...)
+	entry = append(entry, element(0x86, []byte(codec))...)
+	if private != nil {
+		entry = append(entry, element(0x63A2, private)...)
+	}
+	entry = append(entry, element(0xE1, audio)...)
+	return element(0x1654AE6B, element(0xAE, entry))
+}
+
+func webm(tracks []byte, blocks [][]byte) []byte {
+	out := element(0x1A45DFA3, append(element(0x4286, uintBody(1)), element(0x4282, []byte("webm"))...))
+	out = append(out, unknownSizeElement(0x18538067)...)
+	out = append(out, element(0x1549A966, element(0x2AD7B1, uintBody(1000000)))...)
+	out = append(out, tracks...)
+	out = append(out, unknownSizeElement(0x1F43B675)...)
+	out = append(out, element(0xE7, uintBody(0))...)
+	for _, b := range blocks {
+		out = append(out, b...)
+	}
+	return out
+}
+
+func simpleBlock(track byte, frame []byte) []byte {
+	return element(0xA3, append([]byte{0x80 | track, 0, 0, 0x80}, frame...))
+}
+
+func xiphLacedBlock(track byte, frames [][]byte) []byte {
+	out := []byte{0x80 | track, 0, 0, 0x02, byte(len(frames) - 1)}
+	for _, f := range frames[:len(frames)-1] {
+		n := len(f)
+		for ; n >= 255; n -= 255 {
+			out = append(out, 255)
+		}
+		out = append(out, byte(n))
+	}
+	for _, f := range frames {
+		out = append(out, f...)
+	}
+	return out
+}
+
+// oggOpus 把 OpusHead、OpusTags 和音频包各自写入一页
+func oggOpus(packets [][]byte) []byte {
+	tags := append([]byte("OpusTags"), 4, 0, 0, 0)
+	tags = append(tags, "test"...)
+	tags = append(tags, 0, 0, 0, 0)
+
+	out := oggPage(0x02, 0, 0, opusHead())
+	out = append(out, oggPage(0, 0, 1, tags)...)
+	granule := uint64(0)
+	for i, p := range packets {
+		granule += 960
+		headerType := byte(0)
+		if i == len(packets)-1 {
+			headerType = 0x04
+			granule = uint64(len(packets)*960 - 100) // 末尾有 100 个填充样本
+		}
+		out = append(out, oggPage(headerType, granule, uint32(i+2), p)...)
+	}
+	return out
+}
+
+func oggPage(headerType byte, granule uint64, seq uint32, packet []byte) []byte {
+	page := []byte("OggS")
+	page = append(page, 0, headerType)
+	page = binary.LittleEndian.AppendUint64(page, granule)
+	page = binary.LittleEndian.AppendUint32(page, 0x5eed)
+	page = binary.LittleEndian.AppendUint32(page, seq)
+	page = append(page, 0, 0, 0, 0)
+	var lacing []byte
+	n := len(packet)
+	for ; n >= 255; n -= 255 {
+		lacing = append(lacing, 255)
+	}
+	lacing = append(lacing, byte(n))
+	page = append(page, byte(len(lacing)))
+	page = append(page, lacing...)
+	page = append(page, packet...)
+	binary.LittleEndian.PutUint32(page[22:], crc(page))
+	return page
+}
+
+func crc(data []byte) uint32 {
+	var c uint32
+	for _, b := range data {
+		c ^= uint32(b) << 24
+		for i := 0; i < 8; i++ {
+			if c&0x80000000 != 0 {
+				c = c<<1 ^ 0x04C11DB7
+			} else {
+				c <<= 1
+			}
+		}
+	}
+	return c
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrInvalidTextInput 客户端文字输入为空或过长
+var ErrInvalidTextInput = errors.New("invalid text input")
+
+// maxTextInputLength 单条文字输入的最大字符数
+const maxTextInputLength = 4096
+
+// ResolveResponseModalities 确定单轮响应的输出方式，未指定时使用会话配置
+// 上游不支持只输出语音，请求语音时同时返回语音转写
+func (s *RealtimeService) ResolveResponseModalities(session *RealtimeSession, requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return session.Config().Modalities, nil
+	}
+	modalities, err := normalizeModalities(requested)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSessionConfig, err)
+	}
+	return modalities, nil
+}
+
+// SendTextInput 以用户文字消息开始一轮对话，助手正在回复时先打断
+// 文字项由上游的 conversation.item.created 事件回传，与语音转写走同一条记录路径
+func (s *RealtimeService) SendTextInput(session *RealtimeSession, text string, modalities []string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return fmt.Errorf("%w: text is empty", ErrInvalidTextInput)
+	}
+	if utf8.RuneCountInString(text) > maxTextInputLength {
+		return fmt.Errorf("%w: text exceeds %d characters", ErrInvalidTextInput, maxTextInputLength)
+	}
+
+	modalities, err := s.ResolveResponseModalities(session, modalities)
+	if err != nil {
+		return err
+	}
+
+	if err := s.InterruptResponse(session, InterruptReasonTextInput, -1); err != nil {
+		return err
+	}
+
+	if err := s.WriteJSON(session.Upstream(), map[string]interface{}{
+		"type": "conversation.item.create",
+		"item": map[string]interface{}{
+			"type": "message",
+			"role": "user",
+			"content": []map[string]interface{}{
+				{"type": "input_text", "text": text},
+			},
+		},
+	}, 5*time.Second); err != nil {
+		return fmt.Errorf("failed to send text input: %v", err)
+	}
+
+	return s.RequestResponse(session, modalities)
+}
+
+// RequestResponse 请求上游生成一轮响应
+// 关闭服务器 VAD 时提交音频后需要显式请求，文字输入也通过它触发回复
+func (s *RealtimeService) RequestResponse(session *RealtimeSession, modalities []string) error {
+	if len(modalities) == 0 {
+		modalities = session.Config().Modalities
+	}
+
+	if err := s.WriteJSON(session.Upstream(), map[string]interface{}{
+		"type": "response.create",
+		"response": map[string]interface{}{
+			"modalities": modalities,
+		},
+	}, 5*time.Second); err != nil {
+		return fmt.Errorf("failed to request response: %v", err)
+	}
+	return nil
+}
+
+// userTextItem 从 conversation.item.created 事件中取出用户文字消息
+func userTextItem(event map[string]interface{}) (string, string, bool) {
+	item, ok := event["item"].(map[string]interface{})
+	if !ok || item["role"] != "user" {
+		return "", "", false
+	}
+	itemID, _ := item["id"].(string)
+	if strings.HasPrefix(itemID, replayItemPrefix) {
+		return "", "", false // 重连回放的历史消息，不是新的用户输入
+	}
+	content, _ := item["content"].([]interface{})
+
+	var text strings.Builder
+	for _, part := range content {
+		p, ok := part.(map[string]interface{})
+		if !ok || p["type"] != "input_text" {
+			continue
+		}
+		if t, ok := p["text"].(string); ok {
+			text.WriteString(t)
+		}
+	}
+	if text.Len() == 0 {
+		return "", "", false
+	}
+
+	return itemID, text.String(), true
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSendTextInput(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+
+	clientConn, client := wsPair(t)
+	gptConn, upstream := wsPair(t)
+	session := service.NewSession(uuid.New(), uuid.New(), clientConn, gptConn, nil)
+	t.Cleanup(func() { service.EndSession(session) })
+	go service.HandleRealtimeResponse(session)
+
+	if err := service.SendTextInput(session, "  现在几点了？ ", []string{"text"}); err != nil {
+		t.Fatalf("SendTextInput failed: %v", err)
+	}
+
+	create := readUpstream(t, upstream, "conversation.item.create")
+	item := create["item"].(map[string]interface{})
+	content := item["content"].([]interface{})[0].(map[string]interface{})
+	if item["role"] != "user" || content["type"] != "input_text" || content["text"] != "现在几点了？" {
+		t.Errorf("Unexpected conversation item: %v", item)
+	}
+
+	response := readUpstream(t, upstream, "response.create")
+	modalities := response["response"].(map[string]interface{})["modalities"].([]interface{})
+	if len(modalities) != 1 || modalities[0] != "text" {
+		t.Errorf("Expected a text-only response, got %v", modalities)
+	}
+
+	// 上游确认文字项后，作为用户这一轮转发给客户端
+	upstream.WriteJSON(map[string]interface{}{
+		"type": "conversation.item.created",
+		"item": map[string]interface{}{
+			"id":      "item_text",
+			"type":    "message",
+			"role":    "user",
+			"content": []map[string]interface{}{{"type": "input_text", "text": "现在几点了？"}},
+		},
+	})
+	messages := readClientMessages(t, client, "user_transcript")
+	if msg := messages["user_transcript"]; msg["item_id"] != "item_text" || msg["text"] != "现在几点了？" {
+		t.Errorf("Unexpected user transcript: %v", msg)
+	}
+}
+
+func TestSendTextInput_Validation(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+	session := &RealtimeSession{config: DefaultRealtimeSessionConfig()}
+
+	if err := service.SendTextInput(session, "   ", nil); !errors.Is(err, ErrInvalidTextInput) {
+		t.Errorf("Expected ErrInvalidTextInput for empty text, got %v", err)
+	}
+	long := strings.Repeat("字", maxTextInputLength+1)
+	if err := service.SendTextInput(session, long, nil); !errors.Is(err, ErrInvalidTextInput) {
+		t.Errorf("Expected ErrInvalidTextInput for long text, got %v", err)
+	}
+	if err := service.SendTextInput(session, "hello", []string{"video"}); !errors.Is(err, ErrInvalidSessionConfig) {
+		t.Errorf("Expected ErrInvalidSessionConfig for unsupported modality, got %v", err)
+	}
+}
+
+func TestResolveResponseModalities(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+	session := &RealtimeSession{config: DefaultRealtimeSessionConfig()}
+
+	modalities, err := service.ResolveResponseModalities(session, nil)
+	if err != nil || strings.Join(modalities, ",") != strings.Join(session.Config().Modalities, ",") {
+		t.Errorf("Expected session modalities, got %v (%v)", modalities, err)
+	}
+
+	modalities, err = service.ResolveResponseModalities(session, []string{"audio"})
+	if err != nil || strings.Join(modalities, ",") != "text,audio" {
+		t.Errorf("Expected audio response to include transcript, got %v (%v)", modalities, err)
+	}
+}
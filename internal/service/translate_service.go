@@ -1,6 +1,9 @@
 package service
 
 import (
+	"context"
+	"io"
+	"log"
 	"smart-glasses-backend/internal/model"
 	"smart-glasses-backend/internal/repository"
 	"smart-glasses-backend/pkg/azure"
@@ -8,26 +11,59 @@ import (
 	"github.com/google/uuid"
 )
 
+// exportFlushInterval 导出时每写出多少行刷新一次
+const exportFlushInterval = 100
+
+// token_usage.source 取值
+const (
+	tokenSourceText   = "text"
+	tokenSourceStream = "stream"
+)
+
 type TranslateService struct {
 	azureClient   *azure.OpenAIClient
 	translateRepo *repository.TranslateRepository
 	tokenRepo     *repository.TokenRepository
+	memoryRepo    *repository.TranslationMemoryRepository
+	languages     *LanguageRegistry
 }
 
-func NewTranslateService(azureClient *azure.OpenAIClient, translateRepo *repository.TranslateRepository, tokenRepo *repository.TokenRepository) *TranslateService {
+func NewTranslateService(azureClient *azure.OpenAIClient, translateRepo *repository.TranslateRepository, tokenRepo *repository.TokenRepository, memoryRepo *repository.TranslationMemoryRepository, languages *LanguageRegistry) *TranslateService {
 	return &TranslateService{
 		azureClient:   azureClient,
 		translateRepo: translateRepo,
 		tokenRepo:     tokenRepo,
+		memoryRepo:    memoryRepo,
+		languages:     languages,
 	}
 }
 
-func (s *TranslateService) TranslateText(userID uuid.UUID, req *model.TranslateRequest) (*model.TranslateResponse, error) {
-	// Call Azure OpenAI
-	translatedText, inputTokens, outputTokens, err := s.azureClient.Translate(req.Text, req.SourceLanguage, req.TargetLanguage)
+// NormalizeLanguagePair 将请求中的语言解析为规范代码，不支持的语言返回 ErrUnsupportedLanguage
+func (s *TranslateService) NormalizeLanguagePair(sourceLanguage, targetLanguage string) (string, string, error) {
+	return s.languages.NormalizePair(sourceLanguage, targetLanguage)
+}
+
+func (s *TranslateService) TranslateText(ctx context.Context, userID uuid.UUID, req *model.TranslateRequest) (*model.TranslateResponse, error) {
+	var translatedText string
+	var inputTokens, outputTokens int
+
+	sourceLanguage, targetLanguage, err := s.NormalizeLanguagePair(req.SourceLanguage, req.TargetLanguage)
 	if err != nil {
 		return nil, err
 	}
+	req.SourceLanguage, req.TargetLanguage = sourceLanguage, targetLanguage
+
+	if entry := s.lookupMemory(userID, req.Text, req.SourceLanguage, req.TargetLanguage); entry != nil {
+		// Use the user's corrected translation
+		translatedText = entry.TranslatedText
+	} else {
+		// Call Azure OpenAI
+		var err error
+		translatedText, inputTokens, outputTokens, err = s.azureClient.Translate(ctx, req.Text, req.SourceLanguage, req.TargetLanguage)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// Save to history
 	translation := &model.Translation{
@@ -43,11 +79,7 @@ func (s *TranslateService) TranslateText(userID uuid.UUID, req *model.TranslateR
 	}
 
 	// Save token usage (async, don't block)
-	go func() {
-		if inputTokens > 0 || outputTokens > 0 {
-			_ = s.tokenRepo.Create(userID, inputTokens, outputTokens)
-		}
-	}()
+	s.recordTokenUsage(userID, inputTokens, outputTokens, false, tokenSourceText)
 
 	return &model.TranslateResponse{
 		TranslatedText: translatedText,
@@ -56,12 +88,77 @@ func (s *TranslateService) TranslateText(userID uuid.UUID, req *model.TranslateR
 	}, nil
 }
 
-func (s *TranslateService) TranslateStream(userID uuid.UUID, text, sourceLanguage, targetLanguage string, callback func(string) error) error {
-	return s.azureClient.TranslateStream(text, sourceLanguage, targetLanguage, callback)
+func (s *TranslateService) TranslateStream(ctx context.Context, userID uuid.UUID, text, sourceLanguage, targetLanguage string, callback func(string) error) error {
+	sourceLanguage, targetLanguage, err := s.NormalizeLanguagePair(sourceLanguage, targetLanguage)
+	if err != nil {
+		return err
+	}
+
+	if entry := s.lookupMemory(userID, text, sourceLanguage, targetLanguage); entry != nil {
+		return callback(entry.TranslatedText)
+	}
+
+	// 即使流中途失败或被取消，已消耗的用量也要记录
+	usage, err := s.azureClient.TranslateStream(ctx, text, sourceLanguage, targetLanguage, callback)
+	if usage != nil {
+		s.recordTokenUsage(userID, usage.PromptTokens, usage.CompletionTokens, usage.Estimated, tokenSourceStream)
+	}
+	return err
+}
+
+// recordTokenUsage 异步保存 token 用量，不阻塞翻译响应
+func (s *TranslateService) recordTokenUsage(userID uuid.UUID, inputTokens, outputTokens int, estimated bool, source string) {
+	if inputTokens <= 0 && outputTokens <= 0 {
+		return
+	}
+
+	go func() {
+		if err := s.tokenRepo.Create(userID, inputTokens, outputTokens, estimated, source); err != nil {
+			log.Printf("Failed to record token usage for user %s: %v", userID, err)
+		}
+	}()
 }
 
-func (s *TranslateService) GetHistory(userID uuid.UUID, limit, offset int) ([]*model.TranslationHistoryResponse, error) {
-	translations, err := s.translateRepo.GetHistory(userID, limit, offset)
+// lookupMemory 查找用户翻译记忆，查询失败时退回模型翻译
+func (s *TranslateService) lookupMemory(userID uuid.UUID, text, sourceLanguage, targetLanguage string) *model.TranslationMemoryEntry {
+	if s.memoryRepo == nil {
+		return nil
+	}
+
+	entry, err := s.memoryRepo.Lookup(userID, sourceLanguage, targetLanguage, text)
+	if err != nil {
+		log.Printf("Translation memory lookup failed for user %s: %v", userID, err)
+		return nil
+	}
+	return entry
+}
+
+// NormalizeHistoryFilter 过滤条件中的语言按规范代码匹配
+func (s *TranslateService) NormalizeHistoryFilter(filter *model.HistoryFilter) error {
+	if filter == nil {
+		return nil
+	}
+
+	var err error
+	if filter.SourceLanguage != "" {
+		if filter.SourceLanguage, err = s.languages.Normalize(filter.SourceLanguage); err != nil {
+			return err
+		}
+	}
+	if filter.TargetLanguage != "" {
+		if filter.TargetLanguage, err = s.languages.Normalize(filter.TargetLanguage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *TranslateService) GetHistory(userID uuid.UUID, filter *model.HistoryFilter) ([]*model.TranslationHistoryResponse, error) {
+	if err := s.NormalizeHistoryFilter(filter); err != nil {
+		return nil, err
+	}
+
+	translations, err := s.translateRepo.GetHistory(userID, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -81,6 +178,70 @@ func (s *TranslateService) GetHistory(userID uuid.UUID, limit, offset int) ([]*m
 	return result, nil
 }
 
+// ExportHistory 按过滤条件将翻译历史以指定格式流式写出
+func (s *TranslateService) ExportHistory(ctx context.Context, userID uuid.UUID, filter *model.HistoryFilter, format ExportFormat, w io.Writer) error {
+	if err := s.NormalizeHistoryFilter(filter); err != nil {
+		return err
+	}
+
+	exporter := newHistoryExporter(format, w)
+	if err := exporter.writeHeader(); err != nil {
+		return err
+	}
+
+	rowCount := 0
+	err := s.translateRepo.StreamHistory(ctx, userID, filter, func(t *model.Translation) error {
+		if err := exporter.writeRow(t); err != nil {
+			return err
+		}
+		rowCount++
+		// 定期刷新，让客户端尽早收到数据
+		if rowCount%exportFlushInterval == 0 {
+			return exporter.flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return exporter.flush()
+}
+
 func (s *TranslateService) SaveTranslation(translation *model.Translation) error {
+	sourceLanguage, targetLanguage, err := s.NormalizeLanguagePair(translation.SourceLanguage, translation.TargetLanguage)
+	if err != nil {
+		return err
+	}
+	translation.SourceLanguage, translation.TargetLanguage = sourceLanguage, targetLanguage
+
 	return s.translateRepo.Create(translation)
 }
+
+// NormalizeStoredLanguages 将历史记录中的非规范语言值改写为规范代码
+// 无法识别的值保持不变并记录日志
+func (s *TranslateService) NormalizeStoredLanguages() error {
+	values, err := s.translateRepo.DistinctLanguages()
+	if err != nil {
+		return err
+	}
+
+	for _, value := range values {
+		code, err := s.languages.Normalize(value)
+		if err != nil {
+			log.Printf("Language backfill: leaving unrecognized value %q unchanged", value)
+			continue
+		}
+		if code == value {
+			continue
+		}
+
+		updated, err := s.translateRepo.RenameLanguage(value, code)
+		if err != nil {
+			return err
+		}
+		log.Printf("Language backfill: normalized %q to %q in %d history rows", value, code, updated)
+	}
+
+	return nil
+}
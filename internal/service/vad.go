@@ -0,0 +1,188 @@
+package service
+
+import (
+	"fmt"
+	"math"
+)
+
+// 本地静音抑制模式
+const (
+	SilenceModeDrop     = "drop"     // 丢弃拖尾期之后的静音
+	SilenceModeCompress = "compress" // 按比例保留静音，长停顿被缩短但不消失
+)
+
+// 本地 VAD 参数
+const (
+	vadFrameMs          = 10    // 分析帧长
+	vadOnsetFrames      = 2     // 连续多少帧判为语音才算开口，过滤按键声等瞬态
+	vadNoiseMarginDB    = 9.0   // 语音能量至少高出噪声基底的分贝数
+	vadMaxZCR           = 0.35  // 过零率高于该值视为噪声，清辅音在语音段内由拖尾期覆盖，开口时由预留静音补回
+	vadInitialFloorDB   = -60.0 // 噪声基底初值
+	vadMinFloorDB       = -90.0
+	silencePreRollMs    = 200 // 丢弃的静音中保留最近这段，开口时一起发送避免吞掉首音
+	silenceCompressRate = 4   // compress 模式下静音按 1/4 的时长发送
+)
+
+// 静音抑制参数范围与默认值
+const (
+	defaultSilenceHangoverMs  = 300
+	defaultSilenceThresholdDB = -45.0
+	maxSilenceHangoverMs      = 2000
+	minSilenceThresholdDB     = -70.0
+	maxSilenceThresholdDB     = -20.0
+)
+
+// SilenceSuppressionConfig 服务端本地 VAD 与静音抑制参数
+type SilenceSuppressionConfig struct {
+	Enabled     bool    `json:"enabled"`
+	Mode        string  `json:"mode"`         // drop 或 compress
+	HangoverMs  int     `json:"hangover_ms"`  // 语音结束后仍按语音发送的时长
+	ThresholdDB float64 `json:"threshold_db"` // 语音能量下限（dBFS）
+	AutoCommit  bool    `json:"auto_commit"`  // 未启用服务器 VAD 时，语音结束后自动提交并请求回复
+}
+
+// DefaultSilenceSuppressionConfig 默认关闭，开启后丢弃静音
+func DefaultSilenceSuppressionConfig() SilenceSuppressionConfig {
+	return SilenceSuppressionConfig{
+		Mode:        SilenceModeDrop,
+		HangoverMs:  defaultSilenceHangoverMs,
+		ThresholdDB: defaultSilenceThresholdDB,
+	}
+}
+
+// validate 检查参数范围
+func (c SilenceSuppressionConfig) validate() error {
+	if c.Mode != SilenceModeDrop && c.Mode != SilenceModeCompress {
+		return fmt.Errorf("unsupported silence mode %q", c.Mode)
+	}
+	if c.HangoverMs < 0 || c.HangoverMs > maxSilenceHangoverMs {
+		return fmt.Errorf("silence hangover_ms must be between 0 and %d", maxSilenceHangoverMs)
+	}
+	if c.ThresholdDB < minSilenceThresholdDB || c.ThresholdDB > maxSilenceThresholdDB {
+		return fmt.Errorf("silence threshold_db must be between %.0f and %.0f", minSilenceThresholdDB, maxSilenceThresholdDB)
+	}
+	return nil
+}
+
+// VoiceActivity 本地 VAD 自上次读取以来的统计
+type VoiceActivity struct {
+	SpeechMs     float64 `json:"speech_ms"`
+	SilenceMs    float64 `json:"silence_ms"`
+	SuppressedMs float64 `json:"suppressed_ms"` // 未发送给上游的静音
+	SpeechEnded  bool    `json:"speech_ended"`  // 期间有一段语音在拖尾期后结束
+}
+
+// add 合并另一段统计
+func (v *VoiceActivity) add(o VoiceActivity) {
+	v.SpeechMs += o.SpeechMs
+	v.SilenceMs += o.SilenceMs
+	v.SuppressedMs += o.SuppressedMs
+	v.SpeechEnded = v.SpeechEnded || o.SpeechEnded
+}
+
+// vadResult 一个音频块的检测结果
+type vadResult struct {
+	VoiceActivity
+	Active bool // 块内有语音或仍在拖尾期，需要发送
+}
+
+// VoiceActivityDetector 基于短时能量和过零率的语音活动检测
+// 噪声基底在静音时缓慢跟踪环境噪声，能量明显高于基底且过零率不像噪声的帧判为语音；
+// 语音结束后保持 hangover 时长，避免字间停顿被切断
+type VoiceActivityDetector struct {
+	thresholdDB    float64
+	hangoverFrames int
+
+	noiseFloorDB float64
+	onset        int  // 连续语音帧数
+	hangLeft     int  // 剩余拖尾帧数
+	speaking     bool // 处于语音段（含拖尾期）
+	pending      []int16
+}
+
+// NewVoiceActivityDetector 创建检测器，thresholdDB 为语音能量下限
+func NewVoiceActivityDetector(thresholdDB float64, hangoverMs int) *VoiceActivityDetector {
+	return &VoiceActivityDetector{
+		thresholdDB:    thresholdDB,
+		hangoverFrames: hangoverMs / vadFrameMs,
+		noiseFloorDB:   vadInitialFloorDB,
+	}
+}
+
+// Process 检测一块单声道 PCM 样本，不足一帧的尾部留到下一块
+func (d *VoiceActivityDetector) Process(samples []int16, rate int) vadResult {
+	var res vadResult
+	frameLen := rate * vadFrameMs / 1000
+	if frameLen <= 0 {
+		return res
+	}
+
+	buf := samples
+	if len(d.pending) > 0 {
+		buf = append(d.pending, samples...)
+	}
+	for len(buf) >= frameLen {
+		speech := d.classify(buf[:frameLen])
+		buf = buf[frameLen:]
+
+		if speech {
+			res.SpeechMs += vadFrameMs
+			d.onset++
+		} else {
+			res.SilenceMs += vadFrameMs
+			d.onset = 0
+		}
+
+		switch {
+		case speech && (d.speaking || d.onset >= vadOnsetFrames):
+			d.speaking = true
+			d.hangLeft = d.hangoverFrames
+		case d.speaking && d.hangLeft > 0:
+			d.hangLeft--
+		case d.speaking:
+			d.speaking = false
+			res.SpeechEnded = true
+		}
+		res.Active = res.Active || d.speaking
+	}
+	d.pending = append(d.pending[:0], buf...)
+	return res
+}
+
+// classify 判断一帧是否为语音，非语音帧用于更新噪声基底
+func (d *VoiceActivityDetector) classify(frame []int16) bool {
+	var sum float64
+	crossings := 0
+	for i, s := range frame {
+		v := float64(s)
+		sum += v * v
+		if i > 0 && (s >= 0) != (frame[i-1] >= 0) {
+			crossings++
+		}
+	}
+	rms := math.Sqrt(sum / float64(len(frame)))
+	energyDB := vadMinFloorDB
+	if rms > 0 {
+		energyDB = math.Max(20*math.Log10(rms/32768), vadMinFloorDB)
+	}
+	zcr := float64(crossings) / float64(len(frame))
+
+	speech := energyDB > d.thresholdDB && energyDB-d.noiseFloorDB > vadNoiseMarginDB && zcr < vadMaxZCR
+
+	// 噪声变小时快速下降，变大时缓慢上升；语音帧只以极慢的速度抬高基底，
+	// 持续数秒不变的嗡嗡声最终会被当作背景噪声
+	switch {
+	case energyDB < d.noiseFloorDB:
+		d.noiseFloorDB += (energyDB - d.noiseFloorDB) * 0.5
+	case !speech:
+		d.noiseFloorDB += (energyDB - d.noiseFloorDB) * 0.05
+	default:
+		d.noiseFloorDB += (energyDB - d.noiseFloorDB) * 0.002
+	}
+	return speech
+}
+
+// Speaking 当前是否处于语音段
+func (d *VoiceActivityDetector) Speaking() bool {
+	return d.speaking
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// generateNoise 生成均匀分布的白噪声 PCM16，固定种子保证可重复
+func generateNoise(rate int, amplitude float64, durationMs int) []byte {
+	rng := rand.New(rand.NewSource(1))
+	samples := rate * durationMs / 1000
+	out := make([]byte, 0, samples*2)
+	for i := 0; i < samples; i++ {
+		v := int16((rng.Float64()*2 - 1) * amplitude)
+		out = binary.LittleEndian.AppendUint16(out, uint16(v))
+	}
+	return out
+}
+
+// splitChunks 把音频按 chunkBytes 切块
+func splitChunks(data []byte, chunkBytes int) [][]byte {
+	var chunks [][]byte
+	for len(data) > 0 {
+		n := chunkBytes
+		if n > len(data) {
+			n = len(data)
+		}
+		chunks = append(chunks, data[:n])
+		data = data[n:]
+	}
+	return chunks
+}
+
+func TestVoiceActivityDetector_Classification(t *testing.T) {
+	cases := []struct {
+		name   string
+		audio  []byte
+		speech bool
+	}{
+		{"tone", generateTone(16000, 300, 6000, 300, 1), true},
+		{"digital silence", make([]byte, 16000/1000*300*2), false},
+		{"quiet noise", generateNoise(16000, 100, 300), false},
+		{"loud white noise", generateNoise(16000, 4000, 300), false},
+	}
+	for _, tc := range cases {
+		d := NewVoiceActivityDetector(defaultSilenceThresholdDB, 0)
+		res := d.Process(pcm16Samples(tc.audio), 16000)
+		if res.Active != tc.speech {
+			t.Errorf("%s: expected active=%v, got %v (speech %.0fms, silence %.0fms)", tc.name, tc.speech, res.Active, res.SpeechMs, res.SilenceMs)
+		}
+		if res.SpeechMs+res.SilenceMs != 300 {
+			t.Errorf("%s: expected 300ms classified, got %.0fms", tc.name, res.SpeechMs+res.SilenceMs)
+		}
+	}
+}
+
+func TestVoiceActivityDetector_HangoverAndOnset(t *testing.T) {
+	d := NewVoiceActivityDetector(defaultSilenceThresholdDB, 100)
+
+	// 10ms 的瞬态不算开口
+	click := append(generateTone(16000, 300, 6000, 10, 1), make([]byte, 16000/1000*40*2)...)
+	if res := d.Process(pcm16Samples(click), 16000); res.Active {
+		t.Error("Expected a single-frame click not to start speech")
+	}
+
+	if res := d.Process(pcm16Samples(generateTone(16000, 300, 6000, 200, 1)), 16000); !res.Active || res.SpeechEnded {
+		t.Fatalf("Expected speech to start, got %+v", res)
+	}
+
+	// 拖尾期内仍然活跃，之后报告语音结束
+	silence := make([]byte, 16000/1000*50*2)
+	if res := d.Process(pcm16Samples(silence), 16000); !res.Active || res.SpeechEnded {
+		t.Errorf("Expected hangover to keep speech active, got %+v", res)
+	}
+	res := d.Process(pcm16Samples(silence), 16000)
+	res2 := d.Process(pcm16Samples(silence), 16000)
+	if !res.SpeechEnded && !res2.SpeechEnded {
+		t.Error("Expected speech to end after the hangover")
+	}
+	if res2.Active || d.Speaking() {
+		t.Error("Expected detector to be idle after the hangover")
+	}
+
+	// 不足一帧的样本留到下一块
+	if res := d.Process(pcm16Samples(generateTone(16000, 300, 6000, 5, 1)), 16000); res.SpeechMs+res.SilenceMs != 0 {
+		t.Errorf("Expected partial frame to be buffered, got %+v", res)
+	}
+}
+
+func TestSilenceSuppression_DropWithPreRoll(t *testing.T) {
+	ap := NewAudioProcessor()
+	cfg := DefaultSilenceSuppressionConfig()
+	cfg.Enabled = true
+	cfg.HangoverMs = 100
+	if err := ap.SetSilenceSuppression(cfg); err != nil {
+		t.Fatalf("SetSilenceSuppression failed: %v", err)
+	}
+
+	chunkBytes := 16000 / 1000 * 20 * 2 // 20ms
+	for i, chunk := range splitChunks(make([]byte, chunkBytes*25), chunkBytes) {
+		out, err := ap.ProcessRealtimeAudioChunk(chunk)
+		if err != nil || len(out) != 0 {
+			t.Fatalf("Expected silent chunk %d to be dropped, got %d bytes (%v)", i, len(out), err)
+		}
+	}
+
+	// 开口时补发最近 200ms 的静音
+	out, err := ap.ProcessRealtimeAudioChunk(generateTone(16000, 300, 6000, 40, 1))
+	if err != nil {
+		t.Fatalf("ProcessRealtimeAudioChunk failed: %v", err)
+	}
+	if expected := chunkBytes*silencePreRollMs/20 + chunkBytes*2; len(out) != expected {
+		t.Errorf("Expected %d bytes with pre-roll, got %d", expected, len(out))
+	}
+
+	activity := ap.TakeVoiceActivity()
+	if activity.SuppressedMs != 500-silencePreRollMs {
+		t.Errorf("Expected %dms suppressed, got %.0fms", 500-silencePreRollMs, activity.SuppressedMs)
+	}
+	if activity.SpeechMs != 40 || activity.SilenceMs != 500 {
+		t.Errorf("Unexpected activity %+v", activity)
+	}
+	if ap.TakeVoiceActivity() != (VoiceActivity{}) {
+		t.Error("Expected TakeVoiceActivity to reset the counters")
+	}
+
+	// 关闭后原样返回
+	cfg.Enabled = false
+	ap.SetSilenceSuppression(cfg)
+	if out, _ := ap.ProcessRealtimeAudioChunk(make([]byte, chunkBytes)); len(out) != chunkBytes {
+		t.Errorf("Expected silence to pass through when disabled, got %d bytes", len(out))
+	}
+}
+
+func TestSilenceSuppression_Compress(t *testing.T) {
+	ap := NewAudioProcessor()
+	cfg := DefaultSilenceSuppressionConfig()
+	cfg.Enabled = true
+	cfg.Mode = SilenceModeCompress
+	if err := ap.SetSilenceSuppression(cfg); err != nil {
+		t.Fatalf("SetSilenceSuppression failed: %v", err)
+	}
+
+	chunkBytes := 16000 / 1000 * 20 * 2
+	forwarded := 0
+	for _, chunk := range splitChunks(make([]byte, chunkBytes*40), chunkBytes) {
+		out, _ := ap.ProcessRealtimeAudioChunk(chunk)
+		forwarded += len(out)
+	}
+	if expected := chunkBytes * 40 / silenceCompressRate; forwarded != expected {
+		t.Errorf("Expected %d bytes of compressed silence, got %d", expected, forwarded)
+	}
+}
+
+func TestSilenceSuppressionConfig_Validate(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+
+	enabled, mode, hangover := true, "compress", 250
+	cfg, err := service.ResolveSessionConfig(nil, &RealtimeSessionConfigRequest{
+		SilenceSuppression: &SilenceSuppressionRequest{Enabled: &enabled, Mode: mode, HangoverMs: &hangover},
+	})
+	if err != nil {
+		t.Fatalf("ResolveSessionConfig failed: %v", err)
+	}
+	if !cfg.SilenceSuppression.Enabled || cfg.SilenceSuppression.Mode != mode || cfg.SilenceSuppression.HangoverMs != hangover ||
+		cfg.SilenceSuppression.ThresholdDB != defaultSilenceThresholdDB {
+		t.Errorf("Unexpected silence suppression config %+v", cfg.SilenceSuppression)
+	}
+
+	badHangover, badThreshold := maxSilenceHangoverMs+1, -10.0
+	for name, req := range map[string]*SilenceSuppressionRequest{
+		"mode":      {Mode: "squash"},
+		"hangover":  {HangoverMs: &badHangover},
+		"threshold": {ThresholdDB: &badThreshold},
+	} {
+		if _, err := service.ResolveSessionConfig(nil, &RealtimeSessionConfigRequest{SilenceSuppression: req}); err == nil {
+			t.Errorf("%s: expected invalid silence suppression config to be rejected", name)
+		}
+	}
+}
+
+func TestSendSessionAudio_AutoCommitsOnSpeechEnd(t *testing.T) {
+	service := NewRealtimeService("test", "test", "test", "test")
+
+	clientConn, client := wsPair(t)
+	gptConn, upstream := wsPair(t)
+	cfg := DefaultRealtimeSessionConfig()
+	cfg.VAD.Enabled = false
+	cfg.SilenceSuppression.Enabled = true
+	cfg.SilenceSuppression.AutoCommit = true
+	cfg.SilenceSuppression.HangoverMs = 100
+	id := uuid.New()
+	service.GetPerformanceMonitor().StartAudioLatencyMonitoring(id.String(), "user")
+	session := service.NewSession(id, uuid.New(), clientConn, gptConn, cfg)
+	t.Cleanup(func() { service.EndSession(session) })
+
+	chunkBytes := RealtimeSampleRate / 1000 * 20 * 2
+	audio := append(generateTone(RealtimeSampleRate, 300, 6000, 200, 1), make([]byte, chunkBytes*25)...)
+	for _, chunk := range splitChunks(audio, chunkBytes) {
+		if err := service.SendSessionAudio(session, chunk); err != nil {
+			t.Fatalf("SendSessionAudio failed: %v", err)
+		}
+	}
+
+	// 语音和拖尾期的音频、提交、请求回复依次到达上游，之后的静音被丢弃
+	upstream.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var sentBytes int
+	var types []string
+	for len(types) == 0 || types[len(types)-1] != "response.create" {
+		var msg map[string]interface{}
+		if err := upstream.ReadJSON(&msg); err != nil {
+			t.Fatalf("Failed to read upstream message after %v: %v", types, err)
+		}
+		msgType, _ := msg["type"].(string)
+		if msgType == "input_audio_buffer.append" {
+			sentBytes += len(msg["audio"].(string)) / 4 * 3
+			continue
+		}
+		types = append(types, msgType)
+	}
+	if len(types) != 2 || types[0] != "input_audio_buffer.commit" {
+		t.Errorf("Expected commit then response.create, got %v", types)
+	}
+	if sentMs := sentBytes / (chunkBytes / 20); sentMs < 300 || sentMs > 340 {
+		t.Errorf("Expected about 300ms of speech and hangover upstream, got %dms", sentMs)
+	}
+
+	msgs := readClientMessages(t, client, "audio_committed")
+	if msgs["audio_committed"]["reason"] != "speech_ended" {
+		t.Errorf("Unexpected audio_committed message %v", msgs["audio_committed"])
+	}
+
+	metric := service.GetPerformanceMonitor().GetAudioLatencyMetrics(id.String())
+	if metric == nil || metric.SpeechDuration != 200*time.Millisecond || metric.SuppressedDuration != 400*time.Millisecond || metric.SpeechRatio < 0.28 || metric.SpeechRatio > 0.29 {
+		t.Errorf("Unexpected voice activity metrics %+v", metric)
+	}
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"math"
+)
+
+// WAV fmt 块的格式码
+const (
+	wavFormatPCM        uint16 = 0x0001
+	wavFormatIEEEFloat  uint16 = 0x0003
+	wavFormatExtensible uint16 = 0xFFFE
+)
+
+// 可以解码的输入采样率范围，采样率来自不可信的文件头，过大时重采样滤波器会耗尽内存
+const (
+	minInputSampleRate = 8000
+	maxInputSampleRate = 192000
+)
+
+// wavSubformatSuffix WAVE_FORMAT_EXTENSIBLE 子格式 GUID 中格式码之后的固定部分
+var wavSubformatSuffix = []byte{0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71}
+
+// wavFormat WAV fmt 块中解码需要的字段，EXTENSIBLE 已解析为子格式
+type wavFormat struct {
+	tag           uint16
+	channels      int
+	sampleRate    int
+	bitsPerSample int
+	blockAlign    int
+}
+
+// parseWAV 遍历 RIFF 块，返回 fmt 块和 data 块的内容
+// 未知块（LIST、fact、cue 等）被跳过；data 块长度超出文件时按实际长度处理，兼容流式录音写入的文件
+func parseWAV(wavData []byte) (*wavFormat, []byte, error) {
+	if len(wavData) < 12 || !bytes.Equal(wavData[0:4], []byte("RIFF")) || !bytes.Equal(wavData[8:12], []byte("WAVE")) {
+		return nil, nil, &AudioProcessingError{
+			Type:    "format_error",
+			Message: "Invalid WAV file format",
+			Details: "Missing RIFF/WAVE header",
+		}
+	}
+
+	var format *wavFormat
+	var data []byte
+	foundData := false
+	for pos := 12; pos+8 <= len(wavData); {
+		id := string(wavData[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(wavData[pos+4 : pos+8]))
+		body := wavData[pos+8:]
+
+		switch id {
+		case "fmt ":
+			if size > len(body) {
+				return nil, nil, &AudioProcessingError{
+					Type:    "format_error",
+					Message: "Invalid WAV file",
+					Details: fmt.Sprintf("fmt chunk size %d exceeds remaining %d bytes", size, len(body)),
+				}
+			}
+			f, err := parseWAVFormat(body[:size])
+			if err != nil {
+				return nil, nil, err
+			}
+			format = f
+		case "data":
+			if size > len(body) {
+				log.Printf("Warning: WAV data chunk size %d exceeds file, using %d bytes", size, len(body))
+				size = len(body)
+			}
+			data = body[:size]
+			foundData = true
+		}
+		if foundData && format != nil {
+			break
+		}
+
+		// 块按偶数字节对齐
+		next := pos + 8 + size + size%2
+		if next <= pos || next > len(wavData) {
+			break
+		}
+		pos = next
+	}
+
+	if format == nil {
+		return nil, nil, &AudioProcessingError{
+			Type:    "format_error",
+			Message: "Invalid WAV file",
+			Details: "Missing fmt chunk",
+		}
+	}
+	if !foundData {
+		return nil, nil, &AudioProcessingError{
+			Type:    "format_error",
+			Message: "Invalid WAV file",
+			Details: "Missing data chunk",
+		}
+	}
+	if len(data) < format.blockAlign {
+		return nil, nil, &AudioProcessingError{
+			Type:    "format_error",
+			Message: "WAV file has no audio data",
+			Details: "WAV data chunk contains no complete sample frame",
+		}
+	}
+	return format, data, nil
+}
+
+// parseWAVFormat 解析 fmt 块并检查是否为支持的采样格式
+func parseWAVFormat(chunk []byte) (*wavFormat, error) {
+	if len(chunk) < 16 {
+		return nil, &AudioProcessingError{
+			Type:    "format_error",
+			Message: "Invalid WAV file",
+			Details: fmt.Sprintf("fmt chunk is %d bytes, expected at least 16", len(chunk)),
+		}
+	}
+
+	f := &wavFormat{
+		tag:           binary.LittleEndian.Uint16(chunk[0:2]),
+		channels:      int(binary.LittleEndian.Uint16(chunk[2:4])),
+		sampleRate:    int(binary.LittleEndian.Uint32(chunk[4:8])),
+		blockAlign:    int(binary.LittleEndian.Uint16(chunk[12:14])),
+		bitsPerSample: int(binary.LittleEndian.Uint16(chunk[14:16])),
+	}
+
+	if f.tag == wavFormatExtensible {
+		// cbSize(2) + wValidBitsPerSample(2) + dwChannelMask(4) + SubFormat(16)
+		if len(chunk) < 40 {
+			return nil, &AudioProcessingError{
+				Type:    "format_error",
+				Message: "Invalid WAV file",
+				Details: "WAVE_FORMAT_EXTENSIBLE fmt chunk is missing the subformat",
+			}
+		}
+		subformat := chunk[24:40]
+		if !bytes.Equal(subformat[2:], wavSubformatSuffix) {
+			return nil, &AudioProcessingError{
+				Type:    "unsupported_format",
+				Message: "Unsupported WAV encoding",
+				Details: fmt.Sprintf("Unknown WAVE_FORMAT_EXTENSIBLE subformat %x", subformat),
+			}
+		}
+		f.tag = binary.LittleEndian.Uint16(subformat[0:2])
+	}
+
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// validate 检查声道数、采样率和样本格式是否可以解码
+func (f *wavFormat) validate() error {
+	if f.channels == 0 || f.sampleRate == 0 {
+		return &AudioProcessingError{
+			Type:    "format_error",
+			Message: "Invalid WAV file",
+			Details: fmt.Sprintf("Invalid format: %d channels at %d Hz", f.channels, f.sampleRate),
+		}
+	}
+	if f.sampleRate < minInputSampleRate || f.sampleRate > maxInputSampleRate {
+		return &AudioProcessingError{
+			Type:    "unsupported_format",
+			Message: "Unsupported sample rate",
+			Details: fmt.Sprintf("Sample rate %d Hz is outside the supported range %d-%d Hz", f.sampleRate, minInputSampleRate, maxInputSampleRate),
+		}
+	}
+
+	switch {
+	case f.tag == wavFormatPCM && (f.bitsPerSample == 8 || f.bitsPerSample == 16 || f.bitsPerSample == 24 || f.bitsPerSample == 32):
+	case f.tag == wavFormatIEEEFloat && (f.bitsPerSample == 32 || f.bitsPerSample == 64):
+	case f.tag == wavFormatPCM || f.tag == wavFormatIEEEFloat:
+		return &AudioProcessingError{
+			Type:    "unsupported_format",
+			Message: "Unsupported WAV bit depth",
+			Details: fmt.Sprintf("%d-bit samples are not supported for format 0x%04x", f.bitsPerSample, f.tag),
+		}
+	default:
+		return &AudioProcessingError{
+			Type:    "unsupported_format",
+			Message: "Unsupported WAV encoding",
+			Details: fmt.Sprintf("WAV format 0x%04x is not supported, only PCM and IEEE float", f.tag),
+		}
+	}
+
+	if f.blockAlign != f.channels*f.bitsPerSample/8 {
+		return &AudioProcessingError{
+			Type:    "format_error",
+			Message: "Invalid WAV file",
+			Details: fmt.Sprintf("Block align %d does not match %d channels of %d-bit samples", f.blockAlign, f.channels, f.bitsPerSample),
+		}
+	}
+	return nil
+}
+
+// decodeWAVData 把 data 块的样本转换为 PCM16 并平均为单声道，末尾不完整的帧被丢弃
+func decodeWAVData(f *wavFormat, data []byte) []byte {
+	sampleSize := f.bitsPerSample / 8
+	frames := len(data) / f.blockAlign
+	out := make([]byte, frames*2)
+	for i := 0; i < frames; i++ {
+		frame := data[i*f.blockAlign:]
+		var sum float64
+		for c := 0; c < f.channels; c++ {
+			sum += wavSample(f.tag, frame[c*sampleSize:], sampleSize)
+		}
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(clampInt16(sum/float64(f.channels))))
+	}
+	return out
+}
+
+// wavSample 读取一个样本并换算到 PCM16 的数值范围
+func wavSample(tag uint16, b []byte, size int) float64 {
+	if tag == wavFormatIEEEFloat {
+		var v float64
+		if size == 4 {
+			v = float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		} else {
+			v = math.Float64frombits(binary.LittleEndian.Uint64(b))
+		}
+		if math.IsNaN(v) {
+			return 0
+		}
+		return v * 32768
+	}
+
+	switch size {
+	case 1:
+		// 8 位 PCM 为无符号数
+		return float64(int(b[0])-128) * 256
+	case 2:
+		return float64(int16(binary.LittleEndian.Uint16(b)))
+	case 3:
+		return float64(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)) / 65536
+	default:
+		return float64(int32(binary.LittleEndian.Uint32(b))) / 65536
+	}
+}
+
+// writeWAVHeader 写出 PCM16 WAV 文件头，dataLen 为随后 data 块的字节数
+func writeWAVHeader(w io.Writer, sampleRate, channels int, dataLen uint32) error {
+	header := make([]byte, 44)
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], 36+dataLen)
+	copy(header[8:16], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:20], 16)
+	binary.LittleEndian.PutUint16(header[20:22], wavFormatPCM)
+	binary.LittleEndian.PutUint16(header[22:24], uint16(channels))
+	binary.LittleEndian.PutUint32(header[24:28], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(header[28:32], uint32(sampleRate*channels*2))
+	binary.LittleEndian.PutUint16(header[32:34], uint16(channels*2))
+	binary.LittleEndian.PutUint16(header[34:36], 16)
+	copy(header[36:40], "data")
+	binary.LittleEndian.PutUint32(header[40:44], dataLen)
+	_, err := w.Write(header)
+	return err
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// wavChunk 构造一个 RIFF 块，奇数长度补齐一个字节
+func wavChunk(id string, body []byte) []byte {
+	out := append([]byte(id), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(body)))
+	out = append(out, body...)
+	if len(body)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+func wavFmtChunk(tag uint16, channels, rate, bits int) []byte {
+	body := make([]byte, 16)
+	binary.LittleEndian.PutUint16(body[0:], tag)
+	binary.LittleEndian.PutUint16(body[2:], uint16(channels))
+	binary.LittleEndian.PutUint32(body[4:], uint32(rate))
+	binary.LittleEndian.PutUint32(body[8:], uint32(rate*channels*bits/8))
+	binary.LittleEndian.PutUint16(body[12:], uint16(channels*bits/8))
+	binary.LittleEndian.PutUint16(body[14:], uint16(bits))
+	return body
+}
+
+func buildWAV(chunks ...[]byte) []byte {
+	body := []byte("WAVE")
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	return wavChunk("RIFF", body)
+}
+
+// encodeSamples 把 [-1, 1) 的样本编码为指定格式，每帧 channels 个相同样本
+func encodeSamples(samples []float64, tag uint16, bits, channels int) []byte {
+	var out []byte
+	for _, v := range samples {
+		for c := 0; c < channels; c++ {
+			switch {
+			case tag == wavFormatIEEEFloat && bits == 32:
+				out = binary.LittleEndian.AppendUint32(out, math.Float32bits(float32(v)))
+			case tag == wavFormatIEEEFloat:
+				out = binary.LittleEndian.AppendUint64(out, math.Float64bits(v))
+			case bits == 8:
+				out = append(out, byte(int(math.Round(v*128))+128))
+			case bits == 16:
+				out = binary.LittleEndian.AppendUint16(out, uint16(int16(math.Round(v*32768))))
+			case bits == 24:
+				s := int32(math.Round(v * 8388608))
+				out = append(out, byte(s), byte(s>>8), byte(s>>16))
+			default:
+				out = binary.LittleEndian.AppendUint32(out, uint32(int32(math.Round(v*2147483648))))
+			}
+		}
+	}
+	return out
+}
+
+func toneSamples(rate int, freq float64, durationMs int) []float64 {
+	out := make([]float64, rate*durationMs/1000)
+	for i := range out {
+		out[i] = 0.5 * math.Sin(2*math.Pi*freq*float64(i)/float64(rate))
+	}
+	return out
+}
+
+func TestConvertWAVToPCM16_SampleFormats(t *testing.T) {
+	samples := toneSamples(16000, 440, 100)
+	expected := pcmSamples(encodeSamples(samples, wavFormatPCM, 16, 1))
+
+	cases := []struct {
+		name     string
+		tag      uint16
+		bits     int
+		channels int
+		maxError float64
+	}{
+		{"8-bit", wavFormatPCM, 8, 1, 256},
+		{"16-bit", wavFormatPCM, 16, 1, 0},
+		{"24-bit", wavFormatPCM, 24, 1, 1},
+		{"32-bit", wavFormatPCM, 32, 1, 1},
+		{"float32", wavFormatIEEEFloat, 32, 1, 1},
+		{"float64", wavFormatIEEEFloat, 64, 1, 1},
+		{"stereo", wavFormatPCM, 16, 2, 0},
+	}
+
+	ap := NewAudioProcessor()
+	for _, tc := range cases {
+		wav := buildWAV(
+			wavChunk("fmt ", wavFmtChunk(tc.tag, tc.channels, 16000, tc.bits)),
+			wavChunk("data", encodeSamples(samples, tc.tag, tc.bits, tc.channels)),
+		)
+		pcm, err := ap.ConvertAudioFormat(wav, string(FormatWAV), string(FormatPCM16))
+		if err != nil {
+			t.Fatalf("%s: ConvertAudioFormat failed: %v", tc.name, err)
+		}
+		actual := pcmSamples(pcm)
+		if len(actual) != len(expected) {
+			t.Fatalf("%s: expected %d samples, got %d", tc.name, len(expected), len(actual))
+		}
+		for i := range actual {
+			if math.Abs(actual[i]-expected[i]) > tc.maxError {
+				t.Fatalf("%s: sample %d is %v, expected %v", tc.name, i, actual[i], expected[i])
+			}
+		}
+	}
+}
+
+func TestConvertWAVToPCM16_SkipsExtraChunks(t *testing.T) {
+	pcm := encodeSamples(toneSamples(16000, 440, 50), wavFormatPCM, 16, 1)
+
+	// 扩展 fmt 块、奇数长度的 LIST 块和 fact 块都在 data 之前
+	fmtBody := append(wavFmtChunk(wavFormatPCM, 1, 16000, 16), 0, 0)
+	wav := buildWAV(
+		wavChunk("fmt ", fmtBody),
+		wavChunk("LIST", []byte("INFOISFT\x05\x00\x00\x00test\x00")),
+		wavChunk("fact", []byte{0x20, 0x03, 0, 0}),
+		wavChunk("data", pcm),
+	)
+
+	out, err := NewAudioProcessor().ConvertAudioFormat(wav, string(FormatWAV), string(FormatPCM16))
+	if err != nil {
+		t.Fatalf("ConvertAudioFormat failed: %v", err)
+	}
+	if !bytes.Equal(out, pcm) {
+		t.Errorf("Expected data chunk to be returned unchanged (%d bytes), got %d bytes", len(pcm), len(out))
+	}
+}
+
+func TestConvertWAVToPCM16_Extensible(t *testing.T) {
+	samples := toneSamples(16000, 440, 50)
+	fmtBody := wavFmtChunk(wavFormatExtensible, 2, 16000, 24)
+	ext := make([]byte, 24)
+	binary.LittleEndian.PutUint16(ext[0:], 22)  // cbSize
+	binary.LittleEndian.PutUint16(ext[2:], 24)  // wValidBitsPerSample
+	binary.LittleEndian.PutUint32(ext[4:], 0x3) // 左右声道
+	binary.LittleEndian.PutUint16(ext[8:], wavFormatPCM)
+	copy(ext[10:], wavSubformatSuffix)
+	wav := buildWAV(
+		wavChunk("fmt ", append(fmtBody, ext...)),
+		wavChunk("data", encodeSamples(samples, wavFormatPCM, 24, 2)),
+	)
+
+	out, err := NewAudioProcessor().ConvertAudioFormat(wav, string(FormatWAV), string(FormatPCM16))
+	if err != nil {
+		t.Fatalf("ConvertAudioFormat failed: %v", err)
+	}
+	if len(out)/2 != len(samples) {
+		t.Errorf("Expected %d mono samples, got %d", len(samples), len(out)/2)
+	}
+}
+
+func TestConvertWAVToPCM16_ResamplesToTargetRate(t *testing.T) {
+	wav := buildWAV(
+		wavChunk("fmt ", wavFmtChunk(wavFormatPCM, 2, 44100, 16)),
+		wavChunk("data", encodeSamples(toneSamples(44100, 1000, 500), wavFormatPCM, 16, 2)),
+	)
+
+	out, err := NewAudioProcessor().ConvertAudioFormat(wav, string(FormatWAV), string(FormatPCM16))
+	if err != nil {
+		t.Fatalf("ConvertAudioFormat failed: %v", err)
+	}
+	if n := len(out) / 2; n < 7999 || n > 8001 {
+		t.Errorf("Expected about 8000 samples at 16kHz, got %d", n)
+	}
+	amplitude, snr := analyzeTone(pcmSamples(out), 16000, 1000)
+	if math.Abs(amplitude-16384) > 164 || snr < 60 {
+		t.Errorf("Unexpected tone after resampling: amplitude %.1f, SNR %.1fdB", amplitude, snr)
+	}
+}
+
+func TestConvertWAVToPCM16_Errors(t *testing.T) {
+	fmtChunk := wavChunk("fmt ", wavFmtChunk(wavFormatPCM, 1, 16000, 16))
+	dataChunk := wavChunk("data", make([]byte, 320))
+	badAlign := wavFmtChunk(wavFormatPCM, 2, 16000, 16)
+	binary.LittleEndian.PutUint16(badAlign[12:], 3)
+	unknownSub := append(wavFmtChunk(wavFormatExtensible, 1, 16000, 16), make([]byte, 24)...)
+
+	cases := []struct {
+		name    string
+		wav     []byte
+		errType string
+	}{
+		{"not riff", append([]byte("RIFX\x00\x00\x00\x00WAVE"), fmtChunk...), "format_error"},
+		{"missing fmt", buildWAV(dataChunk), "format_error"},
+		{"missing data", buildWAV(fmtChunk), "format_error"},
+		{"short fmt", buildWAV(wavChunk("fmt ", make([]byte, 12)), dataChunk), "format_error"},
+		{"empty data", buildWAV(fmtChunk, wavChunk("data", nil)), "format_error"},
+		{"bad block align", buildWAV(wavChunk("fmt ", badAlign), dataChunk), "format_error"},
+		{"adpcm", buildWAV(wavChunk("fmt ", wavFmtChunk(0x0002, 1, 16000, 4)), dataChunk), "unsupported_format"},
+		{"12-bit", buildWAV(wavChunk("fmt ", wavFmtChunk(wavFormatPCM, 1, 16000, 12)), dataChunk), "unsupported_format"},
+		{"unknown subformat", buildWAV(wavChunk("fmt ", unknownSub), dataChunk), "unsupported_format"},
+		// 过大的采样率会让重采样滤波器耗尽内存
+		{"oversized rate", buildWAV(wavChunk("fmt ", wavFmtChunk(wavFormatPCM, 1, 0xA3001F40, 16)), dataChunk), "unsupported_format"},
+		{"rate too high", buildWAV(wavChunk("fmt ", wavFmtChunk(wavFormatPCM, 1, 384000, 16)), dataChunk), "unsupported_format"},
+		{"rate too low", buildWAV(wavChunk("fmt ", wavFmtChunk(wavFormatPCM, 1, 4000, 16)), dataChunk), "unsupported_format"},
+	}
+
+	ap := NewAudioProcessor()
+	for _, tc := range cases {
+		_, err := ap.ConvertAudioFormat(tc.wav, string(FormatWAV), string(FormatPCM16))
+		audioErr, ok := err.(*AudioProcessingError)
+		if !ok {
+			t.Errorf("%s: expected AudioProcessingError, got %v", tc.name, err)
+			continue
+		}
+		if audioErr.Type != tc.errType {
+			t.Errorf("%s: expected %s, got %s (%s)", tc.name, tc.errType, audioErr.Type, audioErr.Details)
+		}
+	}
+}
+
+func TestConvertWAVToPCM16_TruncatedDataChunk(t *testing.T) {
+	// 流式录音写入的 data 块长度可能是占位值
+	pcm := encodeSamples(toneSamples(16000, 440, 20), wavFormatPCM, 16, 1)
+	data := wavChunk("data", pcm)
+	binary.LittleEndian.PutUint32(data[4:], 0xFFFFFFFF)
+	wav := buildWAV(wavChunk("fmt ", wavFmtChunk(wavFormatPCM, 1, 16000, 16)), data)
+
+	out, err := NewAudioProcessor().ConvertAudioFormat(wav, string(FormatWAV), string(FormatPCM16))
+	if err != nil {
+		t.Fatalf("ConvertAudioFormat failed: %v", err)
+	}
+	if !bytes.Equal(out, pcm) {
+		t.Errorf("Expected %d bytes of audio, got %d", len(pcm), len(out))
+	}
+}
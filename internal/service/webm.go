@@ -0,0 +1,311 @@
+package service
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Matroska/WebM 中用到的元素 ID（保留长度标记位）
+const (
+	ebmlIDHeader            = 0x1A45DFA3
+	ebmlIDDocType           = 0x4282
+	ebmlIDSegment           = 0x18538067
+	ebmlIDCluster           = 0x1F43B675
+	ebmlIDTracks            = 0x1654AE6B
+	ebmlIDTrackEntry        = 0xAE
+	ebmlIDTrackNumber       = 0xD7
+	ebmlIDTrackType         = 0x83
+	ebmlIDCodecID           = 0x86
+	ebmlIDCodecPrivate      = 0x63A2
+	ebmlIDAudio             = 0xE1
+	ebmlIDSamplingFrequency = 0xB5
+	ebmlIDChannels          = 0x9F
+	ebmlIDBitDepth          = 0x6264
+	ebmlIDBlockGroup        = 0xA0
+	ebmlIDBlock             = 0xA1
+	ebmlIDSimpleBlock       = 0xA3
+)
+
+// matroskaTrackTypeAudio TrackType 中音频轨道的取值
+const matroskaTrackTypeAudio = 2
+
+// webmTrack WebM 音轨的编码参数
+type webmTrack struct {
+	number       uint64
+	trackType    uint64
+	codecID      string
+	codecPrivate []byte
+	sampleRate   float64
+	channels     int
+	bitDepth     int
+}
+
+// webmAudio 从 WebM 中解出的第一条音轨及其全部帧
+type webmAudio struct {
+	track  *webmTrack
+	frames [][]byte
+}
+
+// webmFormatError 构造 WebM 容器格式错误
+func webmFormatError(format string, args ...interface{}) error {
+	return &AudioProcessingError{
+		Type:    "format_error",
+		Message: "Invalid WebM data",
+		Details: fmt.Sprintf(format, args...),
+	}
+}
+
+// demuxWebM 解析 EBML 元素树，取出第一条音轨的帧
+// 进入 Segment、Cluster 等主元素时不跳过其内容而是继续平铺解析，从而兼容 MediaRecorder 写出的未知长度元素
+func demuxWebM(data []byte) (*webmAudio, error) {
+	id, _, err := readEBMLID(data, 0)
+	if err != nil || id != ebmlIDHeader {
+		return nil, webmFormatError("Missing EBML header")
+	}
+
+	var tracks []*webmTrack
+	var current *webmTrack
+	blocks := make(map[uint64][][]byte)
+	docTypeChecked := false
+
+elements:
+	for pos := 0; pos < len(data); {
+		id, idLen, err := readEBMLID(data, pos)
+		if err != nil {
+			return nil, webmFormatError("Invalid element ID at offset %d", pos)
+		}
+		size, sizeLen, err := readEBMLVint(data, pos+idLen)
+		if err != nil {
+			return nil, webmFormatError("Invalid element size at offset %d", pos)
+		}
+		bodyStart := pos + idLen + sizeLen
+		bodyEnd := bodyStart + int(size)
+		unknown := size == ebmlUnknownSize
+
+		switch id {
+		case ebmlIDHeader, ebmlIDSegment, ebmlIDCluster, ebmlIDTracks, ebmlIDAudio, ebmlIDBlockGroup:
+			// 主元素：继续解析子元素
+			pos = bodyStart
+			continue
+		case ebmlIDTrackEntry:
+			current = &webmTrack{channels: 1}
+			tracks = append(tracks, current)
+			pos = bodyStart
+			continue
+		}
+
+		if unknown {
+			return nil, webmFormatError("Element 0x%X at offset %d has unknown size", id, pos)
+		}
+		if bodyEnd > len(data) || bodyEnd < bodyStart {
+			// MediaRecorder 中途停止时最后一个块可能不完整，丢弃即可
+			if id == ebmlIDSimpleBlock || id == ebmlIDBlock {
+				break elements
+			}
+			return nil, webmFormatError("Element 0x%X at offset %d exceeds data length", id, pos)
+		}
+		body := data[bodyStart:bodyEnd]
+
+		switch id {
+		case ebmlIDDocType:
+			if docType := string(body); docType != "webm" && docType != "matroska" {
+				return nil, webmFormatError("Unsupported DocType %q", docType)
+			}
+			docTypeChecked = true
+		case ebmlIDTrackNumber, ebmlIDTrackType, ebmlIDChannels, ebmlIDBitDepth:
+			if current == nil {
+				break
+			}
+			v := ebmlUint(body)
+			switch id {
+			case ebmlIDTrackNumber:
+				current.number = v
+			case ebmlIDTrackType:
+				current.trackType = v
+			case ebmlIDChannels:
+				current.channels = int(v)
+			case ebmlIDBitDepth:
+				current.bitDepth = int(v)
+			}
+		case ebmlIDCodecID:
+			if current != nil {
+				current.codecID = string(body)
+			}
+		case ebmlIDCodecPrivate:
+			if current != nil {
+				current.codecPrivate = body
+			}
+		case ebmlIDSamplingFrequency:
+			if current != nil {
+				current.sampleRate = ebmlFloat(body)
+			}
+		case ebmlIDSimpleBlock, ebmlIDBlock:
+			track, frames, err := parseMatroskaBlock(body)
+			if err != nil {
+				return nil, err
+			}
+			blocks[track] = append(blocks[track], frames...)
+		}
+		pos = bodyEnd
+	}
+
+	if !docTypeChecked {
+		return nil, webmFormatError("Missing DocType")
+	}
+	for _, track := range tracks {
+		if track.trackType == matroskaTrackTypeAudio {
+			return &webmAudio{track: track, frames: blocks[track.number]}, nil
+		}
+	}
+	return nil, webmFormatError("No audio track found")
+}
+
+// parseMatroskaBlock 解析 SimpleBlock/Block，支持 Xiph、固定长度和 EBML 三种 lacing
+func parseMatroskaBlock(block []byte) (uint64, [][]byte, error) {
+	track, n, err := readEBMLVint(block, 0)
+	if err != nil || len(block) < n+3 {
+		return 0, nil, webmFormatError("Block header is truncated")
+	}
+	flags := block[n+2]
+	payload := block[n+3:]
+
+	lacing := (flags >> 1) & 0x03
+	if lacing == 0 {
+		return track, [][]byte{payload}, nil
+	}
+	if len(payload) == 0 {
+		return 0, nil, webmFormatError("Laced block is missing frame count")
+	}
+	count := int(payload[0]) + 1
+	pos := 1
+	sizes := make([]int, count)
+
+	switch lacing {
+	case 1: // Xiph
+		total := 0
+		for i := 0; i < count-1; i++ {
+			for {
+				if pos >= len(payload) {
+					return 0, nil, webmFormatError("Xiph lacing is truncated")
+				}
+				b := int(payload[pos])
+				pos++
+				sizes[i] += b
+				if b != 255 {
+					break
+				}
+			}
+			total += sizes[i]
+		}
+		sizes[count-1] = len(payload) - pos - total
+	case 2: // 固定长度
+		if (len(payload)-pos)%count != 0 {
+			return 0, nil, webmFormatError("Fixed lacing size %d is not divisible by %d frames", len(payload)-pos, count)
+		}
+		for i := range sizes {
+			sizes[i] = (len(payload) - pos) / count
+		}
+	case 3: // EBML，第一帧为无符号长度，其余为与前一帧的有符号差值
+		if count == 1 {
+			// 只有一帧时不写长度字段，余下部分即为该帧
+			sizes[0] = len(payload) - pos
+			break
+		}
+		first, l, err := readEBMLVint(payload, pos)
+		if err != nil {
+			return 0, nil, webmFormatError("EBML lacing is truncated")
+		}
+		pos += l
+		sizes[0] = int(first)
+		total := sizes[0]
+		for i := 1; i < count-1; i++ {
+			diff, l, err := readEBMLVint(payload, pos)
+			if err != nil {
+				return 0, nil, webmFormatError("EBML lacing is truncated")
+			}
+			pos += l
+			sizes[i] = sizes[i-1] + int(int64(diff)-(int64(1)<<(7*l-1)-1))
+			total += sizes[i]
+		}
+		sizes[count-1] = len(payload) - pos - total
+	}
+
+	frames := make([][]byte, count)
+	for i, size := range sizes {
+		if size < 0 || pos+size > len(payload) {
+			return 0, nil, webmFormatError("Laced frame %d exceeds block size", i)
+		}
+		frames[i] = payload[pos : pos+size]
+		pos += size
+	}
+	return track, frames, nil
+}
+
+// ebmlUnknownSize 长度字段全为 1 表示未知长度
+const ebmlUnknownSize = math.MaxUint64
+
+// readEBMLID 读取元素 ID，保留长度标记位
+func readEBMLID(data []byte, pos int) (uint64, int, error) {
+	if pos >= len(data) {
+		return 0, 0, fmt.Errorf("unexpected end of data")
+	}
+	length := ebmlVintLength(data[pos])
+	if length == 0 || length > 4 || pos+length > len(data) {
+		return 0, 0, fmt.Errorf("invalid element ID")
+	}
+	var id uint64
+	for _, b := range data[pos : pos+length] {
+		id = id<<8 | uint64(b)
+	}
+	return id, length, nil
+}
+
+// readEBMLVint 读取变长整数并去掉长度标记位，全 1 时返回 ebmlUnknownSize
+func readEBMLVint(data []byte, pos int) (uint64, int, error) {
+	if pos >= len(data) {
+		return 0, 0, fmt.Errorf("unexpected end of data")
+	}
+	length := ebmlVintLength(data[pos])
+	if length == 0 || pos+length > len(data) {
+		return 0, 0, fmt.Errorf("invalid variable-length integer")
+	}
+	value := uint64(data[pos]) & (0xFF >> length)
+	allOnes := value == 0xFF>>length
+	for _, b := range data[pos+1 : pos+length] {
+		value = value<<8 | uint64(b)
+		allOnes = allOnes && b == 0xFF
+	}
+	if allOnes {
+		return ebmlUnknownSize, length, nil
+	}
+	return value, length, nil
+}
+
+// ebmlVintLength 由首字节前导零的个数得到变长整数的字节数
+func ebmlVintLength(first byte) int {
+	for i := 0; i < 8; i++ {
+		if first&(0x80>>i) != 0 {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func ebmlUint(body []byte) uint64 {
+	var v uint64
+	for _, b := range body {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+func ebmlFloat(body []byte) float64 {
+	switch len(body) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(body)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(body))
+	}
+	return 0
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readAudioFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "audio", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture %s: %v", name, err)
+	}
+	return data
+}
+
+func TestConvertWebMToPCM16_PCMTracks(t *testing.T) {
+	cases := []struct {
+		fixture    string
+		freq       float64
+		durationMs int
+		amplitude  float64
+	}{
+		{"tone_pcm_float.webm", 1000, 300, 16384},
+		{"tone_pcm_int16_laced.webm", 440, 200, 16384},
+	}
+
+	ap := NewAudioProcessor()
+	for _, tc := range cases {
+		pcm, err := ap.ConvertAudioFormat(readAudioFixture(t, tc.fixture), string(FormatWebM), string(FormatPCM16))
+		if err != nil {
+			t.Fatalf("%s: ConvertAudioFormat failed: %v", tc.fixture, err)
+		}
+
+		expected := 16000 * tc.durationMs / 1000
+		if n := len(pcm) / 2; n < expected-1 || n > expected+1 {
+			t.Errorf("%s: expected about %d samples, got %d", tc.fixture, expected, n)
+		}
+		amplitude, snr := analyzeTone(pcmSamples(pcm), 16000, tc.freq)
+		if math.Abs(amplitude-tc.amplitude) > tc.amplitude/100 || snr < 60 {
+			t.Errorf("%s: amplitude %.1f, SNR %.1fdB", tc.fixture, amplitude, snr)
+		}
+	}
+}
+
+func TestDemuxWebM_Opus(t *testing.T) {
+	audio, err := demuxWebM(readAudioFixture(t, "silence_opus.webm"))
+	if err != nil {
+		t.Fatalf("demuxWebM failed: %v", err)
+	}
+	if audio.track.codecID != "A_OPUS" || len(audio.frames) != 10 {
+		t.Fatalf("Unexpected track %s with %d frames", audio.track.codecID, len(audio.frames))
+	}
+	head, err := parseOpusHead(audio.track.codecPrivate)
+	if err != nil {
+		t.Fatalf("parseOpusHead failed: %v", err)
+	}
+	if head.channels != 1 || head.preSkip != 312 {
+		t.Errorf("Unexpected OpusHead %+v", head)
+	}
+}
+
+func TestConvertWebMToPCM16_OpusWithoutDecoder(t *testing.T) {
+	if newOpusDecoder != nil {
+		t.Skip("built with an Opus decoder")
+	}
+	_, err := NewAudioProcessor().ConvertAudioFormat(readAudioFixture(t, "silence_opus.webm"), string(FormatWebM), string(FormatPCM16))
+	audioErr, ok := err.(*AudioProcessingError)
+	if !ok || audioErr.Type != "unsupported_format" || !strings.Contains(audioErr.Details, "-tags opus") {
+		t.Errorf("Expected unsupported_format pointing at the opus build tag, got %v", err)
+	}
+}
+
+func TestConvertWebMToPCM16_Errors(t *testing.T) {
+	valid := readAudioFixture(t, "tone_pcm_float.webm")
+	vorbis := bytes.Replace(readAudioFixture(t, "silence_opus.webm"), []byte("A_OPUS"), []byte("A_VORB"), 1)
+	// 替换 SamplingFrequency 的 8 字节浮点取值，夹具中 48000 的编码只出现一次
+	withRate := func(rate float64) []byte {
+		bits := func(v float64) []byte {
+			return binary.BigEndian.AppendUint64(nil, math.Float64bits(v))
+		}
+		return bytes.Replace(valid, bits(48000), bits(rate), 1)
+	}
+
+	cases := []struct {
+		name    string
+		data    []byte
+		errType string
+	}{
+		{"not ebml", []byte("RIFF....WAVEfmt "), "format_error"},
+		{"wrong doctype", bytes.Replace(valid, []byte("webm"), []byte("mp4x"), 1), "format_error"},
+		{"truncated header", valid[:20], "format_error"},
+		{"unsupported codec", vorbis, "unsupported_format"},
+		{"nan sampling frequency", withRate(math.NaN()), "unsupported_format"},
+		{"infinite sampling frequency", withRate(math.Inf(1)), "unsupported_format"},
+		{"oversized sampling frequency", withRate(1e12), "unsupported_format"},
+		{"sampling frequency too low", withRate(4000), "unsupported_format"},
+	}
+
+	ap := NewAudioProcessor()
+	for _, tc := range cases {
+		_, err := ap.ConvertAudioFormat(tc.data, string(FormatWebM), string(FormatPCM16))
+		audioErr, ok := err.(*AudioProcessingError)
+		if !ok || audioErr.Type != tc.errType {
+			t.Errorf("%s: expected %s, got %v", tc.name, tc.errType, err)
+		}
+	}
+
+	// 录音中途停止导致最后一个块不完整时，保留之前的音频
+	pcm, err := ap.ConvertAudioFormat(valid[:len(valid)-100], string(FormatWebM), string(FormatPCM16))
+	if err != nil || len(pcm) == 0 {
+		t.Errorf("Expected truncated recording to decode, got %d bytes, %v", len(pcm), err)
+	}
+}
+
+func TestParseMatroskaBlock_Lacing(t *testing.T) {
+	frames := [][]byte{bytes.Repeat([]byte{1}, 300), bytes.Repeat([]byte{2}, 10), bytes.Repeat([]byte{3}, 20)}
+
+	// EBML lacing：300，差值 -290（两字节有符号数），最后一帧取余下部分
+	ebml := []byte{0x81, 0, 0, 0x06, 2, 0x41, 0x2C}
+	diff := int64(-290) + (1<<13 - 1)
+	ebml = append(ebml, 0x40|byte(diff>>8), byte(diff))
+	for _, f := range frames {
+		ebml = append(ebml, f...)
+	}
+	track, got, err := parseMatroskaBlock(ebml)
+	if err != nil {
+		t.Fatalf("EBML lacing failed: %v", err)
+	}
+	if track != 1 || len(got) != 3 || !bytes.Equal(got[0], frames[0]) || !bytes.Equal(got[1], frames[1]) || !bytes.Equal(got[2], frames[2]) {
+		t.Errorf("EBML lacing returned wrong frames: track %d, %d frames", track, len(got))
+	}
+
+	// 只有一帧的 EBML lacing 不带长度字段
+	single := append([]byte{0x81, 0, 0, 0x06, 0}, frames[0]...)
+	if _, got, err := parseMatroskaBlock(single); err != nil || len(got) != 1 || !bytes.Equal(got[0], frames[0]) {
+		t.Errorf("Single-frame EBML lacing returned %d frames, %v", len(got), err)
+	}
+
+	fixed := append([]byte{0x81, 0, 0, 0x04, 1}, bytes.Repeat([]byte{7}, 20)...)
+	if _, got, err := parseMatroskaBlock(fixed); err != nil || len(got) != 2 || len(got[1]) != 10 {
+		t.Errorf("Fixed lacing returned %d frames, %v", len(got), err)
+	}
+	if _, _, err := parseMatroskaBlock(fixed[:len(fixed)-1]); err == nil {
+		t.Error("Expected error for fixed lacing with uneven frames")
+	}
+}
@@ -0,0 +1,34 @@
+-- Create translation feedback table for quality ratings and corrections
+CREATE TABLE IF NOT EXISTS translation_feedback (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    translation_id UUID NOT NULL REFERENCES translation_history(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    rating SMALLINT NOT NULL CHECK (rating IN (-1, 1)),
+    reason VARCHAR(32),
+    corrected_text TEXT,
+    correction_status VARCHAR(16),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (translation_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_translation_feedback_user_id ON translation_feedback(user_id);
+CREATE INDEX IF NOT EXISTS idx_translation_feedback_created_at ON translation_feedback(created_at);
+
+-- Create per-user translation memory fed by accepted corrections
+CREATE TABLE IF NOT EXISTS translation_memory (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source_language VARCHAR(10) NOT NULL,
+    target_language VARCHAR(10) NOT NULL,
+    source_text TEXT NOT NULL,
+    translated_text TEXT NOT NULL,
+    origin VARCHAR(16) NOT NULL DEFAULT 'correction',
+    hit_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- md5 keeps the unique index small for long source texts
+CREATE UNIQUE INDEX IF NOT EXISTS idx_translation_memory_lookup
+    ON translation_memory(user_id, source_language, target_language, md5(source_text));
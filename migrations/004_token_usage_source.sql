@@ -0,0 +1,4 @@
+-- Flag token usage rows as measured by the API or estimated locally,
+-- and record which translation path produced them
+ALTER TABLE token_usage ADD COLUMN IF NOT EXISTS estimated BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE token_usage ADD COLUMN IF NOT EXISTS source VARCHAR(20) NOT NULL DEFAULT 'text';
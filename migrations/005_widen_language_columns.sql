@@ -0,0 +1,8 @@
+-- BCP-47 tags such as zh-Hant-TW do not fit in VARCHAR(10)
+ALTER TABLE translation_history
+    ALTER COLUMN source_language TYPE VARCHAR(35),
+    ALTER COLUMN target_language TYPE VARCHAR(35);
+
+ALTER TABLE translation_memory
+    ALTER COLUMN source_language TYPE VARCHAR(35),
+    ALTER COLUMN target_language TYPE VARCHAR(35);
@@ -0,0 +1,25 @@
+-- Create realtime conversation sessions
+CREATE TABLE IF NOT EXISTS realtime_sessions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    mode VARCHAR(16) NOT NULL DEFAULT 'assistant',
+    source_language VARCHAR(35),
+    target_language VARCHAR(35),
+    turn_count INTEGER NOT NULL DEFAULT 0,
+    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ended_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_realtime_sessions_user_started ON realtime_sessions(user_id, started_at DESC);
+
+-- Create transcript turns, ordered by seq within a session
+CREATE TABLE IF NOT EXISTS realtime_turns (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    session_id UUID NOT NULL REFERENCES realtime_sessions(id) ON DELETE CASCADE,
+    seq INTEGER NOT NULL,
+    role VARCHAR(16) NOT NULL CHECK (role IN ('user', 'assistant')),
+    item_id VARCHAR(64),
+    text TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (session_id, seq)
+);
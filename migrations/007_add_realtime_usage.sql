@@ -0,0 +1,17 @@
+-- Realtime usage per response, summed per session and per user for quotas
+CREATE TABLE IF NOT EXISTS realtime_usage (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    session_id UUID NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    response_id VARCHAR(64),
+    audio_in_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+    audio_out_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+    input_text_tokens INTEGER NOT NULL DEFAULT 0,
+    input_audio_tokens INTEGER NOT NULL DEFAULT 0,
+    output_text_tokens INTEGER NOT NULL DEFAULT 0,
+    output_audio_tokens INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_realtime_usage_user_created ON realtime_usage(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_realtime_usage_session ON realtime_usage(session_id);
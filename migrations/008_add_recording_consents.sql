@@ -0,0 +1,5 @@
+-- Explicit per-user consent to record realtime sessions for support review; revoking deletes the row
+CREATE TABLE IF NOT EXISTS recording_consents (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    consented_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,23 @@
+-- Feedback stats filter on updated_at so re-rated feedback counts in the current window
+CREATE INDEX IF NOT EXISTS idx_translation_feedback_updated_at ON translation_feedback(updated_at);
+
+-- Link corrections to the history row they came from, so withdrawing one correction
+-- cannot delete a correction of the same source text made on another row
+ALTER TABLE translation_memory
+    ADD COLUMN IF NOT EXISTS translation_id UUID REFERENCES translation_history(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_translation_memory_translation_id ON translation_memory(translation_id);
+
+-- Backfill from accepted corrections that still match the stored memory entry
+UPDATE translation_memory m
+SET translation_id = f.translation_id
+FROM translation_feedback f
+JOIN translation_history th ON th.id = f.translation_id
+WHERE m.translation_id IS NULL
+  AND m.origin = 'correction'
+  AND f.correction_status = 'accepted'
+  AND f.user_id = m.user_id
+  AND f.corrected_text = m.translated_text
+  AND th.source_language = m.source_language
+  AND th.target_language = m.target_language
+  AND th.source_text = m.source_text;
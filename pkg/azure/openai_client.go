@@ -3,11 +3,13 @@ package azure
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync/atomic"
 )
 
 type OpenAIClient struct {
@@ -16,6 +18,8 @@ type OpenAIClient struct {
 	deploymentName string
 	apiVersion    string
 	httpClient    *http.Client
+	// 旧版本 API 不支持 stream_options，首次被拒绝后不再发送
+	streamUsageUnsupported atomic.Bool
 }
 
 type ChatMessage struct {
@@ -24,8 +28,21 @@ type ChatMessage struct {
 }
 
 type ChatRequest struct {
-	Messages []ChatMessage `json:"messages"`
-	Stream   bool          `json:"stream,omitempty"`
+	Messages      []ChatMessage  `json:"messages"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+}
+
+// StreamOptions 流式请求选项，IncludeUsage 让最后一个数据块携带 token 用量
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type Usage struct {
+	PromptTokens     int  `json:"prompt_tokens"`
+	CompletionTokens int  `json:"completion_tokens"`
+	TotalTokens      int  `json:"total_tokens"`
+	Estimated        bool `json:"-"` // 上游未返回用量，由本地估算
 }
 
 type ChatResponse struct {
@@ -34,11 +51,7 @@ type ChatResponse struct {
 			Content string `json:"content"`
 		} `json:"message"`
 	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage Usage `json:"usage"`
 }
 
 type StreamDelta struct {
@@ -51,6 +64,7 @@ type StreamChoice struct {
 
 type StreamResponse struct {
 	Choices []StreamChoice `json:"choices"`
+	Usage   *Usage         `json:"usage,omitempty"`
 }
 
 func NewOpenAIClient(endpoint, apiKey, deploymentName, apiVersion string) *OpenAIClient {
@@ -63,11 +77,16 @@ func NewOpenAIClient(endpoint, apiKey, deploymentName, apiVersion string) *OpenA
 	}
 }
 
-func (c *OpenAIClient) Translate(text, sourceLanguage, targetLanguage string) (string, int, int, error) {
-	prompt := fmt.Sprintf(`你是一个专业的翻译助手。请将以下文本从%s翻译成%s。
+func buildTranslatePrompt(text, sourceLanguage, targetLanguage string) string {
+	return fmt.Sprintf(`你是一个专业的翻译助手。请将以下文本从%s翻译成%s。
 只返回翻译结果，不要添加任何解释。
 
 原文：%s`, sourceLanguage, targetLanguage, text)
+}
+
+// Translate 非流式翻译，ctx 取消或超时时中断上游请求
+func (c *OpenAIClient) Translate(ctx context.Context, text, sourceLanguage, targetLanguage string) (string, int, int, error) {
+	prompt := buildTranslatePrompt(text, sourceLanguage, targetLanguage)
 
 	url := fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
 		c.endpoint, c.deploymentName, c.apiVersion)
@@ -84,7 +103,7 @@ func (c *OpenAIClient) Translate(text, sourceLanguage, targetLanguage string) (s
 		return "", 0, 0, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", 0, 0, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -118,45 +137,19 @@ func (c *OpenAIClient) Translate(text, sourceLanguage, targetLanguage string) (s
 	return chatResp.Choices[0].Message.Content, inputTokens, outputTokens, nil
 }
 
-func (c *OpenAIClient) TranslateStream(text, sourceLanguage, targetLanguage string, callback func(string) error) error {
-	prompt := fmt.Sprintf(`你是一个专业的翻译助手。请将以下文本从%s翻译成%s。
-只返回翻译结果，不要添加任何解释。
-
-原文：%s`, sourceLanguage, targetLanguage, text)
-
-	url := fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
-		c.endpoint, c.deploymentName, c.apiVersion)
+// TranslateStream 流式翻译，ctx 取消时中断上游请求
+// 返回的用量优先取自上游最后一个数据块，缺失时按本地分词估算
+func (c *OpenAIClient) TranslateStream(ctx context.Context, text, sourceLanguage, targetLanguage string, callback func(string) error) (*Usage, error) {
+	prompt := buildTranslatePrompt(text, sourceLanguage, targetLanguage)
 
-	reqBody := ChatRequest{
-		Messages: []ChatMessage{
-			{Role: "user", Content: prompt},
-		},
-		Stream: true,
-	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	resp, err := c.sendStreamRequest(ctx, prompt, !c.streamUsageUnsupported.Load())
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("api-key", c.apiKey)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error: %d - %s", resp.StatusCode, string(body))
-	}
+	var usage *Usage
+	var completion strings.Builder
 
 	// Read SSE stream
 	scanner := bufio.NewScanner(resp.Body)
@@ -178,18 +171,77 @@ func (c *OpenAIClient) TranslateStream(text, sourceLanguage, targetLanguage stri
 				continue
 			}
 
+			if streamResp.Usage != nil {
+				usage = streamResp.Usage
+			}
+
 			if len(streamResp.Choices) > 0 && streamResp.Choices[0].Delta.Content != "" {
+				completion.WriteString(streamResp.Choices[0].Delta.Content)
 				if err := callback(streamResp.Choices[0].Delta.Content); err != nil {
-					return err
+					return c.resolveStreamUsage(usage, prompt, completion.String()), err
 				}
 			}
 		}
 	}
 
+	usage = c.resolveStreamUsage(usage, prompt, completion.String())
 	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("failed to read stream: %w", err)
+		return usage, fmt.Errorf("failed to read stream: %w", err)
 	}
 
-	return nil
+	return usage, nil
 }
 
+// sendStreamRequest 发送流式请求，上游不认识 stream_options 时去掉该参数重试一次
+func (c *OpenAIClient) sendStreamRequest(ctx context.Context, prompt string, includeUsage bool) (*http.Response, error) {
+	url := fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		c.endpoint, c.deploymentName, c.apiVersion)
+
+	reqBody := ChatRequest{
+		Messages: []ChatMessage{
+			{Role: "user", Content: prompt},
+		},
+		Stream: true,
+	}
+	if includeUsage {
+		reqBody.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("api-key", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if includeUsage && resp.StatusCode == http.StatusBadRequest && strings.Contains(string(body), "stream_options") {
+			c.streamUsageUnsupported.Store(true)
+			return c.sendStreamRequest(ctx, prompt, false)
+		}
+		return nil, fmt.Errorf("API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	return resp, nil
+}
+
+// resolveStreamUsage 上游未返回用量时按本地估算补齐
+func (c *OpenAIClient) resolveStreamUsage(usage *Usage, prompt, completion string) *Usage {
+	if usage != nil {
+		return usage
+	}
+	return EstimateChatUsage([]ChatMessage{{Role: "user", Content: prompt}}, completion)
+}
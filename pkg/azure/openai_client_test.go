@@ -0,0 +1,114 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newStreamServer(t *testing.T, withUsage bool, rejectStreamOptions bool) (*httptest.Server, *[]ChatRequest) {
+	var requests []ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		requests = append(requests, req)
+
+		if rejectStreamOptions && req.StreamOptions != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":{"message":"Unrecognized request argument supplied: stream_options"}}`)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{"你好", "，", "世界"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", chunk)
+		}
+		if withUsage && req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+			fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":42,\"completion_tokens\":5,\"total_tokens\":47}}\n\n")
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	return server, &requests
+}
+
+func TestTranslateStream_MeasuredUsage(t *testing.T) {
+	server, requests := newStreamServer(t, true, false)
+	defer server.Close()
+
+	client := NewOpenAIClient(server.URL, "key", "gpt-4", "2024-10-21")
+
+	var text strings.Builder
+	usage, err := client.TranslateStream(context.Background(), "Hello, world", "en", "zh-CN", func(chunk string) error {
+		text.WriteString(chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("TranslateStream failed: %v", err)
+	}
+
+	if text.String() != "你好，世界" {
+		t.Errorf("Unexpected translation: %q", text.String())
+	}
+	if usage == nil || usage.Estimated {
+		t.Fatalf("Expected measured usage, got %+v", usage)
+	}
+	if usage.PromptTokens != 42 || usage.CompletionTokens != 5 {
+		t.Errorf("Unexpected usage: %+v", usage)
+	}
+	if (*requests)[0].StreamOptions == nil || !(*requests)[0].StreamOptions.IncludeUsage {
+		t.Error("Expected stream_options.include_usage to be requested")
+	}
+}
+
+func TestTranslateStream_EstimatedUsageFallback(t *testing.T) {
+	server, requests := newStreamServer(t, false, true)
+	defer server.Close()
+
+	client := NewOpenAIClient(server.URL, "key", "gpt-4", "2024-02-15-preview")
+
+	for i := 0; i < 2; i++ {
+		usage, err := client.TranslateStream(context.Background(), "Hello, world", "en", "zh-CN", func(string) error { return nil })
+		if err != nil {
+			t.Fatalf("TranslateStream failed: %v", err)
+		}
+		if usage == nil || !usage.Estimated {
+			t.Fatalf("Expected estimated usage, got %+v", usage)
+		}
+		if usage.CompletionTokens != EstimateTokens("你好，世界") {
+			t.Errorf("Expected completion estimate from streamed text, got %d", usage.CompletionTokens)
+		}
+	}
+
+	// The first call is rejected and retried; the second call skips stream_options entirely
+	if len(*requests) != 3 {
+		t.Fatalf("Expected 3 upstream requests, got %d", len(*requests))
+	}
+	if (*requests)[2].StreamOptions != nil {
+		t.Error("Expected stream_options to be omitted after the API rejected it")
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected int
+	}{
+		{"", 0},
+		{"hello", 1},
+		{"hi there", 2},
+		{"internationalization", 4},
+		{"你好世界", 4},
+		{"Hello, 世界!", 5},
+	}
+	for _, tc := range cases {
+		if got := EstimateTokens(tc.text); got != tc.expected {
+			t.Errorf("EstimateTokens(%q) = %d, expected %d", tc.text, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package azure
+
+import (
+	"unicode"
+)
+
+// 聊天格式的固定开销：每条消息的角色和分隔符，以及回复的起始标记
+const (
+	tokensPerMessage = 3
+	tokensPerReply   = 3
+)
+
+// EstimateTokens 在没有上游用量时粗略估算文本的 token 数
+// 近似 cl100k 分词行为：中日韩字符约一字一个 token，常见拉丁文单词一个 token、长词每 6 个字符多一个，标点单独计数
+func EstimateTokens(text string) int {
+	tokens := 0
+	wordLen := 0
+
+	flushWord := func() {
+		if wordLen > 0 {
+			tokens += 1 + (wordLen-1)/6
+			wordLen = 0
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord()
+			tokens++
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			wordLen++
+		case unicode.IsSpace(r):
+			flushWord()
+		default:
+			flushWord()
+			tokens++
+		}
+	}
+	flushWord()
+
+	return tokens
+}
+
+// EstimateChatUsage 估算一次聊天请求的用量，结果标记为估算值
+func EstimateChatUsage(messages []ChatMessage, completion string) *Usage {
+	promptTokens := tokensPerReply
+	for _, msg := range messages {
+		promptTokens += tokensPerMessage + EstimateTokens(msg.Role) + EstimateTokens(msg.Content)
+	}
+	completionTokens := EstimateTokens(completion)
+
+	return &Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+		Estimated:        true,
+	}
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}